@@ -1,7 +1,7 @@
 # 1. Third-party library imports
 import google.generativeai as query_agent
 import logging
-from typing import List, Dict
+from typing import List, Dict, Iterator
 import time
 
 # 2. Local application imports
@@ -102,9 +102,9 @@ def prepare_context(retrieved_chunks: List[Dict], max_length: int = MAX_CONTEXT_
     return "\n\n".join(context_parts)
 
 
-def generate_response_optimized(query: str, retrieved_chunks: List[Dict]) -> str:
+def build_response_prompt(query: str, retrieved_chunks: List[Dict]) -> str:
     """
-    Optimized single-call response generation with language detection and translation.
+    Builds the answer prompt shared by generate_response_optimized and generate_response_stream.
     """
     # Detect query language once
     query_language = detect_language(query)
@@ -132,6 +132,14 @@ def generate_response_optimized(query: str, retrieved_chunks: List[Dict]) -> str
     5.  **Direct Answer**: Provide only the final, well-structured answer. Do not include any of these instructions or conversational filler.
     6.  Keep your response concise, informative, and directly relevant to the user's query.
     """
+    return prompt
+
+
+def generate_response_optimized(query: str, retrieved_chunks: List[Dict]) -> str:
+    """
+    Optimized single-call response generation with language detection and translation.
+    """
+    prompt = build_response_prompt(query, retrieved_chunks)
     try:
         response = prompt_gemini(prompt)
         return response if response else "Sorry, I can't help you with that now. There are problems with the LLM service."
@@ -180,6 +188,28 @@ def generate_response(query: str, retrieved_chunks: List[Dict]) -> str:
         return generate_response_simple_fallback(query, retrieved_chunks)
 
 
+def generate_response_stream(query: str, retrieved_chunks: List[Dict]) -> Iterator[str]:
+    """
+    Streams the answer of generate_response piece by piece as Gemini produces it.
+    Once a piece was sent the answer cannot be retried, so a later failure ends it early.
+    """
+    prompt = build_response_prompt(query, retrieved_chunks)
+    sent = False
+    try:
+        for chunk in gemini_model.generate_content(prompt, stream=True):
+            if chunk.text:
+                sent = True
+                yield chunk.text
+    except Exception as e:
+        logger.error(f"Error in generate_response_stream: {e}")
+        if not sent:
+            yield "Sorry, something went wrong while processing your request."
+        return
+
+    if not sent:
+        yield "Sorry, I can't help you with that now. There are problems with the LLM service."
+
+
 def generate_summary(query: str) -> str:
     """
     Generates a summary for the given query using the Gemini model.
@@ -98,7 +98,7 @@ def text_rag_retrieve_from_postgres(
         device_id (int, optional): If provided, filters results to this device ID.
 
     Returns:
-        list: List of matching chunks with similarity score (and device_id if used).
+        list: List of matching chunks with their id and similarity score (and device_id if used).
     """
     query_embedding = get_query_embedding(query)
     retrieved_chunks = []
@@ -111,6 +111,7 @@ def text_rag_retrieve_from_postgres(
         if device_id is not None:
             sql_query = """
             SELECT DISTINCT ON (TRIM(LOWER(pc.context)))
+                pc.id,
                 pc.context,
                 1 - (pc.embedding <=> %s::vector) AS similarity,
                 p.device_id
@@ -135,6 +136,7 @@ def text_rag_retrieve_from_postgres(
         else:
             sql_query = """
             SELECT DISTINCT ON (TRIM(LOWER(pc.context)))
+                pc.id,
                 pc.context, 
                 1 - (pc.embedding <=> %s::vector) AS similarity
             FROM 
@@ -149,13 +151,14 @@ def text_rag_retrieve_from_postgres(
 
         for row in cur.fetchall():
             if device_id is not None:
-                context, similarity, dev_id = row
+                chunk_id, context, similarity, dev_id = row
             else:
-                context, similarity = row
+                chunk_id, context, similarity = row
                 dev_id = None
 
             if similarity >= similarity_threshold:
                 chunk = {
+                    "id": chunk_id,
                     "context": context,
                     "similarity": similarity
                 }
@@ -370,6 +373,14 @@ def retrieve_text_with_rephrasings(
     return ranked_chunks[:top_k]
 
 
+# Ids of the retrieved chunks, for the gateway to cite the pages they come from
+def chunk_ids(chunks: list) -> list:
+    """
+    Returns the ids of the chunks in their ranked order, skipping chunks without one.
+    """
+    return [chunk["id"] for chunk in chunks if chunk.get("id") is not None]
+
+
 # RAG retrieval for images with list of query rephrasings
 def retrieve_images_with_rephrasings(
     query_list: list,
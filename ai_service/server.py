@@ -79,7 +79,8 @@ class RagServiceServicer(server_pb2_grpc.RagServiceServicer):
         images_ids = rag_utils.retrieve_images_with_rephrasings(query_list, db_config=config.db_connection_params, top_k=10, min_threshold=0.5, step=0.05, start_threshold=1)
         return server_pb2.RagResponse(
             response=response_text,
-            images_ids=images_ids
+            images_ids=images_ids,
+            chunk_ids=rag_utils.chunk_ids(retrieved_chunks)
         )
 
 
@@ -100,50 +101,69 @@ class RagServiceWithDeviceIDServicer(server_pb2_grpc.RagServiceWithDeviceIDServi
         images_ids = rag_utils.retrieve_images_with_rephrasings(query_list, device_id=device_id, db_config=config.db_connection_params, top_k=10, min_threshold=0.5, step=0.05, start_threshold=1)
         return server_pb2.RagResponse(
             response=response_text,
-            images_ids=images_ids
+            images_ids=images_ids,
+            chunk_ids=rag_utils.chunk_ids(retrieved_chunks)
         )
     
 
-class RagServiceWithConversationHistoryServicer(server_pb2_grpc.RagServiceWithConversationHistoryServicer):
-    def Query(self, request, _):
-        query = request.query
-        conversation_id = request.conversation_id
-        device_id = request.device_id
+def retrieve_with_conversation_history(request):
+    """Expands the query with the conversation history and retrieves its chunks and images."""
+    # Retrieve conversation history
+    expanded_query = conversation_history.expand_query_with_history(
+        original_query=request.query,
+        conversation_id=request.conversation_id,
+        db_config=config.db_connection_params,
+    )
+
+    # Retrieve relevant text chunks from DB using Device ID logic
+    retrieved_chunks = rag_utils.retrieve_text_with_fallback_threshold(
+        expanded_query, 
+        device_id=request.device_id, 
+        db_config=config.db_connection_params, 
+        top_k=10, min_threshold=0.3, 
+        step=0.1, 
+        start_threshold=0.7
+    )
+
+    # Retrieve relevant image IDs from DB using conversation history
+    images_ids = rag_utils.retrieve_images_with_fallback_threshold(
+        query=expanded_query,
+        db_config=config.db_connection_params,
+        top_k=10,
+        min_threshold=0.3,
+        step=0.05,
+        start_threshold=0.7,
+        device_id=request.device_id
+    )
+    return expanded_query, retrieved_chunks, images_ids
 
-        # Retrieve conversation history
-        expanded_query = conversation_history.expand_query_with_history(
-            original_query=query,
-            conversation_id=conversation_id,
-            db_config=config.db_connection_params,
-        )
 
-        # Retrieve relevant text chunks from DB using Device ID logic
-        retrieved_chunks = rag_utils.retrieve_text_with_fallback_threshold(
-            expanded_query, 
-            device_id=device_id, 
-            db_config=config.db_connection_params, 
-            top_k=10, min_threshold=0.3, 
-            step=0.1, 
-            start_threshold=0.7
-        )
+class RagServiceWithConversationHistoryServicer(server_pb2_grpc.RagServiceWithConversationHistoryServicer):
+    def Query(self, request, _):
+        expanded_query, retrieved_chunks, images_ids = retrieve_with_conversation_history(request)
 
         # Generate response with LLM using conversation history
         response_text = rag_generator.generate_response(expanded_query, retrieved_chunks)
 
-        # Retrieve relevant image IDs from DB using conversation history
-        images_ids = rag_utils.retrieve_images_with_fallback_threshold(
-            query=expanded_query,
-            db_config=config.db_connection_params,
-            top_k=10,
-            min_threshold=0.3,
-            step=0.05,
-            start_threshold=0.7,
-            device_id=device_id
-        )
-
         return server_pb2.RagResponse(
             response=response_text,
-            images_ids=images_ids
+            images_ids=images_ids,
+            chunk_ids=rag_utils.chunk_ids(retrieved_chunks)
+        )
+
+
+class RagStreamServiceWithConversationHistoryServicer(server_pb2_grpc.RagStreamServiceWithConversationHistoryServicer):
+    def QueryStream(self, request, _):
+        expanded_query, retrieved_chunks, images_ids = retrieve_with_conversation_history(request)
+
+        # Relay the answer as the LLM writes it, the images and citations come last
+        for token in rag_generator.generate_response_stream(expanded_query, retrieved_chunks):
+            yield server_pb2.RagStreamResponse(token=token)
+
+        yield server_pb2.RagStreamResponse(
+            images_ids=images_ids,
+            chunk_ids=rag_utils.chunk_ids(retrieved_chunks),
+            done=True
         )
 
 
@@ -165,6 +185,7 @@ def serve():
     server_pb2_grpc.add_RagServiceWithDeviceIDServicer_to_server(RagServiceWithDeviceIDServicer(), server)
     server_pb2_grpc.add_SummarizeQueryServiceServicer_to_server(SummarizeQueryServicer(), server)
     server_pb2_grpc.add_RagServiceWithConversationHistoryServicer_to_server(RagServiceWithConversationHistoryServicer(), server)
+    server_pb2_grpc.add_RagStreamServiceWithConversationHistoryServicer_to_server(RagStreamServiceWithConversationHistoryServicer(), server)
     server.add_insecure_port(f'[::]:{config.PORT}')  # Use PORT from environment variable
     print(f"gRPC server running on port {config.PORT}...")
     server.start()
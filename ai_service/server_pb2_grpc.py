@@ -385,6 +385,78 @@ class RagServiceWithConversationHistory(object):
             _registered_method=True)
 
 
+class RagStreamServiceWithConversationHistoryStub(object):
+    """Missing associated documentation comment in .proto file."""
+
+    def __init__(self, channel):
+        """Constructor.
+
+        Args:
+            channel: A grpc.Channel.
+        """
+        self.QueryStream = channel.unary_stream(
+                '/RagStreamServiceWithConversationHistory/QueryStream',
+                request_serializer=server__pb2.RagWithConversationHistoryRequest.SerializeToString,
+                response_deserializer=server__pb2.RagStreamResponse.FromString,
+                _registered_method=True)
+
+
+class RagStreamServiceWithConversationHistoryServicer(object):
+    """Missing associated documentation comment in .proto file."""
+
+    def QueryStream(self, request, context):
+        """Missing associated documentation comment in .proto file."""
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
+
+def add_RagStreamServiceWithConversationHistoryServicer_to_server(servicer, server):
+    rpc_method_handlers = {
+            'QueryStream': grpc.unary_stream_rpc_method_handler(
+                    servicer.QueryStream,
+                    request_deserializer=server__pb2.RagWithConversationHistoryRequest.FromString,
+                    response_serializer=server__pb2.RagStreamResponse.SerializeToString,
+            ),
+    }
+    generic_handler = grpc.method_handlers_generic_handler(
+            'RagStreamServiceWithConversationHistory', rpc_method_handlers)
+    server.add_generic_rpc_handlers((generic_handler,))
+    server.add_registered_method_handlers('RagStreamServiceWithConversationHistory', rpc_method_handlers)
+
+
+ # This class is part of an EXPERIMENTAL API.
+class RagStreamServiceWithConversationHistory(object):
+    """Missing associated documentation comment in .proto file."""
+
+    @staticmethod
+    def QueryStream(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_stream(
+            request,
+            target,
+            '/RagStreamServiceWithConversationHistory/QueryStream',
+            server__pb2.RagWithConversationHistoryRequest.SerializeToString,
+            server__pb2.RagStreamResponse.FromString,
+            options,
+            channel_credentials,
+            insecure,
+            call_credentials,
+            compression,
+            wait_for_ready,
+            timeout,
+            metadata,
+            _registered_method=True)
+
+
 class SummarizeQueryServiceStub(object):
     """Missing associated documentation comment in .proto file."""
 
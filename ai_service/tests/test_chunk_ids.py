@@ -0,0 +1,140 @@
+"""
+Checks that the RAG services return the ids of the chunks they retrieved,
+which the gateway stores as the page citations of the answer.
+
+Run from ai_service/ with: python -m unittest discover -s tests -t .
+"""
+import sys
+import types
+import unittest
+from unittest import mock
+
+
+def _stub(name, **attrs):
+    """Registers a stand-in module so the tests run without the heavy dependencies."""
+    module = types.ModuleType(name)
+    module.__dict__.update(attrs)
+    module.__getattr__ = lambda attr: mock.MagicMock(name=f"{name}.{attr}")
+    sys.modules[name] = module
+    return module
+
+
+def _servicers(name):
+    """server_pb2_grpc stand-in: the servicer base classes must be real classes."""
+    module = types.ModuleType(name)
+    module.__getattr__ = lambda attr: type(attr, (), {})
+    sys.modules[name] = module
+    return module
+
+
+for _name in (
+    "torch", "torch.nn", "torch.nn.functional", "transformers", "grpc",
+    "google", "google.cloud", "google.cloud.storage", "google.generativeai",
+    "app.embedding.embedding_utils", "app.gcs.gcs_utils",
+    "app.pdf_process.pdf_extractor", "app.rag.rag_generator",
+    "app.rag.conversation_history",
+):
+    _stub(_name)
+_stub("psycopg2", Error=Exception)
+_stub(
+    "server_pb2",
+    RagResponse=lambda **fields: types.SimpleNamespace(**fields),
+    RagStreamResponse=lambda **fields: types.SimpleNamespace(**fields),
+)
+_servicers("server_pb2_grpc")
+
+import server  # noqa: E402
+from app.rag import rag_utils  # noqa: E402
+
+
+class TextRetrievalTest(unittest.TestCase):
+    def setUp(self):
+        self.cursor = mock.MagicMock()
+        connection = mock.MagicMock()
+        connection.cursor.return_value = self.cursor
+        patches = [
+            mock.patch.object(rag_utils, "get_query_embedding", return_value=[0.1]),
+            mock.patch.object(rag_utils.psycopg2, "connect", return_value=connection, create=True),
+        ]
+        for patch in patches:
+            patch.start()
+            self.addCleanup(patch.stop)
+
+    def test_chunks_carry_their_id(self):
+        self.cursor.fetchall.return_value = [(7, "Hold the power button.", 0.9, 2), (8, "Unrelated.", 0.2, 2)]
+
+        chunks = rag_utils.text_rag_retrieve_from_postgres("reset", {}, similarity_threshold=0.5, device_id=2)
+
+        self.assertIn("pc.id", self.cursor.execute.call_args[0][0])
+        self.assertEqual(chunks, [{"id": 7, "context": "Hold the power button.", "similarity": 0.9, "device_id": 2}])
+
+    def test_chunks_without_device_carry_their_id(self):
+        self.cursor.fetchall.return_value = [(3, "Descale monthly.", 0.8)]
+
+        chunks = rag_utils.text_rag_retrieve_from_postgres("descale", {}, similarity_threshold=0.5)
+
+        self.assertEqual(chunks, [{"id": 3, "context": "Descale monthly.", "similarity": 0.8}])
+
+    def test_chunk_ids_keep_the_ranking(self):
+        chunks = [{"id": 4, "context": "a"}, {"context": "no id"}, {"id": 1, "context": "b"}]
+
+        self.assertEqual(rag_utils.chunk_ids(chunks), [4, 1])
+
+
+class RagServicesTest(unittest.TestCase):
+    chunks = [
+        {"id": 12, "context": "Hold the power button.", "similarity": 0.9},
+        {"id": 5, "context": "Unplug the machine.", "similarity": 0.7},
+    ]
+
+    def setUp(self):
+        patches = [
+            mock.patch.object(server.rag_generator, "generate_query_rephrasings", return_value=["reset"], create=True),
+            mock.patch.object(server.rag_generator, "generate_response", return_value="Hold the power button.", create=True),
+            mock.patch.object(
+                server.rag_generator, "generate_response_stream",
+                return_value=iter(["Hold the ", "power button."]), create=True,
+            ),
+            mock.patch.object(server.conversation_history, "expand_query_with_history", return_value="reset", create=True),
+            mock.patch.object(rag_utils, "get_device_info_from_device_id", return_value="WM-7200"),
+            mock.patch.object(rag_utils, "retrieve_text_with_rephrasings", return_value=self.chunks),
+            mock.patch.object(rag_utils, "retrieve_text_with_fallback_threshold", return_value=self.chunks),
+            mock.patch.object(rag_utils, "retrieve_images_with_rephrasings", return_value=[3]),
+            mock.patch.object(rag_utils, "retrieve_images_with_fallback_threshold", return_value=[3]),
+        ]
+        for patch in patches:
+            patch.start()
+            self.addCleanup(patch.stop)
+
+    def test_responses_return_the_retrieved_chunk_ids(self):
+        servicers = {
+            "RagService": (server.RagServiceServicer(), types.SimpleNamespace(query="reset")),
+            "RagServiceWithDeviceID": (
+                server.RagServiceWithDeviceIDServicer(),
+                types.SimpleNamespace(query="reset", device_id=2),
+            ),
+            "RagServiceWithConversationHistory": (
+                server.RagServiceWithConversationHistoryServicer(),
+                types.SimpleNamespace(query="reset", conversation_id="c1", device_id=2),
+            ),
+        }
+        for name, (servicer, request) in servicers.items():
+            with self.subTest(name):
+                response = servicer.Query(request, None)
+
+                self.assertEqual(response.chunk_ids, [12, 5])
+                self.assertEqual(response.images_ids, [3])
+
+    def test_stream_ends_with_the_retrieved_chunk_ids(self):
+        request = types.SimpleNamespace(query="reset", conversation_id="c1", device_id=2)
+
+        responses = list(server.RagStreamServiceWithConversationHistoryServicer().QueryStream(request, None))
+
+        self.assertEqual([r.token for r in responses[:-1]], ["Hold the ", "power button."])
+        self.assertTrue(responses[-1].done)
+        self.assertEqual(responses[-1].chunk_ids, [12, 5])
+        self.assertEqual(responses[-1].images_ids, [3])
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,156 @@
+// Command rageval is the regression check of the RAG answers: it asks the AI agent the questions
+// of an evaluation set and scores the answers (see package rageval).
+//
+//	go run ./cmd/rageval -dataset eval.yaml -out report.json -markdown report.md -baseline baseline.json
+//
+// The questions go to RagServiceWithDeviceID of the agent, and the cited chunks are resolved to
+// their pages in POSTGRES_DSN. With -replay, the answers of an earlier -record run are scored
+// instead, without the agent or the database. It exits with 1 when the scores regressed from
+// the baseline, a report of an earlier run, and with 2 when it couldn't run.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/config"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/models"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/pb"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/rageval"
+)
+
+func main() {
+	dataset := flag.String("dataset", "", "evaluation set, .yaml/.yml or .jsonl (required)")
+	k := flag.Int("k", 5, "cited pages counted by recall@k")
+	replayPath := flag.String("replay", "", "score the answers of this recording instead of asking the agent")
+	recordPath := flag.String("record", "", "record the answers of the agent to this file, for -replay")
+	out := flag.String("out", "-", "JSON report file, - for stdout")
+	markdown := flag.String("markdown", "", "markdown report file")
+	baselinePath := flag.String("baseline", "", "JSON report of an earlier run to compare the scores with")
+	scoreTolerance := flag.Float64("tolerance", 0.02, "drop of recall@k or keyword hit rate tolerated from the baseline")
+	latencyTolerance := flag.Float64("latency-tolerance", 0.25, "relative rise of the p95 latency tolerated from the baseline")
+	flag.Parse()
+
+	if *dataset == "" || *k < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *replayPath != "" && *recordPath != "" {
+		fatalf("-replay and -record can't be combined")
+	}
+
+	cases, err := rageval.LoadDataset(*dataset)
+	if err != nil {
+		fatalf("Could not load the dataset: %v", err)
+	}
+	var baseline *rageval.Report
+	if *baselinePath != "" {
+		b, err := rageval.LoadBaseline(*baselinePath)
+		if err != nil {
+			fatalf("Could not load the baseline: %v", err)
+		}
+		if b.K != *k {
+			fatalf("The baseline scored recall@%d, not recall@%d", b.K, *k)
+		}
+		baseline = &b
+	}
+
+	var client rageval.Client
+	if *replayPath != "" {
+		if client, err = rageval.LoadReplay(*replayPath); err != nil {
+			fatalf("Could not load the recording: %v", err)
+		}
+	} else {
+		config.LoadEnv()
+		dsn := config.GetEnv("POSTGRES_DSN", "")
+		if dsn == "" {
+			fatalf("POSTGRES_DSN is needed to resolve the cited chunks to their pages")
+		}
+		db, err := models.InitDB(dsn)
+		if err != nil {
+			fatalf("Could not initialize database connection: %v", err)
+		}
+		defer db.Close()
+		pb.Init()
+		defer pb.Close()
+		client = rageval.AgentClient{DB: db}
+	}
+	if *recordPath != "" {
+		f, err := os.Create(*recordPath)
+		if err != nil {
+			fatalf("Could not create the recording: %v", err)
+		}
+		defer f.Close()
+		client = rageval.Recorder{Client: client, W: f}
+	}
+
+	results, scores := rageval.Run(cases, client, *k)
+	report := rageval.Report{
+		Dataset:     *dataset,
+		K:           *k,
+		GeneratedAt: time.Now().UTC(),
+		Scores:      scores,
+		Regressions: []string{},
+		Cases:       results,
+	}
+	if baseline != nil {
+		report.Regressions = rageval.Compare(baseline.Scores, scores, rageval.Tolerance{
+			Score:   *scoreTolerance,
+			Latency: *latencyTolerance,
+		})
+	}
+
+	if err := writeJSON(*out, report); err != nil {
+		fatalf("Could not write the report: %v", err)
+	}
+	if *markdown != "" {
+		f, err := os.Create(*markdown)
+		if err == nil {
+			err = rageval.WriteMarkdown(f, report)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		if err != nil {
+			fatalf("Could not write the markdown report: %v", err)
+		}
+	}
+
+	log.Printf("%d cases, %d errors, recall@%d %s, keyword hit rate %s, p95 latency %.0f ms",
+		scores.Cases, scores.Errors, *k, format(scores.RecallAtK), format(scores.KeywordHitRate), scores.LatencyP95Ms)
+	if len(report.Regressions) > 0 {
+		for _, regression := range report.Regressions {
+			log.Printf("Regression: %s", regression)
+		}
+		os.Exit(1)
+	}
+}
+
+func writeJSON(path string, report rageval.Report) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if path == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+func format(score *float64) string {
+	if score == nil {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.3f", *score)
+}
+
+func fatalf(format string, args ...any) {
+	log.Printf(format, args...)
+	os.Exit(2)
+}
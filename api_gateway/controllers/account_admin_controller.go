@@ -0,0 +1,238 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/middlewares"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/models"
+	"github.com/gin-gonic/gin"
+)
+
+// accountParam reads the :id of an account route. On failure it responds and returns false.
+func accountParam(c *gin.Context) (int, bool) {
+	accountID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Invalid account id"})
+		return 0, false
+	}
+	return accountID, true
+}
+
+// notOwnAccount refuses with 400 an action of an admin on its own account, and returns false then.
+func notOwnAccount(c *gin.Context, accountID int) bool {
+	if accountID == c.GetInt("account_id") {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Admins can't do this on their own account"})
+		return false
+	}
+	return true
+}
+
+// accountError answers the errors shared by the account management handlers.
+func accountError(c *gin.Context, err error, message string) {
+	switch {
+	case err == sql.ErrNoRows:
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "Account not found"})
+	case errors.Is(err, models.ErrNoPassword):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Account signs in with Google or SSO and has no password"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": message, "error": err.Error()})
+	}
+}
+
+// ListAccountsHandler lists the accounts, newest first, a page at a time. They can be searched by
+// email or display name (q) and filtered by role_id and status (active, disabled or deleted).
+func ListAccountsHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter := models.AccountFilter{
+			Query:    c.Query("q"),
+			Status:   c.Query("status"),
+		}
+		switch filter.Status {
+		case "", "active", "disabled", "deleted":
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "status must be active, disabled or deleted"})
+			return
+		}
+		var err error
+		if s := c.Query("role_id"); s != "" {
+			if filter.RoleID, err = strconv.Atoi(s); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Invalid role_id"})
+				return
+			}
+		}
+		var ok bool
+		if filter.Page, filter.PageSize, ok = pageQuery(c); !ok {
+			return
+		}
+
+		accounts, total, err := models.SelectAdminAccounts(db, filter)
+		if err != nil {
+			accountError(c, err, "Failed to fetch accounts")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "Fetched accounts successfully",
+			"data": gin.H{
+				"accounts":  accounts,
+				"total":     total,
+				"page":      filter.Page,
+				"page_size": filter.PageSize,
+			},
+		})
+	}
+}
+
+// GetAccountHandler returns an account with its conversation counts, sessions and last activity.
+func GetAccountHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		accountID, ok := accountParam(c)
+		if !ok {
+			return
+		}
+
+		account, err := models.SelectAdminAccount(db, accountID)
+		if err != nil {
+			accountError(c, err, "Failed to fetch account")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "Fetched account successfully",
+			"data": gin.H{
+				"account": account,
+			},
+		})
+	}
+}
+
+// UpdateAccountRoleHandler gives an account another role. The admin role is tied to the admin
+// credentials, so it can't be given or taken away here.
+func UpdateAccountRoleHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		accountID, ok := accountParam(c)
+		if !ok || !notOwnAccount(c, accountID) {
+			return
+		}
+		var req struct {
+			RoleID int `json:"role_id" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "role_id is required", "error": err.Error()})
+			return
+		}
+
+		role, err := models.SelectRoleByID(db, req.RoleID)
+		if err != nil {
+			roleError(c, err, "Failed to fetch role")
+			return
+		}
+		account, err := models.SelectAdminAccount(db, accountID)
+		if err != nil {
+			accountError(c, err, "Failed to fetch account")
+			return
+		}
+		if role.Label == models.AdminPermission || account.Role == models.AdminPermission {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "The admin role can't be given or taken away here"})
+			return
+		}
+
+		if err := models.UpdateAccountRole(db, middlewares.AuditActor(c), accountID, role.ID); err != nil {
+			accountError(c, err, "Failed to update account role")
+			return
+		}
+		middlewares.InvalidateAccountRole(accountID)
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "Account role updated successfully",
+			"data": gin.H{
+				"account_id": accountID,
+				"role_id":    role.ID,
+				"role":       role.Label,
+			},
+		})
+	}
+}
+
+// DisableAccountHandler disables an account: its sessions end, and it can't log in until enabled again.
+func DisableAccountHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		accountID, ok := accountParam(c)
+		if !ok || !notOwnAccount(c, accountID) {
+			return
+		}
+		var req struct {
+			Reason string `json:"reason"`
+		}
+		// The body is optional
+		_ = c.ShouldBindJSON(&req)
+
+		if err := models.SetAccountDisabled(db, middlewares.AuditActor(c), accountID, true, req.Reason); err != nil {
+			accountError(c, err, "Failed to disable account")
+			return
+		}
+		if err := models.RevokeAllSessions(db, accountID); err != nil {
+			accountError(c, err, "Account disabled but sessions could not be revoked")
+			return
+		}
+		middlewares.InvalidateAccountRole(accountID)
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "Account disabled successfully",
+		})
+	}
+}
+
+// EnableAccountHandler enables a disabled account again. It has to log in again.
+func EnableAccountHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		accountID, ok := accountParam(c)
+		if !ok {
+			return
+		}
+
+		if err := models.SetAccountDisabled(db, middlewares.AuditActor(c), accountID, false, ""); err != nil {
+			accountError(c, err, "Failed to enable account")
+			return
+		}
+		middlewares.InvalidateAccountRole(accountID)
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "Account enabled successfully",
+		})
+	}
+}
+
+// ForcePasswordResetHandler ends the sessions of an account and makes it reset its password
+// (can_reset_password then reset_password) before it can log in again.
+func ForcePasswordResetHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		accountID, ok := accountParam(c)
+		if !ok || !notOwnAccount(c, accountID) {
+			return
+		}
+
+		if err := models.RequirePasswordReset(db, middlewares.AuditActor(c), accountID); err != nil {
+			accountError(c, err, "Failed to require password reset")
+			return
+		}
+		if err := models.RevokeAllSessions(db, accountID); err != nil {
+			accountError(c, err, "Password reset required but sessions could not be revoked")
+			return
+		}
+		middlewares.InvalidateAccountRole(accountID)
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "Password reset required successfully",
+		})
+	}
+}
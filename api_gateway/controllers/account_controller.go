@@ -0,0 +1,430 @@
+package controllers
+
+import (
+	"archive/zip"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/internal"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/middlewares"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/models"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/workers"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// GetAccountProfileHandler returns the profile of the authenticated account.
+func GetAccountProfileHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		profile, err := models.SelectAccountProfile(db, c.GetInt("account_id"))
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "Account not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "Failed to fetch account",
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "Fetched account successfully",
+			"data":    profile,
+		})
+	}
+}
+
+// UpdateDisplayNameHandler sets the display name of the authenticated account.
+func UpdateDisplayNameHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			DisplayName string `json:"display_name" binding:"required,max=200"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.DisplayName) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "display_name is required (at most 200 characters)"})
+			return
+		}
+
+		displayName := strings.TrimSpace(req.DisplayName)
+		if err := models.UpdateDisplayName(db, c.GetInt("account_id"), displayName); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "Failed to update display name",
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "Display name updated successfully",
+			"data":    gin.H{"display_name": displayName},
+		})
+	}
+}
+
+// checkAccountPassword verifies the password of the account, with the lockout of password:<id>.
+// On failure it responds and returns false.
+func checkAccountPassword(c *gin.Context, db *sql.DB, accountID int, password string) bool {
+	key := fmt.Sprintf("password:%d", accountID)
+	if !middlewares.CheckLockout(c, db, key) {
+		return false
+	}
+	hash, err := models.SelectUserPassword(db, accountID)
+	if errors.Is(err, models.ErrNoPassword) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "This account signs in with Google or SSO and has no password",
+		})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to check password",
+			"error":   err.Error(),
+		})
+		return false
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		middlewares.RecordAuthFailure(db, key)
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "message": "Wrong password"})
+		return false
+	}
+	middlewares.ClearAuthFailures(db, key)
+	return true
+}
+
+// ChangePasswordHandler changes the password of the authenticated account. The current password
+// is required. All the sessions of the account end, and a new one is returned.
+func ChangePasswordHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			CurrentPassword string `json:"current_password" binding:"required"`
+			NewPassword     string `json:"new_password" binding:"required,min=8"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "current_password and new_password (at least 8 characters) are required",
+			})
+			return
+		}
+
+		accountID := c.GetInt("account_id")
+		if !checkAccountPassword(c, db, accountID, req.CurrentPassword) {
+			return
+		}
+
+		if err := models.UpdateUserPassword(db, accountID, internal.BcryptHashing(req.NewPassword)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "Failed to change password",
+				"error":   err.Error(),
+			})
+			return
+		}
+		// Other devices must log in again with the new password
+		if err := models.RevokeAllSessions(db, accountID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "Password changed but sessions could not be revoked",
+				"error":   err.Error(),
+			})
+			return
+		}
+		middlewares.InvalidateAccountRole(accountID)
+
+		// An admin changing their password stays logged in as an admin
+		session, err := issueSession(db, accountID, c.GetString("audience"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "Password changed but could not generate token",
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "Password changed successfully",
+			"data":    session,
+		})
+	}
+}
+
+// RequestEmailChangeHandler starts changing the email of the authenticated account: an OTP is
+// sent to the new address, and the change is applied by ConfirmEmailChangeHandler.
+func RequestEmailChangeHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			NewEmail string `json:"new_email" binding:"required,email,max=200"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "A valid new_email is required"})
+			return
+		}
+
+		accountID := c.GetInt("account_id")
+		newEmail := strings.TrimSpace(req.NewEmail)
+		otp := internal.Digit6Random()
+		err := models.InsertEmailChange(db, accountID, newEmail, internal.BcryptHashing(otp))
+		if errors.Is(err, models.ErrEmailTaken) {
+			c.JSON(http.StatusConflict, gin.H{"success": false, "message": "Email is already used by another account"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "Failed to start email change",
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		lang := internal.EmailLanguage(c.GetHeader("Accept-Language"))
+		data := internal.OTPEmailData{Code: otp, ValidHours: int(middlewares.OTPValidity.Hours())}
+		if err := workers.QueueEmail(db, internal.EmailKindEmailChange, lang, newEmail, data); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "Failed to send OTP",
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "OTP sent to the new email",
+		})
+	}
+}
+
+// ConfirmEmailChangeHandler applies the pending email change of the authenticated account with
+// the OTP sent to the new address, and notifies the old address.
+func ConfirmEmailChangeHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			OTPCode string `json:"otp_code" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "otp_code is required"})
+			return
+		}
+
+		accountID := c.GetInt("account_id")
+		change, err := models.SelectEmailChange(db, accountID)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "No pending email change"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "Failed to fetch email change",
+				"error":   err.Error(),
+			})
+			return
+		}
+		if time.Now().After(change.OTP.OTPWasGeneratedAt.Add(middlewares.OTPValidity)) {
+			c.JSON(http.StatusUnauthorized, gin.H{"success": false, "message": "OTP expired"})
+			return
+		}
+		if change.OTPAttempts >= middlewares.OTPMaxAttempts() {
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"success": false,
+				"message": "Too many wrong OTPs, request a new one",
+			})
+			return
+		}
+		if bcrypt.CompareHashAndPassword([]byte(change.OTP.OTPCode), []byte(req.OTPCode)) != nil {
+			if err := models.IncrementEmailChangeAttempts(db, accountID); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"success": false,
+					"message": "Failed to check OTP",
+					"error":   err.Error(),
+				})
+				return
+			}
+			c.JSON(http.StatusUnauthorized, gin.H{"success": false, "message": "Invalid OTP"})
+			return
+		}
+
+		oldEmail, newEmail, err := models.ConfirmEmailChange(db, accountID)
+		if errors.Is(err, models.ErrEmailTaken) {
+			c.JSON(http.StatusConflict, gin.H{"success": false, "message": "Email is already used by another account"})
+			return
+		}
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "No pending email change"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "Failed to change email",
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		// The email was changed already, a failed notice doesn't fail the request
+		lang := internal.EmailLanguage(c.GetHeader("Accept-Language"))
+		notice := internal.EmailChangedData{NewEmail: newEmail}
+		if err := workers.QueueEmail(db, internal.EmailKindEmailChanged, lang, oldEmail, notice); err != nil {
+			log.Printf("Email change notice to account %d: %v", accountID, err)
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "Email changed successfully",
+			"data":    gin.H{"email": newEmail},
+		})
+	}
+}
+
+// DeleteAccountHandler erases the authenticated account and its conversations. The password is
+// required for accounts having one. Admin accounts can't delete themselves.
+func DeleteAccountHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Password string `json:"password"`
+			Confirm  bool   `json:"confirm"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil || !req.Confirm {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": `Set "confirm": true to delete the account`,
+			})
+			return
+		}
+		if middlewares.HasPermission(c, models.PermissionAdminAccess) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"message": "Admin accounts can't be deleted this way",
+			})
+			return
+		}
+
+		accountID := c.GetInt("account_id")
+		profile, err := models.SelectAccountProfile(db, accountID)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "Account not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "Failed to fetch account",
+				"error":   err.Error(),
+			})
+			return
+		}
+		if profile.HasPassword && !checkAccountPassword(c, db, accountID, req.Password) {
+			return
+		}
+
+		if err := models.DeleteAccount(db, accountID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "Failed to delete account",
+				"error":   err.Error(),
+			})
+			return
+		}
+		middlewares.InvalidateAccountRole(accountID)
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "Account deleted successfully",
+		})
+	}
+}
+
+// ExportAccountHandler returns all the data of the authenticated account as a download:
+// a JSON file (format=json, default) or a ZIP archive (format=zip).
+func ExportAccountHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		format := c.DefaultQuery("format", "json")
+		if format != "json" && format != "zip" {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "format must be json or zip"})
+			return
+		}
+
+		accountID := c.GetInt("account_id")
+		export, err := models.SelectAccountExport(db, accountID)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "Account not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "Failed to export account",
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		name := fmt.Sprintf("account-%d-%s", accountID, export.ExportedAt.Format("20060102"))
+		var body []byte
+		contentType := "application/json"
+		if format == "zip" {
+			body, err = accountExportZIP(export)
+			contentType = "application/zip"
+		} else {
+			body, err = json.MarshalIndent(export, "", "  ")
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "Failed to export account",
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, name, format))
+		c.Data(http.StatusOK, contentType, body)
+	}
+}
+
+// accountExportZIP packs an account export as account.json and conversations.json.
+func accountExportZIP(export models.AccountExport) ([]byte, error) {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	files := []struct {
+		name string
+		data any
+	}{
+		{"account.json", gin.H{"exported_at": export.ExportedAt, "account": export.Account}},
+		{"conversations.json", export.Conversations},
+	}
+	for _, file := range files {
+		w, err := archive.CreateHeader(&zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: export.ExportedAt})
+		if err != nil {
+			return nil, err
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(file.data); err != nil {
+			return nil, err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,198 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/models"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/pb"
+	"github.com/gin-gonic/gin"
+)
+
+// ListAIAgentsHandler lists the OCR agents and whether they are busy or draining.
+func ListAIAgentsHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		agents, err := models.SelectAllAIAgents(db)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "Failed to fetch OCR agents",
+				"error":   err.Error(),
+			})
+			return
+		}
+		result := make([]gin.H, 0, len(agents))
+		for _, agent := range agents {
+			lockedUntil := ""
+			if agent.OCRLockedUntil.Valid {
+				lockedUntil = agent.OCRLockedUntil.Time.Format(time.RFC3339)
+			}
+			result = append(result, gin.H{
+				"id":               agent.ID,
+				"name":             agent.Name,
+				"ip":               agent.IP,
+				"ocr_in_used":      agent.OCRInUsed,
+				"draining":         agent.Draining,
+				"ocr_locked_until": lockedUntil,
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "Fetched OCR agents successfully",
+			"data": gin.H{
+				"agents": result,
+			},
+		})
+	}
+}
+
+// AddAIAgentHandler registers a new OCR agent. It receives extractions right away.
+func AddAIAgentHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Name string `json:"name" binding:"required"`
+			IP   string `json:"ip" binding:"required"` // host:port of the agent's gRPC server
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "Invalid request",
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		agentID, err := models.InsertAIAgent(db, strings.TrimSpace(req.Name), strings.TrimSpace(req.IP))
+		if errors.Is(err, models.ErrAIAgentExists) {
+			c.JSON(http.StatusConflict, gin.H{
+				"success": false,
+				"message": "OCR agent name is already used",
+				"error":   err.Error(),
+			})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "Failed to add OCR agent",
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "OCR agent added successfully",
+			"data": gin.H{
+				"agent_id": agentID,
+			},
+		})
+	}
+}
+
+// DrainAIAgentHandler stops (or resumes, with "draining": false) sending new extractions to an agent.
+// An extraction already running on the agent is left to finish.
+func DrainAIAgentHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		agentID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "Invalid agent id",
+			})
+			return
+		}
+
+		req := struct {
+			Draining *bool `json:"draining"`
+		}{}
+		_ = c.ShouldBindJSON(&req) // Body is optional, draining defaults to true
+		draining := req.Draining == nil || *req.Draining
+
+		err = models.UpdateAIAgentDraining(db, agentID, draining)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"message": "OCR agent not found",
+			})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "Failed to update OCR agent",
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "OCR agent updated successfully",
+			"data": gin.H{
+				"agent_id": agentID,
+				"draining": draining,
+			},
+		})
+	}
+}
+
+// RemoveAIAgentHandler removes an OCR agent. Agents in the middle of an extraction can't be removed,
+// drain them first and retry once the extraction has finished.
+func RemoveAIAgentHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		agentID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "Invalid agent id",
+			})
+			return
+		}
+
+		agent, err := models.SelectAIAgentByID(db, agentID)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"message": "OCR agent not found",
+			})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "Failed to fetch OCR agent",
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		err = models.DeleteAIAgent(db, agent.ID)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusConflict, gin.H{
+				"success": false,
+				"message": "OCR agent is extracting, drain it and retry later",
+			})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "Failed to remove OCR agent",
+				"error":   err.Error(),
+			})
+			return
+		}
+		pb.CloseAgent(agent.ID)
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "OCR agent removed successfully",
+		})
+	}
+}
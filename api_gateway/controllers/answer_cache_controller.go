@@ -0,0 +1,42 @@
+package controllers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/middlewares"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/models"
+	"github.com/gin-gonic/gin"
+)
+
+// PurgeAnswerCacheHandler deletes the cached RAG answers about the device of the device_id query
+// param, or about every device without it.
+func PurgeAnswerCacheHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		deviceID := 0
+		if s := c.Query("device_id"); s != "" {
+			var err error
+			if deviceID, err = strconv.Atoi(s); err != nil || deviceID < 1 {
+				c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Invalid device_id"})
+				return
+			}
+		}
+
+		purged, err := models.PurgeCachedAnswers(db, middlewares.AuditActor(c), deviceID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "Failed to purge the answer cache",
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "Purged the answer cache successfully",
+			"data":    gin.H{"purged": purged},
+		})
+	}
+}
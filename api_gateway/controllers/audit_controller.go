@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/models"
+	"github.com/gin-gonic/gin"
+)
+
+// ListAuditEventsHandler lists the audit events, newest first, a page at a time. They can be
+// filtered by actor_id, action, target_type and target_id, and by time with from and to (RFC 3339).
+func ListAuditEventsHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter := models.AuditFilter{
+			Action:     c.Query("action"),
+			TargetType: c.Query("target_type"),
+			TargetID:   c.Query("target_id"),
+		}
+		var err error
+		if s := c.Query("actor_id"); s != "" {
+			if filter.ActorID, err = strconv.Atoi(s); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Invalid actor_id"})
+				return
+			}
+		}
+		for param, t := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+			if s := c.Query(param); s != "" {
+				if *t, err = time.Parse(time.RFC3339, s); err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": param + " must be an RFC 3339 time"})
+					return
+				}
+			}
+		}
+		var ok bool
+		if filter.Page, filter.PageSize, ok = pageQuery(c); !ok {
+			return
+		}
+
+		events, total, err := models.SelectAuditEvents(db, filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "Failed to fetch audit events",
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "Fetched audit events successfully",
+			"data": gin.H{
+				"events":    events,
+				"total":     total,
+				"page":      filter.Page,
+				"page_size": filter.PageSize,
+			},
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 
@@ -47,89 +48,87 @@ func CanGoogleRegister(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Google Registration can process"})
 }
 
-func GoogleRegistration(c *gin.Context) {
-	var req struct {
-		GoogleID    string `json:"google_id"`
-		GoogleEmail string `json:"google_email"`
+// GoogleRegistration signs up with the Google identity verified by VerifyGoogleIDToken, and logs in.
+// An account registered with the same (Google verified) email gets linked instead of duplicated,
+// and an identity already linked just logs in.
+func GoogleRegistration(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		googleSignIn(c, db, true)
 	}
+}
 
-	// Bind JSON, form, or query parameter
-	if err := c.ShouldBindBodyWithJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
-		c.Abort()
-		return
-	}
+func UserLogin(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		//Get middlewares results
+		payload, exists := c.Get("user_id")
+		if !exists {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve account existed status"})
+			return
+		}
 
-	user := models.User{
-		Email: "",
-	}
+		userID, ok := payload.(int)
+		if !ok {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return
+		}
 
-	id, err := models.InsertUser(user)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
-		c.Abort()
-		return
-	}
+		session, err := issueSession(db, userID, internal.AudienceUser)
+		if accountDisabled(c, err) {
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not generate token"})
+			return
+		}
 
-	google_user := models.GoogleUser{
-		GoogleID:    req.GoogleID,
-		GoogleEmail: req.GoogleEmail,
+		c.JSON(http.StatusOK, session)
 	}
+}
 
-	if _, err := models.InsertUserGoogleInfomation(google_user, id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
-		c.Abort()
-		return
+// GoogleLogin logs in with the Google identity verified by VerifyGoogleIDToken.
+// An account registered with the same (Google verified) email gets linked on the first Google login.
+func GoogleLogin(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		googleSignIn(c, db, false)
 	}
-
-	//succesfully process
-	c.JSON(http.StatusOK, gin.H{"message": "Google Registration process successfully"})
 }
 
-func UserLogin(c *gin.Context) {
-	//Get middlewares results
-	payload, exists := c.Get("user_id")
+func googleSignIn(c *gin.Context, db *sql.DB, create bool) {
+	googleUser, exists := c.Get("google_user")
 	if !exists {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve account existed status"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve Google identity"})
 		return
 	}
 
-	userID, ok := payload.(int)
-	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+	accountID, created, err := models.SignInGoogleUser(db, googleUser.(models.GoogleUser), create)
+	switch {
+	case errors.Is(err, models.ErrGoogleAccountNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Could not retrieve account"})
 		return
-	}
-
-	token, err := internal.JWTGenerator(userID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not generate token"})
+	case errors.Is(err, models.ErrGoogleEmailNotVerified):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
 		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{"token": token})
-}
-
-func GoogleLogin(c *gin.Context) {
-	//Get middlewares results
-	account_existed, exists := c.Get("account_existed")
-	if !exists {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve account existed status"})
+	case errors.Is(err, models.ErrGoogleEmailTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
 		return
 	}
-	//not existed yet
-	if account_existed == false {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Could not retrieve account"})
+
+	session, err := issueSession(db, accountID, internal.AudienceUser)
+	if accountDisabled(c, err) {
 		return
 	}
-	//db query here
-	var userID int
-	token, err := internal.JWTGenerator(userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not generate token"})
 		return
 	}
-
-	c.JSON(http.StatusOK, gin.H{"token": token})
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	c.JSON(status, session)
 }
 
 func ResetPassword(db *sql.DB) gin.HandlerFunc {
@@ -161,7 +160,7 @@ func ResetPassword(db *sql.DB) gin.HandlerFunc {
 		//db query here
 		query := `
 			update "user"
-			set password = $1
+			set password = $1, password_reset_required = false
 			where email = $2;
 		`
 		rows, err := db.Query(query, hashed_password, email) // Using a placeholder for the argument
@@ -237,7 +236,7 @@ func SendOTP(db *sql.DB, destination_table_index int) gin.HandlerFunc {
 		//db query here
 		query := fmt.Sprintf(`
 			UPDATE %s 
-			SET otp = $1, otp_generated_time = $2
+			SET otp = $1, otp_generated_time = $2, otp_attempts = 0
 			WHERE email = $3;
 		`, destination_table)
 
@@ -263,44 +262,82 @@ func SendOTP(db *sql.DB, destination_table_index int) gin.HandlerFunc {
 	}
 }
 
-func AdminLogin(c *gin.Context) {
-	// Get middleware results
-	payload, exists := c.Get("account_id")
-	if !exists {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"success": false,
-			"message": "Could not retrieve admin account status",
-			"error":   "Could not retrieve admin account status",
-		})
-		return
-	}
+func AdminLogin(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Get middleware results
+		payload, exists := c.Get("account_id")
+		if !exists {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "Could not retrieve admin account status",
+				"error":   "Could not retrieve admin account status",
+			})
+			return
+		}
 
-	adminID, ok := payload.(int)
-	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"success": false,
-			"message": "Internal server error",
-			"error":   "Internal server error",
-		})
-		return
-	}
+		adminID, ok := payload.(int)
+		if !ok {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "Internal server error",
+				"error":   "Internal server error",
+			})
+			return
+		}
 
-	token, err := internal.JWTGenerator(adminID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"success": false,
-			"message": "Could not generate token",
-			"error":   err.Error(),
+		// Admins with TOTP enabled, or all admins if it is required, get an MFA pending token
+		// to exchange with a code at /auth/admin_login/mfa (or to enroll with first).
+		totp, err := models.SelectAdminTOTP(db, adminID)
+		if err != nil && err != sql.ErrNoRows {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "Could not check two-factor authentication",
+				"error":   err.Error(),
+			})
+			return
+		}
+		if totp.Enabled() || internal.AdminMFARequired() {
+			mfaToken, err := internal.MFAPendingTokenGenerator(adminID, internal.NewTokenID())
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"success": false,
+					"message": "Could not generate token",
+					"error":   err.Error(),
+				})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{
+				"success": true,
+				"message": "Two-factor authentication required",
+				"data": gin.H{
+					"mfa_required":            totp.Enabled(),
+					"mfa_enrollment_required": !totp.Enabled(),
+					"mfa_token":               mfaToken,
+					"expires_in":              int(internal.MFAPendingTTL().Seconds()),
+				},
+			})
+			return
+		}
+
+		session, err := issueSession(db, adminID, internal.AudienceAdmin)
+		if accountDisabled(c, err) {
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "Could not generate token",
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "Admin login successful",
+			"data":    session,
 		})
-		return
 	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"message": "Admin login successful",
-		"data": gin.H{
-			"token": token,
-		}})
 }
 
 func AdminAuthorization(c *gin.Context) {
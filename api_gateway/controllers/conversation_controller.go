@@ -5,16 +5,20 @@ import (
 	"database/sql"
 	"encoding/hex"
 	"fmt"
+	"log"
 	"net/http"
 	"strings"
 	"time"
 
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/internal"
 	"github.com/ductruonghoc/DATN_08_2025_Back-end/models"
 	"github.com/ductruonghoc/DATN_08_2025_Back-end/pb"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/retrieval"
 	"github.com/gin-gonic/gin"
 )
 
-func RagQueryHandler() gin.HandlerFunc {
+// RagQueryHandler answers a query, from the answer cache when it holds the answer (cache may be nil).
+func RagQueryHandler(cache *retrieval.AnswerCache) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req struct {
 			Query          string  `json:"query" binding:"required"`
@@ -30,16 +34,19 @@ func RagQueryHandler() gin.HandlerFunc {
 			return
 		}
 
+		cachedResp, cacheEntry := cachedRagResponse(cache, req.Query, req.ConversationID, req.DeviceID)
+
 		// Call gRPC RagService
-		var (
-			ragResp *pb.RagResponse
-			err     error
-		)
-		if req.ConversationID != nil && req.DeviceID != nil {
+		ragResp := cachedResp
+		var err error
+		switch {
+		case ragResp != nil:
+			// Answered from the cache
+		case req.ConversationID != nil && req.DeviceID != nil:
 			ragResp, err = pb.CallRagServiceWithConversationHistory(req.Query, *req.ConversationID, *req.DeviceID)
-		} else if req.DeviceID != nil {
+		case req.DeviceID != nil:
 			ragResp, err = pb.CallRagQueryWithDeviceID(req.Query, *req.DeviceID)
-		} else {
+		default:
 			ragResp, err = pb.CallRagQuery(req.Query)
 		}
 		if err != nil {
@@ -49,37 +56,23 @@ func RagQueryHandler() gin.HandlerFunc {
 			})
 			return
 		}
+		cacheRagResponse(cache, cacheEntry, ragResp)
+
+		citations := ragCitations(ragResp)
 
 		// Only store if account_id is set in context (by Authorization middleware)
 		accountIDVal, exists := c.Get("account_id")
 		rrpID := -1
 		if exists && accountIDVal != nil && req.ConversationID != nil {
-			// Store request-response pair in DB
-			rrp := models.RequestResponsePair{
-				Request:        req.Query,
-				Response:       ragResp.GetResponse(),
-				ConversationID: *req.ConversationID,
-				CreatedTime:    sql.NullTime{Time: time.Now(), Valid: true},
-			}
-			err = models.DB.QueryRow(
-				`INSERT INTO request_response_pair (request, response, conversation_id, created_time) VALUES ($1, $2, $3, $4) RETURNING id`,
-				rrp.Request, rrp.Response, rrp.ConversationID, rrp.CreatedTime.Time,
-			).Scan(&rrpID)
-
-			if err == nil {
-				// If there are images, store them in request_response_pair_pdf_image
-				imagesIds := ragResp.GetImagesIds()
-				for _, imgID := range imagesIds {
-					_, _ = models.DB.Exec(
-						`INSERT INTO request_response_pair_pdf_image (request_response_pair_id, pdf_image_id) VALUES ($1, $2)`,
-						rrpID, imgID,
-					)
-				}
-				// Update conversation's updated_time
-				_, _ = models.DB.Exec(
-					`UPDATE conversation SET updated_time = $1 WHERE id = $2`,
-					time.Now(), *req.ConversationID,
-				)
+			rrpID, err = storeRequestResponsePair(*req.ConversationID, req.Query, ragResp, citations)
+			if err != nil {
+				log.Printf("Failed to store the answer in conversation %s: %v", *req.ConversationID, err)
+				c.JSON(500, gin.H{
+					"success": false,
+					"message": "Failed to store the answer in the conversation",
+					"error":   err.Error(),
+				})
+				return
 			}
 		}
 
@@ -89,11 +82,231 @@ func RagQueryHandler() gin.HandlerFunc {
 			"data": gin.H{
 				"response":   ragResp.GetResponse(),
 				"images_ids": ragResp.GetImagesIds(),
+				"citations":  citations,
 				"pair_id":    rrpID,
+				"cached":     cachedResp != nil,
 			},
 		})
 	}
 }
+// RagQueryStreamHandler relays the RAG answer to the browser token by token as Server-Sent Events.
+// A "token" event is sent for every piece of text, then a final "done" event carries the images_ids,
+// the citations and the stored pair_id, or an error if the pair could not be stored. The request-response pair is only persisted once the stream has completed successfully.
+// An answer from the answer cache (cache may be nil) is sent as a single "token" event.
+func RagQueryStreamHandler(cache *retrieval.AnswerCache) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Query          string  `json:"query" binding:"required"`
+			ConversationID *string `json:"conversation_id"`
+			DeviceID       *int32  `json:"device_id"` // Optional
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{
+				"success": false,
+				"message": "Invalid request",
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		conversationID := ""
+		if req.ConversationID != nil {
+			conversationID = *req.ConversationID
+		}
+		var deviceID int32
+		if req.DeviceID != nil {
+			deviceID = *req.DeviceID
+		}
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Header("X-Accel-Buffering", "no") // Disable proxy buffering so tokens reach the browser immediately
+		c.Status(http.StatusOK)
+
+		cachedResp, cacheEntry := cachedRagResponse(cache, req.Query, req.ConversationID, req.DeviceID)
+
+		ragResp := cachedResp
+		if ragResp != nil {
+			c.SSEvent("token", gin.H{"token": ragResp.GetResponse()})
+			c.Writer.Flush()
+		} else {
+			// The request context is cancelled when the browser disconnects, which aborts the gRPC stream.
+			var err error
+			ragResp, err = pb.CallRagServiceWithConversationHistoryStream(
+				c.Request.Context(),
+				req.Query,
+				conversationID,
+				deviceID,
+				func(token string) error {
+					c.SSEvent("token", gin.H{"token": token})
+					c.Writer.Flush()
+					return c.Request.Context().Err()
+				},
+			)
+			if err != nil {
+				c.SSEvent("error", gin.H{
+					"success": false,
+					"message": err.Error(),
+				})
+				c.Writer.Flush()
+				return
+			}
+			cacheRagResponse(cache, cacheEntry, ragResp)
+		}
+
+		citations := ragCitations(ragResp)
+
+		done := gin.H{
+			"images_ids": ragResp.GetImagesIds(),
+			"citations":  citations,
+			"pair_id":    -1,
+			"cached":     cachedResp != nil,
+		}
+		// Only store if account_id is set in context (by Authorization middleware)
+		accountIDVal, exists := c.Get("account_id")
+		if exists && accountIDVal != nil && req.ConversationID != nil {
+			// The answer is already sent, the done event tells the browser it was not kept
+			if id, err := storeRequestResponsePair(*req.ConversationID, req.Query, ragResp, citations); err != nil {
+				log.Printf("Failed to store the answer in conversation %s: %v", *req.ConversationID, err)
+				done["error"] = "Failed to store the answer in the conversation: " + err.Error()
+			} else {
+				done["pair_id"] = id
+			}
+		}
+
+		c.SSEvent("done", done)
+		c.Writer.Flush()
+	}
+}
+
+// cachedRagResponse looks a query up in the answer cache when its answer can be reused: a query
+// about a device, outside of a conversation or first in it, with no history to answer from. It
+// returns the cached response, or nil and the entry to cache the answer in, nil if it can't be.
+func cachedRagResponse(cache *retrieval.AnswerCache, query string, conversationID *string, deviceID *int32) (*pb.RagResponse, *models.CachedAnswer) {
+	if cache == nil || deviceID == nil || *deviceID == 0 {
+		return nil, nil
+	}
+	if conversationID != nil {
+		hasPairs, err := models.ConversationHasPairs(models.DB, *conversationID)
+		if err != nil {
+			log.Printf("Failed to check the history of conversation %s: %v", *conversationID, err)
+			return nil, nil
+		}
+		if hasPairs {
+			return nil, nil
+		}
+	}
+
+	entry, hit, err := cache.Lookup(int(*deviceID), query)
+	if err != nil {
+		log.Printf("Answer cache lookup failed: %v", err)
+		return nil, nil
+	}
+	if hit {
+		return &pb.RagResponse{Response: entry.Response, ImagesIds: entry.ImagesIDs, ChunkIds: entry.ChunkIDs}, nil
+	}
+	return nil, &entry
+}
+
+// cacheRagResponse caches the answer of the agent in the entry returned by cachedRagResponse.
+func cacheRagResponse(cache *retrieval.AnswerCache, entry *models.CachedAnswer, ragResp *pb.RagResponse) {
+	if entry == nil || ragResp.GetResponse() == "" {
+		return
+	}
+	entry.Response = ragResp.GetResponse()
+	entry.ImagesIDs = ragResp.GetImagesIds()
+	entry.ChunkIDs = ragResp.GetChunkIds()
+	if err := cache.Store(*entry); err != nil {
+		log.Printf("Failed to cache the answer: %v", err)
+	}
+}
+
+// ragCitations resolves the chunks a RAG response was generated from to the PDF pages they cite.
+func ragCitations(ragResp *pb.RagResponse) []models.Citation {
+	chunkIDs := make([]int, len(ragResp.GetChunkIds()))
+	for i, id := range ragResp.GetChunkIds() {
+		chunkIDs[i] = int(id)
+	}
+	citations, err := models.SelectChunkCitations(models.DB, chunkIDs)
+	if err != nil {
+		log.Printf("Failed to resolve the citations of a RAG response: %v", err)
+		return []models.Citation{}
+	}
+	signCitations(citations)
+	return citations
+}
+
+// signCitations sets the URL of the citations: a signed URL of the PDF, opening on the cited page.
+func signCitations(citations []models.Citation) {
+	if internal.Storage == nil {
+		return
+	}
+	for i := range citations {
+		if citations[i].ObjectName == "" {
+			continue
+		}
+		signedURL, err := internal.Storage.SignedReadURL(citations[i].ObjectName, internal.SignedURLTTL)
+		if err != nil {
+			continue
+		}
+		citations[i].URL = fmt.Sprintf("%s#page=%d", signedURL, citations[i].PageNumber)
+	}
+}
+
+// storeRequestResponsePair stores a finished RAG answer in the conversation, links its images and
+// citations and bumps the conversation's updated_time, all or nothing. It returns the id of the new
+// request_response_pair.
+func storeRequestResponsePair(conversationID string, query string, ragResp *pb.RagResponse, citations []models.Citation) (int, error) {
+	rrp := models.RequestResponsePair{
+		Request:        query,
+		Response:       ragResp.GetResponse(),
+		ConversationID: conversationID,
+		CreatedTime:    sql.NullTime{Time: time.Now(), Valid: true},
+	}
+	tx, err := models.DB.Begin()
+	if err != nil {
+		return -1, err
+	}
+	defer tx.Rollback()
+
+	var rrpID int
+	err = tx.QueryRow(
+		`INSERT INTO request_response_pair (request, response, conversation_id, created_time) VALUES ($1, $2, $3, $4) RETURNING id`,
+		rrp.Request, rrp.Response, rrp.ConversationID, rrp.CreatedTime.Time,
+	).Scan(&rrpID)
+	if err != nil {
+		return -1, err
+	}
+
+	// If there are images, store them in request_response_pair_pdf_image
+	imagesIds := ragResp.GetImagesIds()
+	for _, imgID := range imagesIds {
+		_, err := tx.Exec(
+			`INSERT INTO request_response_pair_pdf_image (request_response_pair_id, pdf_image_id) VALUES ($1, $2)`,
+			rrpID, imgID,
+		)
+		if err != nil {
+			return -1, err
+		}
+	}
+	if err := models.InsertPairCitations(tx, rrpID, citations); err != nil {
+		return -1, err
+	}
+	// Update conversation's updated_time
+	_, err = tx.Exec(
+		`UPDATE conversation SET updated_time = $1 WHERE id = $2`,
+		time.Now(), conversationID,
+	)
+	if err != nil {
+		return -1, err
+	}
+	if err := tx.Commit(); err != nil {
+		return -1, err
+	}
+	return rrpID, nil
+}
+
 func ConversationStoringHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Parse optional device_id from JSON body
@@ -215,6 +428,16 @@ func GetConversationInfoHandler() gin.HandlerFunc {
 			deviceID = nil // Not found or error, set blank
 		}
 
+		citations, err := models.SelectConversationCitations(db, conversationID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "Failed to fetch citations",
+				"error":   err.Error(),
+			})
+			return
+		}
+
 		// Get all request-response pairs for this conversation, ordered by id
 		rows, err := db.Query(
 			"SELECT id, request, response, created_time FROM request_response_pair WHERE conversation_id = $1 ORDER BY id ASC",
@@ -258,12 +481,19 @@ func GetConversationInfoHandler() gin.HandlerFunc {
 				imgRows.Close()
 			}
 
+			pairCitations := citations[pairID]
+			if pairCitations == nil {
+				pairCitations = []models.Citation{}
+			}
+			signCitations(pairCitations)
+
 			pairs = append(pairs, gin.H{
 				"id":           pairID,
 				"request":      request,
 				"response":     response,
 				"created_time": createdTimeStr,
 				"images":       images,
+				"citations":    pairCitations,
 			})
 		}
 
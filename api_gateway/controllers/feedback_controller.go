@@ -0,0 +1,171 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/models"
+	"github.com/gin-gonic/gin"
+)
+
+// maxFeedbackComment caps the length of the comment of a rating, in characters.
+const maxFeedbackComment = 2000
+
+// RateAnswerHandler stores the rating of an answer by the account that asked it, with an
+// optional reason and comment. Rating again replaces the previous rating.
+func RateAnswerHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			RequestResponsePairID int     `json:"request_response_pair_id" binding:"required"`
+			Rating                string  `json:"rating" binding:"required"`
+			Reason                *string `json:"reason"`
+			Comment               *string `json:"comment"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "Invalid request",
+				"error":   err.Error(),
+			})
+			return
+		}
+		if req.Rating != models.RatingUp && req.Rating != models.RatingDown {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "rating must be up or down"})
+			return
+		}
+		if req.Reason != nil && !slices.Contains(models.FeedbackReasons, *req.Reason) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "reason must be one of " + strings.Join(models.FeedbackReasons, ", "),
+			})
+			return
+		}
+		if req.Comment != nil {
+			if comment := strings.TrimSpace(*req.Comment); comment == "" {
+				req.Comment = nil
+			} else if len([]rune(comment)) > maxFeedbackComment {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"success": false,
+					"message": fmt.Sprintf("comment must be at most %d characters", maxFeedbackComment),
+				})
+				return
+			} else {
+				req.Comment = &comment
+			}
+		}
+
+		feedback, err := models.UpsertFeedback(models.DB, models.AnswerFeedback{
+			PairID:    req.RequestResponsePairID,
+			AccountID: c.GetInt("account_id"),
+			Rating:    req.Rating,
+			Reason:    req.Reason,
+			Comment:   req.Comment,
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "Failed to store feedback",
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "Feedback stored successfully",
+			"data":    feedback,
+		})
+	}
+}
+
+// DeleteFeedbackHandler removes the rating of an answer by the account that asked it.
+func DeleteFeedbackHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			RequestResponsePairID int `json:"request_response_pair_id" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "Invalid request",
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		err := models.DeleteFeedback(models.DB, req.RequestResponsePairID, c.GetInt("account_id"))
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "Feedback not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "Failed to delete feedback",
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "message": "Feedback deleted successfully"})
+	}
+}
+
+// ListFeedbackHandler lists the rated answers for review, last rated first, a page at a time.
+// They can be filtered by device_id, brand_id and rating. Each citation links to its page in
+// the PDF editor.
+func ListFeedbackHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter := models.FeedbackFilter{Rating: c.Query("rating")}
+		if filter.Rating != "" && filter.Rating != models.RatingUp && filter.Rating != models.RatingDown {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "rating must be up or down"})
+			return
+		}
+		var err error
+		for param, id := range map[string]*int{"device_id": &filter.DeviceID, "brand_id": &filter.BrandID} {
+			if s := c.Query(param); s != "" {
+				if *id, err = strconv.Atoi(s); err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Invalid " + param})
+					return
+				}
+			}
+		}
+		var ok bool
+		if filter.Page, filter.PageSize, ok = pageQuery(c); !ok {
+			return
+		}
+
+		reviews, total, err := models.SelectFeedbackReviews(db, filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "Failed to fetch feedback",
+				"error":   err.Error(),
+			})
+			return
+		}
+		for _, review := range reviews {
+			signCitations(review.Citations)
+			for i, citation := range review.Citations {
+				review.Citations[i].EditorPath = fmt.Sprintf("/pdf_process/get_pdf_state?pdf_id=%d&page_number=%d",
+					citation.PDFID, citation.PageNumber)
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "Fetched feedback successfully",
+			"data": gin.H{
+				"feedback":  reviews,
+				"total":     total,
+				"page":      filter.Page,
+				"page_size": filter.PageSize,
+			},
+		})
+	}
+}
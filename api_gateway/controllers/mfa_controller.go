@@ -0,0 +1,362 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/internal"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/middlewares"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/models"
+	"github.com/gin-gonic/gin"
+)
+
+type secondFactorRequest struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// checkSecondFactor verifies a TOTP code, or else a recovery code, of the admin. Both are single-use.
+func checkSecondFactor(db *sql.DB, adminID int, req secondFactorRequest) (bool, error) {
+	if req.RecoveryCode != "" {
+		return models.UseRecoveryCode(db, adminID, internal.HashRecoveryCode(req.RecoveryCode))
+	}
+	totp, err := models.SelectAdminTOTP(db, adminID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil || !totp.Enabled() {
+		return false, err
+	}
+	step, ok := internal.VerifyTOTP(totp.Secret, req.Code, time.Now(), totp.LastUsedStep)
+	if !ok {
+		return false, nil
+	}
+	return models.UseTOTPStep(db, adminID, step)
+}
+
+// completeMFALogin revokes the MFA pending token of the request and starts the admin session.
+func completeMFALogin(c *gin.Context, db *sql.DB, adminID int) (gin.H, error) {
+	if err := models.RevokeSession(db, adminID, c.GetString("jti"), c.GetTime("token_expires_at")); err != nil {
+		return nil, err
+	}
+	middlewares.InvalidateRevokedTokens()
+	return issueSession(db, adminID, internal.AudienceAdmin)
+}
+
+// AdminMFALogin is the second step of admin_login: it exchanges the MFA pending token and
+// a TOTP or recovery code for an admin session.
+func AdminMFALogin(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		adminID := c.GetInt("account_id")
+		if !verifySecondFactor(c, db, adminID) {
+			return
+		}
+
+		session, err := completeMFALogin(c, db, adminID)
+		if accountDisabled(c, err) {
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "Could not generate token",
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "Admin login successful",
+			"data":    session,
+		})
+	}
+}
+
+// AdminMFAStatus returns whether the admin has TOTP enabled and how many recovery codes are left.
+func AdminMFAStatus(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		adminID := c.GetInt("account_id")
+		totp, err := models.SelectAdminTOTP(db, adminID)
+		if err != nil && err != sql.ErrNoRows {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "Failed to fetch two-factor status",
+				"error":   err.Error(),
+			})
+			return
+		}
+		left, err := models.CountRecoveryCodes(db, adminID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "Failed to fetch two-factor status",
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "Fetched two-factor status successfully",
+			"data": gin.H{
+				"enabled":             totp.Enabled(),
+				"required":            internal.AdminMFARequired(),
+				"recovery_codes_left": left,
+			},
+		})
+	}
+}
+
+// AdminMFAEnroll starts a TOTP enrollment: it returns a new secret and its provisioning URI,
+// to be confirmed with AdminMFAVerify. An unconfirmed enrollment is replaced.
+func AdminMFAEnroll(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		adminID := c.GetInt("account_id")
+		username, err := models.SelectAccountUsername(db, adminID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "Failed to fetch account",
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		secret := internal.NewTOTPSecret()
+		err = models.StartAdminTOTPEnrollment(db, adminID, secret)
+		if errors.Is(err, models.ErrMFAAlreadyEnabled) {
+			c.JSON(http.StatusConflict, gin.H{
+				"success": false,
+				"message": "Two-factor authentication is already enabled",
+				"error":   err.Error(),
+			})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "Failed to start enrollment",
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "Scan the provisioning URI and verify a code to enable two-factor authentication",
+			"data": gin.H{
+				"secret":           secret,
+				"provisioning_uri": internal.TOTPProvisioningURI(secret, username, internal.TOTPIssuer()),
+			},
+		})
+	}
+}
+
+// AdminMFAVerify confirms the pending enrollment with a first code and returns the recovery codes,
+// shown only this once. With an MFA pending token it also completes the login.
+func AdminMFAVerify(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Code string `json:"code" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "Invalid request format",
+				"error":   err.Error(),
+			})
+			return
+		}
+		adminID := c.GetInt("account_id")
+
+		totp, err := models.SelectAdminTOTP(db, adminID)
+		if err == sql.ErrNoRows || (err == nil && totp.Enabled()) {
+			c.JSON(http.StatusConflict, gin.H{
+				"success": false,
+				"message": "No enrollment to verify, start one with /auth/admin_mfa/enroll",
+			})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "Failed to fetch enrollment",
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		step, ok := internal.VerifyTOTP(totp.Secret, req.Code, time.Now(), totp.LastUsedStep)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"message": "Invalid code",
+			})
+			return
+		}
+
+		codes := internal.NewRecoveryCodes(internal.RecoveryCodeCount)
+		err = models.ConfirmAdminTOTP(db, adminID, step, hashRecoveryCodes(codes))
+		if errors.Is(err, models.ErrMFANotEnrolled) {
+			c.JSON(http.StatusConflict, gin.H{
+				"success": false,
+				"message": "No enrollment to verify, start one with /auth/admin_mfa/enroll",
+				"error":   err.Error(),
+			})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "Failed to enable two-factor authentication",
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		data := gin.H{"recovery_codes": codes}
+		if c.GetBool("mfa_pending") {
+			session, err := completeMFALogin(c, db, adminID)
+			if accountDisabled(c, err) {
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"success": false,
+					"message": "Could not generate token",
+					"error":   err.Error(),
+				})
+				return
+			}
+			for k, v := range session {
+				data[k] = v
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "Two-factor authentication enabled",
+			"data":    data,
+		})
+	}
+}
+
+// AdminMFARecoveryCodes replaces the recovery codes of the admin, after checking a current code.
+func AdminMFARecoveryCodes(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		adminID := c.GetInt("account_id")
+		if !requireAdminSession(c) || !verifySecondFactor(c, db, adminID) {
+			return
+		}
+
+		codes := internal.NewRecoveryCodes(internal.RecoveryCodeCount)
+		if err := models.ReplaceRecoveryCodes(db, adminID, hashRecoveryCodes(codes)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "Failed to generate recovery codes",
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "Recovery codes regenerated",
+			"data":    gin.H{"recovery_codes": codes},
+		})
+	}
+}
+
+// AdminMFADisable turns two-factor authentication off, after checking a current code.
+// It is refused while ADMIN_MFA_REQUIRED is set.
+func AdminMFADisable(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if internal.AdminMFARequired() {
+			c.JSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"message": "Two-factor authentication is required for admins",
+			})
+			return
+		}
+		adminID := c.GetInt("account_id")
+		if !requireAdminSession(c) || !verifySecondFactor(c, db, adminID) {
+			return
+		}
+
+		if err := models.DeleteAdminTOTP(db, adminID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "Failed to disable two-factor authentication",
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "Two-factor authentication disabled",
+		})
+	}
+}
+
+// requireAdminSession refuses MFA pending tokens on the routes changing an enabled TOTP setup.
+func requireAdminSession(c *gin.Context) bool {
+	if c.GetBool("mfa_pending") {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"message": "Complete the admin login first",
+		})
+		return false
+	}
+	return true
+}
+
+// verifySecondFactor checks the TOTP or recovery code of the request. Failures count towards
+// locking the admin's second factor out. On failure it writes the response and returns false.
+func verifySecondFactor(c *gin.Context, db *sql.DB, adminID int) bool {
+	var req secondFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil || (req.Code == "" && req.RecoveryCode == "") {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid request format",
+			"error":   "code or recovery_code is required",
+		})
+		return false
+	}
+
+	lockoutKey := fmt.Sprintf("mfa:%d", adminID)
+	if !middlewares.CheckLockout(c, db, lockoutKey) {
+		return false
+	}
+	ok, err := checkSecondFactor(db, adminID, req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to verify code",
+			"error":   err.Error(),
+		})
+		return false
+	}
+	if !ok {
+		middlewares.RecordAuthFailure(db, lockoutKey)
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "Invalid or already used code",
+		})
+		return false
+	}
+	middlewares.ClearAuthFailures(db, lockoutKey)
+	return true
+}
+
+func hashRecoveryCodes(codes []string) []string {
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = internal.HashRecoveryCode(code)
+	}
+	return hashes
+}
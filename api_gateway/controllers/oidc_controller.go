@@ -0,0 +1,166 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/internal"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/middlewares"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/models"
+	"github.com/gin-gonic/gin"
+)
+
+// oidcLoginTTL is how long a user has to sign in at the provider.
+const oidcLoginTTL = 10 * time.Minute
+
+// oidcStateCookie binds a login to the browser that started it, so a callback URL can't be replayed
+// in another browser to sign it in to someone else's account.
+func oidcStateCookie(provider string) string {
+	return "oidc_state_" + provider
+}
+
+func oidcProvider(c *gin.Context) (*internal.OIDCProvider, bool) {
+	provider, ok := internal.OIDCProviders[c.Param("provider")]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "Unknown OIDC provider"})
+	}
+	return provider, ok
+}
+
+// ListOIDCProvidersHandler lists the names of the OIDC providers users can sign in with.
+func ListOIDCProvidersHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		names := make([]string, 0, len(internal.OIDCProviders))
+		for name := range internal.OIDCProviders {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "Fetched OIDC providers successfully",
+			"data": gin.H{
+				"providers": names,
+			},
+		})
+	}
+}
+
+// OIDCLoginHandler starts a login: it redirects the browser to the provider login page.
+func OIDCLoginHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		provider, ok := oidcProvider(c)
+		if !ok {
+			return
+		}
+
+		state := models.OIDCLoginState{
+			State:        internal.NewTokenID(),
+			Provider:     provider.Name,
+			CodeVerifier: internal.NewPKCEVerifier(),
+			Nonce:        internal.NewTokenID(),
+		}
+		authURL, err := provider.AuthCodeURL(c.Request.Context(), state.State, state.Nonce, state.CodeVerifier)
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"success": false, "message": "OIDC provider is unavailable", "error": err.Error()})
+			return
+		}
+		if err := models.InsertOIDCLoginState(db, state, oidcLoginTTL); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Failed to start login", "error": err.Error()})
+			return
+		}
+
+		c.SetSameSite(http.SameSiteLaxMode)
+		c.SetCookie(oidcStateCookie(provider.Name), state.State, int(oidcLoginTTL.Seconds()), "/auth/oidc", "", c.Request.TLS != nil, true)
+		c.Redirect(http.StatusFound, authURL)
+	}
+}
+
+// OIDCCallbackHandler finishes a login: the provider redirects the browser here with a code,
+// which is redeemed for an ID token. The account of the identity gets a session.
+func OIDCCallbackHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		provider, ok := oidcProvider(c)
+		if !ok {
+			return
+		}
+		if idpError := c.Query("error"); idpError != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Login refused by the provider", "error": idpError + ": " + c.Query("error_description")})
+			return
+		}
+
+		stateParam := c.Query("state")
+		cookie, err := c.Cookie(oidcStateCookie(provider.Name))
+		if err != nil || stateParam == "" || cookie != stateParam {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Login was not started by this browser"})
+			return
+		}
+		c.SetCookie(oidcStateCookie(provider.Name), "", -1, "/auth/oidc", "", c.Request.TLS != nil, true)
+
+		state, err := models.ConsumeOIDCLoginState(db, provider.Name, stateParam)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Login expired, please try again"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Failed to finish login", "error": err.Error()})
+			return
+		}
+
+		identity, err := provider.Exchange(c.Request.Context(), c.Query("code"), state.CodeVerifier, state.Nonce)
+		if errors.Is(err, internal.ErrInvalidOIDCIDToken) {
+			c.JSON(http.StatusUnauthorized, gin.H{"success": false, "message": "Invalid ID token", "error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"success": false, "message": "Failed to redeem the authorization code", "error": err.Error()})
+			return
+		}
+
+		accountID, _, err := models.SignInExternalIdentity(db, models.ExternalIdentity{
+			Provider:    provider.Name,
+			Subject:     identity.Subject,
+			Email:       identity.Email,
+			LinkByEmail: identity.EmailVerified || provider.TrustEmail,
+			Name:        identity.Name,
+			Role:        provider.MapRole(identity.Groups),
+			RoleMapped:  len(provider.RoleMap) > 0,
+		})
+		if errors.Is(err, models.ErrExternalEmailTaken) {
+			c.JSON(http.StatusConflict, gin.H{"success": false, "message": "Email is already used by another account", "error": err.Error()})
+			return
+		}
+		if err != nil {
+			log.Printf("OIDC login with %s: %v", provider.Name, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Failed to sign in", "error": err.Error()})
+			return
+		}
+		// The role may come from the provider groups
+		middlewares.InvalidateAccountRole(accountID)
+
+		session, err := issueSession(db, accountID, internal.AudienceUser)
+		if accountDisabled(c, err) {
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Could not generate token", "error": err.Error()})
+			return
+		}
+
+		if provider.PostLoginURL == "" {
+			c.JSON(http.StatusOK, gin.H{"success": true, "message": "Login successful", "data": session})
+			return
+		}
+		// The tokens go in the fragment, which browsers don't send to servers
+		fragment := url.Values{}
+		fragment.Set("token", session["token"].(string))
+		fragment.Set("refresh_token", session["refresh_token"].(string))
+		fragment.Set("expires_in", strconv.Itoa(session["expires_in"].(int)))
+		c.Redirect(http.StatusFound, provider.PostLoginURL+"#"+fragment.Encode())
+	}
+}
@@ -0,0 +1,37 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Page sizes of the admin lists.
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// pageQuery reads the page (from 1) and page_size query params of a list. On invalid values
+// it responds with 400 and returns false.
+func pageQuery(c *gin.Context) (page, pageSize int, ok bool) {
+	page, pageSize = 1, defaultPageSize
+	var err error
+	if s := c.Query("page"); s != "" {
+		if page, err = strconv.Atoi(s); err != nil || page < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "page must be a positive integer"})
+			return 0, 0, false
+		}
+	}
+	if s := c.Query("page_size"); s != "" {
+		if pageSize, err = strconv.Atoi(s); err != nil || pageSize < 1 || pageSize > maxPageSize {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "page_size must be between 1 and " + strconv.Itoa(maxPageSize),
+			})
+			return 0, 0, false
+		}
+	}
+	return page, pageSize, true
+}
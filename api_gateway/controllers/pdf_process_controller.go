@@ -10,15 +10,15 @@ import (
 	"net/url"
 	"strconv"
 	"time"
-	"log"
 	"strings"
 
 	"github.com/ductruonghoc/DATN_08_2025_Back-end/internal"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/middlewares"
 	"github.com/ductruonghoc/DATN_08_2025_Back-end/models"
 	"github.com/ductruonghoc/DATN_08_2025_Back-end/pb"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/workers"
 
 	"github.com/gin-gonic/gin"
-	"github.com/pgvector/pgvector-go"
 )
 
 func NewDevice(db *sql.DB) gin.HandlerFunc {
@@ -178,7 +178,7 @@ func PDFUpload() gin.HandlerFunc {
 
 		// Generate gcs_bucket using bcrypt hash of device label
 		gcsBucket := internal.BcryptHashing(device.Label)
-		gcsBucket = url.PathEscape(gcsBucket) // Ensure the bucket name is URL-safe
+		gcsBucket = internal.UploadPrefix + url.PathEscape(gcsBucket) // Ensure the bucket name is URL-safe
 
 		now := time.Now()
 
@@ -211,10 +211,10 @@ func PDFUpload() gin.HandlerFunc {
 		}
 
 		// Generate signed URL for upload
-		signedURL, err := internal.GenerateWriteSignedURL(
-			internal.BucketNameDefault,
+		signedURL, err := internal.Storage.SignedWriteURL(
 			gcsBucket,
 			"application/pdf",
+			internal.SignedURLTTL,
 		)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
@@ -239,8 +239,9 @@ func PDFUpload() gin.HandlerFunc {
 	}
 }
 
-// ExtractPDFHandler handles requests to extract PDF info from a GCS bucket name.
-func ExtractPDFHandler() gin.HandlerFunc {
+// ExtractPDFHandler queues an extraction job for the pdf_id query parameter.
+// The extraction itself runs in the background, see GetExtractionJobHandler for its progress.
+func ExtractPDFHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		pdfIDStr := c.Query("pdf_id")
 		if pdfIDStr == "" {
@@ -261,131 +262,136 @@ func ExtractPDFHandler() gin.HandlerFunc {
 			return
 		}
 
-		// Retrieve PDF by id
-		pdf, err := models.SelectPDFByID(pdfID)
-		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{
+		enqueueExtractionJob(c, db, pdfID)
+	}
+}
+
+// CreateExtractionJobHandler queues an extraction job for the PDF in the request body.
+func CreateExtractionJobHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			PDFID int `json:"pdf_id" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
 				"success": false,
-				"message": "PDF not found",
-				"errors": gin.H{
-					"code":    404,
-					"details": err.Error(),
-				},
+				"message": "Invalid request",
+				"error":   err.Error(),
 			})
 			return
 		}
 
-		// Call gRPC extract service with the pdf's gcs_bucket
-		resultJson, err := pb.CallExtractPDF(pdf.GCSBucket)
+		enqueueExtractionJob(c, db, req.PDFID)
+	}
+}
+
+func enqueueExtractionJob(c *gin.Context, db *sql.DB, pdfID int) {
+	// Retrieve PDF by id
+	pdf, err := models.SelectPDFByID(pdfID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "PDF not found",
+			"errors": gin.H{
+				"code":    404,
+				"details": err.Error(),
+			},
+		})
+		return
+	}
+	if err := ensurePDFUploaded(c.Request.Context(), db, pdf); err != nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"message": "PDF upload is not complete",
+			"errors": gin.H{
+				"code":    409,
+				"details": err.Error(),
+			},
+		})
+		return
+	}
+
+	jobID, created, err := models.InsertExtractionJob(db, pdfID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to queue extraction job",
+			"error":   err.Error(),
+		})
+		return
+	}
+	workers.NotifyExtractionJob()
+
+	message := "PDF extraction job queued"
+	if !created {
+		message = "PDF extraction job already in progress"
+	}
+	c.JSON(http.StatusAccepted, gin.H{
+		"success": true,
+		"message": message,
+		"data": gin.H{
+			"job_id": jobID,
+			"pdf_id": pdfID,
+		},
+	})
+}
+
+// GetExtractionJobHandler reports the status of an extraction job.
+func GetExtractionJobHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		jobID, err := strconv.Atoi(c.Param("id"))
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
+			c.JSON(http.StatusBadRequest, gin.H{
 				"success": false,
-				"message": "Failed to call ExtractPDF service",
-				"error":   err.Error(),
+				"message": "Invalid job id",
 			})
 			return
 		}
 
-		// Parse the returned JSON
-		var extractResult struct {
-			Pages []struct {
-				Page struct {
-					Imgs []struct {
-						GcsBucketName string `json:"gcs_bucket_name"`
-						Order         int    `json:"order"`
-						RetrievedPath string `json:"retrieved_path"`
-					} `json:"imgs"`
-					PageNumber int    `json:"page_number"`
-					Paragraph  string `json:"paragraph"`
-				} `json:"page"`
-			} `json:"pages"`
-			PDFNumberOfPages int `json:"pdf_number_of_pages"`
-		}
-		if err := json.Unmarshal([]byte(resultJson), &extractResult); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
+		job, err := models.SelectExtractionJobByID(db, jobID)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{
 				"success": false,
-				"message": "Failed to parse result JSON",
-				"error":   err.Error(),
-				"data": gin.H{
-					"result_json": resultJson,
-				},
+				"message": "Extraction job not found",
 			})
-
 			return
 		}
-
-		// Insert pages, paragraphs, and images
-		for _, pageWrap := range extractResult.Pages {
-			page := pageWrap.Page
-			pdfPage := models.PDFPage{
-				PDFID:  pdf.ID,
-				Number: page.PageNumber,
-			}
-			pageID, err := models.InsertPDFPage(pdfPage)
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"success": false,
-					"message": "Failed to insert PDF page",
-					"error":   err.Error(),
-				})
-				return
-			}
-
-			// Insert paragraph
-			paragraph := models.PDFParagraph{
-				PageID:       pageID,
-				Context:      page.Paragraph,
-				LastModified: time.Now(),
-			}
-			_, err = models.InsertPDFParagraph(paragraph)
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"success": false,
-					"message": "Failed to insert PDF paragraph",
-					"error":   err.Error(),
-				})
-				return
-			}
-
-			// Insert images
-			for _, img := range page.Imgs {
-				pdfImg := models.PDFImage{
-					PageID:       pageID,
-					Sequence:     img.Order,
-					GCSBucket:    img.GcsBucketName,
-					LastModified: time.Now(),
-				}
-				_, err := models.InsertPDFImage(pdfImg)
-				if err != nil {
-					c.JSON(http.StatusInternalServerError, gin.H{
-						"success": false,
-						"message": "Failed to insert PDF image",
-						"error":   err.Error(),
-					})
-					return
-				}
-			}
-		}
-
-		// Update PDF: set ocr_flag = true and update number_of_pages
-		err = models.UpdatePDF(pdf.ID, true, extractResult.PDFNumberOfPages)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"success": false,
-				"message": "Failed to update PDF info",
+				"message": "Failed to fetch extraction job",
 				"error":   err.Error(),
 			})
 			return
 		}
 
+		formatTime := func(t sql.NullTime) string {
+			if t.Valid {
+				return t.Time.Format(time.RFC3339)
+			}
+			return ""
+		}
+
 		c.JSON(http.StatusOK, gin.H{
 			"success": true,
-			"message": "PDF extraction and database update successful",
+			"message": "Fetched extraction job successfully",
+			"data": gin.H{
+				"job_id":      job.ID,
+				"pdf_id":      job.PDFID,
+				"status":      job.Status,
+				"error":       job.Error.String,
+				"attempts":    job.Attempts,
+				"created_at":  job.CreatedAt.Format(time.RFC3339),
+				"started_at":  formatTime(job.StartedAt),
+				"finished_at": formatTime(job.FinishedAt),
+			},
 		})
 	}
 }
 
 // SaveAndEmbedHandler handles saving and embedding a paragraph.
+// The new context and its chunks replace the old ones in a single transaction, and the new
+// context is kept as a revision.
 func SaveAndEmbedParagraphHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Parse request parameters
@@ -398,38 +404,6 @@ func SaveAndEmbedParagraphHandler() gin.HandlerFunc {
 			return
 		}
 
-		// Update the paragraph with the incoming context
-		_, err := models.DB.Exec(
-			`UPDATE pdf_paragraph SET context = $1, last_modified = NOW() WHERE id = $2`,
-			req.Context, req.ParagraphID,
-		)
-		if err != nil {
-			c.JSON(500, gin.H{"success": false, "message": "Failed to update paragraph", "error": err.Error()})
-			return
-		}
-
-		// Delete all chunks related to the paragraph ID
-		rows, err := models.DB.Query(`SELECT pdf_chunk_id FROM pdf_chunk_pdf_paragraph WHERE pdf_paragraph_id = $1`, req.ParagraphID)
-		if err != nil {
-			c.JSON(500, gin.H{"success": false, "message": "Failed to query related chunks", "error": err.Error()})
-			return
-		}
-		var chunkIDs []int
-		for rows.Next() {
-			var chunkID int
-			if err := rows.Scan(&chunkID); err == nil {
-				chunkIDs = append(chunkIDs, chunkID)
-			}
-		}
-		rows.Close()
-
-		for _, chunkID := range chunkIDs {
-			if err := models.DeletePDFChunk(chunkID); err != nil {
-				c.JSON(500, gin.H{"success": false, "message": "Failed to delete chunk", "error": err.Error()})
-				return
-			}
-		}
-
 		// Call gRPC to embed and chunk the incoming context
 		resultJson, err := pb.CallChunkAndEmbed(req.Context)
 		if err != nil {
@@ -438,37 +412,21 @@ func SaveAndEmbedParagraphHandler() gin.HandlerFunc {
 		}
 
 		// Parse the returned JSON
-		var chunkResult struct {
-			Chunks []struct {
-				Context string    `json:"context"`
-				Vector  []float32 `json:"vector"`
-			} `json:"chunks"`
-		}
+		var chunkResult models.ChunkResult
 		if err := json.Unmarshal([]byte(resultJson), &chunkResult); err != nil {
 			c.JSON(500, gin.H{"success": false, "message": "Failed to parse embedding result", "error": err.Error()})
 			return
 		}
 
-		// Insert new chunks and create relations
-		for _, chunk := range chunkResult.Chunks {
-			var chunkID int
-			row := models.DB.QueryRow(
-				`INSERT INTO pdf_chunk (context, embedding) VALUES ($1, $2) RETURNING id`,
-				chunk.Context,
-				pgvector.NewVector(chunk.Vector),
-			)
-			if err := row.Scan(&chunkID); err != nil {
-				c.JSON(500, gin.H{"success": false, "message": "Failed to insert chunk", "error": err.Error()})
-				return
-			}
-			_, err = models.DB.Exec(
-				`INSERT INTO pdf_chunk_pdf_paragraph (pdf_chunk_id, pdf_paragraph_id) VALUES ($1, $2)`,
-				chunkID, req.ParagraphID,
-			)
-			if err != nil {
-				c.JSON(500, gin.H{"success": false, "message": "Failed to create chunk-paragraph relation", "error": err.Error()})
-				return
-			}
+		// Update the paragraph and replace its chunks
+		err = models.ReplaceContentChunks(models.DB, middlewares.AuditActor(c), models.ParagraphRevisions, req.ParagraphID, req.Context, 0, chunkResult.Chunks)
+		if err == sql.ErrNoRows {
+			c.JSON(404, gin.H{"success": false, "message": "Paragraph not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(500, gin.H{"success": false, "message": "Failed to save paragraph chunks", "error": err.Error()})
+			return
 		}
 
 		// Return success response
@@ -479,7 +437,9 @@ func SaveAndEmbedParagraphHandler() gin.HandlerFunc {
 	}
 }
 
-// SaveAndEmbedImgAltHandler handles saving and embedding a paragraph.
+// SaveAndEmbedImgAltHandler handles saving and embedding an image alt.
+// The new alt and its chunks replace the old ones in a single transaction, and the new alt
+// is kept as a revision.
 func SaveAndEmbedImgAltHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Parse request parameters
@@ -492,69 +452,29 @@ func SaveAndEmbedImgAltHandler() gin.HandlerFunc {
 			return
 		}
 
-		// 1. Delete related chunks (and relations) for this image alt
-		rows, err := models.DB.Query(`SELECT pdf_chunk_id FROM pdf_chunk_pdf_image WHERE pdf_image_id = $1`, req.ImageID)
-		if err != nil {
-			c.JSON(500, gin.H{"success": false, "message": "Failed to query related chunks"})
-			return
-		}
-		var chunkIDs []int
-
-		for rows.Next() {
-			var chunkID int
-			if err := rows.Scan(&chunkID); err == nil {
-				chunkIDs = append(chunkIDs, chunkID)
-			}
-		}
-		rows.Close()
-
-		for _, chunkID := range chunkIDs {
-			_ = models.DeletePDFChunk(chunkID)
-		}
-
-		// 2. Update the image alt
-		_, err = models.DB.Exec(`UPDATE pdf_image SET alt = $1, last_modified = NOW() WHERE id = $2`, req.ImgAlt, req.ImageID)
-		if err != nil {
-			c.JSON(500, gin.H{"success": false, "message": "Failed to update image alt"})
-			return
-		}
-
-		// 3. Call gRPC to embed and chunk
+		// 1. Call gRPC to embed and chunk
 		resultJson, err := pb.CallChunkAndEmbed(req.ImgAlt)
 		if err != nil {
 			c.JSON(500, gin.H{"success": false, "message": "Failed to call embedding service"})
 			return
 		}
 
-		// 4. Parse the returned JSON
-		var chunkResult struct {
-			Chunks []struct {
-				Context string    `json:"context"`
-				Vector  []float32 `json:"vector"`
-			} `json:"chunks"`
-		}
+		// 2. Parse the returned JSON
+		var chunkResult models.ChunkResult
 		if err := json.Unmarshal([]byte(resultJson), &chunkResult); err != nil {
 			c.JSON(500, gin.H{"success": false, "message": "Failed to parse embedding result"})
 			return
 		}
 
-		// 5. Store chunks and create relations
-		for _, chunk := range chunkResult.Chunks {
-			var chunkID int
-			row := models.DB.QueryRow(
-				`INSERT INTO pdf_chunk (context, embedding) VALUES ($1, $2) RETURNING id`,
-				chunk.Context,
-				pgvector.NewVector(chunk.Vector),
-			)
-			if err := row.Scan(&chunkID); err != nil {
-				c.JSON(500, gin.H{"success": false, "message": "Failed to get chunk id"})
-				return
-			}
-			_, err = models.DB.Exec(`INSERT INTO pdf_chunk_pdf_image (pdf_chunk_id, pdf_image_id) VALUES ($1, $2)`, chunkID, req.ImageID)
-			if err != nil {
-				c.JSON(500, gin.H{"success": false, "message": "Failed to create chunk-image relation"})
-				return
-			}
+		// 3. Update the image alt and replace its chunks
+		err = models.ReplaceContentChunks(models.DB, middlewares.AuditActor(c), models.ImageAltRevisions, req.ImageID, req.ImgAlt, 0, chunkResult.Chunks)
+		if err == sql.ErrNoRows {
+			c.JSON(404, gin.H{"success": false, "message": "Image not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(500, gin.H{"success": false, "message": "Failed to save image alt chunks"})
+			return
 		}
 
 		c.JSON(200, gin.H{"success": true, "message": "Image alt saved and embedded successfully"})
@@ -592,7 +512,7 @@ func GetPDFInitialStateHandler() gin.HandlerFunc {
 		}
 
 		// Generate signed read URL for the PDF
-		signedURL, err := internal.GenerateReadSignedURL(internal.BucketNameDefault, pdf.GCSBucket)
+		signedURL, err := internal.Storage.SignedReadURL(pdf.GCSBucket, internal.SignedURLTTL)
 		if err != nil {
 			c.JSON(500, gin.H{
 				"success": false,
@@ -799,7 +719,7 @@ func GetImgSignedURLHandler() gin.HandlerFunc {
 		}
 
 		// Generate signed read URL for the image
-		signedURL, err := internal.GenerateReadSignedURL(internal.BucketNameDefault, img.GCSBucket)
+		signedURL, err := internal.Storage.SignedReadURL(img.GCSBucket, internal.SignedURLTTL)
 		if err != nil {
 			c.JSON(500, gin.H{
 				"success": false,
@@ -832,7 +752,14 @@ func DeleteChunkHandler() gin.HandlerFunc {
 			return
 		}
 
-		err := models.DeletePDFChunk(req.ChunkID)
+		err := models.DeletePDFChunk(middlewares.AuditActor(c), req.ChunkID)
+		if err == sql.ErrNoRows {
+			c.JSON(404, gin.H{
+				"success": false,
+				"message": "Chunk not found",
+			})
+			return
+		}
 		if err != nil {
 			c.JSON(500, gin.H{
 				"success": false,
@@ -889,7 +816,7 @@ func CreateNewImageHandler() gin.HandlerFunc {
 		hashInput := fmt.Sprintf("%d_%d_%d_%d", req.PDFID, req.PageNumber, sequence, time.Now().UnixNano())
 		hashed := internal.BcryptHashing(hashInput)
 		encoded := base64.URLEncoding.EncodeToString([]byte(hashed))
-		gcsBucketName := encoded
+		gcsBucketName := internal.UploadPrefix + encoded
 
 		// 4. Tạo ảnh mới
 		img := models.PDFImage{
@@ -909,10 +836,10 @@ func CreateNewImageHandler() gin.HandlerFunc {
 		}
 
 		// 5. Sinh signed write URL cho gcsBucketName
-		signedURL, err := internal.GenerateWriteSignedURL(
-			internal.BucketNameDefault,
+		signedURL, err := internal.Storage.SignedWriteURL(
 			gcsBucketName,
 			"image/png",
+			internal.SignedURLTTL,
 		)
 		if err != nil {
 			c.JSON(500, gin.H{
@@ -1028,40 +955,34 @@ func ListDevicesHandler(db *sql.DB) gin.HandlerFunc { //PDF Progress Track
 	}
 }
 
-// Handler to get AgentIsExtracting status with retry logic
-func GetAgentIsExtractingStatusHandler() gin.HandlerFunc {
-    return func(c *gin.Context) {
-        const maxDuration = time.Hour
-        const interval = 5 * time.Second // Try every 5 seconds
-        start := time.Now()
-
-        // If agent is not extracting, return immediately
-        if !pb.AgentIsExtracting {
-            c.JSON(http.StatusOK, gin.H{
-                "success":             true,
-                "agent_is_extracting": false,
-            })
-            return
-        }
+// GetAgentIsExtractingStatusHandler reports whether every OCR agent is busy, i.e. a new extraction would have to wait.
+func GetAgentIsExtractingStatusHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		agents, err := models.SelectAllAIAgents(db)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "Failed to fetch OCR agents",
+				"error":   err.Error(),
+			})
+			return
+		}
 
-        // If agent is extracting, return status and keep retrying in background
-        c.JSON(http.StatusOK, gin.H{
-            "success":             true,
-            "agent_is_extracting": true,
-            "message":             "Agent is extracting. Handler will keep checking in background.",
-        })
+		freeAgents := 0
+		for _, agent := range agents {
+			expired := agent.OCRLockedUntil.Valid && agent.OCRLockedUntil.Time.Before(time.Now())
+			if !agent.Draining && (!agent.OCRInUsed || expired) {
+				freeAgents++
+			}
+		}
 
-        go func() {
-            for time.Since(start) < maxDuration {
-                time.Sleep(interval)
-                if !pb.AgentIsExtracting {
-                    return // Extraction finished, exit goroutine
-                }
-            }
-            // After maxDuration, still extracting: log fatal to reset server
-            log.Fatal("AgentIsExtracting stuck for 1 hour, server will reset.")
-        }()
-    }
+		c.JSON(http.StatusOK, gin.H{
+			"success":             true,
+			"agent_is_extracting": freeAgents == 0,
+			"free_agents":         freeAgents,
+			"total_agents":        len(agents),
+		})
+	}
 }
 
 // ListDeviceForChatHandler returns a paginated list of devices for chat with category and brand info.
@@ -1421,11 +1342,7 @@ func AddBrandHandler(db *sql.DB) gin.HandlerFunc {
         }
 
         // Insert new brand into the database
-        var brandID int
-        err := db.QueryRow(
-            `INSERT INTO brand (label) VALUES ($1) RETURNING id`,
-            req.BrandName,
-        ).Scan(&brandID)
+        brandID, err := models.InsertBrand(db, middlewares.AuditActor(c), req.BrandName)
         if err != nil {
             c.JSON(500, gin.H{
                 "success": false,
@@ -1461,11 +1378,7 @@ func AddCategoryHandler(db *sql.DB) gin.HandlerFunc {
         }
 
         // Insert new category into the database
-        var categoryID int
-        err := db.QueryRow(
-            `INSERT INTO device_type (label) VALUES ($1) RETURNING id`,
-            req.CategoryName,
-        ).Scan(&categoryID)
+        categoryID, err := models.InsertDeviceType(db, middlewares.AuditActor(c), req.CategoryName)
         if err != nil {
             c.JSON(500, gin.H{
                 "success": false,
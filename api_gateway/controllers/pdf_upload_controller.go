@@ -0,0 +1,389 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/config"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/internal"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/models"
+	"github.com/gin-gonic/gin"
+)
+
+// pdfUploadLimits returns the maximum size in bytes and number of pages of an uploaded PDF.
+func pdfUploadLimits() (int64, int) {
+	maxMB, err := strconv.ParseInt(config.GetEnv("PDF_MAX_UPLOAD_MB", "50"), 10, 64)
+	if err != nil || maxMB <= 0 {
+		maxMB = 50
+	}
+	maxPages, err := strconv.Atoi(config.GetEnv("PDF_MAX_PAGES", "500"))
+	if err != nil || maxPages <= 0 {
+		maxPages = 500
+	}
+	return maxMB << 20, maxPages
+}
+
+// imageUploadMaxSize returns the maximum size in bytes of an image uploaded through a signed URL.
+func imageUploadMaxSize() int64 {
+	maxMB, err := strconv.ParseInt(config.GetEnv("IMAGE_MAX_UPLOAD_MB", "10"), 10, 64)
+	if err != nil || maxMB <= 0 {
+		maxMB = 10
+	}
+	return maxMB << 20
+}
+
+func pdfUploadError(c *gin.Context, status int, message, details string) {
+	c.JSON(status, gin.H{
+		"success": false,
+		"message": message,
+		"errors": gin.H{
+			"code":    status,
+			"details": details,
+		},
+	})
+}
+
+// UploadPDFHandler receives a PDF as multipart/form-data (fields device_id, optional pdf_name, then file),
+// validates it, stores it and only then creates its pdf record.
+func UploadPDFHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		maxSize, maxPages := pdfUploadLimits()
+		// Leave some room for the other form fields and the multipart boundaries.
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+1<<20)
+
+		reader, err := c.Request.MultipartReader()
+		if err != nil {
+			pdfUploadError(c, http.StatusBadRequest, "Invalid multipart request", err.Error())
+			return
+		}
+
+		var deviceIDStr, pdfName string
+		var spooled *internal.SpooledPDF
+		defer func() {
+			if spooled != nil {
+				spooled.Remove()
+			}
+		}()
+
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				if isMaxBytesError(err) {
+					pdfUploadError(c, http.StatusRequestEntityTooLarge, "PDF is too large", internal.ErrPDFTooLarge.Error())
+					return
+				}
+				pdfUploadError(c, http.StatusBadRequest, "Invalid multipart request", err.Error())
+				return
+			}
+
+			switch part.FormName() {
+			case "device_id":
+				deviceIDStr, err = readFormField(part)
+			case "pdf_name":
+				pdfName, err = readFormField(part)
+			case "file":
+				if spooled != nil {
+					pdfUploadError(c, http.StatusBadRequest, "Only one file can be uploaded", "file was sent more than once")
+					return
+				}
+				if pdfName == "" {
+					pdfName = strings.TrimSuffix(part.FileName(), ".pdf")
+				}
+				spooled, err = internal.SpoolPDF(part, maxSize)
+			}
+			part.Close()
+
+			switch {
+			case err == nil:
+			case errors.Is(err, internal.ErrNotPDF):
+				pdfUploadError(c, http.StatusUnsupportedMediaType, "File is not a PDF", err.Error())
+				return
+			case errors.Is(err, internal.ErrPDFTooLarge), isMaxBytesError(err):
+				pdfUploadError(c, http.StatusRequestEntityTooLarge, "PDF is too large", err.Error())
+				return
+			default:
+				pdfUploadError(c, http.StatusBadRequest, "Failed to read upload", err.Error())
+				return
+			}
+		}
+
+		if deviceIDStr == "" || spooled == nil {
+			pdfUploadError(c, http.StatusBadRequest, "Missing device_id or file", "device_id and file fields are required")
+			return
+		}
+		deviceID, err := strconv.Atoi(deviceIDStr)
+		if err != nil {
+			pdfUploadError(c, http.StatusBadRequest, "Invalid device_id parameter", "device_id must be a valid integer")
+			return
+		}
+
+		device, err := models.GetDeviceByID(deviceID)
+		if err != nil {
+			pdfUploadError(c, http.StatusNotFound, "Device not found", "No device found with the provided device_id")
+			return
+		}
+
+		pages, err := internal.CountPDFPages(spooled.File, spooled.Size)
+		if err != nil {
+			pdfUploadError(c, http.StatusUnsupportedMediaType, "File is not a PDF", err.Error())
+			return
+		}
+		if pages < 1 || pages > maxPages {
+			pdfUploadError(c, http.StatusUnprocessableEntity, "Unsupported number of pages",
+				"PDF must have between 1 and "+strconv.Itoa(maxPages)+" pages")
+			return
+		}
+
+		if existingID, err := models.SelectPDFIDByDeviceAndSHA256(db, deviceID, spooled.SHA256); err == nil {
+			c.JSON(http.StatusConflict, gin.H{
+				"success": false,
+				"message": "This PDF was already uploaded for the device",
+				"data": gin.H{
+					"pdf_id": existingID,
+				},
+			})
+			return
+		} else if err != sql.ErrNoRows {
+			pdfUploadError(c, http.StatusInternalServerError, "Failed to check for duplicate PDF", err.Error())
+			return
+		}
+
+		// Generate gcs_bucket using bcrypt hash of device label
+		gcsBucket := internal.UploadPrefix + url.PathEscape(internal.BcryptHashing(device.Label))
+		if err := internal.Storage.Put(c.Request.Context(), gcsBucket, "application/pdf", spooled.File); err != nil {
+			pdfUploadError(c, http.StatusInternalServerError, "Failed to store PDF", err.Error())
+			return
+		}
+
+		if pdfName == "" {
+			pdfName = device.Label
+		}
+		// pdf.filename is a varchar(100)
+		if name := []rune(pdfName); len(name) > 100 {
+			pdfName = string(name[:100])
+		}
+		now := time.Now()
+		pdf := models.PDF{
+			GCSBucket:     gcsBucket,
+			DeviceID:      deviceID,
+			OCRFlag:       false,
+			FileName:      pdfName,
+			UploadedAt:    now,
+			LastAccess:    now,
+			NumberOfPages: sql.NullInt32{Int32: int32(pages), Valid: true},
+		}
+		pdfID, err := models.InsertUploadedPDF(db, pdf, spooled.Size, spooled.SHA256)
+		if err != nil {
+			// The record was not created, don't leave the object behind.
+			if delErr := internal.Storage.Delete(context.Background(), gcsBucket); delErr != nil {
+				log.Printf("Failed to delete object %s of a rejected upload: %v", gcsBucket, delErr)
+			}
+			if errors.Is(err, models.ErrDuplicatePDF) {
+				pdfUploadError(c, http.StatusConflict, "This PDF was already uploaded for the device", err.Error())
+				return
+			}
+			pdfUploadError(c, http.StatusInternalServerError, "Failed to insert PDF record", err.Error())
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"success": true,
+			"message": "PDF uploaded successfully",
+			"data": gin.H{
+				"pdf_id":          pdfID,
+				"file_name":       pdfName,
+				"number_of_pages": pages,
+				"size":            spooled.Size,
+				"sha256":          spooled.SHA256,
+			},
+		})
+	}
+}
+
+func isMaxBytesError(err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	return errors.As(err, &maxBytesErr)
+}
+
+// readFormField reads a small, non-file multipart field.
+func readFormField(part *multipart.Part) (string, error) {
+	value, err := io.ReadAll(io.LimitReader(part, 1024))
+	return strings.TrimSpace(string(value)), err
+}
+
+// ConfirmPDFUploadHandler is called by the client once it PUT a PDF to the signed URL from PDFUpload.
+func ConfirmPDFUploadHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		maxSize, _ := pdfUploadLimits()
+		confirmUpload(c, db, models.UploadTablePDF, "application/pdf", maxSize)
+	}
+}
+
+// ConfirmImageUploadHandler is called by the client once it PUT an image to the signed URL from CreateNewImageHandler.
+func ConfirmImageUploadHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		confirmUpload(c, db, models.UploadTableImage, "image/png", imageUploadMaxSize())
+	}
+}
+
+// confirmUpload stats the object of a pending row and marks the row uploaded,
+// or failed (deleting the object) if the object doesn't match what was expected.
+func confirmUpload(c *gin.Context, db *sql.DB, table, contentType string, maxSize int64) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		pdfUploadError(c, http.StatusBadRequest, "Invalid id parameter", "id must be a valid integer")
+		return
+	}
+
+	// checksum (hex MD5 of the file) is optional
+	var req struct {
+		Checksum string `json:"checksum"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			pdfUploadError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+			return
+		}
+	}
+
+	upload, err := models.SelectUpload(db, table, id)
+	if err == sql.ErrNoRows {
+		pdfUploadError(c, http.StatusNotFound, "Upload not found", "No upload found with the provided id")
+		return
+	}
+	if err != nil {
+		pdfUploadError(c, http.StatusInternalServerError, "Failed to fetch upload", err.Error())
+		return
+	}
+
+	switch upload.Status {
+	case models.UploadStatusUploaded:
+		c.JSON(http.StatusOK, uploadResponse("Upload already confirmed", upload))
+		return
+	case models.UploadStatusFailed:
+		pdfUploadError(c, http.StatusConflict, "Upload was rejected", "upload the file again to a new signed URL")
+		return
+	}
+
+	upload, err = confirmPendingUpload(c.Request.Context(), db, table, upload, contentType, maxSize, req.Checksum)
+	var rejected *uploadRejectedError
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, uploadResponse("Upload confirmed", upload))
+	case errors.Is(err, errUploadMissing):
+		pdfUploadError(c, http.StatusConflict, "File not uploaded yet", err.Error())
+	case errors.Is(err, errUploadNotPending):
+		pdfUploadError(c, http.StatusConflict, "Upload is no longer pending", err.Error())
+	case errors.As(err, &rejected):
+		pdfUploadError(c, http.StatusUnprocessableEntity, "Uploaded file was rejected", rejected.reason)
+	default:
+		pdfUploadError(c, http.StatusInternalServerError, "Failed to confirm upload", err.Error())
+	}
+}
+
+var (
+	errUploadMissing    = errors.New("no object found at the signed URL")
+	errUploadNotPending = errors.New("the upload was confirmed or removed meanwhile")
+)
+
+type uploadRejectedError struct {
+	reason string
+}
+
+func (e *uploadRejectedError) Error() string {
+	return "uploaded file was rejected: " + e.reason
+}
+
+// confirmPendingUpload stats the object of a pending row and marks the row uploaded.
+// If the object doesn't match what was expected, it is deleted, the row is marked failed
+// and an *uploadRejectedError is returned. checksum (hex MD5) is only checked when not empty.
+func confirmPendingUpload(ctx context.Context, db *sql.DB, table string, upload models.Upload, contentType string, maxSize int64, checksum string) (models.Upload, error) {
+	attrs, err := internal.Storage.Stat(ctx, upload.ObjectName)
+	if errors.Is(err, internal.ErrBlobNotFound) {
+		return upload, errUploadMissing
+	}
+	if err != nil {
+		return upload, err
+	}
+
+	storedChecksum := hex.EncodeToString(attrs.MD5)
+	mediaType, _, _ := mime.ParseMediaType(attrs.ContentType)
+	var reason string
+	switch {
+	case mediaType != contentType:
+		reason = "content type must be " + contentType
+	case attrs.Size == 0 || attrs.Size > maxSize:
+		reason = "file size must be between 1 and " + strconv.FormatInt(maxSize, 10) + " bytes"
+	case checksum != "" && storedChecksum != "" && !strings.EqualFold(checksum, storedChecksum):
+		reason = "checksum does not match"
+	}
+	if reason != "" {
+		if err := internal.Storage.Delete(ctx, upload.ObjectName); err != nil && !errors.Is(err, internal.ErrBlobNotFound) {
+			log.Printf("Failed to delete rejected object %s: %v", upload.ObjectName, err)
+		}
+		if err := models.MarkUploadFailed(db, table, upload.ID); err != nil && err != sql.ErrNoRows {
+			log.Printf("Failed to mark upload %s %d as failed: %v", table, upload.ID, err)
+		}
+		return upload, &uploadRejectedError{reason: reason}
+	}
+
+	err = models.MarkUploadUploaded(db, table, upload.ID, attrs.Size, attrs.ContentType, storedChecksum)
+	if err == sql.ErrNoRows {
+		return upload, errUploadNotPending
+	}
+	if err != nil {
+		return upload, err
+	}
+
+	upload.Status = models.UploadStatusUploaded
+	upload.SizeBytes = sql.NullInt64{Int64: attrs.Size, Valid: true}
+	upload.ContentType = sql.NullString{String: attrs.ContentType, Valid: true}
+	upload.Checksum = sql.NullString{String: storedChecksum, Valid: storedChecksum != ""}
+	return upload, nil
+}
+
+// ensurePDFUploaded confirms the upload of a pending PDF, for clients that queue the
+// extraction right after the signed URL upload without confirming it.
+func ensurePDFUploaded(ctx context.Context, db *sql.DB, pdf models.PDF) error {
+	switch pdf.UploadStatus {
+	case models.UploadStatusUploaded:
+		return nil
+	case models.UploadStatusFailed:
+		return errors.New("the uploaded file was rejected")
+	}
+	upload, err := models.SelectUpload(db, models.UploadTablePDF, pdf.ID)
+	if err != nil {
+		return err
+	}
+	maxSize, _ := pdfUploadLimits()
+	_, err = confirmPendingUpload(ctx, db, models.UploadTablePDF, upload, "application/pdf", maxSize, "")
+	return err
+}
+
+func uploadResponse(message string, upload models.Upload) gin.H {
+	return gin.H{
+		"success": true,
+		"message": message,
+		"data": gin.H{
+			"id":            upload.ID,
+			"upload_status": upload.Status,
+			"size_bytes":    upload.SizeBytes.Int64,
+			"content_type":  upload.ContentType.String,
+			"checksum":      upload.Checksum.String,
+		},
+	}
+}
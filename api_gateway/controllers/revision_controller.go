@@ -0,0 +1,171 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/internal"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/middlewares"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/models"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/pb"
+	"github.com/gin-gonic/gin"
+)
+
+// revisionNotFound answers 404 for a paragraph or image, or one of its revisions, that doesn't exist.
+func revisionNotFound(c *gin.Context, kind models.RevisionKind) {
+	message := "Paragraph or revision not found"
+	if kind == models.ImageAltRevisions {
+		message = "Image or revision not found"
+	}
+	c.JSON(http.StatusNotFound, gin.H{"success": false, "message": message})
+}
+
+// revisionParam reads a revision id of a route or query. On failure it responds and returns false.
+func revisionParam(c *gin.Context, name, value string) (int64, bool) {
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Invalid " + name})
+		return 0, false
+	}
+	return id, true
+}
+
+// chunkAndEmbed chunks a text and embeds its chunks through the AI service.
+func chunkAndEmbed(text string) ([]models.EmbeddedChunk, error) {
+	resultJson, err := pb.CallChunkAndEmbed(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call embedding service: %w", err)
+	}
+	var chunkResult models.ChunkResult
+	if err := json.Unmarshal([]byte(resultJson), &chunkResult); err != nil {
+		return nil, fmt.Errorf("failed to parse embedding result: %w", err)
+	}
+	return chunkResult.Chunks, nil
+}
+
+// ListRevisionsHandler lists the revisions of a paragraph or image alt (:id), newest first.
+func ListRevisionsHandler(db *sql.DB, kind models.RevisionKind) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ownerID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Invalid id"})
+			return
+		}
+
+		revisions, err := models.SelectRevisions(db, kind, ownerID)
+		if err == sql.ErrNoRows {
+			revisionNotFound(c, kind)
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Failed to fetch revisions", "error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "Fetched revisions successfully",
+			"data": gin.H{
+				"kind":      kind.Name,
+				"id":        ownerID,
+				"revisions": revisions,
+			},
+		})
+	}
+}
+
+// DiffRevisionsHandler diffs two revisions (from and to) of a paragraph or image alt word by word.
+func DiffRevisionsHandler(db *sql.DB, kind models.RevisionKind) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ownerID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Invalid id"})
+			return
+		}
+		fromID, ok := revisionParam(c, "from", c.Query("from"))
+		if !ok {
+			return
+		}
+		toID, ok := revisionParam(c, "to", c.Query("to"))
+		if !ok {
+			return
+		}
+
+		var revisions [2]models.Revision
+		for i, id := range []int64{fromID, toID} {
+			revisions[i], err = models.SelectRevision(db, kind, ownerID, id)
+			if err == sql.ErrNoRows {
+				revisionNotFound(c, kind)
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Failed to fetch revision", "error": err.Error()})
+				return
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "Diffed revisions successfully",
+			"data": gin.H{
+				"from": revisions[0],
+				"to":   revisions[1],
+				"diff": internal.DiffWords(revisions[0].Content, revisions[1].Content),
+			},
+		})
+	}
+}
+
+// RestoreRevisionHandler makes a revision (:revision_id) the current text of its paragraph or image
+// alt again. The text is chunked and embedded again, and saved as a new revision.
+func RestoreRevisionHandler(db *sql.DB, kind models.RevisionKind) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ownerID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Invalid id"})
+			return
+		}
+		revisionID, ok := revisionParam(c, "revision_id", c.Param("revision_id"))
+		if !ok {
+			return
+		}
+
+		revision, err := models.SelectRevision(db, kind, ownerID, revisionID)
+		if err == sql.ErrNoRows {
+			revisionNotFound(c, kind)
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Failed to fetch revision", "error": err.Error()})
+			return
+		}
+
+		chunks, err := chunkAndEmbed(revision.Content)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Failed to embed revision", "error": err.Error()})
+			return
+		}
+
+		err = models.ReplaceContentChunks(db, middlewares.AuditActor(c), kind, ownerID, revision.Content, revision.ID, chunks)
+		if err == sql.ErrNoRows {
+			revisionNotFound(c, kind)
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Failed to restore revision", "error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "Revision restored and embedded successfully",
+			"data": gin.H{
+				"restored_from": revision.ID,
+				"content":       revision.Content,
+			},
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/middlewares"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/models"
+	"github.com/gin-gonic/gin"
+)
+
+// isBuiltInRole reports whether the code relies on the role label (registration, admin login),
+// so the role can't be renamed or deleted.
+func isBuiltInRole(label string) bool {
+	return label == models.UserPermission || label == models.AdminPermission
+}
+
+// roleError answers the errors shared by the role handlers.
+func roleError(c *gin.Context, err error, message string) {
+	var unknown *models.UnknownPermissionsError
+	switch {
+	case err == sql.ErrNoRows:
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "Role not found"})
+	case errors.Is(err, models.ErrRoleExists):
+		c.JSON(http.StatusConflict, gin.H{"success": false, "message": "Role label is already used", "error": err.Error()})
+	case errors.Is(err, models.ErrRoleInUse):
+		c.JSON(http.StatusConflict, gin.H{"success": false, "message": "Role is still assigned to accounts", "error": err.Error()})
+	case errors.As(err, &unknown):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Unknown permissions", "error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": message, "error": err.Error()})
+	}
+}
+
+// ListPermissionsHandler lists the permissions roles can grant.
+func ListPermissionsHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		permissions, err := models.SelectAllPermissions(db)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "Failed to fetch permissions",
+				"error":   err.Error(),
+			})
+			return
+		}
+		if permissions == nil {
+			permissions = []models.Permission{}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "Fetched permissions successfully",
+			"data": gin.H{
+				"permissions": permissions,
+			},
+		})
+	}
+}
+
+// ListRolesHandler lists the roles with their permissions and number of accounts.
+func ListRolesHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		roles, err := models.SelectAllRoles(db)
+		if err != nil {
+			roleError(c, err, "Failed to fetch roles")
+			return
+		}
+		if roles == nil {
+			roles = []models.Role{}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "Fetched roles successfully",
+			"data": gin.H{
+				"roles": roles,
+			},
+		})
+	}
+}
+
+// CreateRoleHandler creates a role granting the given permissions.
+func CreateRoleHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Label       string   `json:"label" binding:"required"`
+			Permissions []string `json:"permissions"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "Invalid request",
+				"error":   err.Error(),
+			})
+			return
+		}
+		label := strings.TrimSpace(req.Label)
+		if label == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Role label is required"})
+			return
+		}
+
+		roleID, err := models.InsertRole(db, middlewares.AuditActor(c), label, req.Permissions)
+		if err != nil {
+			roleError(c, err, "Failed to create role")
+			return
+		}
+		role, err := models.SelectRoleByID(db, roleID)
+		if err != nil {
+			roleError(c, err, "Failed to fetch role")
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"success": true,
+			"message": "Role created successfully",
+			"data": gin.H{
+				"role": role,
+			},
+		})
+	}
+}
+
+// UpdateRoleHandler renames a role and/or replaces its permissions.
+// The accounts having the role get the new permissions on their next request.
+func UpdateRoleHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		roleID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Invalid role id"})
+			return
+		}
+		var req struct {
+			Label       *string  `json:"label"`
+			Permissions []string `json:"permissions"` // Omit to keep the permissions, [] to remove them all
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "Invalid request",
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		role, err := models.SelectRoleByID(db, roleID)
+		if err != nil {
+			roleError(c, err, "Failed to fetch role")
+			return
+		}
+		if req.Label != nil {
+			label := strings.TrimSpace(*req.Label)
+			if label == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Role label can't be empty"})
+				return
+			}
+			if label != role.Label && isBuiltInRole(role.Label) {
+				c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Built-in roles can't be renamed"})
+				return
+			}
+			req.Label = &label
+		}
+		if req.Permissions != nil && role.Label == models.AdminPermission && !slices.Contains(req.Permissions, models.PermissionRoleManage) {
+			// Otherwise no one could manage roles anymore
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "The admin role must keep " + models.PermissionRoleManage})
+			return
+		}
+
+		if err := models.UpdateRole(db, middlewares.AuditActor(c), roleID, req.Label, req.Permissions); err != nil {
+			roleError(c, err, "Failed to update role")
+			return
+		}
+		// Role labels are in the access tokens, and permissions are cached per account
+		middlewares.InvalidateAllAccountRoles()
+
+		role, err = models.SelectRoleByID(db, roleID)
+		if err != nil {
+			roleError(c, err, "Failed to fetch role")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "Role updated successfully",
+			"data": gin.H{
+				"role": role,
+			},
+		})
+	}
+}
+
+// DeleteRoleHandler deletes a role no account has.
+func DeleteRoleHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		roleID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Invalid role id"})
+			return
+		}
+
+		role, err := models.SelectRoleByID(db, roleID)
+		if err != nil {
+			roleError(c, err, "Failed to fetch role")
+			return
+		}
+		if isBuiltInRole(role.Label) {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Built-in roles can't be deleted"})
+			return
+		}
+
+		if err := models.DeleteRole(db, middlewares.AuditActor(c), roleID); err != nil {
+			roleError(c, err, "Failed to delete role")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "Role deleted successfully",
+			"data": gin.H{
+				"role_id": roleID,
+			},
+		})
+	}
+}
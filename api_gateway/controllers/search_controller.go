@@ -0,0 +1,55 @@
+package controllers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/retrieval"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultSearchLimit = 10
+	maxSearchLimit     = 50
+)
+
+// SearchChunksHandler finds the chunks matching q, by meaning and by exact words (model numbers,
+// error codes), optionally of one device (device_id). Each chunk comes with its page and PDF.
+func SearchChunksHandler(db *sql.DB, embedder retrieval.Embedder) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query := retrieval.Query{Text: strings.TrimSpace(c.Query("q")), Limit: defaultSearchLimit}
+		if query.Text == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "q is required"})
+			return
+		}
+		var err error
+		if s := c.Query("device_id"); s != "" {
+			if query.DeviceID, err = strconv.Atoi(s); err != nil || query.DeviceID <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Invalid device_id"})
+				return
+			}
+		}
+		if s := c.Query("limit"); s != "" {
+			if query.Limit, err = strconv.Atoi(s); err != nil || query.Limit < 1 || query.Limit > maxSearchLimit {
+				c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "limit must be between 1 and 50"})
+				return
+			}
+		}
+
+		hits, err := retrieval.Search(db, embedder, query)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Failed to search chunks", "error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "Searched chunks successfully",
+			"data": gin.H{
+				"chunks": hits,
+			},
+		})
+	}
+}
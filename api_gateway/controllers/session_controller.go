@@ -0,0 +1,193 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/internal"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/middlewares"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/models"
+	"github.com/gin-gonic/gin"
+)
+
+// issueSession starts a new session for the account: an access token for audience
+// and a refresh token of a new family.
+func issueSession(db *sql.DB, accountID int, audience string) (gin.H, error) {
+	jti := internal.NewTokenID()
+	refreshToken, refreshHash := internal.NewRefreshToken()
+	err := models.InsertRefreshToken(db, models.RefreshToken{
+		AccountID: accountID,
+		TokenHash: refreshHash,
+		FamilyID:  internal.NewTokenID(),
+		Audience:  audience,
+		AccessJTI: jti,
+	}, internal.AccessTokenTTL(), internal.RefreshTokenTTL())
+	if err != nil {
+		return nil, err
+	}
+	return sessionTokens(db, accountID, audience, jti, refreshToken)
+}
+
+// sessionTokens signs the access token with the current role and token version of the account.
+func sessionTokens(db *sql.DB, accountID int, audience, jti, refreshToken string) (gin.H, error) {
+	role, err := models.SelectAccountRole(db, accountID)
+	if err != nil {
+		return nil, err
+	}
+	if role.Disabled {
+		return nil, models.ErrAccountDisabled
+	}
+	token, err := internal.JWTGenerator(internal.TokenSubject{
+		AccountID:    accountID,
+		Role:         role.Role,
+		TokenVersion: role.TokenVersion,
+		Audience:     audience,
+	}, jti)
+	if err != nil {
+		return nil, err
+	}
+	return gin.H{
+		"token":         token,
+		"refresh_token": refreshToken,
+		"expires_in":    int(internal.AccessTokenTTL().Seconds()),
+	}, nil
+}
+
+// accountDisabled answers 403 if a session couldn't start because the account is disabled.
+func accountDisabled(c *gin.Context, err error) bool {
+	if !errors.Is(err, models.ErrAccountDisabled) {
+		return false
+	}
+	c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "Account is disabled", "error": err.Error()})
+	return true
+}
+
+// RefreshTokenHandler exchanges a refresh token for a new access token and a new refresh token.
+// The old refresh token can't be used again.
+func RefreshTokenHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			RefreshToken string `json:"refresh_token" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "Invalid request format",
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		jti := internal.NewTokenID()
+		refreshToken, refreshHash := internal.NewRefreshToken()
+		rotated, err := models.RotateRefreshToken(db, internal.HashRefreshToken(req.RefreshToken), models.RefreshToken{
+			TokenHash: refreshHash,
+			AccessJTI: jti,
+		}, internal.AccessTokenTTL(), internal.RefreshTokenTTL())
+		if errors.Is(err, models.ErrRefreshTokenReused) {
+			// The access tokens of the family were revoked with it
+			middlewares.InvalidateRevokedTokens()
+		}
+		if errors.Is(err, models.ErrRefreshTokenInvalid) || errors.Is(err, models.ErrRefreshTokenReused) {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"message": "Invalid or expired refresh token",
+				"error":   err.Error(),
+			})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "Failed to refresh token",
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		session, err := sessionTokens(db, rotated.AccountID, rotated.Audience, jti, refreshToken)
+		if accountDisabled(c, err) {
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "Could not generate token",
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "Token refreshed successfully",
+			"data":    session,
+		})
+	}
+}
+
+// LogoutHandler revokes the session of the access token the request is authorized with.
+func LogoutHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		accountID := c.GetInt("account_id")
+		jti := c.GetString("jti")
+		expiresAt := c.GetTime("token_expires_at")
+		if jti == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"message": "Unauthorized: token not found in context",
+			})
+			return
+		}
+		if expiresAt.IsZero() {
+			expiresAt = time.Now().Add(internal.AccessTokenTTL())
+		}
+
+		if err := models.RevokeSession(db, accountID, jti, expiresAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "Failed to log out",
+				"error":   err.Error(),
+			})
+			return
+		}
+		middlewares.InvalidateRevokedTokens()
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "Logged out successfully",
+		})
+	}
+}
+
+// LogoutAllHandler revokes every session of the account, on all devices.
+func LogoutAllHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		accountID := c.GetInt("account_id")
+		jti := c.GetString("jti")
+		if jti == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"message": "Unauthorized: token not found in context",
+			})
+			return
+		}
+
+		if err := models.RevokeAllSessions(db, accountID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "Failed to log out everywhere",
+				"error":   err.Error(),
+			})
+			return
+		}
+		middlewares.InvalidateAccountRole(accountID)
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "Logged out of all sessions successfully",
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/internal"
+	"github.com/gin-gonic/gin"
+)
+
+// maxLocalUploadSize caps the body of an upload to the local store.
+const maxLocalUploadSize = 200 << 20
+
+// localBlobStore returns the local store, or answers 404 when the gateway uses another backend.
+func localBlobStore(c *gin.Context) (*internal.LocalBlobStore, bool) {
+	store, ok := internal.Storage.(*internal.LocalBlobStore)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "Local storage is not enabled",
+		})
+	}
+	return store, ok
+}
+
+// DownloadLocalBlobHandler serves an object of the local store to the holder of a signed read URL.
+func DownloadLocalBlobHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		store, ok := localBlobStore(c)
+		if !ok {
+			return
+		}
+
+		objectName, err := store.VerifySignedURL(http.MethodGet, c.Request.URL.Query(), "")
+		if err != nil {
+			c.JSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"message": "Invalid or expired signed URL",
+			})
+			return
+		}
+
+		attrs, err := store.Stat(c.Request.Context(), objectName)
+		if errors.Is(err, internal.ErrBlobNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"message": "Object not found",
+			})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "Failed to read object",
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		reader, err := store.Get(c.Request.Context(), objectName)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "Failed to read object",
+				"error":   err.Error(),
+			})
+			return
+		}
+		defer reader.Close()
+
+		c.DataFromReader(http.StatusOK, attrs.Size, attrs.ContentType, reader, nil)
+	}
+}
+
+// UploadLocalBlobHandler stores the body of a PUT to a signed write URL of the local store.
+func UploadLocalBlobHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		store, ok := localBlobStore(c)
+		if !ok {
+			return
+		}
+
+		contentType := c.GetHeader("Content-Type")
+		objectName, err := store.VerifySignedURL(http.MethodPut, c.Request.URL.Query(), contentType)
+		if err != nil {
+			c.JSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"message": "Invalid or expired signed URL",
+			})
+			return
+		}
+
+		body := http.MaxBytesReader(c.Writer, c.Request.Body, maxLocalUploadSize)
+		if err := store.Put(c.Request.Context(), objectName, contentType, body); err != nil {
+			if isMaxBytesError(err) {
+				c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+					"success": false,
+					"message": "Upload is too large",
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "Failed to store object",
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		c.Status(http.StatusOK)
+	}
+}
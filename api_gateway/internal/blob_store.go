@@ -0,0 +1,77 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/config"
+)
+
+// SignedURLTTL is how long the signed URLs handed to clients stay valid.
+const SignedURLTTL = 15 * time.Minute
+
+// UploadPrefix starts the names of the objects the gateway creates for uploads. The bucket is shared
+// with the OCR agents, so the upload reaper only sweeps the objects under it.
+const UploadPrefix = "uploads/"
+
+// ErrBlobNotFound is returned when an object does not exist in the store.
+var ErrBlobNotFound = errors.New("blob not found")
+
+// BlobAttrs describes a stored object.
+type BlobAttrs struct {
+	Size        int64
+	ContentType string
+	MD5         []byte
+	Updated     time.Time
+}
+
+// BlobStore stores the PDFs and images uploaded by clients and extracted by the OCR agents.
+type BlobStore interface {
+	// SignedReadURL returns a URL a client can GET the object from until it expires.
+	SignedReadURL(objectName string, expires time.Duration) (string, error)
+	// SignedWriteURL returns a URL a client can PUT the object to until it expires.
+	// The upload must be sent with the given Content-Type.
+	SignedWriteURL(objectName, contentType string, expires time.Duration) (string, error)
+	Put(ctx context.Context, objectName, contentType string, r io.Reader) error
+	Get(ctx context.Context, objectName string) (io.ReadCloser, error)
+	Delete(ctx context.Context, objectName string) error
+	Stat(ctx context.Context, objectName string) (BlobAttrs, error)
+	// List calls fn with every object whose name starts with prefix, stopping at the first error fn returns.
+	List(ctx context.Context, prefix string, fn func(objectName string, attrs BlobAttrs) error) error
+}
+
+// Storage is the store picked by InitStorage.
+var Storage BlobStore
+
+// InitStorage creates the blob store selected by STORAGE_BACKEND ("gcs" or "local").
+func InitStorage() error {
+	backend := config.GetEnv("STORAGE_BACKEND", "gcs")
+	switch backend {
+	case "gcs":
+		store, err := CreateStorageClient()
+		if err != nil {
+			return err
+		}
+		Storage = store
+	case "local":
+		signingKey := config.GetEnv("STORAGE_SIGNING_KEY", config.GetEnv("JWT_KEY", ""))
+		if signingKey == "" {
+			return errors.New("STORAGE_SIGNING_KEY or JWT_KEY is required for the local storage backend")
+		}
+		store, err := NewLocalBlobStore(
+			config.GetEnv("STORAGE_LOCAL_DIR", "./storage"),
+			config.GetEnv("STORAGE_PUBLIC_URL", "http://localhost:"+config.GetEnv("GATEWAY_PORT", "8080")),
+			[]byte(signingKey),
+		)
+		if err != nil {
+			return err
+		}
+		Storage = store
+	default:
+		return fmt.Errorf("unknown STORAGE_BACKEND %q", backend)
+	}
+	return nil
+}
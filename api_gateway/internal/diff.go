@@ -0,0 +1,99 @@
+package internal
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Operations of a DiffOp.
+const (
+	DiffEqual  = "equal"
+	DiffInsert = "insert"
+	DiffDelete = "delete"
+)
+
+// DiffOp is a run of text kept, inserted or deleted going from the old text to the new one.
+type DiffOp struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// maxDiffCells bounds the table of DiffWords. Beyond it, the texts are shown as replaced whole.
+const maxDiffCells = 4_000_000
+
+// diffTokens splits a text into words, each with the whitespace following it.
+var diffTokens = regexp.MustCompile(`\s+|\S+\s*`)
+
+// DiffWords diffs two texts word by word. Joining the equal and delete texts gives back
+// the old text, joining the equal and insert texts gives back the new one.
+func DiffWords(oldText, newText string) []DiffOp {
+	a := diffTokens.FindAllString(oldText, -1)
+	b := diffTokens.FindAllString(newText, -1)
+
+	// Common prefix and suffix don't need the table
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var ops []DiffOp
+	add := func(op, text string) {
+		if text == "" {
+			return
+		}
+		if n := len(ops); n > 0 && ops[n-1].Op == op {
+			ops[n-1].Text += text
+			return
+		}
+		ops = append(ops, DiffOp{Op: op, Text: text})
+	}
+
+	add(DiffEqual, strings.Join(a[:prefix], ""))
+	ma, mb := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if (len(ma)+1)*(len(mb)+1) > maxDiffCells {
+		add(DiffDelete, strings.Join(ma, ""))
+		add(DiffInsert, strings.Join(mb, ""))
+	} else {
+		// lcs[i][j] is the length of the longest common subsequence of ma[i:] and mb[j:]
+		lcs := make([][]int, len(ma)+1)
+		for i := range lcs {
+			lcs[i] = make([]int, len(mb)+1)
+		}
+		for i := len(ma) - 1; i >= 0; i-- {
+			for j := len(mb) - 1; j >= 0; j-- {
+				if ma[i] == mb[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else {
+					lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+				}
+			}
+		}
+		i, j := 0, 0
+		for i < len(ma) && j < len(mb) {
+			switch {
+			case ma[i] == mb[j]:
+				add(DiffEqual, ma[i])
+				i++
+				j++
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				add(DiffDelete, ma[i])
+				i++
+			default:
+				add(DiffInsert, mb[j])
+				j++
+			}
+		}
+		add(DiffDelete, strings.Join(ma[i:], ""))
+		add(DiffInsert, strings.Join(mb[j:], ""))
+	}
+	add(DiffEqual, strings.Join(a[len(a)-suffix:], ""))
+
+	if ops == nil {
+		ops = []DiffOp{}
+	}
+	return ops
+}
@@ -1,34 +1,168 @@
 package internal
 
 import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+
 	"github.com/ductruonghoc/DATN_08_2025_Back-end/config"
-	
 	"gopkg.in/gomail.v2"
+)
 
-	"fmt"
-);
+// Email is a rendered message, with a plain-text and an HTML body.
+type Email struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
 
-func GetEmailCredentials() (string, string, string) {
-	email := "graduateproject26@gmail.com";
-	password := config.GetEnv("SMTP_PWD", "");
-	smtp_server := "smtp.gmail.com";
+// Mailer delivers emails.
+type Mailer interface {
+	Send(ctx context.Context, email Email) error
+}
 
-	return email, password, smtp_server;
+// SMTPMailer sends emails through an SMTP server.
+type SMTPMailer struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	// TLS is "starttls" (upgrade the connection, usually port 587) or "tls" (implicit TLS, usually port 465).
+	TLS string
 }
 
-// sendEmail sends an OTP via email using Gomail and SMTP
-func EmailOTP(to string, otp string) error {
-	//Get recent config credentials
-	email, password, smtp_server := GetEmailCredentials();
-	
-	//mail object
-	m := gomail.NewMessage();
-	m.SetHeader("From", email);
-	m.SetHeader("To", to);
-	m.SetHeader("Subject", "Your OTP Code");
-	m.SetBody("text/plain", fmt.Sprintf("Your OTP code is: %s", otp));
+// Send delivers the email, giving up when ctx is done: the connection is closed, so a stuck
+// server can't hold the caller past its deadline.
+func (m *SMTPMailer) Send(ctx context.Context, email Email) error {
+	msg := gomail.NewMessage()
+	msg.SetHeader("From", m.From)
+	msg.SetHeader("To", email.To)
+	msg.SetHeader("Subject", email.Subject)
+	msg.SetBody("text/plain", email.Text)
+	if email.HTML != "" {
+		msg.AddAlternative("text/html", email.HTML)
+	}
 
-	d := gomail.NewDialer(smtp_server, 587, email, password);
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(m.Host, strconv.Itoa(m.Port)))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
 
-	return d.DialAndSend(m);
-}
\ No newline at end of file
+	tlsConfig := &tls.Config{ServerName: m.Host}
+	if m.TLS == "tls" {
+		conn = tls.Client(conn, tlsConfig)
+	}
+	c, err := smtp.NewClient(conn, m.Host)
+	if err != nil {
+		return contextError(ctx, err)
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok && m.TLS == "starttls" {
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return contextError(ctx, err)
+		}
+	}
+	if ok, _ := c.Extension("AUTH"); ok && m.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", m.Username, m.Password, m.Host)); err != nil {
+			return contextError(ctx, err)
+		}
+	}
+
+	if err := c.Mail(m.From); err != nil {
+		return contextError(ctx, err)
+	}
+	if err := c.Rcpt(email.To); err != nil {
+		return contextError(ctx, err)
+	}
+	w, err := c.Data()
+	if err != nil {
+		return contextError(ctx, err)
+	}
+	if _, err := msg.WriteTo(w); err != nil {
+		return contextError(ctx, err)
+	}
+	if err := w.Close(); err != nil {
+		return contextError(ctx, err)
+	}
+	return contextError(ctx, c.Quit())
+}
+
+// contextError reports why an SMTP exchange was cut: the error of the closed connection is
+// meaningless once ctx is done.
+func contextError(ctx context.Context, err error) error {
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// LogMailer is for local development: it logs the emails instead of sending them,
+// and writes them to Dir (one .txt and one .html file each) if it is set.
+type LogMailer struct {
+	Dir string
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._@-]+`)
+
+func (m *LogMailer) Send(ctx context.Context, email Email) error {
+	log.Printf("Email to %s: %s\n%s", email.To, email.Subject, email.Text)
+	if m.Dir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+	name := filepath.Join(m.Dir, time.Now().Format("20060102-150405.000000000")+"-"+unsafeFileChars.ReplaceAllString(email.To, "_"))
+	text := fmt.Sprintf("To: %s\nSubject: %s\n\n%s", email.To, email.Subject, email.Text)
+	if err := os.WriteFile(name+".txt", []byte(text), 0o644); err != nil {
+		return err
+	}
+	return os.WriteFile(name+".html", []byte(email.HTML), 0o644)
+}
+
+// Mail is the mailer picked by InitMailer.
+var Mail Mailer
+
+// InitMailer creates the mailer selected by MAIL_BACKEND: "smtp" (SMTP_* variables) or "log" (MAIL_LOG_DIR).
+func InitMailer() error {
+	backend := config.GetEnv("MAIL_BACKEND", "smtp")
+	switch backend {
+	case "smtp":
+		port, err := strconv.Atoi(config.GetEnv("SMTP_PORT", "587"))
+		if err != nil {
+			return fmt.Errorf("invalid SMTP_PORT: %w", err)
+		}
+		tls := config.GetEnv("SMTP_TLS", "starttls")
+		if tls != "starttls" && tls != "tls" {
+			return fmt.Errorf("unknown SMTP_TLS %q", tls)
+		}
+		from := config.GetEnv("SMTP_FROM", "graduateproject26@gmail.com")
+		Mail = &SMTPMailer{
+			Host:     config.GetEnv("SMTP_HOST", "smtp.gmail.com"),
+			Port:     port,
+			Username: config.GetEnv("SMTP_USERNAME", from),
+			Password: config.GetEnv("SMTP_PWD", ""),
+			From:     from,
+			TLS:      tls,
+		}
+	case "log":
+		Mail = &LogMailer{Dir: config.GetEnv("MAIL_LOG_DIR", "")}
+	default:
+		return fmt.Errorf("unknown MAIL_BACKEND %q", backend)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/config"
+)
+
+// Kinds of emails, each with a template per language in email_templates:
+// <kind>.<lang>.txt (text body, defining "subject") and <kind>.<lang>.html (HTML body).
+const (
+	EmailKindOTP          = "otp"
+	EmailKindEmailChange  = "email_change"
+	EmailKindEmailChanged = "email_changed"
+)
+
+// EmailLanguages are the languages emails are translated to.
+var EmailLanguages = []string{"vi", "en"}
+
+// OTPEmailData fills the otp and email_change templates.
+type OTPEmailData struct {
+	Code       string
+	ValidHours int
+}
+
+// EmailChangedData fills the email_changed template, sent to the old address.
+type EmailChangedData struct {
+	NewEmail string
+}
+
+//go:embed email_templates
+var emailTemplates embed.FS
+
+// DefaultEmailLanguage is the language of emails to users without a preference (MAIL_DEFAULT_LANG, vi by default).
+func DefaultEmailLanguage() string {
+	return config.GetEnv("MAIL_DEFAULT_LANG", "vi")
+}
+
+// EmailLanguage picks the email language from an Accept-Language header, e.g. "en-US,en;q=0.9".
+func EmailLanguage(acceptLanguage string) string {
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag := strings.ToLower(strings.TrimSpace(strings.SplitN(part, ";", 2)[0]))
+		tag = strings.SplitN(tag, "-", 2)[0]
+		for _, lang := range EmailLanguages {
+			if tag == lang {
+				return lang
+			}
+		}
+	}
+	return DefaultEmailLanguage()
+}
+
+// RenderEmail renders the email of a kind in a language (the default one if it isn't supported) to to.
+func RenderEmail(kind, lang, to string, data any) (Email, error) {
+	supported := false
+	for _, l := range EmailLanguages {
+		supported = supported || l == lang
+	}
+	if !supported {
+		lang = DefaultEmailLanguage()
+	}
+	name := "email_templates/" + kind + "." + lang
+
+	text, err := texttemplate.ParseFS(emailTemplates, name+".txt")
+	if err != nil {
+		return Email{}, fmt.Errorf("email template %s: %w", name, err)
+	}
+	html, err := htmltemplate.ParseFS(emailTemplates, name+".html")
+	if err != nil {
+		return Email{}, fmt.Errorf("email template %s: %w", name, err)
+	}
+
+	var subject, textBody, htmlBody bytes.Buffer
+	if err := text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Email{}, err
+	}
+	if err := text.Execute(&textBody, data); err != nil {
+		return Email{}, err
+	}
+	if err := html.Execute(&htmlBody, data); err != nil {
+		return Email{}, err
+	}
+	return Email{
+		To:      to,
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    textBody.String(),
+		HTML:    htmlBody.String(),
+	}, nil
+}
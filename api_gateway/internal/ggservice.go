@@ -3,19 +3,27 @@ package internal
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"io"
 	"strings"
 	"time"
 
 	"cloud.google.com/go/storage"
 	"github.com/ductruonghoc/DATN_08_2025_Back-end/config"
+	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 )
 
-var bucketClient *storage.Client
 var BucketNameDefault = "extract_pdf_22_05_2025"
 
+// GCSBlobStore is the BlobStore backed by a Google Cloud Storage bucket.
+type GCSBlobStore struct {
+	client *storage.Client
+	bucket string
+	key    ServiceAccountKey
+}
+
 // ServiceAccountKey represents the structure of your service account JSON key
 type ServiceAccountKey struct {
 	Type         string `json:"type"` // Thêm trường "type"
@@ -26,12 +34,11 @@ type ServiceAccountKey struct {
 	// ... other fields you might have
 }
 
-var serviceAccountKey ServiceAccountKey
-
-func CreateStorageClient() {
+// CreateStorageClient creates the GCS store of the GCS_PDF_BUCKET_NAME bucket.
+func CreateStorageClient() (*GCSBlobStore, error) {
 	ctx := context.Background()
 	// Lấy thông tin từ biến môi trường
-    serviceAccountKey = ServiceAccountKey{
+    serviceAccountKey := ServiceAccountKey{
 		Type:         "service_account", // Giá trị mặc định cho trường "type"
         PrivateKeyID: config.GetEnv("GOOGLE_PRIVATE_KEY_ID", ""),
         PrivateKey:   config.GetEnv("GOOGLE_PRIVATE_KEY", ""),
@@ -44,66 +51,133 @@ func CreateStorageClient() {
 		serviceAccountKey.PrivateKey == "" || 
 		serviceAccountKey.ClientEmail == "" ||
 		serviceAccountKey.ClientID == "" {
-        log.Fatalf("Missing required environment variables for Google Cloud authentication")
+        return nil, errors.New("missing required environment variables for Google Cloud authentication")
     }
 	serviceAccountKey.PrivateKey = strings.ReplaceAll(serviceAccountKey.PrivateKey, "\\n", "\n") // Chuyển đổi ký tự \n thành dòng mới
 	// Chuyển đổi ServiceAccountKey thành JSON
     credentialsJSON, err := json.Marshal(serviceAccountKey)
     if err != nil {
-        log.Fatalf("Không thể chuyển đổi ServiceAccountKey thành JSON: %v", err)
+        return nil, fmt.Errorf("Không thể chuyển đổi ServiceAccountKey thành JSON: %w", err)
     }
 	// Khởi tạo client.
 	// Thư viện sẽ tự động tìm thông tin xác thực nếu bạn đã cấu hình ADC
 	// hoặc đặt biến môi trường GOOGLE_APPLICATION_CREDENTIALS.
 	client, err := storage.NewClient(ctx, option.WithCredentialsJSON(credentialsJSON))
 	if err != nil {
-		log.Fatalf("Creating Client Fails: %v", err)
+		return nil, fmt.Errorf("Creating Client Fails: %w", err)
 	}
-	defer client.Close()
 
-	bucketClient = client
+	return &GCSBlobStore{
+		client: client,
+		bucket: config.GetEnv("GCS_PDF_BUCKET_NAME", BucketNameDefault),
+		key:    serviceAccountKey,
+	}, nil
 }
 
-// generateReadSignedURL tạo một Signed URL để đọc (GET) một đối tượng.
-func GenerateReadSignedURL(bucketName, objectName string) (string, error) {
+// SignedReadURL tạo một Signed URL để đọc (GET) một đối tượng.
+func (s *GCSBlobStore) SignedReadURL(objectName string, expires time.Duration) (string, error) {
 	// Cấu hình các tùy chọn cho Signed URL
 	opts := &storage.SignedURLOptions{
 		Scheme:         storage.SigningSchemeV4,
 		Method:         "GET",
-		Expires:        time.Now().Add(15 * time.Minute),
-		GoogleAccessID: serviceAccountKey.ClientEmail,
-		PrivateKey:     []byte(serviceAccountKey.PrivateKey),
+		Expires:        time.Now().Add(expires),
+		GoogleAccessID: s.key.ClientEmail,
+		PrivateKey:     []byte(s.key.PrivateKey),
 	}
 
-	u, err := bucketClient.Bucket(bucketName).SignedURL(objectName, opts)
+	u, err := s.client.Bucket(s.bucket).SignedURL(objectName, opts)
 	if err != nil {
-		return "", fmt.Errorf("Bucket(%q).SignedURL: %v", bucketName, err)
+		return "", fmt.Errorf("Bucket(%q).SignedURL: %v", s.bucket, err)
 	}
 	return u, nil
 }
 
-// generateWriteSignedURL tạo một Signed URL để ghi (PUT) một đối tượng.
-func GenerateWriteSignedURL(bucketName, objectName, contentType string) (string, error) {
+// SignedWriteURL tạo một Signed URL để ghi (PUT) một đối tượng.
+func (s *GCSBlobStore) SignedWriteURL(objectName, contentType string, expires time.Duration) (string, error) {
 	// Cấu hình các tùy chọn cho Signed URL
 	opts := &storage.SignedURLOptions{
 		Scheme:         storage.SigningSchemeV4,
 		Method:         "PUT",
-		Expires:        time.Now().Add(15 * time.Minute),
+		Expires:        time.Now().Add(expires),
 		Headers:        []string{fmt.Sprintf("Content-Type: %s", contentType)}, // Content-Type phải khớp khi tải lên
-		GoogleAccessID: serviceAccountKey.ClientEmail,
-		PrivateKey:     []byte(serviceAccountKey.PrivateKey),
+		GoogleAccessID: s.key.ClientEmail,
+		PrivateKey:     []byte(s.key.PrivateKey),
 		// GoogleAccessID và PrivateKey: tương tự như với GET
 	}
 
-	u, err := bucketClient.Bucket(bucketName).SignedURL(objectName, opts)
+	u, err := s.client.Bucket(s.bucket).SignedURL(objectName, opts)
 	if err != nil {
-		return "", fmt.Errorf("Bucket(%q).SignedURL for PUT: %v", bucketName, err)
+		return "", fmt.Errorf("Bucket(%q).SignedURL for PUT: %v", s.bucket, err)
 	}
 	return u, nil
 }
 
+func (s *GCSBlobStore) Put(ctx context.Context, objectName, contentType string, r io.Reader) error {
+	w := s.client.Bucket(s.bucket).Object(objectName).NewWriter(ctx)
+	w.ContentType = contentType
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+func (s *GCSBlobStore) Get(ctx context.Context, objectName string) (io.ReadCloser, error) {
+	r, err := s.client.Bucket(s.bucket).Object(objectName).NewReader(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil, ErrBlobNotFound
+	}
+	return r, err
+}
+
+func (s *GCSBlobStore) Delete(ctx context.Context, objectName string) error {
+	err := s.client.Bucket(s.bucket).Object(objectName).Delete(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return ErrBlobNotFound
+	}
+	return err
+}
+
+func (s *GCSBlobStore) Stat(ctx context.Context, objectName string) (BlobAttrs, error) {
+	attrs, err := s.client.Bucket(s.bucket).Object(objectName).Attrs(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return BlobAttrs{}, ErrBlobNotFound
+	}
+	if err != nil {
+		return BlobAttrs{}, err
+	}
+	return BlobAttrs{
+		Size:        attrs.Size,
+		ContentType: attrs.ContentType,
+		MD5:         attrs.MD5,
+		Updated:     attrs.Updated,
+	}, nil
+}
+
+func (s *GCSBlobStore) List(ctx context.Context, prefix string, fn func(objectName string, attrs BlobAttrs) error) error {
+	objects := s.client.Bucket(s.bucket).Objects(ctx, &storage.Query{Prefix: prefix})
+	for {
+		attrs, err := objects.Next()
+		if errors.Is(err, iterator.Done) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		err = fn(attrs.Name, BlobAttrs{
+			Size:        attrs.Size,
+			ContentType: attrs.ContentType,
+			MD5:         attrs.MD5,
+			Updated:     attrs.Updated,
+		})
+		if err != nil {
+			return err
+		}
+	}
+}
+
 // setBucketCORSConfiguration sets a CORS configuration on a bucket.
-func SetBucketCORSConfiguration() error {
+func (s *GCSBlobStore) SetBucketCORSConfiguration() error {
 	ctx := context.Background()
 	corsConfig := []storage.CORS{
 		{
@@ -121,7 +195,7 @@ func SetBucketCORSConfiguration() error {
 		// },
 	}
 	// Get a handle to the bucket
-	bucket := bucketClient.Bucket(BucketNameDefault)
+	bucket := s.client.Bucket(s.bucket)
 
 	// Update the bucket's CORS configuration
 	// The BucketAttrsToUpdate struct is used to specify which attributes to update.
@@ -133,10 +207,10 @@ func SetBucketCORSConfiguration() error {
 	// Perform the update operation
 	_, err := bucket.Update(ctx, bucketAttrsToUpdate)
 	if err != nil {
-		return fmt.Errorf("Bucket(%q).Update: %w", BucketNameDefault, err)
+		return fmt.Errorf("Bucket(%q).Update: %w", s.bucket, err)
 	}
 
-	fmt.Printf("CORS configuration successfully set for bucket %q.\n", BucketNameDefault)
+	fmt.Printf("CORS configuration successfully set for bucket %q.\n", s.bucket)
 	return nil
 }
 
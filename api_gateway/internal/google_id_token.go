@@ -0,0 +1,110 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/config"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// GoogleJWKSURL publishes the keys Google signs ID tokens with.
+const GoogleJWKSURL = "https://www.googleapis.com/oauth2/v3/certs"
+
+var googleIssuers = []string{"accounts.google.com", "https://accounts.google.com"}
+
+// ErrInvalidGoogleIDToken is returned for ID tokens that are malformed, expired, not signed by Google
+// or issued to another client.
+var ErrInvalidGoogleIDToken = errors.New("invalid Google ID token")
+
+// GoogleClaims is the payload of a Google ID token.
+type GoogleClaims struct {
+	Email         string     `json:"email"`
+	EmailVerified googleBool `json:"email_verified"`
+	Name          string     `json:"name"`
+	Picture       string     `json:"picture"`
+	jwt.RegisteredClaims
+}
+
+// googleBool accepts both true and "true", older tokens have string booleans.
+type googleBool bool
+
+func (b *googleBool) UnmarshalJSON(data []byte) error {
+	v, err := strconv.ParseBool(strings.Trim(string(data), `"`))
+	if err != nil {
+		return err
+	}
+	*b = googleBool(v)
+	return nil
+}
+
+// GoogleIDTokenVerifier verifies Google ID tokens against Google's JWKS.
+type GoogleIDTokenVerifier struct {
+	keys      *JWKS
+	clientIDs []string
+}
+
+// NewGoogleIDTokenVerifier accepts the tokens issued to one of clientIDs, checked with the keys at jwksURL.
+func NewGoogleIDTokenVerifier(jwksURL string, clientIDs []string) *GoogleIDTokenVerifier {
+	return &GoogleIDTokenVerifier{keys: NewJWKS(jwksURL), clientIDs: clientIDs}
+}
+
+var (
+	googleVerifier     *GoogleIDTokenVerifier
+	googleVerifierOnce sync.Once
+)
+
+// GoogleVerifier returns the verifier configured by GOOGLE_OAUTH_CLIENT_ID (comma-separated client IDs)
+// and GOOGLE_JWKS_URL (to use a local JWKS in tests).
+func GoogleVerifier() *GoogleIDTokenVerifier {
+	googleVerifierOnce.Do(func() {
+		var clientIDs []string
+		for _, id := range strings.Split(config.GetEnv("GOOGLE_OAUTH_CLIENT_ID", ""), ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				clientIDs = append(clientIDs, id)
+			}
+		}
+		googleVerifier = NewGoogleIDTokenVerifier(config.GetEnv("GOOGLE_JWKS_URL", GoogleJWKSURL), clientIDs)
+	})
+	return googleVerifier
+}
+
+// Verify checks the signature, issuer, audience and expiry of an ID token and returns its claims.
+func (v *GoogleIDTokenVerifier) Verify(ctx context.Context, idToken string) (*GoogleClaims, error) {
+	if len(v.clientIDs) == 0 {
+		return nil, errors.New("GOOGLE_OAUTH_CLIENT_ID is not set")
+	}
+
+	claims := &GoogleClaims{}
+	_, err := jwt.ParseWithClaims(idToken, claims, v.keys.Keyfunc(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidGoogleIDToken, err)
+	}
+
+	issuerOK := false
+	for _, iss := range googleIssuers {
+		if claims.Issuer == iss {
+			issuerOK = true
+		}
+	}
+	if !issuerOK {
+		return nil, fmt.Errorf("%w: unexpected issuer %q", ErrInvalidGoogleIDToken, claims.Issuer)
+	}
+	audienceOK := false
+	for _, id := range v.clientIDs {
+		if claims.VerifyAudience(id, true) {
+			audienceOK = true
+		}
+	}
+	if !audienceOK {
+		return nil, fmt.Errorf("%w: issued to another client", ErrInvalidGoogleIDToken)
+	}
+	if claims.ExpiresAt == nil || claims.Subject == "" {
+		return nil, fmt.Errorf("%w: missing exp or sub", ErrInvalidGoogleIDToken)
+	}
+	return claims, nil
+}
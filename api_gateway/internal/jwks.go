@@ -0,0 +1,125 @@
+package internal
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// JWKS is the RSA key set an identity provider signs its ID tokens with.
+// The keys are cached for the max-age the provider sends, and refetched early when a token has an unknown kid.
+type JWKS struct {
+	url    string
+	client *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	expiresAt time.Time
+	fetchedAt time.Time
+}
+
+func NewJWKS(url string) *JWKS {
+	return &JWKS{url: url, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Keyfunc returns a jwt.Keyfunc accepting RS256 tokens signed by one of the keys.
+func (j *JWKS) Keyfunc(ctx context.Context) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		if token.Method != jwt.SigningMethodRS256 {
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+		kid, _ := token.Header["kid"].(string)
+		return j.key(ctx, kid)
+	}
+}
+
+// key returns the public key kid, fetching the set when the cache expired or doesn't know kid.
+func (j *JWKS) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	now := time.Now()
+	key, known := j.keys[kid]
+	// Refetch at most once a minute for unknown kids, so forged tokens can't hammer the provider
+	if now.After(j.expiresAt) || (!known && now.Sub(j.fetchedAt) > time.Minute) {
+		if err := j.fetch(ctx); err != nil {
+			if known {
+				return key, nil // Keep using the cached key while the provider is unreachable
+			}
+			return nil, err
+		}
+		key, known = j.keys[kid]
+	}
+	if !known {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	return key, nil
+}
+
+func (j *JWKS) fetch(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch signing keys: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch signing keys: %s", resp.Status)
+	}
+
+	var jwks struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&jwks); err != nil {
+		return fmt.Errorf("failed to decode signing keys: %w", err)
+	}
+	keys := make(map[string]*rsa.PublicKey, len(jwks.Keys))
+	for _, k := range jwks.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(k.N)
+		e, errE := base64.RawURLEncoding.DecodeString(k.E)
+		if errN != nil || errE != nil {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+
+	now := time.Now()
+	j.keys = keys
+	j.fetchedAt = now
+	j.expiresAt = now.Add(cacheMaxAge(resp.Header.Get("Cache-Control"), time.Hour))
+	return nil
+}
+
+// cacheMaxAge reads max-age from a Cache-Control header.
+func cacheMaxAge(header string, fallback time.Duration) time.Duration {
+	for _, directive := range strings.Split(header, ",") {
+		directive = strings.TrimSpace(directive)
+		if value, ok := strings.CutPrefix(directive, "max-age="); ok {
+			if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+				return time.Duration(seconds) * time.Second
+			}
+		}
+	}
+	return fallback
+}
@@ -4,22 +4,74 @@ import (
 	"github.com/ductruonghoc/DATN_08_2025_Back-end/config"
 	"github.com/golang-jwt/jwt/v4"
 
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"time"
 	"errors"
 )
 
+// Audiences of access tokens: admin tokens are only issued by admin_login.
+// MFA pending tokens are not access tokens: admin_login returns one when a second factor
+// is still to be verified, and only the admin MFA routes accept it.
+const (
+	AudienceUser       = "techbot-user"
+	AudienceAdmin      = "techbot-admin"
+	AudienceMFAPending = "techbot-mfa-pending"
+)
+
 // Claims struct for JWT payload
 type UserIDClaims struct {
-	UserID int `json:"user_id"`
+	UserID       int    `json:"user_id"`
+	Role         string `json:"role"`
+	TokenVersion int    `json:"token_version"`
 	jwt.RegisteredClaims
 }
 
-func JWTGenerator(userID int) (string, error) {
-	expirationTime := time.Now().Add(24 * time.Hour)
+// TokenSubject is the account an access token is issued for.
+type TokenSubject struct {
+	AccountID    int
+	Role         string
+	TokenVersion int
+	Audience     string
+}
+
+// AccessTokenTTL is the lifetime of access tokens (ACCESS_TOKEN_TTL, 15 minutes by default).
+func AccessTokenTTL() time.Duration {
+	return durationEnv("ACCESS_TOKEN_TTL", 15*time.Minute)
+}
+
+// RefreshTokenTTL is the lifetime of refresh tokens (REFRESH_TOKEN_TTL, 30 days by default).
+func RefreshTokenTTL() time.Duration {
+	return durationEnv("REFRESH_TOKEN_TTL", 30*24*time.Hour)
+}
+
+// MFAPendingTTL is the time an admin has to enter the second factor after the password (MFA_PENDING_TTL, 5 minutes by default).
+func MFAPendingTTL() time.Duration {
+	return durationEnv("MFA_PENDING_TTL", 5*time.Minute)
+}
+
+func durationEnv(key string, fallback time.Duration) time.Duration {
+	d, err := time.ParseDuration(config.GetEnv(key, ""))
+	if err != nil || d <= 0 {
+		return fallback
+	}
+	return d
+}
+
+// JWTGenerator issues a short-lived access token with tokenID as its jti.
+func JWTGenerator(subject TokenSubject, tokenID string) (string, error) {
+	now := time.Now()
 	claims := &UserIDClaims{
-		UserID: userID,
+		UserID:       subject.AccountID,
+		Role:         subject.Role,
+		TokenVersion: subject.TokenVersion,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
+			ID:        tokenID,
+			Audience:  jwt.ClaimStrings{subject.Audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(AccessTokenTTL())),
 		},
 	}
 
@@ -31,8 +83,9 @@ func JWTGenerator(userID int) (string, error) {
 	return token.SignedString([]byte(jwtKey));
 }
 
-// JWTValidator parses a JWT string and returns the user ID if valid
-func JWTValidator(tokenString string) (int, error) {
+// JWTParse parses and verifies an access token and returns its claims.
+// Tokens without a jti or a known audience (issued by older versions) are refused.
+func JWTParse(tokenString string) (*UserIDClaims, error) {
 	jwtKey := config.GetEnv("JWT_KEY", "")
 
 	claims := &UserIDClaims{}
@@ -43,9 +96,80 @@ func JWTValidator(tokenString string) (int, error) {
 		return []byte(jwtKey), nil
 	})
 
-	if err != nil || !token.Valid {
-		return 0, errors.New("invalid token")
+	if err != nil || !token.Valid || claims.ID == "" {
+		return nil, errors.New("invalid token")
+	}
+	if !claims.VerifyAudience(AudienceUser, true) && !claims.VerifyAudience(AudienceAdmin, true) {
+		return nil, errors.New("invalid token audience")
+	}
+
+	return claims, nil
+}
+
+// MFAPendingTokenGenerator issues the token proving the admin passed the password step of admin_login.
+func MFAPendingTokenGenerator(adminID int, tokenID string) (string, error) {
+	now := time.Now()
+	claims := &UserIDClaims{
+		UserID: adminID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID,
+			Audience:  jwt.ClaimStrings{AudienceMFAPending},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(MFAPendingTTL())),
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(config.GetEnv("JWT_KEY", "")))
+}
+
+// MFAPendingTokenParse parses and verifies an MFA pending token. Access tokens are refused.
+func MFAPendingTokenParse(tokenString string) (*UserIDClaims, error) {
+	claims := &UserIDClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, jwt.ErrSignatureInvalid
+		}
+		return []byte(config.GetEnv("JWT_KEY", "")), nil
+	})
+	if err != nil || !token.Valid || claims.ID == "" {
+		return nil, errors.New("invalid token")
 	}
+	if !claims.VerifyAudience(AudienceMFAPending, true) {
+		return nil, errors.New("invalid token audience")
+	}
+	return claims, nil
+}
 
+// JWTValidator parses a JWT string and returns the user ID if valid
+func JWTValidator(tokenString string) (int, error) {
+	claims, err := JWTParse(tokenString)
+	if err != nil {
+		return 0, err
+	}
 	return claims.UserID, nil
-}
\ No newline at end of file
+}
+
+// NewTokenID returns a random id for a jti or a refresh token family.
+func NewTokenID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// NewRefreshToken returns a random refresh token and the SHA-256 hash it is stored as.
+func NewRefreshToken() (token, hash string) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, HashRefreshToken(token)
+}
+
+// HashRefreshToken returns the hex SHA-256 of a refresh token.
+func HashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,234 @@
+package internal
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LocalBlobPath is the gateway route serving the signed URLs of the local store.
+const LocalBlobPath = "/storage/blob"
+
+// ErrInvalidSignature is returned when a local signed URL is forged, altered or expired.
+var ErrInvalidSignature = errors.New("invalid or expired signature")
+
+// LocalBlobStore keeps objects on the local disk and signs URLs pointing back to the gateway.
+// It lets the gateway run without Google credentials (development, tests).
+type LocalBlobStore struct {
+	dir        string
+	publicURL  string
+	signingKey []byte
+}
+
+// localBlobMeta is stored next to each object, since the file system has no content type.
+type localBlobMeta struct {
+	ContentType string `json:"content_type"`
+	MD5         string `json:"md5"`
+}
+
+// NewLocalBlobStore creates the store in dir. publicURL is the base URL clients reach the gateway at.
+func NewLocalBlobStore(dir, publicURL string, signingKey []byte) (*LocalBlobStore, error) {
+	for _, sub := range []string{"objects", "meta"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create storage directory: %w", err)
+		}
+	}
+	return &LocalBlobStore{
+		dir:        dir,
+		publicURL:  strings.TrimRight(publicURL, "/"),
+		signingKey: signingKey,
+	}, nil
+}
+
+// objectPath maps an object name to a single file name, so names can't escape the store directory.
+func (s *LocalBlobStore) objectPath(sub, objectName string) (string, error) {
+	name := url.PathEscape(objectName)
+	if name == "" || name == "." || name == ".." {
+		return "", fmt.Errorf("invalid object name %q", objectName)
+	}
+	return filepath.Join(s.dir, sub, name), nil
+}
+
+func (s *LocalBlobStore) signature(method, objectName, contentType string, expires int64) string {
+	mac := hmac.New(sha256.New, s.signingKey)
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%d", method, objectName, contentType, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *LocalBlobStore) signedURL(method, objectName, contentType string, expires time.Duration) string {
+	expiresAt := time.Now().Add(expires).Unix()
+	query := url.Values{}
+	query.Set("name", objectName)
+	query.Set("method", method)
+	query.Set("expires", strconv.FormatInt(expiresAt, 10))
+	query.Set("signature", s.signature(method, objectName, contentType, expiresAt))
+	return s.publicURL + LocalBlobPath + "?" + query.Encode()
+}
+
+func (s *LocalBlobStore) SignedReadURL(objectName string, expires time.Duration) (string, error) {
+	return s.signedURL("GET", objectName, "", expires), nil
+}
+
+func (s *LocalBlobStore) SignedWriteURL(objectName, contentType string, expires time.Duration) (string, error) {
+	return s.signedURL("PUT", objectName, contentType, expires), nil
+}
+
+// VerifySignedURL checks the query of a signed URL for the request method.
+// contentType is the Content-Type of the request, only checked for uploads.
+// It returns the object name the URL was signed for.
+func (s *LocalBlobStore) VerifySignedURL(method string, query url.Values, contentType string) (string, error) {
+	if query.Get("method") != method {
+		return "", ErrInvalidSignature
+	}
+	expiresAt, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil || time.Now().Unix() > expiresAt {
+		return "", ErrInvalidSignature
+	}
+	if method != "PUT" {
+		contentType = ""
+	}
+	objectName := query.Get("name")
+	expected := s.signature(method, objectName, contentType, expiresAt)
+	if !hmac.Equal([]byte(expected), []byte(query.Get("signature"))) {
+		return "", ErrInvalidSignature
+	}
+	return objectName, nil
+}
+
+func (s *LocalBlobStore) Put(ctx context.Context, objectName, contentType string, r io.Reader) error {
+	objectPath, err := s.objectPath("objects", objectName)
+	if err != nil {
+		return err
+	}
+	metaPath, err := s.objectPath("meta", objectName)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first, so readers never see a partial object.
+	tmp, err := os.CreateTemp(filepath.Dir(objectPath), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := md5.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, hash), r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	meta, err := json.Marshal(localBlobMeta{ContentType: contentType, MD5: hex.EncodeToString(hash.Sum(nil))})
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(metaPath, meta, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), objectPath)
+}
+
+func (s *LocalBlobStore) Get(ctx context.Context, objectName string) (io.ReadCloser, error) {
+	objectPath, err := s.objectPath("objects", objectName)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(objectPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrBlobNotFound
+	}
+	return f, err
+}
+
+func (s *LocalBlobStore) Delete(ctx context.Context, objectName string) error {
+	objectPath, err := s.objectPath("objects", objectName)
+	if err != nil {
+		return err
+	}
+	metaPath, err := s.objectPath("meta", objectName)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(objectPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrBlobNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(metaPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *LocalBlobStore) Stat(ctx context.Context, objectName string) (BlobAttrs, error) {
+	objectPath, err := s.objectPath("objects", objectName)
+	if err != nil {
+		return BlobAttrs{}, err
+	}
+	metaPath, err := s.objectPath("meta", objectName)
+	if err != nil {
+		return BlobAttrs{}, err
+	}
+	info, err := os.Stat(objectPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return BlobAttrs{}, ErrBlobNotFound
+	}
+	if err != nil {
+		return BlobAttrs{}, err
+	}
+
+	attrs := BlobAttrs{Size: info.Size(), Updated: info.ModTime()}
+	if data, err := os.ReadFile(metaPath); err == nil {
+		var meta localBlobMeta
+		if err := json.Unmarshal(data, &meta); err == nil {
+			attrs.ContentType = meta.ContentType
+			attrs.MD5, _ = hex.DecodeString(meta.MD5)
+		}
+	}
+	return attrs, nil
+}
+
+func (s *LocalBlobStore) List(ctx context.Context, prefix string, fn func(objectName string, attrs BlobAttrs) error) error {
+	entries, err := os.ReadDir(filepath.Join(s.dir, "objects"))
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		// Skip the temporary files of the uploads in progress
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".upload-") {
+			continue
+		}
+		objectName, err := url.PathUnescape(entry.Name())
+		if err != nil || !strings.HasPrefix(objectName, prefix) {
+			continue
+		}
+		attrs, err := s.Stat(ctx, objectName)
+		if errors.Is(err, ErrBlobNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(objectName, attrs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
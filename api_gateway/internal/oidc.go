@@ -0,0 +1,330 @@
+package internal
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/config"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// ErrInvalidOIDCIDToken is returned for ID tokens that are malformed, expired, not signed by the provider,
+// issued to another client or for another login (nonce).
+var ErrInvalidOIDCIDToken = errors.New("invalid OIDC ID token")
+
+// RoleMapping grants Role to the accounts in Group.
+type RoleMapping struct {
+	Group string
+	Role  string
+}
+
+// OIDCProvider is an OpenID Connect identity provider (Keycloak, Azure AD, ...) users sign in with,
+// using the authorization code flow with PKCE.
+type OIDCProvider struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string   // The gateway callback, /auth/oidc/<name>/callback
+	PostLoginURL string   // Where the browser is sent with the tokens after the callback, JSON response if empty
+	Scopes       []string // openid is always requested
+	GroupsClaim  string   // Claim of the ID token listing the groups of the user
+	RoleMap      []RoleMapping
+	TrustEmail   bool // Link accounts by email even if the provider doesn't say it verified it
+
+	client *http.Client
+
+	mu        sync.Mutex
+	discovery *oidcDiscovery
+	keys      *JWKS
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// OIDCIdentity is the user an ID token was issued for.
+type OIDCIdentity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Groups        []string
+}
+
+// OIDCProviders are the providers configured by InitOIDCProviders, by name.
+var OIDCProviders = map[string]*OIDCProvider{}
+
+// InitOIDCProviders loads the providers listed in OIDC_PROVIDERS (comma-separated names).
+// Each provider is configured by OIDC_<NAME>_ISSUER, _CLIENT_ID, _CLIENT_SECRET, _REDIRECT_URL,
+// _POST_LOGIN_URL, _SCOPES, _GROUPS_CLAIM, _ROLE_MAP ("group=role,...") and _TRUST_EMAIL.
+func InitOIDCProviders() error {
+	providers := map[string]*OIDCProvider{}
+	for _, name := range strings.Split(config.GetEnv("OIDC_PROVIDERS", ""), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		env := func(key, fallback string) string {
+			return config.GetEnv("OIDC_"+strings.ToUpper(name)+"_"+key, fallback)
+		}
+
+		provider := &OIDCProvider{
+			Name:         name,
+			Issuer:       env("ISSUER", ""),
+			ClientID:     env("CLIENT_ID", ""),
+			ClientSecret: env("CLIENT_SECRET", ""),
+			RedirectURL:  env("REDIRECT_URL", ""),
+			PostLoginURL: env("POST_LOGIN_URL", ""),
+			Scopes:       strings.FieldsFunc(env("SCOPES", "openid email profile"), func(r rune) bool { return r == ' ' || r == ',' }),
+			GroupsClaim:  env("GROUPS_CLAIM", "groups"),
+		}
+		if provider.Issuer == "" || provider.ClientID == "" || provider.RedirectURL == "" {
+			return fmt.Errorf("OIDC provider %q needs an issuer, a client id and a redirect URL", name)
+		}
+		trustEmail, err := strconv.ParseBool(env("TRUST_EMAIL", "false"))
+		if err != nil {
+			return fmt.Errorf("invalid TRUST_EMAIL for OIDC provider %q: %w", name, err)
+		}
+		provider.TrustEmail = trustEmail
+		if provider.RoleMap, err = ParseRoleMap(env("ROLE_MAP", "")); err != nil {
+			return fmt.Errorf("invalid ROLE_MAP for OIDC provider %q: %w", name, err)
+		}
+		providers[name] = provider
+	}
+	OIDCProviders = providers
+	return nil
+}
+
+// ParseRoleMap parses "group=role,group=role". The first mapping matching a group of the user wins.
+// The admin role can't be mapped: admins are only made by admins. Roles granting admin_only
+// permissions are refused at sign in (see models.SignInExternalIdentity).
+func ParseRoleMap(s string) ([]RoleMapping, error) {
+	var mappings []RoleMapping
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		group, role, ok := strings.Cut(pair, "=")
+		group, role = strings.TrimSpace(group), strings.TrimSpace(role)
+		if !ok || group == "" || role == "" {
+			return nil, fmt.Errorf("expected group=role, got %q", pair)
+		}
+		if role == "admin" {
+			return nil, fmt.Errorf("group %q can't be mapped to the admin role", group)
+		}
+		mappings = append(mappings, RoleMapping{Group: group, Role: role})
+	}
+	return mappings, nil
+}
+
+// MapRole returns the role granted to a user in groups, or "" if no mapping matches.
+func (p *OIDCProvider) MapRole(groups []string) string {
+	for _, m := range p.RoleMap {
+		for _, g := range groups {
+			if g == m.Group {
+				return m.Role
+			}
+		}
+	}
+	return ""
+}
+
+func (p *OIDCProvider) httpClient() *http.Client {
+	if p.client == nil {
+		return &http.Client{Timeout: 10 * time.Second}
+	}
+	return p.client
+}
+
+// discover fetches the provider metadata once.
+func (p *OIDCProvider) discover(ctx context.Context) (*oidcDiscovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(p.Issuer, "/")+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.httpClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch OIDC configuration: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch OIDC configuration: %s", resp.Status)
+	}
+	var d oidcDiscovery
+	if err := json.NewDecoder(resp.Body).Decode(&d); err != nil {
+		return nil, fmt.Errorf("failed to decode OIDC configuration: %w", err)
+	}
+	if d.Issuer != p.Issuer {
+		return nil, fmt.Errorf("OIDC configuration is for issuer %q, expected %q", d.Issuer, p.Issuer)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return nil, errors.New("OIDC configuration misses an endpoint")
+	}
+	p.discovery = &d
+	p.keys = NewJWKS(d.JWKSURI)
+	return p.discovery, nil
+}
+
+// AuthCodeURL returns the URL of the provider login page. The provider redirects back to RedirectURL
+// with state and a code to Exchange with codeVerifier.
+func (p *OIDCProvider) AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	scopes := p.Scopes
+	hasOpenID := false
+	for _, s := range scopes {
+		hasOpenID = hasOpenID || s == "openid"
+	}
+	if !hasOpenID {
+		scopes = append([]string{"openid"}, scopes...)
+	}
+
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", p.ClientID)
+	query.Set("redirect_uri", p.RedirectURL)
+	query.Set("scope", strings.Join(scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", PKCEChallenge(codeVerifier))
+	query.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return d.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange redeems an authorization code and returns the identity in the verified ID token.
+func (p *OIDCProvider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*OIDCIdentity, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.RedirectURL)
+	form.Set("client_id", p.ClientID)
+	form.Set("code_verifier", codeVerifier)
+	if p.ClientSecret != "" {
+		form.Set("client_secret", p.ClientSecret)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.httpClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to redeem the authorization code: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var token struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return nil, fmt.Errorf("failed to decode the token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || token.IDToken == "" {
+		return nil, fmt.Errorf("failed to redeem the authorization code: %s %s %s", resp.Status, token.Error, token.ErrorDescription)
+	}
+	return p.VerifyIDToken(ctx, token.IDToken, nonce)
+}
+
+// VerifyIDToken checks the signature, issuer, audience, expiry and nonce of an ID token.
+func (p *OIDCProvider) VerifyIDToken(ctx context.Context, idToken, nonce string) (*OIDCIdentity, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := jwt.MapClaims{}
+	if _, err := jwt.ParseWithClaims(idToken, claims, p.keys.Keyfunc(ctx)); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidOIDCIDToken, err)
+	}
+	if !claims.VerifyIssuer(d.Issuer, true) {
+		return nil, fmt.Errorf("%w: unexpected issuer", ErrInvalidOIDCIDToken)
+	}
+	if !claims.VerifyAudience(p.ClientID, true) {
+		return nil, fmt.Errorf("%w: issued to another client", ErrInvalidOIDCIDToken)
+	}
+	if _, ok := claims["exp"]; !ok {
+		return nil, fmt.Errorf("%w: missing exp", ErrInvalidOIDCIDToken)
+	}
+	if got, _ := claims["nonce"].(string); got != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidOIDCIDToken)
+	}
+
+	identity := &OIDCIdentity{}
+	identity.Subject, _ = claims["sub"].(string)
+	if identity.Subject == "" {
+		return nil, fmt.Errorf("%w: missing sub", ErrInvalidOIDCIDToken)
+	}
+	identity.Email, _ = claims["email"].(string)
+	switch v := claims["email_verified"].(type) {
+	case bool:
+		identity.EmailVerified = v
+	case string:
+		identity.EmailVerified, _ = strconv.ParseBool(v)
+	}
+	identity.Name, _ = claims["name"].(string)
+	if identity.Name == "" {
+		identity.Name, _ = claims["preferred_username"].(string)
+	}
+	switch groups := claims[p.GroupsClaim].(type) {
+	case string:
+		identity.Groups = []string{groups}
+	case []interface{}:
+		for _, g := range groups {
+			if s, ok := g.(string); ok {
+				identity.Groups = append(identity.Groups, s)
+			}
+		}
+	}
+	return identity, nil
+}
+
+// NewPKCEVerifier returns a random PKCE code verifier.
+func NewPKCEVerifier() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// PKCEChallenge returns the S256 code challenge of a code verifier.
+func PKCEChallenge(codeVerifier string) string {
+	sum := sha256.Sum256([]byte(codeVerifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
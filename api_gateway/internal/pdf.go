@@ -0,0 +1,86 @@
+package internal
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ledongthuc/pdf"
+)
+
+var (
+	// ErrNotPDF is returned for files that don't start with the PDF magic bytes or can't be parsed.
+	ErrNotPDF = errors.New("file is not a valid PDF")
+	// ErrPDFTooLarge is returned for files over the size limit.
+	ErrPDFTooLarge = errors.New("file is too large")
+)
+
+var pdfMagic = []byte("%PDF-")
+
+// SpooledPDF is an uploaded PDF written to a temporary file, with its size and SHA-256.
+type SpooledPDF struct {
+	File   *os.File
+	Size   int64
+	SHA256 string
+}
+
+// Remove closes and deletes the temporary file.
+func (p *SpooledPDF) Remove() {
+	p.File.Close()
+	os.Remove(p.File.Name())
+}
+
+// SpoolPDF copies r to a temporary file while hashing it. It stops as soon as the
+// first bytes are not the PDF magic bytes or more than maxSize bytes were read.
+func SpoolPDF(r io.Reader, maxSize int64) (*SpooledPDF, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(len(pdfMagic))
+	if err != nil || string(magic) != string(pdfMagic) {
+		return nil, ErrNotPDF
+	}
+
+	tmp, err := os.CreateTemp("", "upload-*.pdf")
+	if err != nil {
+		return nil, err
+	}
+	spooled := &SpooledPDF{File: tmp}
+
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(tmp, hash), io.LimitReader(br, maxSize+1))
+	if err != nil {
+		spooled.Remove()
+		return nil, err
+	}
+	if n > maxSize {
+		spooled.Remove()
+		return nil, ErrPDFTooLarge
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		spooled.Remove()
+		return nil, err
+	}
+
+	spooled.Size = n
+	spooled.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	return spooled, nil
+}
+
+// CountPDFPages parses the PDF and returns its number of pages.
+func CountPDFPages(r io.ReaderAt, size int64) (pages int, err error) {
+	// The parser panics on some malformed files.
+	defer func() {
+		if recover() != nil {
+			pages, err = 0, ErrNotPDF
+		}
+	}()
+
+	reader, err := pdf.NewReader(r, size)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrNotPDF, err)
+	}
+	return reader.NumPage(), nil
+}
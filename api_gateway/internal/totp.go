@@ -0,0 +1,133 @@
+package internal
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/config"
+)
+
+// TOTP parameters (RFC 6238), the defaults of authenticator apps.
+const (
+	TOTPPeriod = 30 * time.Second
+	TOTPDigits = 6
+)
+
+// RecoveryCodeCount is the number of recovery codes an admin gets when enabling TOTP.
+const RecoveryCodeCount = 10
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// AdminMFARequired reports whether admins must enroll TOTP before they can log in (ADMIN_MFA_REQUIRED).
+func AdminMFARequired() bool {
+	required, _ := strconv.ParseBool(config.GetEnv("ADMIN_MFA_REQUIRED", "false"))
+	return required
+}
+
+// TOTPIssuer is the issuer name authenticator apps show next to the account (TOTP_ISSUER).
+func TOTPIssuer() string {
+	return config.GetEnv("TOTP_ISSUER", "TechBot")
+}
+
+// NewTOTPSecret returns a random base32 TOTP secret.
+func NewTOTPSecret() string {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return totpEncoding.EncodeToString(b)
+}
+
+// TOTPProvisioningURI returns the otpauth:// URI authenticator apps enroll from (usually shown as a QR code).
+func TOTPProvisioningURI(secret, accountName, issuer string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(TOTPDigits))
+	query.Set("period", fmt.Sprint(int(TOTPPeriod.Seconds())))
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(accountName)
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// TOTPStep returns the time step t falls in.
+func TOTPStep(t time.Time) int64 {
+	return t.Unix() / int64(TOTPPeriod.Seconds())
+}
+
+// TOTPCode returns the code of a time step.
+func TOTPCode(secret string, step int64) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", fmt.Errorf("invalid TOTP secret: %w", err)
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < TOTPDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", TOTPDigits, value%mod), nil
+}
+
+// VerifyTOTP checks a code at time t, allowing one step of clock drift either way.
+// Steps up to lastStep were already used and are refused, so a code can't be replayed.
+// It returns the step the code matched.
+func VerifyTOTP(secret, code string, t time.Time, lastStep int64) (int64, bool) {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) != TOTPDigits {
+		return 0, false
+	}
+	now := TOTPStep(t)
+	for _, step := range []int64{now - 1, now, now + 1} {
+		if step <= lastStep {
+			continue
+		}
+		expected, err := TOTPCode(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if hmac.Equal([]byte(expected), []byte(code)) {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// NewRecoveryCodes returns n random single-use recovery codes, formatted xxxxx-xxxxx.
+func NewRecoveryCodes(n int) []string {
+	codes := make([]string, n)
+	for i := range codes {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			panic(err)
+		}
+		s := hex.EncodeToString(b)
+		codes[i] = s[:5] + "-" + s[5:]
+	}
+	return codes
+}
+
+// HashRecoveryCode returns the hex SHA-256 a recovery code is stored as.
+// Codes are compared without the dash and case-insensitively.
+func HashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
@@ -1,17 +1,22 @@
 package internal;
 
 import (
-	"math/rand"
+	"crypto/rand"
 	"fmt"
 	"log"
+	"math/big"
 
 	"golang.org/x/crypto/bcrypt"
 );
 
-// generateOTP generates a 6-digit OTP
+// generateOTP generates a 6-digit OTP, from crypto/rand so codes can't be predicted
 func Digit6Random() string {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		panic(err)
+	}
 	//Format the value to string
-	return fmt.Sprintf("%06d", rand.Intn(1000000));
+	return fmt.Sprintf("%06d", n.Int64());
 }
 
 // bcrypt hash generator
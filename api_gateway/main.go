@@ -3,13 +3,18 @@ package main
 import (
 	"github.com/ductruonghoc/DATN_08_2025_Back-end/config"
 	"github.com/ductruonghoc/DATN_08_2025_Back-end/internal"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/middlewares"
 	"github.com/ductruonghoc/DATN_08_2025_Back-end/models"
 	"github.com/ductruonghoc/DATN_08_2025_Back-end/pb"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/retrieval"
 	"github.com/ductruonghoc/DATN_08_2025_Back-end/routes"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/workers"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 
+	"fmt"
 	"log"
+	"strconv"
 	"time"
 )
 
@@ -29,25 +34,102 @@ func main() {
 		log.Fatalf("Could not initialize database connection: %v", err)
 	}
 	defer DB.Close() // Ensure the database connection is closed when main exits.
-	//Google services intergrated
-	internal.CreateStorageClient()
+	// Apply pending schema migrations (models/migrations)
+	if err := models.RunSchemaMigrations(DB); err != nil {
+		log.Fatalf("Could not apply schema migrations: %v", err)
+	}
+	//Blob storage (GCS or local disk, see STORAGE_BACKEND)
+	if err := internal.InitStorage(); err != nil {
+		log.Fatalf("Could not initialize blob storage: %v", err)
+	}
+	//Enterprise SSO (OIDC_PROVIDERS)
+	if err := internal.InitOIDCProviders(); err != nil {
+		log.Fatalf("Could not load OIDC providers: %v", err)
+	}
+	//Rate limits of the auth routes (RATE_LIMIT_STORE)
+	if err := middlewares.InitRateLimitStore(DB); err != nil {
+		log.Fatalf("Could not initialize rate limiting: %v", err)
+	}
+	//Emails are queued in email_outbox and sent by the email worker (MAIL_BACKEND)
+	if err := internal.InitMailer(); err != nil {
+		log.Fatalf("Could not initialize mailer: %v", err)
+	}
+	workers.StartEmailWorker(DB, internal.Mail)
 	//PBClient initialize
 	pb.Init()
 	defer pb.Close()
+	if err := pb.InitAgents(DB); err != nil {
+		log.Fatalf("Could not load OCR agents: %v", err)
+	}
+	//Background PDF extraction jobs
+	extractionWorkers, err := strconv.Atoi(config.GetEnv("EXTRACTION_WORKERS", "1"))
+	if err != nil {
+		log.Fatalf("Invalid EXTRACTION_WORKERS: %v", err)
+	}
+	workers.StartExtractionWorkers(DB, extractionWorkers)
+	//Remove signed URL uploads that were never confirmed
+	uploadTTL, err := time.ParseDuration(config.GetEnv("UPLOAD_PENDING_TTL", "24h"))
+	if err == nil && uploadTTL <= 0 {
+		err = fmt.Errorf("must be positive")
+	}
+	if err != nil {
+		log.Fatalf("Invalid UPLOAD_PENDING_TTL: %v", err)
+	}
+	reaperInterval, err := time.ParseDuration(config.GetEnv("UPLOAD_REAPER_INTERVAL", "10m"))
+	if err == nil && reaperInterval <= 0 {
+		err = fmt.Errorf("must be positive")
+	}
+	if err != nil {
+		log.Fatalf("Invalid UPLOAD_REAPER_INTERVAL: %v", err)
+	}
+	sweepStray, err := strconv.ParseBool(config.GetEnv("UPLOAD_REAPER_SWEEP_STRAY", "false"))
+	if err != nil {
+		log.Fatalf("Invalid UPLOAD_REAPER_SWEEP_STRAY: %v", err)
+	}
+	workers.StartUploadReaper(DB, uploadTTL, reaperInterval, sweepStray)
+	//Drop expired refresh tokens and access token denylist entries
+	workers.StartTokenCleanup(DB, time.Hour)
+	//Cache of the RAG answers per device (ANSWER_CACHE_TTL, 0 disables it)
+	answerCacheTTL, err := time.ParseDuration(config.GetEnv("ANSWER_CACHE_TTL", "24h"))
+	if err == nil && answerCacheTTL < 0 {
+		err = fmt.Errorf("must not be negative")
+	}
+	if err != nil {
+		log.Fatalf("Invalid ANSWER_CACHE_TTL: %v", err)
+	}
+	answerCacheSimilarity, err := strconv.ParseFloat(config.GetEnv("ANSWER_CACHE_MIN_SIMILARITY", "0.95"), 64)
+	if err == nil && (answerCacheSimilarity < 0 || answerCacheSimilarity > 1) {
+		err = fmt.Errorf("must be between 0 and 1")
+	}
+	if err != nil {
+		log.Fatalf("Invalid ANSWER_CACHE_MIN_SIMILARITY: %v", err)
+	}
+	var answerCache *retrieval.AnswerCache
+	if answerCacheTTL > 0 {
+		answerCache = &retrieval.AnswerCache{
+			DB:            DB,
+			Embedder:      retrieval.AgentEmbedder{},
+			TTL:           answerCacheTTL,
+			MinSimilarity: answerCacheSimilarity,
+		}
+		workers.StartAnswerCacheCleanup(DB, time.Hour)
+	}
 
 	r := gin.Default()
+	// Request ids, sent back and recorded with audit events
+	r.Use(middlewares.RequestID())
 
 	// Allow all CORS (dev only)
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", middlewares.RequestIDHeader},
+		ExposeHeaders:    []string{"Content-Length", middlewares.RequestIDHeader},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
 	// Register all routes
-	routes.RegisterRoutes(r, DB)
+	routes.RegisterRoutes(r, DB, answerCache)
 
 	// Start server
 	port := config.GetEnv("GATEWAY_PORT", "8080")
@@ -2,13 +2,18 @@ package middlewares
 
 import (
 	"database/sql"
+	"errors"
 	"net/http"
 
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/config"
 	"github.com/ductruonghoc/DATN_08_2025_Back-end/internal"
 	"github.com/ductruonghoc/DATN_08_2025_Back-end/models"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/workers"
 
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
@@ -102,7 +107,8 @@ func StoreTemporatoryUser(db *sql.DB) gin.HandlerFunc {
 			ON CONFLICT (email) DO UPDATE
 			SET password = EXCLUDED.password,
 				otp = EXCLUDED.otp,
-				otp_generated_time = EXCLUDED.otp_generated_time;
+				otp_generated_time = EXCLUDED.otp_generated_time,
+				otp_attempts = 0;
 		`
 		rows, err := db.Query(query, email, hashed_password, hashed_otp, otp.OTPWasGeneratedAt) // Using a placeholder for the argument
 		if err != nil {
@@ -116,7 +122,9 @@ func StoreTemporatoryUser(db *sql.DB) gin.HandlerFunc {
 	}
 }
 
-func SendOTP() gin.HandlerFunc {
+// SendOTP generates an OTP, sets it as "otp" and queues the email carrying it
+// (in the language of the Accept-Language header).
+func SendOTP(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req struct {
 			Email string `json:"email"`
@@ -143,8 +151,10 @@ func SendOTP() gin.HandlerFunc {
 		otp.OTPCode = otpCode
 		otp.OTPWasGeneratedAt = time.Now()
 
-		//email otp
-		if err := internal.EmailOTP(req.Email, otp.OTPCode); err != nil {
+		//email otp, sent by the email worker
+		err := workers.QueueEmail(db, internal.EmailKindOTP, internal.EmailLanguage(c.GetHeader("Accept-Language")), req.Email,
+			internal.OTPEmailData{Code: otp.OTPCode, ValidHours: int(OTPValidity.Hours())})
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send OTP"})
 			c.Abort()
 			return
@@ -155,6 +165,61 @@ func SendOTP() gin.HandlerFunc {
 	}
 }
 
+// OTPResendCooldown refuses to send a new OTP to an email that got one less than OTP_RESEND_COOLDOWN
+// (1 minute by default) ago. Use it before SendOTP; destination_table_index is that of VeirifyOTP.
+func OTPResendCooldown(db *sql.DB, destination_table_index int) gin.HandlerFunc {
+	destination_table_slice := []string{
+		`"user"`,
+		"temp_user",
+	}
+	return func(c *gin.Context) {
+		var req struct {
+			Email string `json:"email"`
+		}
+		if err := c.ShouldBindBodyWithJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+			c.Abort()
+			return
+		}
+
+		var generatedAt sql.NullTime
+		query := fmt.Sprintf(`select otp_generated_time from %s where email = $1;`, destination_table_slice[destination_table_index])
+		err := db.QueryRow(query, req.Email).Scan(&generatedAt)
+		if err != nil && err != sql.ErrNoRows {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Can't query"})
+			c.Abort()
+			return
+		}
+		if generatedAt.Valid {
+			if wait := otpResendCooldown() - time.Since(generatedAt.Time); wait > 0 {
+				tooManyRequests(c, "An OTP was sent recently, try again later", wait)
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// OTPValidity is how long an OTP can be used.
+const OTPValidity = 2 * time.Hour
+
+// OTPMaxAttempts is the number of times an OTP can be tried (OTP_MAX_ATTEMPTS, 5 by default).
+func OTPMaxAttempts() int {
+	n, err := strconv.Atoi(config.GetEnv("OTP_MAX_ATTEMPTS", "5"))
+	if err != nil || n < 1 {
+		return 5
+	}
+	return n
+}
+
+func otpResendCooldown() time.Duration {
+	d, err := time.ParseDuration(config.GetEnv("OTP_RESEND_COOLDOWN", "1m"))
+	if err != nil || d < 0 {
+		return time.Minute
+	}
+	return d
+}
+
 func VeirifyOTP(db *sql.DB, destination_table_index int) gin.HandlerFunc {
 	destination_table_slice := []string{
 		`"user"`,
@@ -175,10 +240,16 @@ func VeirifyOTP(db *sql.DB, destination_table_index int) gin.HandlerFunc {
 		email := req.Email
 		destination_table := destination_table_slice[destination_table_index]
 
+		lockoutKey := "otp:" + strings.ToLower(strings.TrimSpace(email))
+		if !CheckLockout(c, db, lockoutKey) {
+			return
+		}
+
 		query := fmt.Sprintf(`
 			select 
 				otp as otp_code,
-				otp_generated_time as generated_at
+				otp_generated_time as generated_at,
+				otp_attempts
 			from %s
 			where email = $1;
 		`, destination_table)
@@ -191,9 +262,10 @@ func VeirifyOTP(db *sql.DB, destination_table_index int) gin.HandlerFunc {
 		defer rows.Close() // Important to close rows to free resources
 
 		var otp models.OTP
+		var attempts int
 		if rows.Next() { // Advances to the first (and expected only) row.
 			// Could return false here if there's an immediate error fetching the first row.
-			if err := rows.Scan(&otp.OTPCode, &otp.OTPWasGeneratedAt); err != nil {
+			if err := rows.Scan(&otp.OTPCode, &otp.OTPWasGeneratedAt, &attempts); err != nil {
 				// This error is specific to scanning THIS row's data.
 				// e.g., otp_code was NULL and OTPCode is a non-pointer string, or types are incompatible.
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process data"})
@@ -224,7 +296,7 @@ func VeirifyOTP(db *sql.DB, destination_table_index int) gin.HandlerFunc {
 		currentTime := time.Now()
 
 		// Add 2 hours to the input time
-		expirationTime := otp.OTPWasGeneratedAt.Add(2 * time.Hour)
+		expirationTime := otp.OTPWasGeneratedAt.Add(OTPValidity)
 		otp_is_expired := expirationTime.Before(currentTime)
 
 		if otp_is_expired {
@@ -233,34 +305,86 @@ func VeirifyOTP(db *sql.DB, destination_table_index int) gin.HandlerFunc {
 			return
 		}
 
+		// Each OTP can only be tried a few times, then a new one must be sent
+		if attempts >= OTPMaxAttempts() {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many attempts for this OTP, request a new one"})
+			c.Abort()
+			return
+		}
+
 		// Compare the hashed password with the plain text one
 		err = bcrypt.CompareHashAndPassword([]byte(otp.OTPCode), []byte(req.OTPCode))
 		if err != nil {
+			query = fmt.Sprintf(`UPDATE %s SET otp_attempts = otp_attempts + 1 WHERE email = $1`, destination_table)
+			if _, err := db.Exec(query, email); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Can't query"})
+				c.Abort()
+				return
+			}
+			RecordAuthFailure(db, lockoutKey)
 			c.JSON(http.StatusBadRequest, gin.H{"error": "OTP is not match"})
 			c.Abort()
 			return
 		}
 
 		//Successful
+		ClearAuthFailures(db, lockoutKey)
 		c.Set("verified_email", req.Email)
 		c.Next()
 	}
 }
 
-func CheckGoogleUserExisted() gin.HandlerFunc {
+// VerifyGoogleIDToken verifies the Google ID token (id_token) sent by the client against Google's keys,
+// and sets the Google identity it carries as "google_user".
+func VerifyGoogleIDToken() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req struct {
-			GoogleID string `json:"google_id"`
+			IDToken string `json:"id_token"`
 		}
 		//try binding the request
-		if err := c.ShouldBindBodyWithJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		if err := c.ShouldBindBodyWithJSON(&req); err != nil || req.IDToken == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "id_token is required"})
 			c.Abort()
 			return
 		}
 
-		account_existed := false
-		//db query here
+		claims, err := internal.GoogleVerifier().Verify(c.Request.Context(), req.IDToken)
+		if errors.Is(err, internal.ErrInvalidGoogleIDToken) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid Google ID token", "details": err.Error()})
+			c.Abort()
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Could not verify Google ID token", "details": err.Error()})
+			c.Abort()
+			return
+		}
+
+		c.Set("google_user", models.GoogleUser{
+			GoogleID:      claims.Subject,
+			GoogleEmail:   claims.Email,
+			EmailVerified: bool(claims.EmailVerified),
+			Name:          claims.Name,
+		})
+		c.Next()
+	}
+}
+
+// CheckGoogleUserExisted sets "account_existed" for the Google identity set by VerifyGoogleIDToken:
+// an account is linked to it, or has the same verified email.
+func CheckGoogleUserExisted(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		googleUser := c.MustGet("google_user").(models.GoogleUser)
+
+		account_existed := true
+		_, err := models.SelectAccountByGoogleUser(db, googleUser)
+		if errors.Is(err, models.ErrGoogleAccountNotFound) {
+			account_existed = false
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Can't query"})
+			c.Abort()
+			return
+		}
 		c.Set("account_existed", account_existed)
 		c.Next()
 	}
@@ -304,6 +428,11 @@ func UserAuthenticate(db *sql.DB) gin.HandlerFunc {
 		//db query here
 		email := req.Email
 
+		lockoutKey := "login:" + strings.ToLower(strings.TrimSpace(email))
+		if !CheckLockout(c, db, lockoutKey) {
+			return
+		}
+
 		query := `
 			select password
 			from "user"
@@ -333,6 +462,7 @@ func UserAuthenticate(db *sql.DB) gin.HandlerFunc {
 			// 1. No rows were found (sql.ErrNoRows if using QueryRow().Scan(), but with raw Next() it's just 'false').
 			// 2. An error occurred trying to fetch the first row.
 			// This 'else' block in your code assumes it's "No rows found".
+			RecordAuthFailure(db, lockoutKey)
 			c.JSON(http.StatusNotFound, gin.H{"error": "Password not found", "email": email})
 			c.Abort()
 			return
@@ -350,10 +480,30 @@ func UserAuthenticate(db *sql.DB) gin.HandlerFunc {
 		// Compare the hashed password with the plain text one
 		err = bcrypt.CompareHashAndPassword([]byte(password), []byte(req.Password))
 		if err != nil {
+			RecordAuthFailure(db, lockoutKey)
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Password is not match"})
 			c.Abort()
 			return
 		}
+		ClearAuthFailures(db, lockoutKey)
+
+		state, err := models.SelectAccountLoginState(db, email)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process data"})
+			c.Abort()
+			return
+		}
+		if state.Disabled {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Account is disabled"})
+			c.Abort()
+			return
+		}
+		if state.PasswordResetRequired {
+			// The client sends the user through can_reset_password and reset_password
+			c.JSON(http.StatusForbidden, gin.H{"error": "Password reset required", "password_reset_required": true})
+			c.Abort()
+			return
+		}
 
 		query = `
 			select id
@@ -413,63 +563,25 @@ func UserExistedFirst() gin.HandlerFunc {
 	}
 }
 
-// AuthorizationMiddleware returns a gin.HandlerFunc with required permission as input
+// Authorization returns a gin.HandlerFunc letting through requests whose account has any of the permissions.
+// With no permissions, requests without a token pass too (optional authentication).
 func Authorization(requiredPermissions []string) gin.HandlerFunc {
 	db := models.DB
 	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-
-
 		// Special case: No permission required and no token provided
-        if (len(requiredPermissions) == 0) && authHeader == "" {
-            c.Next()
-            return
-        }
-
-		if authHeader == "" {
-			c.JSON(401, gin.H{"success": false, "message": "Missing Authorization header"})
-			c.Abort()
-			return
-		}
-
-		var tokenString string
-		if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
-			tokenString = authHeader[7:]
-		} else {
-			c.JSON(401, gin.H{"success": false, "message": "Invalid Authorization header"})
-			c.Abort()
+		if len(requiredPermissions) == 0 && c.GetHeader("Authorization") == "" {
+			c.Next()
 			return
 		}
 
-		accountID, err := internal.JWTValidator(tokenString)
-		if err != nil {
-			c.JSON(401, gin.H{"success": false, "message": "Invalid or expired token", "error": err.Error()})
-			c.Abort()
+		claims, access, ok := authenticate(c, db)
+		if !ok {
 			return
 		}
-
-
-		// If requiredPermissions is not empty, check role from DB
-		
-		if requiredPermissions != nil || len(requiredPermissions) > 0 {
-			var roleLabel string
-			query := `
-				SELECT r.label
-				FROM account a
-				JOIN role r ON a.role_id = r.id
-				WHERE a.id = $1
-				LIMIT 1
-			`
-			err := db.QueryRow(query, accountID).Scan(&roleLabel)
-			if err != nil {
-				c.JSON(403, gin.H{"success": false, "message": "Permission denied: cannot retrieve role", "error": err.Error()}, )
-				c.Abort()
-				return
-			}
-			// Check if roleLabel is in requiredPermissions
+		if len(requiredPermissions) > 0 {
 			allowed := false
 			for _, perm := range requiredPermissions {
-				if roleLabel == perm {
+				if access.allows(perm, isAdminToken(claims)) {
 					allowed = true
 					break
 				}
@@ -480,13 +592,148 @@ func Authorization(requiredPermissions []string) gin.HandlerFunc {
 				return
 			}
 		}
+		setAuthContext(c, claims, access)
+		c.Next()
+	}
+}
 
-		// Set accountID and role to context for downstream handlers
-		c.Set("account_id", accountID)
+// RequirePermission returns a gin.HandlerFunc letting through requests whose account has all the permissions,
+// e.g. RequirePermission(models.PermissionPDFEdit). With no permissions it only requires a valid token.
+func RequirePermission(permissions ...string) gin.HandlerFunc {
+	db := models.DB
+	return func(c *gin.Context) {
+		// A route group may have authenticated the request already
+		if _, done := c.Get("permissions"); !done {
+			claims, access, ok := authenticate(c, db)
+			if !ok {
+				return
+			}
+			setAuthContext(c, claims, access)
+		}
+		for _, perm := range permissions {
+			if !HasPermission(c, perm) {
+				c.JSON(403, gin.H{"success": false, "message": "Forbidden: missing permission " + perm})
+				c.Abort()
+				return
+			}
+		}
 		c.Next()
 	}
 }
 
+// RequireUserToken returns a gin.HandlerFunc refusing the tokens issued by admin_login. It must follow
+// RequirePermission, which authenticates the request.
+func RequireUserToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("audience") != internal.AudienceUser {
+			c.JSON(403, gin.H{"success": false, "message": "Forbidden: admin tokens are not accepted here"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// authenticate checks the bearer token of the request and loads the current access of its account.
+// On failure it aborts the request and returns false.
+func authenticate(c *gin.Context, db *sql.DB) (*internal.UserIDClaims, accountAccess, bool) {
+	authHeader := c.GetHeader("Authorization")
+	if authHeader == "" {
+		c.JSON(401, gin.H{"success": false, "message": "Missing Authorization header"})
+		c.Abort()
+		return nil, accountAccess{}, false
+	}
+
+	var tokenString string
+	if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
+		tokenString = authHeader[7:]
+	} else {
+		c.JSON(401, gin.H{"success": false, "message": "Invalid Authorization header"})
+		c.Abort()
+		return nil, accountAccess{}, false
+	}
+
+	claims, err := internal.JWTParse(tokenString)
+	if err != nil {
+		c.JSON(401, gin.H{"success": false, "message": "Invalid or expired token", "error": err.Error()})
+		c.Abort()
+		return nil, accountAccess{}, false
+	}
+
+	// Reject access tokens revoked by a logout
+	revoked, err := revokedTokens.isRevoked(db, claims.ID)
+	if err != nil {
+		c.JSON(500, gin.H{"success": false, "message": "Failed to check token revocation", "error": err.Error()})
+		c.Abort()
+		return nil, accountAccess{}, false
+	}
+	if revoked {
+		c.JSON(401, gin.H{"success": false, "message": "Token has been revoked"})
+		c.Abort()
+		return nil, accountAccess{}, false
+	}
+
+	// The role and token version in the claims must still be current.
+	// They come from a short-lived cache, so demotions and token_version bumps apply quickly.
+	access, err := accountRoles.get(db, claims.UserID)
+	if err == sql.ErrNoRows {
+		c.JSON(401, gin.H{"success": false, "message": "Account not found"})
+		c.Abort()
+		return nil, accountAccess{}, false
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"success": false, "message": "Permission denied: cannot retrieve role", "error": err.Error()})
+		c.Abort()
+		return nil, accountAccess{}, false
+	}
+	if access.Disabled {
+		c.JSON(403, gin.H{"success": false, "message": "Account is disabled"})
+		c.Abort()
+		return nil, accountAccess{}, false
+	}
+	if access.TokenVersion != claims.TokenVersion || access.Role != claims.Role {
+		c.JSON(401, gin.H{"success": false, "message": "Token is outdated, please refresh or log in again"})
+		c.Abort()
+		return nil, accountAccess{}, false
+	}
+	return claims, access, true
+}
+
+// isAdminToken reports whether the token was issued by admin_login.
+func isAdminToken(claims *internal.UserIDClaims) bool {
+	return claims.VerifyAudience(internal.AudienceAdmin, true)
+}
+
+// setAuthContext sets the account of the request for downstream handlers.
+func setAuthContext(c *gin.Context, claims *internal.UserIDClaims, access accountAccess) {
+	c.Set("account_id", claims.UserID)
+	c.Set("role", claims.Role)
+	// The permissions this token grants, see HasPermission
+	granted := make(map[string]bool, len(access.permissions))
+	for perm := range access.permissions {
+		if access.allows(perm, isAdminToken(claims)) {
+			granted[perm] = true
+		}
+	}
+	c.Set("permissions", granted)
+	// The audience of the token, for the sessions issued in its place
+	if isAdminToken(claims) {
+		c.Set("audience", internal.AudienceAdmin)
+	} else {
+		c.Set("audience", internal.AudienceUser)
+	}
+	// The token itself, for logout
+	c.Set("jti", claims.ID)
+	c.Set("token_expires_at", claims.ExpiresAt.Time)
+}
+
+// HasPermission reports whether the authenticated account of the request has the permission.
+func HasPermission(c *gin.Context, permission string) bool {
+	granted, _ := c.Get("permissions")
+	permissions, _ := granted.(map[string]bool)
+	return permissions[permission]
+}
+
 func AdminAuthenticate(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req struct {
@@ -508,6 +755,11 @@ func AdminAuthenticate(db *sql.DB) gin.HandlerFunc {
 		//db query here
 		username := req.Username
 
+		lockoutKey := "admin_login:" + strings.ToLower(strings.TrimSpace(username))
+		if !CheckLockout(c, db, lockoutKey) {
+			return
+		}
+
 		query := `
 			select a.password 
 			from "admin" a 
@@ -546,6 +798,7 @@ func AdminAuthenticate(db *sql.DB) gin.HandlerFunc {
 			// 1. No rows were found (sql.ErrNoRows if using QueryRow().Scan(), but with raw Next() it's just 'false').
 			// 2. An error occurred trying to fetch the first row.
 			// This 'else' block in your code assumes it's "No rows found".
+			RecordAuthFailure(db, lockoutKey)
 			c.JSON(http.StatusNotFound, gin.H{
 				"success": false,
 				"message": "Password not found",
@@ -571,6 +824,7 @@ func AdminAuthenticate(db *sql.DB) gin.HandlerFunc {
 		// Compare the hashed password with the plain text one
 		err = bcrypt.CompareHashAndPassword([]byte(password), []byte(req.Password))
 		if err != nil {
+			RecordAuthFailure(db, lockoutKey)
 			c.JSON(http.StatusBadRequest, gin.H{
 				"success": false,
 				"message": "Password is not match",
@@ -579,6 +833,27 @@ func AdminAuthenticate(db *sql.DB) gin.HandlerFunc {
 			c.Abort()
 			return
 		}
+		ClearAuthFailures(db, lockoutKey)
+
+		state, err := models.SelectAccountLoginState(db, username)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "Failed to process data",
+				"error": err.Error(),
+			})
+			c.Abort()
+			return
+		}
+		if state.Disabled {
+			c.JSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"message": "Account is disabled",
+				"error": models.ErrAccountDisabled.Error(),
+			})
+			c.Abort()
+			return
+		}
 
 		query = `
 			select id
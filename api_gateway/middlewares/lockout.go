@@ -0,0 +1,105 @@
+package middlewares
+
+import (
+	"database/sql"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/config"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/models"
+	"github.com/gin-gonic/gin"
+)
+
+// LockoutPolicy locks a key (an email, an admin...) out after Threshold failed attempts in a row:
+// for Base, then twice as long with each further failure, up to Max.
+// Failures are forgotten Window after the last one.
+type LockoutPolicy struct {
+	Threshold int
+	Base      time.Duration
+	Max       time.Duration
+	Window    time.Duration
+}
+
+// LockFor returns how long a key with this many failures in a row is locked out.
+func (p LockoutPolicy) LockFor(failures int) time.Duration {
+	if failures < p.Threshold {
+		return 0
+	}
+	d := p.Base
+	for i := p.Threshold; i < failures && d < p.Max; i++ {
+		d *= 2
+	}
+	return min(d, p.Max)
+}
+
+var (
+	lockoutPolicy     LockoutPolicy
+	lockoutPolicyOnce sync.Once
+)
+
+// lockout returns the policy read from the environment on first use, after config.LoadEnv.
+func lockout() LockoutPolicy {
+	lockoutPolicyOnce.Do(func() {
+		lockoutPolicy = lockoutPolicyFromEnv()
+	})
+	return lockoutPolicy
+}
+
+// lockoutPolicyFromEnv reads LOCKOUT_THRESHOLD (5), LOCKOUT_BASE (1m), LOCKOUT_MAX (1h) and LOCKOUT_WINDOW (1h).
+func lockoutPolicyFromEnv() LockoutPolicy {
+	threshold, err := strconv.Atoi(config.GetEnv("LOCKOUT_THRESHOLD", "5"))
+	if err != nil || threshold < 1 {
+		threshold = 5
+	}
+	duration := func(key string, fallback time.Duration) time.Duration {
+		d, err := time.ParseDuration(config.GetEnv(key, ""))
+		if err != nil || d <= 0 {
+			return fallback
+		}
+		return d
+	}
+	return LockoutPolicy{
+		Threshold: threshold,
+		Base:      duration("LOCKOUT_BASE", time.Minute),
+		Max:       duration("LOCKOUT_MAX", time.Hour),
+		Window:    duration("LOCKOUT_WINDOW", time.Hour),
+	}
+}
+
+// CheckLockout refuses the request with 429 while key is locked out, and returns false then.
+func CheckLockout(c *gin.Context, db *sql.DB, key string) bool {
+	remaining, err := models.SelectAuthLockout(db, key)
+	if err != nil {
+		c.JSON(500, gin.H{"success": false, "message": "Failed to check lockout", "error": err.Error()})
+		c.Abort()
+		return false
+	}
+	if remaining > 0 {
+		tooManyRequests(c, "Too many failed attempts, try again later", remaining)
+		return false
+	}
+	return true
+}
+
+// RecordAuthFailure counts a failed attempt of key and locks it out once the policy says so.
+func RecordAuthFailure(db *sql.DB, key string) {
+	policy := lockout()
+	failures, err := models.InsertAuthFailure(db, key, policy.Window)
+	if err == nil {
+		if d := policy.LockFor(failures); d > 0 {
+			err = models.LockAuthKey(db, key, d)
+		}
+	}
+	if err != nil {
+		log.Printf("Lockout %s: %v", key, err)
+	}
+}
+
+// ClearAuthFailures forgets the failed attempts of key after a successful one.
+func ClearAuthFailures(db *sql.DB, key string) {
+	if err := models.DeleteAuthFailures(db, key); err != nil {
+		log.Printf("Lockout %s: %v", key, err)
+	}
+}
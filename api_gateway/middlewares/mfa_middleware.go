@@ -0,0 +1,81 @@
+package middlewares
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/internal"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/models"
+	"github.com/gin-gonic/gin"
+)
+
+// RequireMFAPending authorizes the second step of admin_login: the request must carry the
+// MFA pending token admin_login returned. It sets account_id, jti, token_expires_at and mfa_pending.
+func RequireMFAPending(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, err := internal.MFAPendingTokenParse(bearerToken(c))
+		if err != nil {
+			c.JSON(401, gin.H{"success": false, "message": "Invalid or expired MFA token", "error": err.Error()})
+			c.Abort()
+			return
+		}
+		if !setMFAPendingContext(c, db, claims) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// AdminMFAAuthorization authorizes the admin MFA routes, with either an admin access token
+// granting admin:access or an MFA pending token (then mfa_pending is set), so that admins
+// who must enroll before they can log in are able to.
+func AdminMFAAuthorization(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if claims, err := internal.MFAPendingTokenParse(bearerToken(c)); err == nil {
+			if !setMFAPendingContext(c, db, claims) {
+				return
+			}
+			c.Next()
+			return
+		}
+
+		claims, access, ok := authenticate(c, db)
+		if !ok {
+			return
+		}
+		setAuthContext(c, claims, access)
+		if !HasPermission(c, models.PermissionAdminAccess) {
+			c.JSON(403, gin.H{"success": false, "message": "Forbidden: missing permission " + models.PermissionAdminAccess})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// setMFAPendingContext sets the admin of an MFA pending token, unless the token was already used.
+// On failure it aborts the request and returns false.
+func setMFAPendingContext(c *gin.Context, db *sql.DB, claims *internal.UserIDClaims) bool {
+	// A pending token is revoked once the login it started is complete
+	revoked, err := revokedTokens.isRevoked(db, claims.ID)
+	if err != nil {
+		c.JSON(500, gin.H{"success": false, "message": "Failed to check token revocation", "error": err.Error()})
+		c.Abort()
+		return false
+	}
+	if revoked {
+		c.JSON(401, gin.H{"success": false, "message": "Token has been revoked"})
+		c.Abort()
+		return false
+	}
+	c.Set("account_id", claims.UserID)
+	c.Set("jti", claims.ID)
+	c.Set("token_expires_at", claims.ExpiresAt.Time)
+	c.Set("mfa_pending", true)
+	return true
+}
+
+func bearerToken(c *gin.Context) string {
+	token, _ := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	return token
+}
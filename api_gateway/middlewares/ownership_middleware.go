@@ -0,0 +1,147 @@
+package middlewares
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/models"
+	"github.com/gin-gonic/gin"
+)
+
+// OwnedResource is a kind of resource belonging to an account.
+type OwnedResource struct {
+	name  string
+	owner func(db *sql.DB, id string) (int, error)
+}
+
+// intOwner wraps the owner lookups of resources with integer ids,
+// so malformed ids are "not found" rather than database errors.
+func intOwner(lookup func(db *sql.DB, id string) (int, error)) func(db *sql.DB, id string) (int, error) {
+	return func(db *sql.DB, id string) (int, error) {
+		if _, err := strconv.Atoi(id); err != nil {
+			return 0, sql.ErrNoRows
+		}
+		return lookup(db, id)
+	}
+}
+
+var (
+	ConversationResource = OwnedResource{name: "Conversation", owner: models.SelectConversationOwner}
+	PairResource         = OwnedResource{name: "Request-response pair", owner: intOwner(models.SelectPairOwner)}
+	NoteResource         = OwnedResource{name: "Note", owner: intOwner(models.SelectNoteOwner)}
+)
+
+// IDSource tells RequireOwner where the id of the resource is in the request.
+type IDSource struct {
+	param     string
+	jsonField string
+	optional  bool
+}
+
+// IDFromParam reads the id from a path parameter.
+func IDFromParam(name string) IDSource {
+	return IDSource{param: name}
+}
+
+// IDFromJSON reads the id from a field of the JSON body. The body is left for the handler to bind.
+// Requests without the field are refused with 400.
+func IDFromJSON(field string) IDSource {
+	return IDSource{jsonField: field}
+}
+
+// OptionalIDFromJSON is IDFromJSON for an optional field: requests without it are let through.
+func OptionalIDFromJSON(field string) IDSource {
+	return IDSource{jsonField: field, optional: true}
+}
+
+// read returns the id of the request, false if it has none. JSON fields are decoded like the
+// handlers bind them (encoding/json: keys matched case-insensitively, the last one wins), so
+// the id checked is the id the handler acts on.
+func (s IDSource) read(c *gin.Context) (string, bool) {
+	if s.param != "" {
+		id := c.Param(s.param)
+		return id, id != ""
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return "", false
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	field := reflect.New(reflect.StructOf([]reflect.StructField{{
+		Name: "ID",
+		Type: reflect.TypeOf(json.RawMessage{}),
+		Tag:  reflect.StructTag(fmt.Sprintf(`json:%q`, s.jsonField)),
+	}}))
+	if err := json.Unmarshal(body, field.Interface()); err != nil {
+		return "", false
+	}
+	raw := field.Elem().Field(0).Interface().(json.RawMessage)
+	if len(raw) == 0 || string(raw) == "null" {
+		return "", false
+	}
+	// Ids are strings (conversations) or numbers (pairs, notes)
+	var id string
+	if err := json.Unmarshal(raw, &id); err != nil {
+		id = strings.TrimSpace(string(raw))
+	}
+	return id, true
+}
+
+// RequireOwner returns a gin.HandlerFunc letting through requests on a resource of the authenticated account.
+// Accounts with the override permission (if not empty) may access any resource.
+// It answers 404 otherwise, so requests can't tell others' resources from missing ones.
+// It must run after Authorization or RequirePermission.
+func RequireOwner(resource OwnedResource, from IDSource, override string) gin.HandlerFunc {
+	db := models.DB
+	return func(c *gin.Context) {
+		id, ok := from.read(c)
+		if !ok && (from.param != "" || from.optional) {
+			c.Next()
+			return
+		}
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Invalid request: " + from.jsonField + " is required"})
+			c.Abort()
+			return
+		}
+
+		notFound := func() {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "message": resource.name + " not found"})
+			c.Abort()
+		}
+		accountID, authenticated := c.Get("account_id")
+		if !authenticated {
+			notFound()
+			return
+		}
+
+		ownerID, err := resource.owner(db, id)
+		if err == sql.ErrNoRows {
+			notFound()
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "Failed to fetch " + strings.ToLower(resource.name),
+				"error":   err.Error(),
+			})
+			c.Abort()
+			return
+		}
+		if ownerID != accountID && (override == "" || !HasPermission(c, override)) {
+			notFound()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,170 @@
+package middlewares
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/config"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/models"
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimitRule is a token bucket: Burst requests at once, then one more every Every.
+type RateLimitRule struct {
+	Burst int
+	Every time.Duration
+}
+
+// RateLimitStore holds the token buckets of the rate limits.
+type RateLimitStore interface {
+	// Take takes a token from the bucket of key. When the bucket is empty it returns false
+	// and the time until the next token.
+	Take(key string, rule RateLimitRule) (bool, time.Duration, error)
+}
+
+// MemoryRateLimitStore keeps the buckets in memory, so each gateway replica limits on its own.
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+	// The bucket is full again from then on, and can be dropped
+	fullAt time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{buckets: map[string]*tokenBucket{}, lastSweep: time.Now()}
+}
+
+func (s *MemoryRateLimitStore) Take(key string, rule RateLimitRule) (bool, time.Duration, error) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) > time.Minute {
+		for k, b := range s.buckets {
+			if now.After(b.fullAt) {
+				delete(s.buckets, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: float64(rule.Burst), updated: now}
+		s.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(rule.Burst), b.tokens+float64(now.Sub(b.updated))/float64(rule.Every))
+	b.updated = now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) * float64(rule.Every)), nil
+	}
+	b.tokens--
+	b.fullAt = now.Add(time.Duration((float64(rule.Burst) - b.tokens) * float64(rule.Every)))
+	return true, 0, nil
+}
+
+// PostgresRateLimitStore keeps the buckets in the rate_limit_bucket table, shared by all replicas.
+type PostgresRateLimitStore struct {
+	db *sql.DB
+}
+
+func NewPostgresRateLimitStore(db *sql.DB) *PostgresRateLimitStore {
+	return &PostgresRateLimitStore{db: db}
+}
+
+func (s *PostgresRateLimitStore) Take(key string, rule RateLimitRule) (bool, time.Duration, error) {
+	ok, tokens, err := models.TakeRateLimitToken(s.db, key, rule.Burst, rule.Every)
+	if err != nil || ok {
+		return ok, 0, err
+	}
+	return false, time.Duration((1 - tokens) * float64(rule.Every)), nil
+}
+
+var rateLimits RateLimitStore = NewMemoryRateLimitStore()
+
+// InitRateLimitStore selects where the rate limit buckets are kept (RATE_LIMIT_STORE):
+// memory (default) or postgres to share them between gateway replicas.
+func InitRateLimitStore(db *sql.DB) error {
+	switch store := config.GetEnv("RATE_LIMIT_STORE", "memory"); store {
+	case "memory":
+		rateLimits = NewMemoryRateLimitStore()
+	case "postgres":
+		rateLimits = NewPostgresRateLimitStore(db)
+	default:
+		return fmt.Errorf("unknown RATE_LIMIT_STORE %q", store)
+	}
+	return nil
+}
+
+// RateLimitKey is what requests are limited by. Requests without a value (e.g. no email) aren't limited by it.
+type RateLimitKey struct {
+	Name string
+	key  func(c *gin.Context) string
+}
+
+// ByClientIP limits requests per client IP.
+var ByClientIP = RateLimitKey{Name: "ip", key: func(c *gin.Context) string { return c.ClientIP() }}
+
+// ByAccount limits requests per authenticated account.
+var ByAccount = RateLimitKey{Name: "account", key: func(c *gin.Context) string {
+	if id := c.GetInt("account_id"); id != 0 {
+		return strconv.Itoa(id)
+	}
+	return ""
+}}
+
+// ByJSONField limits requests per value of a field of the JSON body, e.g. the email, case-insensitively.
+func ByJSONField(field string) RateLimitKey {
+	source := IDFromJSON(field)
+	return RateLimitKey{Name: field, key: func(c *gin.Context) string {
+		value, _ := source.read(c)
+		return strings.ToLower(strings.TrimSpace(value))
+	}}
+}
+
+// RateLimit limits the requests of a route with a token bucket per key: a request is refused with 429
+// as soon as one of its buckets is empty. scope names the buckets, routes sharing one share them.
+// If the store fails, requests are let through.
+func RateLimit(scope string, rule RateLimitRule, keys ...RateLimitKey) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, k := range keys {
+			value := k.key(c)
+			if value == "" {
+				continue
+			}
+			ok, retryAfter, err := rateLimits.Take(scope+":"+k.Name+":"+value, rule)
+			if err != nil {
+				log.Printf("Rate limit %s: %v", scope, err)
+				continue
+			}
+			if !ok {
+				tooManyRequests(c, "Too many requests, try again later", retryAfter)
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// tooManyRequests aborts the request with 429 and a Retry-After header.
+func tooManyRequests(c *gin.Context, message string, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.JSON(429, gin.H{"success": false, "message": message, "retry_after": seconds})
+	c.Abort()
+}
@@ -0,0 +1,33 @@
+package middlewares
+
+import (
+	"regexp"
+
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/internal"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/models"
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader carries the id of a request, from the client or a proxy, or else generated.
+const RequestIDHeader = "X-Request-ID"
+
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,100}$`)
+
+// RequestID gives each request an id, sent back in X-Request-ID and recorded with its audit events.
+// An X-Request-ID set by the client or a proxy is kept if it looks sane.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = internal.NewTokenID()
+		}
+		c.Set("request_id", id)
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// AuditActor returns who makes the changes of the request, for the audit log.
+func AuditActor(c *gin.Context) models.Audit {
+	return models.Audit{ActorID: c.GetInt("account_id"), RequestID: c.GetString("request_id")}
+}
@@ -0,0 +1,50 @@
+package middlewares
+
+import (
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/models"
+)
+
+// revocationCache is an in-process copy of the access token denylist, reloaded every
+// ROLE_CACHE_TTL, so authenticating a request doesn't query revoked_access_token. A logout on
+// this gateway drops the copy (InvalidateRevokedTokens); other gateways see it within the TTL,
+// like a role change.
+type revocationCache struct {
+	mu       sync.Mutex
+	loadedAt time.Time
+	// jtis holds the revoked access tokens that have not expired yet.
+	jtis map[string]struct{}
+}
+
+var revokedTokens = &revocationCache{}
+
+func (rc *revocationCache) isRevoked(db *sql.DB, jti string) (bool, error) {
+	now := time.Now()
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	// Requests wait for the reload rather than all querying the table at once.
+	if rc.jtis == nil || now.Sub(rc.loadedAt) >= accountRoles.cacheTTL() {
+		jtis, err := models.SelectRevokedAccessTokens(db)
+		if err != nil {
+			return false, err
+		}
+		rc.jtis = make(map[string]struct{}, len(jtis))
+		for _, revoked := range jtis {
+			rc.jtis[revoked] = struct{}{}
+		}
+		rc.loadedAt = now
+	}
+	_, revoked := rc.jtis[jti]
+	return revoked, nil
+}
+
+// InvalidateRevokedTokens drops the cached denylist after access tokens were revoked, e.g. by a
+// logout, so this gateway reloads it on the next request (other gateways within ROLE_CACHE_TTL).
+func InvalidateRevokedTokens() {
+	revokedTokens.mu.Lock()
+	revokedTokens.jtis = nil
+	revokedTokens.mu.Unlock()
+}
@@ -0,0 +1,109 @@
+package middlewares
+
+import (
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/config"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/models"
+)
+
+// roleCache keeps the current role, token version and permissions of recently seen accounts for a
+// short time, so Authorization doesn't query the database on every request, yet a role change,
+// a permission change or a token_version bump is noticed within the TTL.
+type roleCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	ttlOnce sync.Once
+	entries map[int]roleCacheEntry
+}
+
+type roleCacheEntry struct {
+	access    accountAccess
+	expiresAt time.Time
+}
+
+// accountAccess is what the gateway knows about an account when authorizing a request.
+type accountAccess struct {
+	models.AccountRole
+	// permissions maps the permissions granted by the role to whether they are admin_only.
+	permissions map[string]bool
+}
+
+// allows reports whether the account has the permission. admin_only permissions
+// also need a token issued by admin_login.
+func (a accountAccess) allows(permission string, adminToken bool) bool {
+	adminOnly, ok := a.permissions[permission]
+	return ok && (!adminOnly || adminToken)
+}
+
+var accountRoles = newRoleCache()
+
+func newRoleCache() *roleCache {
+	return &roleCache{entries: map[int]roleCacheEntry{}}
+}
+
+// cacheTTL returns ROLE_CACHE_TTL (30s), read on first use: the cache is created before
+// config.LoadEnv.
+func (rc *roleCache) cacheTTL() time.Duration {
+	rc.ttlOnce.Do(func() {
+		ttl, err := time.ParseDuration(config.GetEnv("ROLE_CACHE_TTL", "30s"))
+		if err != nil || ttl < 0 {
+			ttl = 30 * time.Second
+		}
+		rc.ttl = ttl
+	})
+	return rc.ttl
+}
+
+func (rc *roleCache) get(db *sql.DB, accountID int) (accountAccess, error) {
+	now := time.Now()
+	rc.mu.Lock()
+	entry, ok := rc.entries[accountID]
+	rc.mu.Unlock()
+	if ok && now.Before(entry.expiresAt) {
+		return entry.access, nil
+	}
+
+	role, err := models.SelectAccountRole(db, accountID)
+	if err != nil {
+		return accountAccess{}, err
+	}
+	permissions, err := models.SelectAccountPermissions(db, accountID)
+	if err != nil {
+		return accountAccess{}, err
+	}
+	access := accountAccess{AccountRole: role, permissions: make(map[string]bool, len(permissions))}
+	for _, p := range permissions {
+		access.permissions[p.Name] = p.AdminOnly
+	}
+
+	rc.mu.Lock()
+	// Drop expired entries from time to time so the map doesn't grow forever.
+	if len(rc.entries) > 10000 {
+		for id, e := range rc.entries {
+			if now.After(e.expiresAt) {
+				delete(rc.entries, id)
+			}
+		}
+	}
+	rc.entries[accountID] = roleCacheEntry{access: access, expiresAt: now.Add(rc.cacheTTL())}
+	rc.mu.Unlock()
+	return access, nil
+}
+
+// InvalidateAccountRole drops the cached role of an account, e.g. after its role changed,
+// so this gateway sees the change on the next request (other gateways within ROLE_CACHE_TTL).
+func InvalidateAccountRole(accountID int) {
+	accountRoles.mu.Lock()
+	delete(accountRoles.entries, accountID)
+	accountRoles.mu.Unlock()
+}
+
+// InvalidateAllAccountRoles empties the cache, e.g. after the permissions of a role changed.
+func InvalidateAllAccountRoles() {
+	accountRoles.mu.Lock()
+	accountRoles.entries = map[int]roleCacheEntry{}
+	accountRoles.mu.Unlock()
+}
@@ -0,0 +1,226 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// adminAccountColumns are scanned by scanAdminAccount, from account a joined with role r.
+const adminAccountColumns = `
+        a.id, a.username, COALESCE(a.display_name, ''), a.role_id, r.label,
+        u.id IS NOT NULL, g.id IS NOT NULL, COALESCE(u.password_reset_required, false),
+        a.disabled_at, COALESCE(a.disabled_reason, ''), a.deleted_at,
+        (SELECT COUNT(*) FROM conversation c WHERE c.account_id = a.id),
+        GREATEST(
+            (SELECT MAX(c.updated_time) FROM conversation c WHERE c.account_id = a.id),
+            (SELECT MAX(t.created_at) FROM refresh_token t WHERE t.account_id = a.id)
+        )`
+
+const adminAccountJoins = `
+        FROM account a
+        JOIN role r ON r.id = a.role_id
+        LEFT JOIN "user" u ON u.id = a.id
+        LEFT JOIN google_user g ON g.id = a.id`
+
+func scanAdminAccount(row interface{ Scan(...any) error }) (AdminAccount, error) {
+	var account AdminAccount
+	var disabledAt, deletedAt, lastActivity sql.NullTime
+	err := row.Scan(&account.ID, &account.Email, &account.DisplayName, &account.RoleID, &account.Role,
+		&account.HasPassword, &account.GoogleLinked, &account.PasswordResetRequired,
+		&disabledAt, &account.DisabledReason, &deletedAt, &account.ConversationCount, &lastActivity)
+	if disabledAt.Valid {
+		account.DisabledAt = &disabledAt.Time
+	}
+	if deletedAt.Valid {
+		account.DeletedAt = &deletedAt.Time
+	}
+	if lastActivity.Valid {
+		account.LastActivity = &lastActivity.Time
+	}
+	return account, err
+}
+
+// likePattern escapes the LIKE wildcards of a search query.
+func likePattern(query string) string {
+	query = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(query)
+	return "%" + query + "%"
+}
+
+// SelectAdminAccounts returns a page of the accounts matching the filter, newest first, and how many match.
+func SelectAdminAccounts(db *sql.DB, filter AccountFilter) ([]AdminAccount, int, error) {
+	var where []string
+	var args []any
+	if q := strings.TrimSpace(filter.Query); q != "" {
+		args = append(args, likePattern(q))
+		where = append(where, fmt.Sprintf(`(a.username ILIKE $%d OR a.display_name ILIKE $%d)`, len(args), len(args)))
+	}
+	if filter.RoleID != 0 {
+		args = append(args, filter.RoleID)
+		where = append(where, fmt.Sprintf(`a.role_id = $%d`, len(args)))
+	}
+	switch filter.Status {
+	case "active":
+		where = append(where, `a.deleted_at IS NULL AND a.disabled_at IS NULL`)
+	case "disabled":
+		where = append(where, `a.deleted_at IS NULL AND a.disabled_at IS NOT NULL`)
+	case "deleted":
+		where = append(where, `a.deleted_at IS NOT NULL`)
+	default:
+		where = append(where, `a.deleted_at IS NULL`)
+	}
+	conditions := " WHERE " + strings.Join(where, " AND ")
+
+	var total int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM account a`+conditions, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	args = append(args, filter.PageSize, (filter.Page-1)*filter.PageSize)
+	rows, err := db.Query(`SELECT `+adminAccountColumns+adminAccountJoins+conditions+
+		fmt.Sprintf(` ORDER BY a.id DESC LIMIT $%d OFFSET $%d`, len(args)-1, len(args)), args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	accounts := []AdminAccount{}
+	for rows.Next() {
+		account, err := scanAdminAccount(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		accounts = append(accounts, account)
+	}
+	return accounts, total, rows.Err()
+}
+
+// SelectAdminAccount returns an account with its activity, sql.ErrNoRows if it doesn't exist.
+func SelectAdminAccount(db *sql.DB, accountID int) (AdminAccountDetail, error) {
+	account, err := scanAdminAccount(db.QueryRow(`SELECT `+adminAccountColumns+adminAccountJoins+` WHERE a.id = $1`, accountID))
+	detail := AdminAccountDetail{AdminAccount: account, SSOProviders: []string{}}
+	if err != nil {
+		return detail, err
+	}
+
+	err = db.QueryRow(`
+        SELECT
+            (SELECT COUNT(*) FROM request_response_pair rrp
+             JOIN conversation c ON c.id = rrp.conversation_id WHERE c.account_id = $1),
+            (SELECT COUNT(*) FROM note n
+             JOIN request_response_pair rrp ON rrp.id = n.id
+             JOIN conversation c ON c.id = rrp.conversation_id WHERE c.account_id = $1),
+            (SELECT COUNT(*) FROM refresh_token
+             WHERE account_id = $1 AND revoked_at IS NULL AND expires_at > NOW())
+    `, accountID).Scan(&detail.QuestionCount, &detail.NoteCount, &detail.ActiveSessions)
+	if err != nil {
+		return detail, err
+	}
+
+	rows, err := db.Query(`SELECT provider FROM external_identity WHERE account_id = $1 ORDER BY provider`, accountID)
+	if err != nil {
+		return detail, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var provider string
+		if err := rows.Scan(&provider); err != nil {
+			return detail, err
+		}
+		detail.SSOProviders = append(detail.SSOProviders, provider)
+	}
+	return detail, rows.Err()
+}
+
+// UpdateAccountRole gives an account another role, audited. Access tokens carry the role label,
+// so the tokens of the account are outdated from then on and must be refreshed.
+func UpdateAccountRole(db *sql.DB, audit Audit, accountID, roleID int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var oldRoleID int
+	err = tx.QueryRow(`SELECT role_id FROM account WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, accountID).Scan(&oldRoleID)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE account SET role_id = $2 WHERE id = $1`, accountID, roleID); err != nil {
+		return err
+	}
+	err = audit.record(tx, AuditAccountRoleUpdate, "account", accountID,
+		map[string]any{"role_id": oldRoleID}, map[string]any{"role_id": roleID})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SetAccountDisabled disables (with a reason) or enables an account, audited. It returns sql.ErrNoRows
+// if the account doesn't exist or was deleted.
+func SetAccountDisabled(db *sql.DB, audit Audit, accountID int, disabled bool, reason string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var wasDisabled bool
+	var oldReason string
+	err = tx.QueryRow(`
+        SELECT disabled_at IS NOT NULL, COALESCE(disabled_reason, '')
+        FROM account WHERE id = $1 AND deleted_at IS NULL FOR UPDATE
+    `, accountID).Scan(&wasDisabled, &oldReason)
+	if err != nil {
+		return err
+	}
+
+	action := AuditAccountEnable
+	query := `UPDATE account SET disabled_at = NULL, disabled_reason = NULL WHERE id = $1`
+	args := []any{accountID}
+	if disabled {
+		action = AuditAccountDisable
+		query = `UPDATE account SET disabled_at = COALESCE(disabled_at, NOW()), disabled_reason = NULLIF($2, '') WHERE id = $1`
+		args = append(args, reason)
+	} else {
+		reason = ""
+	}
+	if _, err := tx.Exec(query, args...); err != nil {
+		return err
+	}
+	err = audit.record(tx, action, "account", accountID,
+		map[string]any{"disabled": wasDisabled, "reason": oldReason}, map[string]any{"disabled": disabled, "reason": reason})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RequirePasswordReset makes the account reset its password before its next login, audited.
+// It returns ErrNoPassword if the account signs in with Google or SSO only.
+func RequirePasswordReset(db *sql.DB, audit Audit, accountID int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var wasRequired bool
+	err = tx.QueryRow(`SELECT password_reset_required FROM "user" WHERE id = $1 FOR UPDATE`, accountID).Scan(&wasRequired)
+	if err == sql.ErrNoRows {
+		return ErrNoPassword
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE "user" SET password_reset_required = true WHERE id = $1`, accountID); err != nil {
+		return err
+	}
+	err = audit.record(tx, AuditAccountPasswordReset, "account", accountID,
+		map[string]any{"password_reset_required": wasRequired}, map[string]any{"password_reset_required": true})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
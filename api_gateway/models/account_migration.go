@@ -1,16 +1,329 @@
 package models;
 
-func InsertUser(user User) (int, error) {
-	id := 0;
-	//db query here
+import (
+	"database/sql"
+	"time"
+)
 
-	return id, nil;
+// SelectAccountRole returns the role label and token version of an account, and whether it is disabled.
+func SelectAccountRole(db *sql.DB, accountID int) (AccountRole, error) {
+	var role AccountRole
+	query := `
+        SELECT r.label, a.token_version, a.disabled_at IS NOT NULL
+        FROM account a
+        JOIN role r ON a.role_id = r.id
+        WHERE a.id = $1
+    `
+	err := db.QueryRow(query, accountID).Scan(&role.Role, &role.TokenVersion, &role.Disabled)
+	return role, err
 }
 
-func InsertUserGoogleInfomation(user GoogleUser, user_id int) (int, error) {
-	id := 0;
-	//db query here
+// SelectAccountLoginState returns whether the account of a username is disabled or must reset its password.
+func SelectAccountLoginState(db *sql.DB, username string) (AccountLoginState, error) {
+	var state AccountLoginState
+	err := db.QueryRow(`
+        SELECT a.disabled_at IS NOT NULL, COALESCE(u.password_reset_required, false)
+        FROM account a
+        LEFT JOIN "user" u ON u.id = a.id
+        WHERE a.username = $1
+    `, username).Scan(&state.Disabled, &state.PasswordResetRequired)
+	return state, err
+}
+
+// SelectAccountUsername returns the username (email) of an account.
+func SelectAccountUsername(db *sql.DB, accountID int) (string, error) {
+	var username string
+	err := db.QueryRow(`SELECT username FROM account WHERE id = $1`, accountID).Scan(&username)
+	return username, err
+}
+
+// SelectAccountProfile returns the profile of an account.
+func SelectAccountProfile(db *sql.DB, accountID int) (AccountProfile, error) {
+	profile := AccountProfile{ID: accountID, SSOProviders: []string{}}
+	var displayName sql.NullString
+	err := db.QueryRow(`
+        SELECT a.username, a.display_name, r.label,
+               EXISTS (SELECT 1 FROM "user" u WHERE u.id = a.id),
+               EXISTS (SELECT 1 FROM google_user g WHERE g.id = a.id)
+        FROM account a
+        JOIN role r ON a.role_id = r.id
+        WHERE a.id = $1 AND a.deleted_at IS NULL
+    `, accountID).Scan(&profile.Email, &displayName, &profile.Role, &profile.HasPassword, &profile.GoogleLinked)
+	if err != nil {
+		return profile, err
+	}
+	profile.DisplayName = displayName.String
+
+	rows, err := db.Query(`SELECT provider FROM external_identity WHERE account_id = $1 ORDER BY provider`, accountID)
+	if err != nil {
+		return profile, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var provider string
+		if err := rows.Scan(&provider); err != nil {
+			return profile, err
+		}
+		profile.SSOProviders = append(profile.SSOProviders, provider)
+	}
+	return profile, rows.Err()
+}
+
+// UpdateDisplayName sets the display name of an account.
+func UpdateDisplayName(db *sql.DB, accountID int, displayName string) error {
+	result, err := db.Exec(`UPDATE account SET display_name = $2 WHERE id = $1 AND deleted_at IS NULL`, accountID, displayName)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(result)
+}
+
+// SelectUserPassword returns the bcrypt hash of the password of an account, ErrNoPassword if it has none.
+func SelectUserPassword(db *sql.DB, accountID int) (string, error) {
+	var hash string
+	err := db.QueryRow(`SELECT password FROM "user" WHERE id = $1`, accountID).Scan(&hash)
+	if err == sql.ErrNoRows {
+		return "", ErrNoPassword
+	}
+	return hash, err
+}
+
+// UpdateUserPassword sets the bcrypt hash of the password of an account, which satisfies a forced reset.
+func UpdateUserPassword(db *sql.DB, accountID int, hash string) error {
+	result, err := db.Exec(`UPDATE "user" SET password = $2, password_reset_required = false WHERE id = $1`, accountID, hash)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(result)
+}
+
+// emailTaken reports whether an account other than accountID uses email.
+func emailTaken(q interface {
+	QueryRow(query string, args ...any) *sql.Row
+}, accountID int, email string) (bool, error) {
+	var taken bool
+	err := q.QueryRow(`
+        SELECT EXISTS (SELECT 1 FROM account WHERE lower(username) = lower($2) AND id <> $1)
+            OR EXISTS (SELECT 1 FROM "user" WHERE lower(email) = lower($2) AND id <> $1)
+    `, accountID, email).Scan(&taken)
+	return taken, err
+}
+
+// InsertEmailChange starts a change of the email of an account to newEmail, replacing a pending one.
+// It returns ErrEmailTaken if another account uses newEmail.
+func InsertEmailChange(db *sql.DB, accountID int, newEmail, otpHash string) error {
+	taken, err := emailTaken(db, accountID, newEmail)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return ErrEmailTaken
+	}
+	_, err = db.Exec(`
+        INSERT INTO email_change (account_id, new_email, otp, otp_generated_time, otp_attempts)
+        VALUES ($1, $2, $3, NOW(), 0)
+        ON CONFLICT (account_id) DO UPDATE
+        SET new_email = EXCLUDED.new_email, otp = EXCLUDED.otp, otp_generated_time = NOW(), otp_attempts = 0
+    `, accountID, newEmail, otpHash)
+	return err
+}
+
+// SelectEmailChange returns the pending email change of an account, sql.ErrNoRows if there is none.
+func SelectEmailChange(db *sql.DB, accountID int) (EmailChange, error) {
+	change := EmailChange{AccountID: accountID}
+	err := db.QueryRow(`
+        SELECT new_email, otp, otp_generated_time, otp_attempts FROM email_change WHERE account_id = $1
+    `, accountID).Scan(&change.NewEmail, &change.OTP.OTPCode, &change.OTP.OTPWasGeneratedAt, &change.OTPAttempts)
+	return change, err
+}
+
+// IncrementEmailChangeAttempts counts a wrong OTP for the pending email change of an account.
+func IncrementEmailChangeAttempts(db *sql.DB, accountID int) error {
+	_, err := db.Exec(`UPDATE email_change SET otp_attempts = otp_attempts + 1 WHERE account_id = $1`, accountID)
+	return err
+}
+
+// ConfirmEmailChange applies the pending email change of an account and returns the old email.
+// It returns sql.ErrNoRows if there is none and ErrEmailTaken if the new email got used meanwhile.
+func ConfirmEmailChange(db *sql.DB, accountID int) (oldEmail, newEmail string, err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return "", "", err
+	}
+	defer tx.Rollback()
 
-	return id, nil;
+	err = tx.QueryRow(`
+        DELETE FROM email_change WHERE account_id = $1 RETURNING new_email
+    `, accountID).Scan(&newEmail)
+	if err != nil {
+		return "", "", err
+	}
+	taken, err := emailTaken(tx, accountID, newEmail)
+	if err != nil {
+		return "", "", err
+	}
+	if taken {
+		return "", "", ErrEmailTaken
+	}
+
+	err = tx.QueryRow(`SELECT username FROM account WHERE id = $1 FOR UPDATE`, accountID).Scan(&oldEmail)
+	if err != nil {
+		return "", "", err
+	}
+	_, err = tx.Exec(`UPDATE account SET username = $2 WHERE id = $1`, accountID, newEmail)
+	if err == nil {
+		_, err = tx.Exec(`UPDATE "user" SET email = $2 WHERE id = $1`, accountID, newEmail)
+	}
+	if IsUniqueViolation(err) {
+		return "", "", ErrEmailTaken
+	}
+	if err != nil {
+		return "", "", err
+	}
+	return oldEmail, newEmail, tx.Commit()
 }
 
+// DeleteAccount erases an account: its conversations, questions, answers and notes are deleted,
+// and so are its credentials, identities and sessions. The account row is kept anonymized, so
+// rows referencing it stay valid, and its tokens stop working (token_version is bumped).
+func DeleteAccount(db *sql.DB, accountID int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var email string
+	err = tx.QueryRow(`SELECT username FROM account WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, accountID).Scan(&email)
+	if err != nil {
+		return err
+	}
+
+	for _, query := range []string{
+		// Conversation data, children first
+		`DELETE FROM note WHERE id IN (
+            SELECT rrp.id FROM request_response_pair rrp
+            JOIN conversation c ON c.id = rrp.conversation_id
+            WHERE c.account_id = $1)`,
+		`DELETE FROM request_response_pair_pdf_image WHERE request_response_pair_id IN (
+            SELECT rrp.id FROM request_response_pair rrp
+            JOIN conversation c ON c.id = rrp.conversation_id
+            WHERE c.account_id = $1)`,
+		`DELETE FROM request_response_pair WHERE conversation_id IN (
+            SELECT id FROM conversation WHERE account_id = $1)`,
+		`DELETE FROM device_conversation WHERE conversation_id IN (
+            SELECT id FROM conversation WHERE account_id = $1)`,
+		`DELETE FROM conversation WHERE account_id = $1`,
+		// Credentials and identities
+		`DELETE FROM "user" WHERE id = $1`,
+		`DELETE FROM google_user WHERE id = $1`,
+		`DELETE FROM external_identity WHERE account_id = $1`,
+		`DELETE FROM email_change WHERE account_id = $1`,
+		// Sessions
+		`INSERT INTO revoked_access_token (jti, account_id, expires_at)
+         SELECT access_jti, account_id, access_expires_at FROM refresh_token
+         WHERE account_id = $1 AND access_expires_at > NOW()
+         ON CONFLICT (jti) DO NOTHING`,
+		`DELETE FROM refresh_token WHERE account_id = $1`,
+		`UPDATE account
+         SET username = 'deleted:' || id, display_name = NULL, deleted_at = NOW(), token_version = token_version + 1
+         WHERE id = $1`,
+	} {
+		if _, err := tx.Exec(query, accountID); err != nil {
+			return err
+		}
+	}
+	// Leftovers keyed by the email
+	for _, query := range []string{
+		`DELETE FROM temp_user WHERE lower(email) = lower($1)`,
+		`DELETE FROM email_outbox WHERE lower(recipient) = lower($1) AND status = 'pending'`,
+		`DELETE FROM auth_failure WHERE key = 'login:' || lower($1) OR key = 'otp:' || lower($1)`,
+	} {
+		if _, err := tx.Exec(query, email); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// SelectAccountExport returns all the data of an account.
+func SelectAccountExport(db *sql.DB, accountID int) (AccountExport, error) {
+	export := AccountExport{ExportedAt: time.Now().UTC(), Conversations: []ConversationExport{}}
+	profile, err := SelectAccountProfile(db, accountID)
+	if err != nil {
+		return export, err
+	}
+	export.Account = profile
+
+	rows, err := db.Query(`
+        SELECT id, account_id, COALESCE(title, ''), created_time, updated_time
+        FROM conversation WHERE account_id = $1 ORDER BY created_time
+    `, accountID)
+	if err != nil {
+		return export, err
+	}
+	index := map[string]int{}
+	for rows.Next() {
+		c := ConversationExport{DeviceIDs: []int{}, Pairs: []PairExport{}}
+		if err := rows.Scan(&c.ID, &c.AccountID, &c.Title, &c.CreatedAt, &c.UpdatedAt); err != nil {
+			rows.Close()
+			return export, err
+		}
+		index[c.ID] = len(export.Conversations)
+		export.Conversations = append(export.Conversations, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return export, err
+	}
+
+	rows, err = db.Query(`
+        SELECT dc.conversation_id, dc.device_id
+        FROM device_conversation dc
+        JOIN conversation c ON c.id = dc.conversation_id
+        WHERE c.account_id = $1
+        ORDER BY dc.device_id
+    `, accountID)
+	if err != nil {
+		return export, err
+	}
+	for rows.Next() {
+		var dc DeviceConversation
+		if err := rows.Scan(&dc.ConversationID, &dc.DeviceID); err != nil {
+			rows.Close()
+			return export, err
+		}
+		c := &export.Conversations[index[dc.ConversationID]]
+		c.DeviceIDs = append(c.DeviceIDs, dc.DeviceID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return export, err
+	}
+
+	rows, err = db.Query(`
+        SELECT rrp.id, COALESCE(rrp.request, ''), COALESCE(rrp.response, ''), rrp.conversation_id, rrp.created_time, n.title
+        FROM request_response_pair rrp
+        JOIN conversation c ON c.id = rrp.conversation_id
+        LEFT JOIN note n ON n.id = rrp.id
+        WHERE c.account_id = $1
+        ORDER BY rrp.id
+    `, accountID)
+	if err != nil {
+		return export, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var p PairExport
+		var noteTitle sql.NullString
+		if err := rows.Scan(&p.ID, &p.Request, &p.Response, &p.ConversationID, &p.CreatedTime, &noteTitle); err != nil {
+			return export, err
+		}
+		if noteTitle.Valid {
+			p.NoteTitle = &noteTitle.String
+		}
+		c := &export.Conversations[index[p.ConversationID]]
+		c.Pairs = append(c.Pairs, p)
+	}
+	return export, rows.Err()
+}
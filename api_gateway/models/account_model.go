@@ -1,6 +1,7 @@
 package models;
 
 import (
+	"errors"
 	"time"
 );
 
@@ -13,10 +14,112 @@ type OTP struct {
 	OTPWasGeneratedAt time.Time `json:"generated_at"`
 };
 
+// GoogleUser is a Google identity, read from a verified ID token.
 type GoogleUser struct {
 	GoogleID string `json:"google_id"`
 	GoogleEmail string `json:"google_email"`
+	EmailVerified bool `json:"email_verified"`
+	Name string `json:"name"`
 };
 
 var UserPermission = "user"
-var AdminPermission = "admin"
\ No newline at end of file
+var AdminPermission = "admin"
+
+var (
+	// ErrEmailTaken is returned when changing the email of an account to one another account uses.
+	ErrEmailTaken = errors.New("email is already used by another account")
+	// ErrNoPassword is returned for password operations on accounts signing in with Google or SSO only.
+	ErrNoPassword = errors.New("account has no password")
+	// ErrAccountDisabled is returned when starting a session for an account an admin disabled.
+	ErrAccountDisabled = errors.New("account is disabled")
+)
+
+// AccountProfile is what an account can see and edit about itself.
+type AccountProfile struct {
+	ID           int      `json:"id"`
+	Email        string   `json:"email"`
+	DisplayName  string   `json:"display_name"`
+	Role         string   `json:"role"`
+	HasPassword  bool     `json:"has_password"`
+	GoogleLinked bool     `json:"google_linked"`
+	SSOProviders []string `json:"sso_providers"`
+}
+
+// EmailChange is a pending change of the email of an account.
+type EmailChange struct {
+	AccountID   int
+	NewEmail    string
+	OTP         OTP
+	OTPAttempts int
+}
+
+// AccountExport is all the data of an account, for the export archive.
+type AccountExport struct {
+	ExportedAt    time.Time            `json:"exported_at"`
+	Account       AccountProfile       `json:"account"`
+	Conversations []ConversationExport `json:"conversations"`
+}
+
+// ConversationExport is a conversation of an account export, with its questions and answers.
+type ConversationExport struct {
+	Conversation
+	DeviceIDs []int        `json:"device_ids"`
+	Pairs     []PairExport `json:"request_response_pairs"`
+}
+
+// PairExport is a question and its answer, with the title of its note if it has one.
+type PairExport struct {
+	RequestResponsePair
+	NoteTitle *string `json:"note_title"`
+}
+
+// AccountRole is what access tokens carry about their account, and whether it is disabled.
+type AccountRole struct {
+	Role         string `json:"role"`
+	TokenVersion int    `json:"token_version"`
+	Disabled     bool   `json:"disabled"`
+}
+
+// AccountLoginState is what a password login checks once the password matched.
+type AccountLoginState struct {
+	Disabled              bool
+	PasswordResetRequired bool
+}
+
+// AccountFilter selects the accounts an admin lists.
+type AccountFilter struct {
+	// Query matches the email or the display name, case-insensitively
+	Query  string
+	RoleID int
+	// Status is active, disabled or deleted; empty lists the active and disabled accounts
+	Status   string
+	Page     int
+	PageSize int
+}
+
+// AdminAccount is an account as admins see it in the account list.
+type AdminAccount struct {
+	ID                    int        `json:"id"`
+	Email                 string     `json:"email"`
+	DisplayName           string     `json:"display_name"`
+	RoleID                int        `json:"role_id"`
+	Role                  string     `json:"role"`
+	HasPassword           bool       `json:"has_password"`
+	GoogleLinked          bool       `json:"google_linked"`
+	PasswordResetRequired bool       `json:"password_reset_required"`
+	DisabledAt            *time.Time `json:"disabled_at"`
+	DisabledReason        string     `json:"disabled_reason"`
+	DeletedAt             *time.Time `json:"deleted_at"`
+	ConversationCount     int        `json:"conversation_count"`
+	// LastActivity is the last conversation update, login or token refresh
+	LastActivity *time.Time `json:"last_activity"`
+}
+
+// AdminAccountDetail is an account with its activity, for the admin account view.
+type AdminAccountDetail struct {
+	AdminAccount
+	QuestionCount  int      `json:"question_count"`
+	NoteCount      int      `json:"note_count"`
+	ActiveSessions int      `json:"active_sessions"`
+	SSOProviders   []string `json:"sso_providers"`
+}
@@ -0,0 +1,134 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+const aiAgentColumns = `id, name, ip, ocr_in_used, draining, ocr_locked_until`
+
+func scanAIAgent(row interface{ Scan(...any) error }) (AIAgent, error) {
+	var agent AIAgent
+	err := row.Scan(
+		&agent.ID,
+		&agent.Name,
+		&agent.IP,
+		&agent.OCRInUsed,
+		&agent.Draining,
+		&agent.OCRLockedUntil,
+	)
+	return agent, err
+}
+
+func SelectAllAIAgents(db *sql.DB) ([]AIAgent, error) {
+	rows, err := db.Query(`SELECT ` + aiAgentColumns + ` FROM ai_agent_resources ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var agents []AIAgent
+	for rows.Next() {
+		agent, err := scanAIAgent(rows)
+		if err != nil {
+			return nil, err
+		}
+		agents = append(agents, agent)
+	}
+	return agents, rows.Err()
+}
+
+func SelectAIAgentByID(db *sql.DB, id int) (AIAgent, error) {
+	return scanAIAgent(db.QueryRow(`SELECT `+aiAgentColumns+` FROM ai_agent_resources WHERE id = $1`, id))
+}
+
+// InsertAIAgent registers an agent. It returns ErrAIAgentExists if the name is already used.
+func InsertAIAgent(db *sql.DB, name, ip string) (int, error) {
+	var id int
+	query := `
+        INSERT INTO ai_agent_resources (name, ip, ocr_in_used, draining)
+        VALUES ($1, $2, false, false)
+        RETURNING id
+    `
+	err := db.QueryRow(query, name, ip).Scan(&id)
+	if IsUniqueViolation(err) {
+		return 0, ErrAIAgentExists
+	}
+	return id, err
+}
+
+// UpdateAIAgentDraining stops (or resumes) sending new extractions to the agent.
+func UpdateAIAgentDraining(db *sql.DB, id int, draining bool) error {
+	result, err := db.Exec(`UPDATE ai_agent_resources SET draining = $1 WHERE id = $2`, draining, id)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(result)
+}
+
+// DeleteAIAgent removes the agent unless it is running an extraction.
+// It returns sql.ErrNoRows if the agent does not exist or is busy.
+func DeleteAIAgent(db *sql.DB, id int) error {
+	result, err := db.Exec(`
+        DELETE FROM ai_agent_resources
+        WHERE id = $1 AND (ocr_in_used = false OR ocr_locked_until < NOW())
+    `, id)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(result)
+}
+
+// ClaimFreeAIAgent picks an agent that is neither draining nor extracting and marks it in use for the lease.
+// The row is locked with SKIP LOCKED inside a transaction, so two gateway replicas never claim the same agent.
+// It returns sql.ErrNoRows when every agent is busy.
+func ClaimFreeAIAgent(db *sql.DB, lease time.Duration) (AIAgent, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return AIAgent{}, err
+	}
+	defer tx.Rollback()
+
+	agent, err := scanAIAgent(tx.QueryRow(`
+        SELECT ` + aiAgentColumns + `
+        FROM ai_agent_resources
+        WHERE draining = false
+          AND (ocr_in_used = false OR ocr_locked_until < NOW())
+        ORDER BY id
+        FOR UPDATE SKIP LOCKED
+        LIMIT 1
+    `))
+	if err != nil {
+		return AIAgent{}, err
+	}
+
+	_, err = tx.Exec(`
+        UPDATE ai_agent_resources
+        SET ocr_in_used = true, ocr_locked_until = NOW() + $1 * INTERVAL '1 second'
+        WHERE id = $2
+    `, int(lease.Seconds()), agent.ID)
+	if err != nil {
+		return AIAgent{}, err
+	}
+	agent.OCRInUsed = true
+
+	return agent, tx.Commit()
+}
+
+// ReleaseAIAgent marks the agent free again after an extraction.
+func ReleaseAIAgent(db *sql.DB, id int) error {
+	_, err := db.Exec(`UPDATE ai_agent_resources SET ocr_in_used = false, ocr_locked_until = NULL WHERE id = $1`, id)
+	return err
+}
+
+// expectOneRow turns an UPDATE/DELETE that matched nothing into sql.ErrNoRows.
+func expectOneRow(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
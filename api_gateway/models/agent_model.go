@@ -0,0 +1,18 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// ErrAIAgentExists is returned when registering an agent under the name of another one.
+var ErrAIAgentExists = errors.New("OCR agent name already used")
+
+type AIAgent struct {
+	ID             int          `json:"id"`
+	Name           string       `json:"name"`
+	IP             string       `json:"ip"` // host:port of the agent's gRPC server
+	OCRInUsed      bool         `json:"ocr_in_used"`
+	Draining       bool         `json:"draining"`
+	OCRLockedUntil sql.NullTime `json:"ocr_locked_until"`
+}
@@ -0,0 +1,162 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/pgvector/pgvector-go"
+)
+
+func selectCachedAnswer(db *sql.DB, condition string, args ...any) (CachedAnswer, error) {
+	answer := CachedAnswer{}
+	var imagesIDs, chunkIDs pq.Int32Array
+	err := db.QueryRow(`
+        SELECT id, device_id, normalized_query, response, images_ids, chunk_ids, expires_at
+        FROM answer_cache
+        WHERE device_id = $1 AND expires_at > NOW() AND `+condition, args...).
+		Scan(&answer.ID, &answer.DeviceID, &answer.NormalizedQuery, &answer.Response, &imagesIDs, &chunkIDs, &answer.ExpiresAt)
+	answer.ImagesIDs, answer.ChunkIDs = imagesIDs, chunkIDs
+	return answer, err
+}
+
+// SelectCachedAnswer returns the unexpired answer to a normalized query about a device,
+// sql.ErrNoRows if there is none.
+func SelectCachedAnswer(db *sql.DB, deviceID int, normalizedQuery string) (CachedAnswer, error) {
+	return selectCachedAnswer(db, `normalized_query = $2`, deviceID, normalizedQuery)
+}
+
+// SelectSimilarCachedAnswer returns the unexpired answer about a device whose query embedding is
+// the closest to embedding, if within maxDistance (cosine distance), sql.ErrNoRows otherwise.
+func SelectSimilarCachedAnswer(db *sql.DB, deviceID int, embedding []float32, maxDistance float64) (CachedAnswer, error) {
+	return selectCachedAnswer(db, `query_embedding <=> $2 <= $3
+        ORDER BY query_embedding <=> $2, id
+        LIMIT 1`, deviceID, pgvector.NewVector(embedding), maxDistance)
+}
+
+// CountCachedAnswerHit counts a reuse of a cached answer.
+func CountCachedAnswerHit(db *sql.DB, id int64) error {
+	_, err := db.Exec(`UPDATE answer_cache SET hits = hits + 1 WHERE id = $1`, id)
+	return err
+}
+
+// SelectAnswerCacheGeneration returns the generation of the cached answers of a device, bumped
+// when they are invalidated.
+func SelectAnswerCacheGeneration(db *sql.DB, deviceID int) (int64, error) {
+	var generation int64
+	err := db.QueryRow(`SELECT answer_cache_generation FROM device WHERE id = $1`, deviceID).Scan(&generation)
+	return generation, err
+}
+
+// UpsertCachedAnswer caches an answer for ttl, replacing the previous answer to the same query.
+// It caches nothing if the answers of the device were invalidated since answer.Generation: the
+// answer may come from the chunks before the change.
+func UpsertCachedAnswer(db *sql.DB, answer CachedAnswer, ttl time.Duration) error {
+	var embedding any
+	if answer.Embedding != nil {
+		embedding = pgvector.NewVector(answer.Embedding)
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// FOR SHARE waits for an invalidation in progress, and blocks the next ones until the commit
+	var generation int64
+	err = tx.QueryRow(`SELECT answer_cache_generation FROM device WHERE id = $1 FOR SHARE`, answer.DeviceID).Scan(&generation)
+	if err != nil {
+		return err
+	}
+	if generation != answer.Generation {
+		return nil
+	}
+	_, err = tx.Exec(`
+        INSERT INTO answer_cache (device_id, normalized_query, query_embedding, response, images_ids, chunk_ids, expires_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        ON CONFLICT (device_id, normalized_query) DO UPDATE
+            SET query_embedding = EXCLUDED.query_embedding, response = EXCLUDED.response,
+                images_ids = EXCLUDED.images_ids, chunk_ids = EXCLUDED.chunk_ids,
+                hits = 0, created_at = NOW(), expires_at = EXCLUDED.expires_at
+    `, answer.DeviceID, answer.NormalizedQuery, embedding, answer.Response,
+		pq.Int32Array(answer.ImagesIDs), pq.Int32Array(answer.ChunkIDs), time.Now().Add(ttl))
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Subqueries of the device whose cached answers a chunk change invalidates, from $1.
+const (
+	devicesOfPDF   = `SELECT device_id FROM pdf WHERE id = $1`
+	devicesOfChunk = chunkPages + `
+        SELECT p.device_id FROM chunk_page cp
+        JOIN pdf_page pg ON pg.id = cp.page_id
+        JOIN pdf p ON p.id = pg.pdf_id
+        WHERE cp.chunk_id = $1`
+)
+
+// devicesOfPageRow is the subquery of the device of a paragraph or image (a row of table) from $1.
+func devicesOfPageRow(table string) string {
+	return fmt.Sprintf(`
+        SELECT p.device_id FROM %s o
+        JOIN pdf_page pg ON pg.id = o.pdf_page_id
+        JOIN pdf p ON p.id = pg.pdf_id
+        WHERE o.id = $1`, table)
+}
+
+// invalidateCachedAnswers deletes the cached answers of the devices selected by devices from id,
+// in the transaction re-embedding or deleting their chunks, and bumps their generation so the
+// answers being generated from the old chunks aren't cached.
+func invalidateCachedAnswers(q execer, devices string, id int) error {
+	_, err := q.Exec(`
+        WITH invalidated AS (
+            UPDATE device SET answer_cache_generation = answer_cache_generation + 1
+            WHERE id IN (`+devices+`)
+            RETURNING id
+        )
+        DELETE FROM answer_cache WHERE device_id IN (SELECT id FROM invalidated)`, id)
+	if err != nil {
+		return fmt.Errorf("failed to invalidate cached answers: %w", err)
+	}
+	return nil
+}
+
+// PurgeCachedAnswers deletes the cached answers of a device, or of every device if deviceID is 0,
+// and returns how many were deleted.
+func PurgeCachedAnswers(db *sql.DB, audit Audit, deviceID int) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM answer_cache WHERE $1 = 0 OR device_id = $1`, deviceID)
+	if err != nil {
+		return 0, err
+	}
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	// target_id is the device, or all
+	target := "all"
+	if deviceID != 0 {
+		target = fmt.Sprint(deviceID)
+	}
+	err = audit.record(tx, AuditAnswerCachePurge, "answer_cache", target, nil, map[string]any{"purged": purged})
+	if err != nil {
+		return 0, err
+	}
+	return purged, tx.Commit()
+}
+
+// DeleteExpiredCachedAnswers deletes the expired cached answers and returns how many were deleted.
+func DeleteExpiredCachedAnswers(db *sql.DB) (int64, error) {
+	result, err := db.Exec(`DELETE FROM answer_cache WHERE expires_at <= NOW()`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// CachedAnswer is a RAG answer to a question about a device, in answer_cache. A nil Embedding
+// is only matched exactly.
+type CachedAnswer struct {
+	ID              int64
+	DeviceID        int
+	NormalizedQuery string
+	Embedding       []float32
+	Response        string
+	ImagesIDs       []int32
+	ChunkIDs        []int32
+	ExpiresAt       time.Time
+	// Generation is the answer_cache_generation of the device when the answer was looked up
+	Generation int64
+}
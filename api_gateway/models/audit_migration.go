@@ -0,0 +1,109 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// auditJSON marshals the before or after value of an audit event, nil to NULL.
+func auditJSON(v any) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// record inserts the audit event of a change through q, the transaction of the change.
+func (a Audit) record(q execer, action, targetType string, targetID any, before, after any) error {
+	beforeJSON, err := auditJSON(before)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := auditJSON(after)
+	if err != nil {
+		return err
+	}
+	_, err = q.Exec(`
+        INSERT INTO audit_event (actor_account_id, action, target_type, target_id, before, after, request_id)
+        VALUES (NULLIF($1, 0), $2, $3, $4, $5, $6, NULLIF($7, ''))
+    `, a.ActorID, action, targetType, fmt.Sprint(targetID), beforeJSON, afterJSON, a.RequestID)
+	if err != nil {
+		return fmt.Errorf("failed to record audit event: %w", err)
+	}
+	return nil
+}
+
+// SelectAuditEvents returns a page of the audit events matching the filter, newest first, and how many match.
+func SelectAuditEvents(db *sql.DB, filter AuditFilter) ([]AuditEvent, int, error) {
+	var where []string
+	var args []any
+	add := func(condition string, arg any) {
+		args = append(args, arg)
+		where = append(where, fmt.Sprintf(condition, len(args)))
+	}
+	if filter.ActorID != 0 {
+		add(`actor_account_id = $%d`, filter.ActorID)
+	}
+	if filter.Action != "" {
+		add(`action = $%d`, filter.Action)
+	}
+	if filter.TargetType != "" {
+		add(`target_type = $%d`, filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		add(`target_id = $%d`, filter.TargetID)
+	}
+	if !filter.From.IsZero() {
+		add(`occurred_at >= $%d`, filter.From.UTC())
+	}
+	if !filter.To.IsZero() {
+		add(`occurred_at < $%d`, filter.To.UTC())
+	}
+	conditions := ""
+	if len(where) > 0 {
+		conditions = " WHERE " + strings.Join(where, " AND ")
+	}
+
+	var total int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM audit_event`+conditions, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	args = append(args, filter.PageSize, (filter.Page-1)*filter.PageSize)
+	rows, err := db.Query(`
+        SELECT id, occurred_at, actor_account_id, action, target_type, target_id, before, after, COALESCE(request_id, '')
+        FROM audit_event`+conditions+
+		fmt.Sprintf(` ORDER BY occurred_at DESC, id DESC LIMIT $%d OFFSET $%d`, len(args)-1, len(args)), args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	events := []AuditEvent{}
+	for rows.Next() {
+		var e AuditEvent
+		var actorID sql.NullInt64
+		var before, after []byte
+		if err := rows.Scan(&e.ID, &e.OccurredAt, &actorID, &e.Action, &e.TargetType, &e.TargetID, &before, &after, &e.RequestID); err != nil {
+			return nil, 0, err
+		}
+		if actorID.Valid {
+			id := int(actorID.Int64)
+			e.ActorID = &id
+		}
+		if before != nil {
+			e.Before = before
+		}
+		if after != nil {
+			e.After = after
+		}
+		events = append(events, e)
+	}
+	return events, total, rows.Err()
+}
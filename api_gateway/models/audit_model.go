@@ -0,0 +1,57 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Audited actions (audit_event.action).
+const (
+	AuditParagraphUpdate      = "paragraph.update"
+	AuditParagraphRestore     = "paragraph.restore"
+	AuditImageAltUpdate       = "image_alt.update"
+	AuditImageAltRestore      = "image_alt.restore"
+	AuditChunkDelete          = "chunk.delete"
+	AuditBrandCreate          = "brand.create"
+	AuditCategoryCreate       = "category.create"
+	AuditRoleCreate           = "role.create"
+	AuditRoleUpdate           = "role.update"
+	AuditRoleDelete           = "role.delete"
+	AuditAccountRoleUpdate    = "account.role_update"
+	AuditAccountDisable       = "account.disable"
+	AuditAccountEnable        = "account.enable"
+	AuditAccountPasswordReset = "account.password_reset"
+	AuditAnswerCachePurge     = "answer_cache.purge"
+)
+
+// Audit is who makes a change. Model functions changing audited data take one and record
+// the change in audit_event, in the same transaction.
+type Audit struct {
+	ActorID   int
+	RequestID string
+}
+
+// AuditEvent is a row of audit_event.
+type AuditEvent struct {
+	ID         int64           `json:"id"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	ActorID    *int            `json:"actor_account_id"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type"`
+	TargetID   string          `json:"target_id"`
+	Before     json.RawMessage `json:"before"`
+	After      json.RawMessage `json:"after"`
+	RequestID  string          `json:"request_id"`
+}
+
+// AuditFilter selects the audit events an admin lists. Zero fields don't filter.
+type AuditFilter struct {
+	ActorID    int
+	Action     string
+	TargetType string
+	TargetID   string
+	From       time.Time
+	To         time.Time
+	Page       int
+	PageSize   int
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// TakeRateLimitToken takes a token from the bucket of key, which holds up to burst tokens and gains
+// one every `every`. When the bucket is empty it returns false and the tokens it holds (less than one).
+func TakeRateLimitToken(db *sql.DB, key string, burst int, every time.Duration) (bool, float64, error) {
+	var tokens float64
+	err := db.QueryRow(`
+        INSERT INTO rate_limit_bucket AS b (key, tokens, updated_at)
+        VALUES ($1, $2::double precision - 1, NOW())
+        ON CONFLICT (key) DO UPDATE SET
+            tokens = LEAST($2::double precision, b.tokens + EXTRACT(EPOCH FROM NOW() - b.updated_at) / $3::double precision) - 1,
+            updated_at = NOW()
+        WHERE LEAST($2::double precision, b.tokens + EXTRACT(EPOCH FROM NOW() - b.updated_at) / $3::double precision) >= 1
+        RETURNING tokens
+    `, key, burst, every.Seconds()).Scan(&tokens)
+	if err == nil {
+		return true, tokens, nil
+	}
+	if err != sql.ErrNoRows {
+		return false, 0, err
+	}
+
+	err = db.QueryRow(`
+        SELECT LEAST($2::double precision, tokens + EXTRACT(EPOCH FROM NOW() - updated_at) / $3::double precision)
+        FROM rate_limit_bucket WHERE key = $1
+    `, key, burst, every.Seconds()).Scan(&tokens)
+	return false, tokens, err
+}
+
+// SelectAuthLockout returns how long key is still locked out, 0 if it isn't.
+func SelectAuthLockout(db *sql.DB, key string) (time.Duration, error) {
+	var seconds float64
+	err := db.QueryRow(`
+        SELECT GREATEST(EXTRACT(EPOCH FROM locked_until - NOW()), 0)
+        FROM auth_failure WHERE key = $1 AND locked_until IS NOT NULL
+    `, key).Scan(&seconds)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return time.Duration(seconds * float64(time.Second)), err
+}
+
+// InsertAuthFailure counts a failed attempt of key and returns the failures in a row.
+// Failures older than window are forgotten.
+func InsertAuthFailure(db *sql.DB, key string, window time.Duration) (int, error) {
+	var failures int
+	err := db.QueryRow(`
+        INSERT INTO auth_failure AS f (key, failures, expires_at)
+        VALUES ($1, 1, NOW() + $2 * INTERVAL '1 second')
+        ON CONFLICT (key) DO UPDATE SET
+            failures = CASE WHEN f.expires_at < NOW() THEN 1 ELSE f.failures + 1 END,
+            locked_until = CASE WHEN f.expires_at < NOW() THEN NULL ELSE f.locked_until END,
+            expires_at = GREATEST(NOW() + $2 * INTERVAL '1 second', f.locked_until)
+        RETURNING failures
+    `, key, window.Seconds()).Scan(&failures)
+	return failures, err
+}
+
+// LockAuthKey locks key out for d.
+func LockAuthKey(db *sql.DB, key string, d time.Duration) error {
+	_, err := db.Exec(`
+        UPDATE auth_failure
+        SET locked_until = NOW() + $2 * INTERVAL '1 second',
+            expires_at = GREATEST(expires_at, NOW() + $2 * INTERVAL '1 second')
+        WHERE key = $1
+    `, key, d.Seconds())
+	return err
+}
+
+// DeleteAuthFailures forgets the failed attempts of key, after a successful one.
+func DeleteAuthFailures(db *sql.DB, key string) error {
+	_, err := db.Exec(`DELETE FROM auth_failure WHERE key = $1`, key)
+	return err
+}
@@ -0,0 +1,108 @@
+package models
+
+import (
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+// SelectChunkCitations resolves chunks to the page and PDF they were embedded from, in the order
+// of chunkIDs. Chunks that no longer exist are left out.
+func SelectChunkCitations(db *sql.DB, chunkIDs []int) ([]Citation, error) {
+	citations := []Citation{}
+	if len(chunkIDs) == 0 {
+		return citations, nil
+	}
+	rows, err := db.Query(chunkPages+`
+        SELECT DISTINCT ON (c.id) c.id, p.id, COALESCE(p.filename, ''), COALESCE(pg.page_number, 0), COALESCE(p.gcs_bucket, '')
+        FROM pdf_chunk c
+        JOIN chunk_page cp ON cp.chunk_id = c.id
+        JOIN pdf_page pg ON pg.id = cp.page_id
+        JOIN pdf p ON p.id = pg.pdf_id
+        WHERE c.id = ANY($1)
+        ORDER BY c.id, cp.source DESC, cp.source_id
+    `, pq.Array(chunkIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byChunk := map[int]Citation{}
+	for rows.Next() {
+		var citation Citation
+		var chunkID int
+		if err := rows.Scan(&chunkID, &citation.PDFID, &citation.FileName, &citation.PageNumber, &citation.ObjectName); err != nil {
+			return nil, err
+		}
+		citation.ChunkID = &chunkID
+		byChunk[chunkID] = citation
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, id := range chunkIDs {
+		if citation, ok := byChunk[id]; ok {
+			citations = append(citations, citation)
+			delete(byChunk, id)
+		}
+	}
+	return citations, nil
+}
+
+// InsertPairCitations stores the citations of a request-response pair, in order.
+func InsertPairCitations(db execer, pairID int, citations []Citation) error {
+	for i, citation := range citations {
+		_, err := db.Exec(`
+            INSERT INTO request_response_pair_pdf_chunk
+                (request_response_pair_id, position, pdf_chunk_id, pdf_id, filename, page_number)
+            VALUES ($1, $2, $3, $4, $5, $6)
+        `, pairID, i, citation.ChunkID, citation.PDFID, citation.FileName, citation.PageNumber)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SelectConversationCitations returns the citations of the pairs of a conversation, by pair id.
+func SelectConversationCitations(db *sql.DB, conversationID string) (map[int][]Citation, error) {
+	return selectPairCitations(db, `rrp.conversation_id = $1`, conversationID)
+}
+
+// SelectPairCitations returns the citations of request-response pairs, by pair id.
+func SelectPairCitations(db *sql.DB, pairIDs []int) (map[int][]Citation, error) {
+	return selectPairCitations(db, `rc.request_response_pair_id = ANY($1)`, pq.Array(pairIDs))
+}
+
+func selectPairCitations(db *sql.DB, condition string, arg any) (map[int][]Citation, error) {
+	rows, err := db.Query(`
+        SELECT rc.request_response_pair_id, rc.pdf_chunk_id, rc.pdf_id, COALESCE(rc.filename, ''), rc.page_number,
+               COALESCE(p.gcs_bucket, '')
+        FROM request_response_pair_pdf_chunk rc
+        JOIN request_response_pair rrp ON rrp.id = rc.request_response_pair_id
+        JOIN pdf p ON p.id = rc.pdf_id
+        WHERE `+condition+`
+        ORDER BY rc.request_response_pair_id, rc.position
+    `, arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	citations := map[int][]Citation{}
+	for rows.Next() {
+		var pairID int
+		var chunkID sql.NullInt64
+		var citation Citation
+		if err := rows.Scan(&pairID, &chunkID, &citation.PDFID, &citation.FileName, &citation.PageNumber, &citation.ObjectName); err != nil {
+			return nil, err
+		}
+		if chunkID.Valid {
+			id := int(chunkID.Int64)
+			citation.ChunkID = &id
+		}
+		citations[pairID] = append(citations[pairID], citation)
+	}
+	return citations, rows.Err()
+}
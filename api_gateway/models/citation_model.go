@@ -0,0 +1,12 @@
+package models
+
+// Citation is a PDF page a RAG answer was generated from.
+type Citation struct {
+	ChunkID    *int   `json:"chunk_id"` // nil once the chunk was re-embedded or deleted
+	PDFID      int    `json:"pdf_id"`
+	FileName   string `json:"filename"`
+	PageNumber int    `json:"page_number"`
+	URL        string `json:"url"`                   // signed URL of the PDF, opening on the page
+	EditorPath string `json:"editor_path,omitempty"` // page in the PDF editor, for the feedback review
+	ObjectName string `json:"-"`                     // object of the PDF in the blob store
+}
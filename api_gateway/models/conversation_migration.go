@@ -0,0 +1,43 @@
+package models
+
+import "database/sql"
+
+// SelectConversationOwner returns the account owning a conversation.
+func SelectConversationOwner(db *sql.DB, conversationID string) (int, error) {
+	var accountID int
+	err := db.QueryRow(`SELECT account_id FROM conversation WHERE id = $1`, conversationID).Scan(&accountID)
+	return accountID, err
+}
+
+// SelectPairOwner returns the account owning the conversation of a request-response pair.
+func SelectPairOwner(db *sql.DB, pairID string) (int, error) {
+	var accountID int
+	err := db.QueryRow(`
+        SELECT c.account_id
+        FROM request_response_pair rrp
+        JOIN conversation c ON c.id = rrp.conversation_id
+        WHERE rrp.id = $1
+    `, pairID).Scan(&accountID)
+	return accountID, err
+}
+
+// SelectNoteOwner returns the account owning a note (notes share the id of their pair).
+func SelectNoteOwner(db *sql.DB, noteID string) (int, error) {
+	var accountID int
+	err := db.QueryRow(`
+        SELECT c.account_id
+        FROM note n
+        JOIN request_response_pair rrp ON rrp.id = n.id
+        JOIN conversation c ON c.id = rrp.conversation_id
+        WHERE n.id = $1
+    `, noteID).Scan(&accountID)
+	return accountID, err
+}
+
+// ConversationHasPairs tells whether a conversation has request-response pairs, a history the
+// next answer depends on.
+func ConversationHasPairs(db *sql.DB, conversationID string) (bool, error) {
+	var exists bool
+	err := db.QueryRow(`SELECT EXISTS (SELECT 1 FROM request_response_pair WHERE conversation_id = $1)`, conversationID).Scan(&exists)
+	return exists, err
+}
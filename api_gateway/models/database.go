@@ -2,11 +2,12 @@ package models
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"time"
 
-	_ "github.com/lib/pq" // PostgreSQL driver
+	"github.com/lib/pq" // PostgreSQL driver
 );
 
 // initDB initializes the database connection pool.
@@ -43,4 +44,10 @@ func InitDB(dataSourceName string) (*sql.DB, error) {
 	return db, nil
 }
 
-var DB *sql.DB
\ No newline at end of file
+var DB *sql.DB
+
+// IsUniqueViolation reports whether err comes from a unique constraint or index.
+func IsUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
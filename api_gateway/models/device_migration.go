@@ -2,8 +2,12 @@ package models
 
 import (
 	"database/sql"
+	"errors"
 )
 
+// ErrDuplicatePDF is returned when the same file was already uploaded for the device.
+var ErrDuplicatePDF = errors.New("PDF already uploaded for this device")
+
 func InsertDevice(db *sql.DB, device Device) (int, error) {
 	id := 0
 	//db query here
@@ -20,6 +24,34 @@ func InsertDevice(db *sql.DB, device Device) (int, error) {
 	return id, nil
 }
 
+// InsertBrand creates a brand, audited, and returns its id.
+func InsertBrand(db *sql.DB, audit Audit, label string) (int, error) {
+	return insertLabel(db, audit, "brand", AuditBrandCreate, label)
+}
+
+// InsertDeviceType creates a device category, audited, and returns its id.
+func InsertDeviceType(db *sql.DB, audit Audit, label string) (int, error) {
+	return insertLabel(db, audit, "device_type", AuditCategoryCreate, label)
+}
+
+// insertLabel inserts a row of a (id, label) table and records action.
+func insertLabel(db *sql.DB, audit Audit, table, action, label string) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var id int
+	if err := tx.QueryRow(`INSERT INTO `+table+` (label) VALUES ($1) RETURNING id`, label).Scan(&id); err != nil {
+		return 0, err
+	}
+	if err := audit.record(tx, action, table, id, nil, map[string]any{"label": label}); err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
 func SelectAllBrands(db *sql.DB) ([]Brand, error) {
 	query := `SELECT id, label FROM brand`
 	rows, err := db.Query(query)
@@ -68,11 +100,13 @@ func GetDeviceByID(id int) (Device, error) {
 	return device, nil
 }
 
+// InsertPDF inserts the record of a PDF the client uploads through a signed URL.
+// It stays pending until the upload is confirmed.
 func InsertPDF(pdf PDF) (int, error) {
 	var id int
 	query := `
-        INSERT INTO pdf (gcs_bucket, device_id, ocr_flag, filename, uploaded_at, last_access)
-        VALUES ($1, $2, $3, $4, $5, $6)
+        INSERT INTO pdf (gcs_bucket, device_id, ocr_flag, filename, uploaded_at, last_access, upload_status)
+        VALUES ($1, $2, $3, $4, $5, $6, 'pending')
         RETURNING id;
     `
 	err := DB.QueryRow(query, pdf.GCSBucket, pdf.DeviceID, pdf.OCRFlag, pdf.FileName, pdf.UploadedAt, pdf.LastAccess).Scan(&id)
@@ -82,6 +116,32 @@ func InsertPDF(pdf PDF) (int, error) {
 	return id, nil
 }
 
+// InsertUploadedPDF inserts the record of a PDF that is already in storage, with its size and SHA-256.
+func InsertUploadedPDF(db *sql.DB, pdf PDF, sizeBytes int64, sha256 string) (int, error) {
+	var id int
+	query := `
+        INSERT INTO pdf (gcs_bucket, device_id, ocr_flag, filename, uploaded_at, last_access, number_of_pages, sha256,
+                         upload_status, size_bytes, content_type)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, 'uploaded', $9, 'application/pdf')
+        RETURNING id;
+    `
+	err := db.QueryRow(query, pdf.GCSBucket, pdf.DeviceID, pdf.OCRFlag, pdf.FileName, pdf.UploadedAt, pdf.LastAccess, pdf.NumberOfPages, sha256, sizeBytes).Scan(&id)
+	if IsUniqueViolation(err) {
+		return 0, ErrDuplicatePDF
+	}
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// SelectPDFIDByDeviceAndSHA256 returns the id of the PDF with this content for the device, or sql.ErrNoRows.
+func SelectPDFIDByDeviceAndSHA256(db *sql.DB, deviceID int, sha256 string) (int, error) {
+	var id int
+	err := db.QueryRow(`SELECT id FROM pdf WHERE device_id = $1 AND sha256 = $2`, deviceID, sha256).Scan(&id)
+	return id, err
+}
+
 // InsertPDFPage inserts a new PDFPage record and returns its ID.
 func InsertPDFPage(page PDFPage) (int, error) {
 	var id int
@@ -98,11 +158,12 @@ func InsertPDFPage(page PDFPage) (int, error) {
 }
 
 // InsertPDFImage inserts a new PDFImage record and returns its ID.
+// The client uploads the image through a signed URL, so it stays pending until the upload is confirmed.
 func InsertPDFImage(img PDFImage) (int, error) {
 	var id int
 	query := `
-        INSERT INTO pdf_image (pdf_page_id, sequence, gcs_bucket, last_modified)
-        VALUES ($1, $2, $3, $4)
+        INSERT INTO pdf_image (pdf_page_id, sequence, gcs_bucket, last_modified, upload_status)
+        VALUES ($1, $2, $3, $4, 'pending')
         RETURNING id
     `
 	err := DB.QueryRow(query, img.PageID, img.Sequence, img.GCSBucket, img.LastModified).Scan(&id)
@@ -140,7 +201,7 @@ func UpdatePDF(pdfID int, ocrFlag bool, numberOfPages int) error {
 func SelectPDFByID(id int) (PDF, error) {
 	var pdf PDF
 	query := `
-        SELECT id, gcs_bucket, device_id, ocr_flag, filename, uploaded_at, last_access, number_of_pages
+        SELECT id, gcs_bucket, device_id, ocr_flag, filename, uploaded_at, last_access, number_of_pages, upload_status
         FROM pdf
         WHERE id = $1
     `
@@ -153,6 +214,7 @@ func SelectPDFByID(id int) (PDF, error) {
 		&pdf.UploadedAt,
 		&pdf.LastAccess,
 		&pdf.NumberOfPages,
+		&pdf.UploadStatus,
 	)
 	if err != nil {
 		return pdf, err
@@ -160,15 +222,28 @@ func SelectPDFByID(id int) (PDF, error) {
 	return pdf, nil
 }
 
-func DeletePDFChunk(chunkID int) error {
-	// Assuming you have foreign key constraints with ON DELETE CASCADE set up in your DB schema,
-	// deleting from the main chunk table will automatically delete related records.
-	// If not, you need to manually delete from related tables first.
+// DeletePDFChunk deletes a chunk, audited with its text. It returns sql.ErrNoRows if the chunk doesn't exist.
+func DeletePDFChunk(audit Audit, chunkID int) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
-	// Example for a table named pdf_chunk:
-	query := `DELETE FROM pdf_chunk WHERE id = $1`
-	_, err := DB.Exec(query, chunkID)
-	return err
+	// The device of the chunk is found through its relations to paragraphs and images,
+	// which go with the chunk through ON DELETE CASCADE
+	if err := invalidateCachedAnswers(tx, devicesOfChunk, chunkID); err != nil {
+		return err
+	}
+	var context sql.NullString
+	err = tx.QueryRow(`DELETE FROM pdf_chunk WHERE id = $1 RETURNING context`, chunkID).Scan(&context)
+	if err != nil {
+		return err
+	}
+	if err := audit.record(tx, AuditChunkDelete, "pdf_chunk", chunkID, map[string]any{"context": context.String}, nil); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 func SelectPDFParagraphByPDFIDAndPageNumber(pdfID int, pageNumber int) (PDFParagraph, error) {
@@ -31,6 +31,7 @@ type PDF struct {
     UploadedAt time.Time `json:"uploaded_at"`
     LastAccess time.Time `json:"last_access"`
 	NumberOfPages sql.NullInt32       `json:"number_of_pages"` // Total number of pages in the PDF
+	UploadStatus  string              `json:"upload_status"`
 }
 
 type PDFPage struct {
@@ -72,4 +73,31 @@ type PDFChunkImage struct {
     ID         int `json:"id"`
     ChunkID    int `json:"pdf_chunk_id"` // Reference to the PDFChunk
     ImageID    int `json:"pdf_image_id"` // Reference to the PDFImage
+}
+
+// ExtractResult is the result_json returned by the extract service (see REGULATION.md).
+type ExtractResult struct {
+	Pages []struct {
+		Page struct {
+			Imgs []struct {
+				GcsBucketName string `json:"gcs_bucket_name"`
+				Order         int    `json:"order"`
+				RetrievedPath string `json:"retrieved_path"`
+			} `json:"imgs"`
+			PageNumber int    `json:"page_number"`
+			Paragraph  string `json:"paragraph"`
+		} `json:"page"`
+	} `json:"pages"`
+	PDFNumberOfPages int `json:"pdf_number_of_pages"`
+}
+
+// EmbeddedChunk is one chunk of the result_json returned by the chunk and embed service.
+type EmbeddedChunk struct {
+	Context string    `json:"context"`
+	Vector  []float32 `json:"vector"`
+}
+
+// ChunkResult is the result_json returned by the chunk and embed service.
+type ChunkResult struct {
+	Chunks []EmbeddedChunk `json:"chunks"`
 }
\ No newline at end of file
@@ -0,0 +1,93 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// InsertOutboxEmail enqueues an email, to be sent right away by the email worker.
+func InsertOutboxEmail(db *sql.DB, email OutboxEmail) (int64, error) {
+	var id int64
+	err := db.QueryRow(`
+        INSERT INTO email_outbox (kind, recipient, subject, text_body, html_body)
+        VALUES ($1, $2, $3, $4, $5)
+        RETURNING id
+    `, email.Kind, email.Recipient, email.Subject, email.TextBody, email.HTMLBody).Scan(&id)
+	return id, err
+}
+
+// ClaimOutboxEmails locks up to limit due pending emails for lease and counts an attempt for each.
+// Emails of a worker that stopped are due again once the lease expires.
+func ClaimOutboxEmails(db *sql.DB, lease time.Duration, limit int) ([]OutboxEmail, error) {
+	rows, err := db.Query(`
+        UPDATE email_outbox
+        SET attempts = attempts + 1,
+            next_attempt_at = NOW() + $1 * INTERVAL '1 second'
+        WHERE id IN (
+            SELECT id
+            FROM email_outbox
+            WHERE status = 'pending' AND next_attempt_at <= NOW()
+            ORDER BY next_attempt_at, id
+            FOR UPDATE SKIP LOCKED
+            LIMIT $2
+        )
+        RETURNING id, kind, recipient, subject, text_body, html_body, status, attempts, last_error, created_at, sent_at
+    `, int(lease.Seconds()), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var emails []OutboxEmail
+	for rows.Next() {
+		var e OutboxEmail
+		if err := rows.Scan(&e.ID, &e.Kind, &e.Recipient, &e.Subject, &e.TextBody, &e.HTMLBody,
+			&e.Status, &e.Attempts, &e.LastError, &e.CreatedAt, &e.SentAt); err != nil {
+			return nil, err
+		}
+		emails = append(emails, e)
+	}
+	return emails, rows.Err()
+}
+
+// MarkOutboxEmailSent marks an email as sent and clears its bodies.
+func MarkOutboxEmailSent(db *sql.DB, id int64) error {
+	_, err := db.Exec(`
+        UPDATE email_outbox
+        SET status = 'sent', sent_at = NOW(), text_body = '', html_body = '', last_error = NULL
+        WHERE id = $1
+    `, id)
+	return err
+}
+
+// RetryOutboxEmail records a failed send; the email is tried again after delay.
+func RetryOutboxEmail(db *sql.DB, id int64, errText string, delay time.Duration) error {
+	_, err := db.Exec(`
+        UPDATE email_outbox
+        SET last_error = $2, next_attempt_at = NOW() + $3 * INTERVAL '1 second'
+        WHERE id = $1
+    `, id, errText, int(delay.Seconds()))
+	return err
+}
+
+// FailOutboxEmail gives up on an email and clears its bodies.
+func FailOutboxEmail(db *sql.DB, id int64, errText string) error {
+	_, err := db.Exec(`
+        UPDATE email_outbox
+        SET status = 'failed', last_error = $2, text_body = '', html_body = ''
+        WHERE id = $1
+    `, id, errText)
+	return err
+}
+
+// DeleteOldOutboxEmails removes the sent and failed emails older than age.
+func DeleteOldOutboxEmails(db *sql.DB, age time.Duration) (int64, error) {
+	result, err := db.Exec(`
+        DELETE FROM email_outbox
+        WHERE status IN ('sent', 'failed') AND created_at < NOW() - $1 * INTERVAL '1 second'
+    `, int(age.Seconds()))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Email outbox statuses
+const (
+	EmailStatusPending = "pending"
+	EmailStatusSent    = "sent"
+	EmailStatusFailed  = "failed"
+)
+
+// OutboxEmail is a row of email_outbox.
+type OutboxEmail struct {
+	ID        int64          `json:"id"`
+	Kind      string         `json:"kind"`
+	Recipient string         `json:"recipient"`
+	Subject   string         `json:"subject"`
+	TextBody  string         `json:"-"`
+	HTMLBody  string         `json:"-"`
+	Status    string         `json:"status"`
+	Attempts  int            `json:"attempts"`
+	LastError sql.NullString `json:"last_error"`
+	CreatedAt time.Time      `json:"created_at"`
+	SentAt    sql.NullTime   `json:"sent_at"`
+}
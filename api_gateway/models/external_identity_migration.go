@@ -0,0 +1,136 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// InsertOIDCLoginState stores a pending OIDC login for ttl.
+func InsertOIDCLoginState(db *sql.DB, state OIDCLoginState, ttl time.Duration) error {
+	_, err := db.Exec(`
+        INSERT INTO oidc_login_state (state, provider, code_verifier, nonce, expires_at)
+        VALUES ($1, $2, $3, $4, NOW() + $5 * INTERVAL '1 second')
+    `, state.State, state.Provider, state.CodeVerifier, state.Nonce, ttl.Seconds())
+	return err
+}
+
+// ConsumeOIDCLoginState deletes and returns a pending login of the provider.
+// It returns sql.ErrNoRows if the state is unknown, expired or already used.
+func ConsumeOIDCLoginState(db *sql.DB, provider, state string) (OIDCLoginState, error) {
+	s := OIDCLoginState{State: state, Provider: provider}
+	var valid bool
+	err := db.QueryRow(`
+        DELETE FROM oidc_login_state
+        WHERE state = $1 AND provider = $2
+        RETURNING code_verifier, nonce, expires_at > NOW()
+    `, state, provider).Scan(&s.CodeVerifier, &s.Nonce, &valid)
+	if err == nil && !valid {
+		err = sql.ErrNoRows
+	}
+	return s, err
+}
+
+// SignInExternalIdentity returns the account of an external identity, in a transaction:
+//   - the account already linked to the identity;
+//   - else, if identity.LinkByEmail, the (non admin) account registered with the same email;
+//     the identity gets linked to it;
+//   - else a new account with the user role.
+//
+// The account gets identity.Role, or the user role if identity.RoleMapped and no mapping matched,
+// so leaving the mapped groups takes the role away. A role granting admin_only permissions is
+// refused. created reports whether the account was created.
+func SignInExternalIdentity(db *sql.DB, identity ExternalIdentity) (accountID int, created bool, err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, false, err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+        UPDATE external_identity SET last_login_at = NOW(), email = COALESCE(NULLIF($3, ''), email)
+        WHERE provider = $1 AND subject = $2
+        RETURNING account_id
+    `, identity.Provider, identity.Subject, identity.Email).Scan(&accountID)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, false, err
+	}
+
+	if err == sql.ErrNoRows {
+		email := strings.TrimSpace(identity.Email)
+		linked := false
+		if identity.LinkByEmail && email != "" {
+			err = tx.QueryRow(`
+                SELECT a.id FROM account a
+                WHERE lower(a.username) = lower($1)
+                  AND NOT EXISTS (SELECT 1 FROM admin WHERE admin.id = a.id)
+                FOR UPDATE
+            `, email).Scan(&accountID)
+			if err != nil && err != sql.ErrNoRows {
+				return 0, false, err
+			}
+			linked = err == nil
+		}
+
+		if !linked {
+			username := email
+			if username == "" {
+				username = identity.Provider + ":" + identity.Subject
+			}
+			displayName := strings.TrimSpace(identity.Name)
+			if displayName == "" {
+				displayName = username
+			}
+			err = tx.QueryRow(`
+                INSERT INTO account (username, role_id, display_name)
+                SELECT $1, id, $2 FROM role WHERE label = $3
+                RETURNING id
+            `, username, displayName, UserPermission).Scan(&accountID)
+			if IsUniqueViolation(err) {
+				return 0, false, ErrExternalEmailTaken
+			}
+			if err != nil {
+				return 0, false, err
+			}
+			created = true
+		}
+
+		_, err = tx.Exec(`
+            INSERT INTO external_identity (provider, subject, account_id, email)
+            VALUES ($1, $2, $3, $4)
+        `, identity.Provider, identity.Subject, accountID, identity.Email)
+		if err != nil {
+			return 0, false, err
+		}
+	}
+
+	role := identity.Role
+	if role == "" && identity.RoleMapped {
+		role = UserPermission
+	}
+	if role != "" {
+		var roleID int
+		var adminOnly bool
+		err := tx.QueryRow(`
+            SELECT r.id, EXISTS (
+                SELECT 1 FROM role_permission rp JOIN permission p ON p.id = rp.permission_id
+                WHERE rp.role_id = r.id AND p.admin_only
+            )
+            FROM role r WHERE r.label = $1
+        `, role).Scan(&roleID, &adminOnly)
+		if err == sql.ErrNoRows {
+			return 0, false, fmt.Errorf("mapped role %q does not exist", role)
+		}
+		if err != nil {
+			return 0, false, err
+		}
+		if adminOnly {
+			return 0, false, fmt.Errorf("mapped role %q grants admin-only permissions", role)
+		}
+		if _, err := tx.Exec(`UPDATE account SET role_id = $1 WHERE id = $2`, roleID, accountID); err != nil {
+			return 0, false, err
+		}
+	}
+	return accountID, created, tx.Commit()
+}
@@ -0,0 +1,30 @@
+package models
+
+import "errors"
+
+// ErrExternalEmailTaken is returned when creating an account for an external identity
+// whose email is the username of an account that can't be linked.
+var ErrExternalEmailTaken = errors.New("email is already used by another account")
+
+// ExternalIdentity is a user signing in with an OIDC provider.
+type ExternalIdentity struct {
+	Provider string
+	Subject  string
+	Email    string
+	// LinkByEmail allows linking the identity to the account registered with Email.
+	LinkByEmail bool
+	Name        string
+	// Role is the role label mapped from the provider groups.
+	Role string
+	// RoleMapped reports whether the provider maps groups to roles: without Role, the account is
+	// then reset to the user role. Otherwise the account role is kept.
+	RoleMapped bool
+}
+
+// OIDCLoginState is a pending OIDC login.
+type OIDCLoginState struct {
+	State        string
+	Provider     string
+	CodeVerifier string
+	Nonce        string
+}
@@ -0,0 +1,118 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// UpsertFeedback stores the rating of an answer by an account, replacing its previous rating.
+func UpsertFeedback(db *sql.DB, feedback AnswerFeedback) (AnswerFeedback, error) {
+	err := db.QueryRow(`
+        INSERT INTO answer_feedback (request_response_pair_id, account_id, rating, reason, comment)
+        VALUES ($1, $2, $3, $4, $5)
+        ON CONFLICT (request_response_pair_id, account_id) DO UPDATE
+            SET rating = EXCLUDED.rating, reason = EXCLUDED.reason, comment = EXCLUDED.comment, updated_at = NOW()
+        RETURNING created_at, updated_at
+    `, feedback.PairID, feedback.AccountID, feedback.Rating, feedback.Reason, feedback.Comment).
+		Scan(&feedback.CreatedAt, &feedback.UpdatedAt)
+	return feedback, err
+}
+
+// DeleteFeedback removes the rating of an answer by an account. It returns sql.ErrNoRows if
+// the account didn't rate the answer.
+func DeleteFeedback(db *sql.DB, pairID, accountID int) error {
+	result, err := db.Exec(
+		`DELETE FROM answer_feedback WHERE request_response_pair_id = $1 AND account_id = $2`,
+		pairID, accountID,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// feedbackReviews relates the ratings to their answer and to the device of the conversation.
+const feedbackReviews = `
+    FROM answer_feedback f
+    JOIN request_response_pair rrp ON rrp.id = f.request_response_pair_id
+    LEFT JOIN LATERAL (
+        SELECT device_id FROM device_conversation WHERE conversation_id = rrp.conversation_id LIMIT 1
+    ) dc ON true
+    LEFT JOIN device d ON d.id = dc.device_id`
+
+// SelectFeedbackReviews returns a page of the rated answers matching the filter, last rated
+// first, with their citations, and how many match.
+func SelectFeedbackReviews(db *sql.DB, filter FeedbackFilter) ([]FeedbackReview, int, error) {
+	var where []string
+	var args []any
+	add := func(condition string, arg any) {
+		args = append(args, arg)
+		where = append(where, fmt.Sprintf(condition, len(args)))
+	}
+	if filter.DeviceID != 0 {
+		add(`dc.device_id = $%d`, filter.DeviceID)
+	}
+	if filter.BrandID != 0 {
+		add(`d.brand_id = $%d`, filter.BrandID)
+	}
+	if filter.Rating != "" {
+		add(`f.rating = $%d`, filter.Rating)
+	}
+	conditions := ""
+	if len(where) > 0 {
+		conditions = " WHERE " + strings.Join(where, " AND ")
+	}
+
+	var total int
+	if err := db.QueryRow(`SELECT COUNT(*)`+feedbackReviews+conditions, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	args = append(args, filter.PageSize, (filter.Page-1)*filter.PageSize)
+	rows, err := db.Query(`
+        SELECT f.request_response_pair_id, f.account_id, f.rating, f.reason, f.comment, f.created_at, f.updated_at,
+               rrp.conversation_id, dc.device_id, d.label, d.brand_id, COALESCE(rrp.request, ''), COALESCE(rrp.response, '')`+
+		feedbackReviews+conditions+
+		fmt.Sprintf(` ORDER BY f.updated_at DESC, f.request_response_pair_id DESC, f.account_id LIMIT $%d OFFSET $%d`, len(args)-1, len(args)), args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	reviews := []FeedbackReview{}
+	var pairIDs []int
+	for rows.Next() {
+		var r FeedbackReview
+		err := rows.Scan(&r.PairID, &r.AccountID, &r.Rating, &r.Reason, &r.Comment, &r.CreatedAt, &r.UpdatedAt,
+			&r.ConversationID, &r.DeviceID, &r.DeviceLabel, &r.BrandID, &r.Query, &r.Response)
+		if err != nil {
+			return nil, 0, err
+		}
+		reviews = append(reviews, r)
+		pairIDs = append(pairIDs, r.PairID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	if len(reviews) == 0 {
+		return reviews, total, nil
+	}
+
+	citations, err := SelectPairCitations(db, pairIDs)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := range reviews {
+		reviews[i].Citations = citations[reviews[i].PairID]
+		if reviews[i].Citations == nil {
+			reviews[i].Citations = []Citation{}
+		}
+	}
+	return reviews, total, nil
+}
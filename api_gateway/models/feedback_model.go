@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// Ratings of an answer (answer_feedback.rating).
+const (
+	RatingUp   = "up"
+	RatingDown = "down"
+)
+
+// FeedbackReasons are the reasons a user can give with a rating (answer_feedback.reason).
+var FeedbackReasons = []string{"incorrect", "incomplete", "irrelevant", "wrong_device", "outdated", "other"}
+
+// AnswerFeedback is the rating of an answer by the account that asked it.
+type AnswerFeedback struct {
+	PairID    int       `json:"request_response_pair_id"`
+	AccountID int       `json:"account_id"`
+	Rating    string    `json:"rating"`
+	Reason    *string   `json:"reason"`
+	Comment   *string   `json:"comment"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// FeedbackReview is a rated answer in the review queue of the admins, with what was asked,
+// what was answered and the pages it was answered from.
+type FeedbackReview struct {
+	AnswerFeedback
+	ConversationID string     `json:"conversation_id"`
+	DeviceID       *int       `json:"device_id"`
+	DeviceLabel    *string    `json:"device_label"`
+	BrandID        *int       `json:"brand_id"`
+	Query          string     `json:"query"`
+	Response       string     `json:"response"`
+	Citations      []Citation `json:"citations"`
+}
+
+// FeedbackFilter selects the rated answers an admin reviews. Zero fields don't filter.
+type FeedbackFilter struct {
+	DeviceID int
+	BrandID  int
+	Rating   string
+	Page     int
+	PageSize int
+}
@@ -0,0 +1,109 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+var (
+	// ErrGoogleAccountNotFound is returned when no account is linked to, or can be linked to, a Google identity.
+	ErrGoogleAccountNotFound = errors.New("no account for this Google identity")
+	// ErrGoogleEmailNotVerified is returned when creating an account for a Google identity whose email isn't verified.
+	ErrGoogleEmailNotVerified = errors.New("Google email is not verified")
+	// ErrGoogleEmailTaken is returned when the Google email is the username of an account that can't be linked,
+	// or the matching account is already linked to another Google identity.
+	ErrGoogleEmailTaken = errors.New("email is already used by another account")
+)
+
+// SelectAccountByGoogleUser returns the account linked to the Google identity, or the
+// account with the same verified email that it would be linked to. It returns ErrGoogleAccountNotFound otherwise.
+func SelectAccountByGoogleUser(db *sql.DB, user GoogleUser) (int, error) {
+	var accountID int
+	err := db.QueryRow(`SELECT id FROM google_user WHERE google_id = $1`, user.GoogleID).Scan(&accountID)
+	if err != sql.ErrNoRows {
+		return accountID, err
+	}
+	if !user.EmailVerified || user.GoogleEmail == "" {
+		return 0, ErrGoogleAccountNotFound
+	}
+	err = db.QueryRow(`SELECT id FROM "user" WHERE lower(email) = lower($1)`, user.GoogleEmail).Scan(&accountID)
+	if err == sql.ErrNoRows {
+		return 0, ErrGoogleAccountNotFound
+	}
+	return accountID, err
+}
+
+// SignInGoogleUser returns the account of a Google identity, in a transaction:
+//   - the account already linked to the Google identity;
+//   - else the account registered with the same email, if Google verified it; the identity gets linked to it;
+//   - else, if create is set, a new account with the user role.
+//
+// created reports whether the account was created.
+func SignInGoogleUser(db *sql.DB, user GoogleUser, create bool) (accountID int, created bool, err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, false, err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`SELECT id FROM google_user WHERE google_id = $1`, user.GoogleID).Scan(&accountID)
+	if err == nil {
+		return accountID, false, tx.Commit()
+	}
+	if err != sql.ErrNoRows {
+		return 0, false, err
+	}
+
+	email := strings.TrimSpace(user.GoogleEmail)
+	if user.EmailVerified && email != "" {
+		err = tx.QueryRow(`SELECT id FROM "user" WHERE lower(email) = lower($1) FOR UPDATE`, email).Scan(&accountID)
+		if err == nil {
+			if err := insertGoogleUser(tx, accountID, user); err != nil {
+				return 0, false, err
+			}
+			return accountID, false, tx.Commit()
+		}
+		if err != sql.ErrNoRows {
+			return 0, false, err
+		}
+	}
+
+	if !create {
+		return 0, false, ErrGoogleAccountNotFound
+	}
+	if !user.EmailVerified || email == "" {
+		return 0, false, ErrGoogleEmailNotVerified
+	}
+	displayName := strings.TrimSpace(user.Name)
+	if displayName == "" {
+		displayName = email
+	}
+	err = tx.QueryRow(`
+        INSERT INTO account (username, role_id, display_name)
+        SELECT $1, id, $2 FROM role WHERE label = $3
+        RETURNING id
+    `, email, displayName, UserPermission).Scan(&accountID)
+	if IsUniqueViolation(err) {
+		return 0, false, ErrGoogleEmailTaken
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	if err := insertGoogleUser(tx, accountID, user); err != nil {
+		return 0, false, err
+	}
+	return accountID, true, tx.Commit()
+}
+
+func insertGoogleUser(tx *sql.Tx, accountID int, user GoogleUser) error {
+	_, err := tx.Exec(
+		`INSERT INTO google_user (id, google_id, email) VALUES ($1, $2, $3)`,
+		accountID, user.GoogleID, user.GoogleEmail,
+	)
+	if IsUniqueViolation(err) {
+		// The account is linked to another Google identity, or the identity was linked concurrently
+		return ErrGoogleEmailTaken
+	}
+	return err
+}
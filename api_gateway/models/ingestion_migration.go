@@ -0,0 +1,225 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/pgvector/pgvector-go"
+)
+
+// InsertExtractionResult stores the pages, paragraphs and images of an extraction result
+// and marks the PDF as OCR processed, all in one transaction.
+// Re-extracting a PDF replaces its previous pages, paragraphs, images and their chunks.
+func InsertExtractionResult(db *sql.DB, pdfID int, result ExtractResult) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Lock the PDF so two extractions of the same PDF can't interleave.
+	if _, err := tx.Exec(`SELECT id FROM pdf WHERE id = $1 FOR UPDATE`, pdfID); err != nil {
+		return fmt.Errorf("failed to lock PDF: %w", err)
+	}
+	if err := invalidateCachedAnswers(tx, devicesOfPDF, pdfID); err != nil {
+		return err
+	}
+
+	if err := deletePDFContent(tx, pdfID); err != nil {
+		return fmt.Errorf("failed to delete previous PDF content: %w", err)
+	}
+
+	pageIDs, err := nextIDs(tx, "pdf_page", len(result.Pages))
+	if err != nil {
+		return fmt.Errorf("failed to allocate PDF page ids: %w", err)
+	}
+
+	now := time.Now()
+	var pages, paragraphs, images [][]any
+	for i, pageWrap := range result.Pages {
+		page := pageWrap.Page
+		pages = append(pages, []any{pageIDs[i], pdfID, page.PageNumber})
+		paragraphs = append(paragraphs, []any{pageIDs[i], page.Paragraph, now})
+		for _, img := range page.Imgs {
+			images = append(images, []any{pageIDs[i], img.Order, img.GcsBucketName, now})
+		}
+	}
+
+	if err := copyRows(tx, "pdf_page", []string{"id", "pdf_id", "page_number"}, pages); err != nil {
+		return fmt.Errorf("failed to insert PDF pages: %w", err)
+	}
+	if err := copyRows(tx, "pdf_paragraph", []string{"pdf_page_id", "context", "last_modified"}, paragraphs); err != nil {
+		return fmt.Errorf("failed to insert PDF paragraphs: %w", err)
+	}
+	if err := copyRows(tx, "pdf_image", []string{"pdf_page_id", "sequence", "gcs_bucket", "last_modified"}, images); err != nil {
+		return fmt.Errorf("failed to insert PDF images: %w", err)
+	}
+
+	// The extracted texts are the first revisions of the paragraphs
+	_, err = tx.Exec(`
+        INSERT INTO pdf_paragraph_revision (pdf_paragraph_id, content, source)
+        SELECT pp.id, pp.context, 'ocr'
+        FROM pdf_paragraph pp
+        JOIN pdf_page pg ON pp.pdf_page_id = pg.id
+        WHERE pg.pdf_id = $1 AND pp.context IS NOT NULL
+    `, pdfID)
+	if err != nil {
+		return fmt.Errorf("failed to insert PDF paragraph revisions: %w", err)
+	}
+
+	// Set ocr_flag = true and update number_of_pages
+	_, err = tx.Exec(`UPDATE pdf SET ocr_flag = true, number_of_pages = $1 WHERE id = $2`, result.PDFNumberOfPages, pdfID)
+	if err != nil {
+		return fmt.Errorf("failed to update PDF info: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// deletePDFContent deletes the pages of a PDF and the chunks embedded from them.
+// Paragraphs, images and chunk relations go with their page through ON DELETE CASCADE.
+func deletePDFContent(tx *sql.Tx, pdfID int) error {
+	_, err := tx.Exec(`
+        DELETE FROM pdf_chunk
+        WHERE id IN (
+            SELECT cpp.pdf_chunk_id
+            FROM pdf_chunk_pdf_paragraph cpp
+            JOIN pdf_paragraph pp ON cpp.pdf_paragraph_id = pp.id
+            JOIN pdf_page pg ON pp.pdf_page_id = pg.id
+            WHERE pg.pdf_id = $1
+            UNION
+            SELECT cpi.pdf_chunk_id
+            FROM pdf_chunk_pdf_image cpi
+            JOIN pdf_image img ON cpi.pdf_image_id = img.id
+            JOIN pdf_page pg ON img.pdf_page_id = pg.id
+            WHERE pg.pdf_id = $1
+        )
+    `, pdfID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`DELETE FROM pdf_page WHERE pdf_id = $1`, pdfID)
+	return err
+}
+
+// ReplaceContentChunks saves the new context of a paragraph or alt text of an image as a new revision
+// and replaces its chunks in one transaction, audited with the old text. restoredFrom is the id of
+// the revision restored, 0 for an edit. It returns sql.ErrNoRows if the paragraph or image doesn't exist.
+func ReplaceContentChunks(db *sql.DB, audit Audit, kind RevisionKind, ownerID int, content string, restoredFrom int64, chunks []EmbeddedChunk) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var old sql.NullString
+	err = tx.QueryRow(fmt.Sprintf(`SELECT %s FROM %s WHERE id = $1 FOR UPDATE`, kind.contentColumn, kind.ownerTable), ownerID).Scan(&old)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(fmt.Sprintf(`UPDATE %s SET %s = $1, last_modified = NOW() WHERE id = $2`, kind.ownerTable, kind.contentColumn), content, ownerID)
+	if err != nil {
+		return fmt.Errorf("failed to update %s: %w", kind.Name, err)
+	}
+
+	revisionID, err := insertRevision(tx, kind, audit, ownerID, content, restoredFrom)
+	if err != nil {
+		return err
+	}
+	if err := invalidateCachedAnswers(tx, devicesOfPageRow(kind.ownerTable), ownerID); err != nil {
+		return err
+	}
+	if err := replaceChunks(tx, kind.relationTable, kind.ownerColumn, ownerID, chunks); err != nil {
+		return err
+	}
+
+	action := kind.updateAction
+	after := map[string]any{kind.contentColumn: content, "chunks": len(chunks), "revision_id": revisionID}
+	if restoredFrom != 0 {
+		action = kind.restoreAction
+		after["restored_from"] = restoredFrom
+	}
+	err = audit.record(tx, action, kind.ownerTable, ownerID, map[string]any{kind.contentColumn: old.String}, after)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// replaceChunks deletes the chunks linked to a paragraph or image through relationTable
+// and bulk inserts the new chunks and their relations.
+func replaceChunks(tx *sql.Tx, relationTable, ownerColumn string, ownerID int, chunks []EmbeddedChunk) error {
+	_, err := tx.Exec(fmt.Sprintf(`
+        DELETE FROM pdf_chunk
+        WHERE id IN (SELECT pdf_chunk_id FROM %s WHERE %s = $1)
+    `, relationTable, ownerColumn), ownerID)
+	if err != nil {
+		return fmt.Errorf("failed to delete chunks: %w", err)
+	}
+
+	chunkIDs, err := nextIDs(tx, "pdf_chunk", len(chunks))
+	if err != nil {
+		return fmt.Errorf("failed to allocate chunk ids: %w", err)
+	}
+
+	var chunkRows, relationRows [][]any
+	for i, chunk := range chunks {
+		chunkRows = append(chunkRows, []any{chunkIDs[i], chunk.Context, pgvector.NewVector(chunk.Vector)})
+		relationRows = append(relationRows, []any{chunkIDs[i], ownerID})
+	}
+
+	if err := copyRows(tx, "pdf_chunk", []string{"id", "context", "embedding"}, chunkRows); err != nil {
+		return fmt.Errorf("failed to insert chunks: %w", err)
+	}
+	if err := copyRows(tx, relationTable, []string{"pdf_chunk_id", ownerColumn}, relationRows); err != nil {
+		return fmt.Errorf("failed to insert chunk relations: %w", err)
+	}
+	return nil
+}
+
+// nextIDs reserves n ids from the serial sequence of table, so rows can be bulk inserted with COPY
+// and still be referenced by the rows inserted after them.
+func nextIDs(tx *sql.Tx, table string, n int) ([]int, error) {
+	if n == 0 {
+		return nil, nil
+	}
+	rows, err := tx.Query(`SELECT nextval(pg_get_serial_sequence($1, 'id')) FROM generate_series(1, $2)`, table, n)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]int, 0, n)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// copyRows bulk inserts rows into table with COPY FROM STDIN.
+func copyRows(tx *sql.Tx, table string, columns []string, rows [][]any) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	stmt, err := tx.Prepare(pq.CopyIn(table, columns...))
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if _, err := stmt.Exec(row...); err != nil {
+			stmt.Close()
+			return err
+		}
+	}
+	if _, err := stmt.Exec(); err != nil {
+		stmt.Close()
+		return err
+	}
+	return stmt.Close()
+}
@@ -0,0 +1,122 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+const extractionJobColumns = `id, pdf_id, status, error, attempts, created_at, started_at, finished_at`
+
+func scanExtractionJob(row interface{ Scan(...any) error }) (ExtractionJob, error) {
+	var job ExtractionJob
+	err := row.Scan(
+		&job.ID,
+		&job.PDFID,
+		&job.Status,
+		&job.Error,
+		&job.Attempts,
+		&job.CreatedAt,
+		&job.StartedAt,
+		&job.FinishedAt,
+	)
+	return job, err
+}
+
+// InsertExtractionJob queues a new extraction job for the PDF and returns its ID.
+// If the PDF already has a queued or running job, that job's ID is returned instead and created is false.
+func InsertExtractionJob(db *sql.DB, pdfID int) (id int, created bool, err error) {
+	query := `
+        INSERT INTO extraction_job (pdf_id, status)
+        VALUES ($1, 'queued')
+        ON CONFLICT (pdf_id) WHERE status IN ('queued', 'running') DO NOTHING
+        RETURNING id
+    `
+	err = db.QueryRow(query, pdfID).Scan(&id)
+	if err == nil {
+		return id, true, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, false, err
+	}
+
+	query = `
+        SELECT id
+        FROM extraction_job
+        WHERE pdf_id = $1 AND status IN ('queued', 'running')
+        LIMIT 1
+    `
+	err = db.QueryRow(query, pdfID).Scan(&id)
+	return id, false, err
+}
+
+func SelectExtractionJobByID(db *sql.DB, id int) (ExtractionJob, error) {
+	query := `SELECT ` + extractionJobColumns + ` FROM extraction_job WHERE id = $1`
+	return scanExtractionJob(db.QueryRow(query, id))
+}
+
+// ClaimExtractionJob moves the oldest queued job, or a running job whose lease has expired, to running
+// and locks it for the given lease. It returns sql.ErrNoRows when there is nothing to do.
+func ClaimExtractionJob(db *sql.DB, lease time.Duration) (ExtractionJob, error) {
+	query := `
+        UPDATE extraction_job
+        SET status = 'running',
+            attempts = attempts + 1,
+            started_at = NOW(),
+            locked_until = NOW() + $1 * INTERVAL '1 second'
+        WHERE id = (
+            SELECT id
+            FROM extraction_job
+            WHERE status = 'queued'
+               OR (status = 'running' AND locked_until < NOW())
+            ORDER BY id
+            FOR UPDATE SKIP LOCKED
+            LIMIT 1
+        )
+        RETURNING ` + extractionJobColumns
+	return scanExtractionJob(db.QueryRow(query, int(lease.Seconds())))
+}
+
+// ExtendExtractionJobLease pushes locked_until forward while the worker is still busy with the job.
+func ExtendExtractionJobLease(db *sql.DB, id int, lease time.Duration) error {
+	query := `
+        UPDATE extraction_job
+        SET locked_until = NOW() + $1 * INTERVAL '1 second'
+        WHERE id = $2 AND status = 'running'
+    `
+	_, err := db.Exec(query, int(lease.Seconds()), id)
+	return err
+}
+
+// FinishExtractionJob marks a running job as succeeded or failed.
+func FinishExtractionJob(db *sql.DB, id int, status string, errText string) error {
+	query := `
+        UPDATE extraction_job
+        SET status = $1, error = NULLIF($2, ''), finished_at = NOW(), locked_until = NULL
+        WHERE id = $3
+    `
+	_, err := db.Exec(query, status, errText, id)
+	return err
+}
+
+// RequeueExtractionJob puts a failed running job back in the queue so it is retried.
+func RequeueExtractionJob(db *sql.DB, id int, errText string) error {
+	query := `
+        UPDATE extraction_job
+        SET status = 'queued', error = NULLIF($1, ''), locked_until = NULL
+        WHERE id = $2
+    `
+	_, err := db.Exec(query, errText, id)
+	return err
+}
+
+// ReleaseExtractionJob puts a running job back in the queue without counting the claim as an attempt,
+// e.g. when no OCR agent was free to run it.
+func ReleaseExtractionJob(db *sql.DB, id int) error {
+	query := `
+        UPDATE extraction_job
+        SET status = 'queued', attempts = GREATEST(attempts - 1, 0), locked_until = NULL
+        WHERE id = $1
+    `
+	_, err := db.Exec(query, id)
+	return err
+}
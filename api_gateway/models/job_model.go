@@ -0,0 +1,25 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Extraction job statuses
+const (
+	JobStatusQueued    = "queued"
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	JobStatusFailed    = "failed"
+)
+
+type ExtractionJob struct {
+	ID         int            `json:"id"`
+	PDFID      int            `json:"pdf_id"`
+	Status     string         `json:"status"`
+	Error      sql.NullString `json:"error"`
+	Attempts   int            `json:"attempts"`
+	CreatedAt  time.Time      `json:"created_at"`
+	StartedAt  sql.NullTime   `json:"started_at"`
+	FinishedAt sql.NullTime   `json:"finished_at"`
+}
@@ -0,0 +1,137 @@
+package models
+
+import (
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+// SelectAdminTOTP returns the TOTP enrollment of an admin, sql.ErrNoRows if there is none.
+func SelectAdminTOTP(db *sql.DB, adminID int) (AdminTOTP, error) {
+	totp := AdminTOTP{AdminID: adminID}
+	err := db.QueryRow(`
+        SELECT secret, confirmed_at, last_used_step FROM admin_totp WHERE admin_id = $1
+    `, adminID).Scan(&totp.Secret, &totp.ConfirmedAt, &totp.LastUsedStep)
+	return totp, err
+}
+
+// StartAdminTOTPEnrollment stores a new, unconfirmed TOTP secret for the admin, replacing
+// an enrollment that wasn't confirmed. It returns ErrMFAAlreadyEnabled if TOTP is already enabled.
+func StartAdminTOTPEnrollment(db *sql.DB, adminID int, secret string) error {
+	result, err := db.Exec(`
+        INSERT INTO admin_totp (admin_id, secret) VALUES ($1, $2)
+        ON CONFLICT (admin_id) DO UPDATE SET secret = EXCLUDED.secret, last_used_step = 0, created_at = NOW()
+        WHERE admin_totp.confirmed_at IS NULL
+    `, adminID, secret)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrMFAAlreadyEnabled
+	}
+	return nil
+}
+
+// ConfirmAdminTOTP enables the pending enrollment of the admin, with step as the code just used,
+// and replaces its recovery codes. It returns ErrMFANotEnrolled if no enrollment is pending.
+func ConfirmAdminTOTP(db *sql.DB, adminID int, step int64, recoveryHashes []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+        UPDATE admin_totp SET confirmed_at = NOW(), last_used_step = $2
+        WHERE admin_id = $1 AND confirmed_at IS NULL
+    `, adminID, step)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrMFANotEnrolled
+	}
+	if err := setRecoveryCodes(tx, adminID, recoveryHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UseTOTPStep records step as the last code used by the admin. It reports false if a code
+// of this step or a later one was already used (the code is replayed).
+func UseTOTPStep(db *sql.DB, adminID int, step int64) (bool, error) {
+	result, err := db.Exec(`
+        UPDATE admin_totp SET last_used_step = $2
+        WHERE admin_id = $1 AND confirmed_at IS NOT NULL AND last_used_step < $2
+    `, adminID, step)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+// UseRecoveryCode marks the unused recovery code with this hash as used.
+// It reports false if the admin has no such unused code.
+func UseRecoveryCode(db *sql.DB, adminID int, codeHash string) (bool, error) {
+	result, err := db.Exec(`
+        UPDATE admin_recovery_code SET used_at = NOW()
+        WHERE admin_id = $1 AND code_hash = $2 AND used_at IS NULL
+    `, adminID, codeHash)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+// CountRecoveryCodes returns the number of unused recovery codes of the admin.
+func CountRecoveryCodes(db *sql.DB, adminID int) (int, error) {
+	var n int
+	err := db.QueryRow(`
+        SELECT COUNT(*) FROM admin_recovery_code WHERE admin_id = $1 AND used_at IS NULL
+    `, adminID).Scan(&n)
+	return n, err
+}
+
+// ReplaceRecoveryCodes invalidates the recovery codes of the admin and stores new ones.
+func ReplaceRecoveryCodes(db *sql.DB, adminID int, recoveryHashes []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := setRecoveryCodes(tx, adminID, recoveryHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func setRecoveryCodes(tx *sql.Tx, adminID int, recoveryHashes []string) error {
+	if _, err := tx.Exec(`DELETE FROM admin_recovery_code WHERE admin_id = $1`, adminID); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`
+        INSERT INTO admin_recovery_code (admin_id, code_hash)
+        SELECT $1, h FROM unnest($2::text[]) AS h
+    `, adminID, pq.Array(recoveryHashes))
+	return err
+}
+
+// DeleteAdminTOTP disables two-factor authentication of the admin and drops its recovery codes.
+func DeleteAdminTOTP(db *sql.DB, adminID int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM admin_recovery_code WHERE admin_id = $1`, adminID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM admin_totp WHERE admin_id = $1`, adminID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+)
+
+var (
+	// ErrMFAAlreadyEnabled is returned when enrolling an admin whose TOTP is already confirmed.
+	ErrMFAAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	// ErrMFANotEnrolled is returned when confirming a TOTP enrollment that wasn't started.
+	ErrMFANotEnrolled = errors.New("two-factor authentication enrollment not started")
+)
+
+// AdminTOTP is the TOTP enrollment of an admin. It is enabled once ConfirmedAt is set.
+type AdminTOTP struct {
+	AdminID      int
+	Secret       string
+	ConfirmedAt  sql.NullTime
+	LastUsedStep int64
+}
+
+// Enabled reports whether the enrollment was confirmed.
+func (t AdminTOTP) Enabled() bool {
+	return t.ConfirmedAt.Valid
+}
@@ -0,0 +1,24 @@
+-- Asynchronous PDF extraction jobs.
+-- A job is claimed by a worker by moving it to 'running' and setting locked_until;
+-- the worker keeps extending the lease while the gRPC call is in flight, so a job whose
+-- lease has expired was abandoned (e.g. the gateway restarted) and can be picked up again.
+CREATE TABLE IF NOT EXISTS public.extraction_job (
+    id serial PRIMARY KEY,
+    pdf_id integer NOT NULL REFERENCES public.pdf(id) ON DELETE CASCADE,
+    status character varying(20) NOT NULL DEFAULT 'queued',
+    error text,
+    attempts integer NOT NULL DEFAULT 0,
+    locked_until timestamp without time zone,
+    created_at timestamp without time zone NOT NULL DEFAULT NOW(),
+    started_at timestamp without time zone,
+    finished_at timestamp without time zone,
+    CONSTRAINT extraction_job_status_check CHECK (status IN ('queued', 'running', 'succeeded', 'failed'))
+);
+
+-- Only one active job per PDF.
+CREATE UNIQUE INDEX IF NOT EXISTS extraction_job_active_pdf_id
+    ON public.extraction_job (pdf_id)
+    WHERE status IN ('queued', 'running');
+
+CREATE INDEX IF NOT EXISTS extraction_job_status_id
+    ON public.extraction_job (status, id);
@@ -0,0 +1,21 @@
+-- OCR agents are scheduled from ai_agent_resources.
+-- ocr_locked_until bounds how long ocr_in_used may stay set, so an agent claimed by a gateway
+-- that died mid-extraction becomes free again; draining agents receive no new extractions.
+ALTER TABLE public.ai_agent_resources
+    ALTER COLUMN ip TYPE character varying(200),
+    ADD COLUMN IF NOT EXISTS draining boolean NOT NULL DEFAULT false,
+    ADD COLUMN IF NOT EXISTS ocr_locked_until timestamp without time zone;
+
+UPDATE public.ai_agent_resources SET ocr_in_used = false WHERE ocr_in_used IS NULL;
+
+-- Agents already in use have no ocr_locked_until, so the lease would never free them.
+UPDATE public.ai_agent_resources
+SET ocr_in_used = false
+WHERE ocr_in_used AND ocr_locked_until IS NULL;
+
+ALTER TABLE public.ai_agent_resources
+    ALTER COLUMN ocr_in_used SET DEFAULT false,
+    ALTER COLUMN ocr_in_used SET NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS ai_agent_resources_unique_name
+    ON public.ai_agent_resources (name);
@@ -0,0 +1,8 @@
+-- PDFs uploaded through the gateway record the SHA-256 of their content,
+-- so the same file can't be uploaded twice for one device.
+ALTER TABLE public.pdf
+    ADD COLUMN IF NOT EXISTS sha256 character(64);
+
+CREATE UNIQUE INDEX IF NOT EXISTS pdf_device_sha256_unique
+    ON public.pdf (device_id, sha256)
+    WHERE sha256 IS NOT NULL;
@@ -0,0 +1,26 @@
+-- Objects uploaded by clients through signed URLs are tracked until the upload is confirmed.
+-- Rows created before this migration (and those written by the extraction) are already uploaded.
+-- checksum is the hex MD5 reported by the blob store.
+ALTER TABLE public.pdf
+    ADD COLUMN IF NOT EXISTS upload_status character varying(20) NOT NULL DEFAULT 'uploaded'
+        CHECK (upload_status IN ('pending', 'uploaded', 'failed')),
+    ADD COLUMN IF NOT EXISTS size_bytes bigint,
+    ADD COLUMN IF NOT EXISTS content_type character varying(100),
+    ADD COLUMN IF NOT EXISTS checksum character varying(64);
+
+ALTER TABLE public.pdf_image
+    ADD COLUMN IF NOT EXISTS upload_status character varying(20) NOT NULL DEFAULT 'uploaded'
+        CHECK (upload_status IN ('pending', 'uploaded', 'failed')),
+    ADD COLUMN IF NOT EXISTS size_bytes bigint,
+    ADD COLUMN IF NOT EXISTS content_type character varying(100),
+    ADD COLUMN IF NOT EXISTS checksum character varying(64);
+
+-- The reaper only looks at rows that are not uploaded.
+CREATE INDEX IF NOT EXISTS pdf_upload_status_idx
+    ON public.pdf (uploaded_at) WHERE upload_status <> 'uploaded';
+CREATE INDEX IF NOT EXISTS pdf_image_upload_status_idx
+    ON public.pdf_image (last_modified) WHERE upload_status <> 'uploaded';
+
+-- The stray object sweep looks up every object name in both tables.
+CREATE INDEX IF NOT EXISTS pdf_gcs_bucket_idx ON public.pdf (gcs_bucket);
+CREATE INDEX IF NOT EXISTS pdf_image_gcs_bucket_idx ON public.pdf_image (gcs_bucket);
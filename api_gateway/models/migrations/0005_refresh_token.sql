@@ -0,0 +1,29 @@
+-- Rotating refresh tokens. Only the SHA-256 of a token is stored.
+-- A refresh replaces its row by a new one of the same family; presenting a replaced token again
+-- means it leaked, and the whole family is revoked.
+-- access_jti is the jti of the access token issued together with the refresh token,
+-- so revoking a session can also deny its access token.
+CREATE TABLE IF NOT EXISTS public.refresh_token (
+    id serial PRIMARY KEY,
+    account_id integer NOT NULL REFERENCES public.account(id) ON DELETE CASCADE,
+    token_hash character(64) NOT NULL UNIQUE,
+    family_id character varying(64) NOT NULL,
+    access_jti character varying(64) NOT NULL,
+    access_expires_at timestamp without time zone NOT NULL,
+    created_at timestamp without time zone NOT NULL DEFAULT NOW(),
+    expires_at timestamp without time zone NOT NULL,
+    revoked_at timestamp without time zone
+);
+
+CREATE INDEX IF NOT EXISTS refresh_token_account_id ON public.refresh_token (account_id);
+CREATE INDEX IF NOT EXISTS refresh_token_family_id ON public.refresh_token (family_id);
+CREATE INDEX IF NOT EXISTS refresh_token_access_jti ON public.refresh_token (access_jti);
+
+-- Access tokens revoked before they expire (logout). Rows are useless once expires_at has passed.
+CREATE TABLE IF NOT EXISTS public.revoked_access_token (
+    jti character varying(64) PRIMARY KEY,
+    account_id integer NOT NULL,
+    expires_at timestamp without time zone NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS revoked_access_token_expires_at ON public.revoked_access_token (expires_at);
@@ -0,0 +1,8 @@
+-- Access tokens carry the role and token_version of their account.
+-- Bumping token_version invalidates every token issued before.
+ALTER TABLE public.account
+    ADD COLUMN IF NOT EXISTS token_version integer NOT NULL DEFAULT 0;
+
+-- Refresh tokens keep the audience of the login they come from (user or admin).
+ALTER TABLE public.refresh_token
+    ADD COLUMN IF NOT EXISTS audience character varying(50) NOT NULL DEFAULT 'techbot-user';
@@ -0,0 +1,42 @@
+-- Fine-grained permissions. Routes require a permission; roles grant permissions.
+-- admin_only permissions are only granted to tokens issued by admin_login.
+CREATE TABLE IF NOT EXISTS public.permission (
+    id serial PRIMARY KEY,
+    name character varying(100) NOT NULL UNIQUE,
+    description text NOT NULL DEFAULT '',
+    admin_only boolean NOT NULL DEFAULT false
+);
+
+CREATE TABLE IF NOT EXISTS public.role_permission (
+    role_id integer NOT NULL REFERENCES public.role(id) ON DELETE CASCADE,
+    permission_id integer NOT NULL REFERENCES public.permission(id) ON DELETE CASCADE,
+    PRIMARY KEY (role_id, permission_id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS role_unique_label ON public.role (label);
+
+INSERT INTO public.permission (name, description, admin_only) VALUES
+    ('admin:access', 'Use the admin console', true),
+    ('agent:manage', 'Add, drain and remove OCR agents', true),
+    ('role:manage', 'Manage roles and their permissions', true),
+    ('device:create', 'Create devices, brands and categories', false),
+    ('pdf:upload', 'Upload PDFs', false),
+    ('pdf:extract', 'Run PDF extractions', false),
+    ('pdf:edit', 'Fix OCR paragraphs, image alts, chunks and images', false),
+    ('conversation:chat', 'Chat, store conversations and take notes', false),
+    ('conversation:read_any', 'Read the conversations and notes of any account', false)
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO public.role (label)
+SELECT label FROM (VALUES ('content_editor'), ('viewer')) AS r(label)
+WHERE NOT EXISTS (SELECT 1 FROM public.role WHERE role.label = r.label);
+
+INSERT INTO public.role_permission (role_id, permission_id)
+SELECT r.id, p.id
+FROM public.role r
+JOIN public.permission p ON
+       r.label = 'admin'
+    OR (r.label = 'user' AND p.name IN ('conversation:chat'))
+    OR (r.label = 'content_editor' AND p.name IN ('conversation:chat', 'pdf:edit'))
+    OR (r.label = 'viewer' AND p.name IN ('conversation:chat'))
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,6 @@
+-- Google sign-in: google_user.id is the account the Google identity (sub) is linked to.
+ALTER TABLE public.google_user
+    ADD COLUMN IF NOT EXISTS email character varying(200),
+    ADD COLUMN IF NOT EXISTS linked_at timestamp without time zone NOT NULL DEFAULT NOW();
+
+CREATE UNIQUE INDEX IF NOT EXISTS google_user_unique_google_id ON public.google_user (google_id);
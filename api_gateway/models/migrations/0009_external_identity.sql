@@ -0,0 +1,23 @@
+-- Accounts signing in with an OIDC provider (enterprise SSO).
+-- (provider, subject) is the identity: the provider name from OIDC_PROVIDERS and the sub of its ID tokens.
+CREATE TABLE IF NOT EXISTS public.external_identity (
+    id serial PRIMARY KEY,
+    provider character varying(100) NOT NULL,
+    subject text NOT NULL,
+    account_id integer NOT NULL REFERENCES public.account(id) ON DELETE CASCADE,
+    email character varying(200),
+    created_at timestamp without time zone NOT NULL DEFAULT NOW(),
+    last_login_at timestamp without time zone NOT NULL DEFAULT NOW(),
+    UNIQUE (provider, subject)
+);
+
+CREATE INDEX IF NOT EXISTS external_identity_account_id ON public.external_identity (account_id);
+
+-- Pending OIDC logins: the state sent to the provider, with the PKCE verifier and nonce to check its answer.
+CREATE TABLE IF NOT EXISTS public.oidc_login_state (
+    state character varying(64) PRIMARY KEY,
+    provider character varying(100) NOT NULL,
+    code_verifier character varying(128) NOT NULL,
+    nonce character varying(64) NOT NULL,
+    expires_at timestamp without time zone NOT NULL
+);
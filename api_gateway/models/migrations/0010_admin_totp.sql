@@ -0,0 +1,19 @@
+-- TOTP two-factor authentication of admins (RFC 6238).
+-- The secret is usable once confirmed_at is set, by verifying a first code after enrollment.
+CREATE TABLE IF NOT EXISTS public.admin_totp (
+    admin_id integer PRIMARY KEY REFERENCES public.admin(id) ON DELETE CASCADE,
+    secret character varying(64) NOT NULL,
+    confirmed_at timestamp without time zone,
+    -- Time step of the last accepted code: a code can't be used twice
+    last_used_step bigint NOT NULL DEFAULT 0,
+    created_at timestamp without time zone NOT NULL DEFAULT NOW()
+);
+
+-- Single-use recovery codes, stored as their SHA-256.
+CREATE TABLE IF NOT EXISTS public.admin_recovery_code (
+    id serial PRIMARY KEY,
+    admin_id integer NOT NULL REFERENCES public.admin(id) ON DELETE CASCADE,
+    code_hash character(64) NOT NULL,
+    used_at timestamp without time zone,
+    UNIQUE (admin_id, code_hash)
+);
@@ -0,0 +1,22 @@
+-- Brute-force protection of the auth routes.
+
+-- Token buckets of the rate limits, when RATE_LIMIT_STORE=postgres (shared by the gateway replicas).
+CREATE UNLOGGED TABLE IF NOT EXISTS public.rate_limit_bucket (
+    key character varying(300) PRIMARY KEY,
+    tokens double precision NOT NULL,
+    updated_at timestamp without time zone NOT NULL DEFAULT NOW()
+);
+
+-- Failed attempts in a row per key (login:<email>, admin_login:<username>, otp:<email>, mfa:<admin id>).
+-- After LOCKOUT_THRESHOLD failures the key is locked until locked_until, for twice as long each further failure.
+CREATE TABLE IF NOT EXISTS public.auth_failure (
+    key character varying(300) PRIMARY KEY,
+    failures integer NOT NULL DEFAULT 0,
+    locked_until timestamp without time zone,
+    -- The failures are forgotten after this (LOCKOUT_WINDOW after the last one, or the end of the lock)
+    expires_at timestamp without time zone NOT NULL
+);
+
+-- Verification attempts of the current OTP, reset when a new one is sent.
+ALTER TABLE public."user" ADD COLUMN IF NOT EXISTS otp_attempts integer NOT NULL DEFAULT 0;
+ALTER TABLE public.temp_user ADD COLUMN IF NOT EXISTS otp_attempts integer NOT NULL DEFAULT 0;
@@ -0,0 +1,21 @@
+-- Emails waiting to be sent by the email worker. HTTP handlers only enqueue them,
+-- so a mail server outage doesn't fail the request; failed sends are retried with backoff.
+CREATE TABLE IF NOT EXISTS public.email_outbox (
+    id bigserial PRIMARY KEY,
+    kind character varying(50) NOT NULL,
+    recipient character varying(200) NOT NULL,
+    subject text NOT NULL,
+    -- The bodies are cleared once sent (they may hold codes)
+    text_body text NOT NULL,
+    html_body text NOT NULL,
+    status character varying(20) NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'sent', 'failed')),
+    attempts integer NOT NULL DEFAULT 0,
+    last_error text,
+    -- A pending email is sent from then on; claiming it pushes this forward (lease)
+    next_attempt_at timestamp without time zone NOT NULL DEFAULT NOW(),
+    created_at timestamp without time zone NOT NULL DEFAULT NOW(),
+    sent_at timestamp without time zone
+);
+
+CREATE INDEX IF NOT EXISTS email_outbox_pending ON public.email_outbox (next_attempt_at) WHERE status = 'pending';
@@ -0,0 +1,11 @@
+-- Pending email changes: the OTP sent to the new address, confirmed by the account.
+CREATE TABLE IF NOT EXISTS public.email_change (
+    account_id integer PRIMARY KEY REFERENCES public.account(id) ON DELETE CASCADE,
+    new_email character varying(200) NOT NULL,
+    otp character(60) NOT NULL,
+    otp_generated_time timestamp without time zone NOT NULL DEFAULT NOW(),
+    otp_attempts integer NOT NULL DEFAULT 0
+);
+
+-- Deleted accounts are kept anonymized (username deleted:<id>) without any personal data.
+ALTER TABLE public.account ADD COLUMN IF NOT EXISTS deleted_at timestamp without time zone;
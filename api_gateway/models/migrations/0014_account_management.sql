@@ -0,0 +1,22 @@
+-- Accounts an admin disabled can't log in, and their tokens are refused.
+ALTER TABLE public.account
+    ADD COLUMN IF NOT EXISTS disabled_at timestamp without time zone,
+    ADD COLUMN IF NOT EXISTS disabled_reason text;
+
+-- Set by an admin: the user must reset the password (OTP flow) before logging in again.
+ALTER TABLE public."user"
+    ADD COLUMN IF NOT EXISTS password_reset_required boolean NOT NULL DEFAULT false;
+
+-- Searching accounts by email or display name
+CREATE INDEX IF NOT EXISTS account_lower_username ON public.account (lower(username));
+
+INSERT INTO public.permission (name, description, admin_only) VALUES
+    ('account:manage', 'List accounts, change their role, disable them and force password resets', true)
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO public.role_permission (role_id, permission_id)
+SELECT r.id, p.id
+FROM public.role r
+JOIN public.permission p ON p.name = 'account:manage'
+WHERE r.label = 'admin'
+ON CONFLICT DO NOTHING;
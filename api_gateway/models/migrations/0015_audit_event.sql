@@ -0,0 +1,28 @@
+-- Who changed what: knowledge-base edits and admin actions, written in the transaction of the change.
+-- before and after hold the changed fields (NULL for creations and deletions).
+CREATE TABLE IF NOT EXISTS public.audit_event (
+    id bigserial PRIMARY KEY,
+    occurred_at timestamp without time zone NOT NULL DEFAULT NOW(),
+    actor_account_id integer REFERENCES public.account(id) ON DELETE SET NULL,
+    action character varying(100) NOT NULL,
+    target_type character varying(50) NOT NULL,
+    target_id character varying(100) NOT NULL,
+    before jsonb,
+    after jsonb,
+    request_id character varying(100)
+);
+
+CREATE INDEX IF NOT EXISTS audit_event_occurred_at ON public.audit_event (occurred_at);
+CREATE INDEX IF NOT EXISTS audit_event_actor ON public.audit_event (actor_account_id, occurred_at);
+CREATE INDEX IF NOT EXISTS audit_event_target ON public.audit_event (target_type, target_id, occurred_at);
+
+INSERT INTO public.permission (name, description, admin_only) VALUES
+    ('audit:read', 'Read the audit log', true)
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO public.role_permission (role_id, permission_id)
+SELECT r.id, p.id
+FROM public.role r
+JOIN public.permission p ON p.name = 'audit:read'
+WHERE r.label = 'admin'
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,39 @@
+-- Every saved version of a paragraph context and of an image alt text, so an edit can be undone.
+-- source is ocr for the text of an extraction, manual for an edit or a restore (restored_from set).
+CREATE TABLE IF NOT EXISTS public.pdf_paragraph_revision (
+    id bigserial PRIMARY KEY,
+    pdf_paragraph_id integer NOT NULL REFERENCES public.pdf_paragraph(id) ON DELETE CASCADE,
+    content text NOT NULL,
+    source character varying(10) NOT NULL CHECK (source IN ('ocr', 'manual')),
+    author_account_id integer REFERENCES public.account(id) ON DELETE SET NULL,
+    restored_from bigint REFERENCES public.pdf_paragraph_revision(id) ON DELETE SET NULL,
+    created_at timestamp without time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS pdf_paragraph_revision_paragraph ON public.pdf_paragraph_revision (pdf_paragraph_id, id);
+
+CREATE TABLE IF NOT EXISTS public.pdf_image_alt_revision (
+    id bigserial PRIMARY KEY,
+    pdf_image_id integer NOT NULL REFERENCES public.pdf_image(id) ON DELETE CASCADE,
+    content text NOT NULL,
+    source character varying(10) NOT NULL CHECK (source IN ('ocr', 'manual')),
+    author_account_id integer REFERENCES public.account(id) ON DELETE SET NULL,
+    restored_from bigint REFERENCES public.pdf_image_alt_revision(id) ON DELETE SET NULL,
+    created_at timestamp without time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS pdf_image_alt_revision_image ON public.pdf_image_alt_revision (pdf_image_id, id);
+
+-- The current texts become the first revisions. Paragraphs come from the extraction, alt texts
+-- are only ever written by editors.
+INSERT INTO public.pdf_paragraph_revision (pdf_paragraph_id, content, source, created_at)
+SELECT p.id, p.context, 'ocr', COALESCE(p.last_modified, NOW())
+FROM public.pdf_paragraph p
+WHERE p.context IS NOT NULL
+  AND NOT EXISTS (SELECT 1 FROM public.pdf_paragraph_revision r WHERE r.pdf_paragraph_id = p.id);
+
+INSERT INTO public.pdf_image_alt_revision (pdf_image_id, content, source, created_at)
+SELECT i.id, i.alt, 'manual', COALESCE(i.last_modified, NOW())
+FROM public.pdf_image i
+WHERE i.alt IS NOT NULL
+  AND NOT EXISTS (SELECT 1 FROM public.pdf_image_alt_revision r WHERE r.pdf_image_id = i.id);
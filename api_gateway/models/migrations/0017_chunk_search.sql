@@ -0,0 +1,10 @@
+-- Full-text search over the chunks, next to the pgvector search. The simple configuration keeps
+-- model numbers and error codes (WM-7200, E-04) as tokens and doesn't stem Vietnamese text.
+ALTER TABLE public.pdf_chunk ADD COLUMN IF NOT EXISTS context_tsv tsvector
+    GENERATED ALWAYS AS (to_tsvector('simple', COALESCE(context, ''))) STORED;
+
+CREATE INDEX IF NOT EXISTS pdf_chunk_context_tsv ON public.pdf_chunk USING GIN (context_tsv);
+
+-- The searches join the chunks to their pages
+CREATE INDEX IF NOT EXISTS pdf_chunk_pdf_paragraph_chunk ON public.pdf_chunk_pdf_paragraph (pdf_chunk_id);
+CREATE INDEX IF NOT EXISTS pdf_chunk_pdf_image_chunk ON public.pdf_chunk_pdf_image (pdf_chunk_id);
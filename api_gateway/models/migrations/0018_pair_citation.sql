@@ -0,0 +1,13 @@
+-- The chunks a RAG answer was generated from, resolved to their PDF page when the answer is stored.
+-- The page stays cited when the chunk is re-embedded or deleted.
+CREATE TABLE IF NOT EXISTS public.request_response_pair_pdf_chunk (
+    request_response_pair_id integer NOT NULL REFERENCES public.request_response_pair(id) ON DELETE CASCADE,
+    position integer NOT NULL,
+    pdf_chunk_id integer REFERENCES public.pdf_chunk(id) ON DELETE SET NULL,
+    pdf_id integer NOT NULL REFERENCES public.pdf(id) ON DELETE CASCADE,
+    filename character varying(100),
+    page_number integer NOT NULL,
+    PRIMARY KEY (request_response_pair_id, position)
+);
+
+CREATE INDEX IF NOT EXISTS request_response_pair_pdf_chunk_chunk ON public.request_response_pair_pdf_chunk (pdf_chunk_id);
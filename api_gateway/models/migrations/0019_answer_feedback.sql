@@ -0,0 +1,24 @@
+-- Ratings of RAG answers by their askers, reviewed by admins to find what to fix in the manuals.
+CREATE TABLE IF NOT EXISTS public.answer_feedback (
+    request_response_pair_id integer NOT NULL REFERENCES public.request_response_pair(id) ON DELETE CASCADE,
+    account_id integer NOT NULL REFERENCES public.account(id) ON DELETE CASCADE,
+    rating character varying(10) NOT NULL CHECK (rating IN ('up', 'down')),
+    reason character varying(30) CHECK (reason IN ('incorrect', 'incomplete', 'irrelevant', 'wrong_device', 'outdated', 'other')),
+    comment text,
+    created_at timestamp without time zone NOT NULL DEFAULT NOW(),
+    updated_at timestamp without time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (request_response_pair_id, account_id)
+);
+
+CREATE INDEX IF NOT EXISTS answer_feedback_rating ON public.answer_feedback (rating, updated_at);
+
+INSERT INTO public.permission (name, description, admin_only) VALUES
+    ('feedback:review', 'Review the feedback on answers', true)
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO public.role_permission (role_id, permission_id)
+SELECT r.id, p.id
+FROM public.role r
+JOIN public.permission p ON p.name = 'feedback:review'
+WHERE r.label = 'admin'
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,34 @@
+-- RAG answers to the questions about a device, reused for the same question (exact match on the
+-- normalized query) or a close one (cosine similarity of the query embeddings). The entries of a
+-- device are deleted when a chunk of its PDFs is re-embedded or deleted.
+CREATE TABLE IF NOT EXISTS public.answer_cache (
+    id bigserial PRIMARY KEY,
+    device_id integer NOT NULL REFERENCES public.device(id) ON DELETE CASCADE,
+    normalized_query text NOT NULL,
+    query_embedding public.vector(768),
+    response text NOT NULL,
+    images_ids integer[] NOT NULL DEFAULT '{}',
+    chunk_ids integer[] NOT NULL DEFAULT '{}',
+    hits integer NOT NULL DEFAULT 0,
+    created_at timestamp without time zone NOT NULL DEFAULT NOW(),
+    expires_at timestamp without time zone NOT NULL,
+    UNIQUE (device_id, normalized_query)
+);
+
+-- A device has few entries: the similarity search scans them without a vector index
+CREATE INDEX IF NOT EXISTS answer_cache_expires_at ON public.answer_cache (expires_at);
+
+-- Bumped with the deletion of the cached answers of a device, so an answer generated from chunks
+-- that changed while it was generated isn't cached after the deletion.
+ALTER TABLE public.device ADD COLUMN IF NOT EXISTS answer_cache_generation bigint NOT NULL DEFAULT 0;
+
+INSERT INTO public.permission (name, description, admin_only) VALUES
+    ('answer_cache:purge', 'Purge the cached RAG answers', true)
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO public.role_permission (role_id, permission_id)
+SELECT r.id, p.id
+FROM public.role r
+JOIN public.permission p ON p.name = 'answer_cache:purge'
+WHERE r.label = 'admin'
+ON CONFLICT DO NOTHING;
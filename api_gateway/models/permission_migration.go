@@ -0,0 +1,256 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrRoleInUse is returned when deleting a role that accounts still have.
+	ErrRoleInUse = errors.New("role is assigned to accounts")
+	// ErrRoleExists is returned when a role label is already taken.
+	ErrRoleExists = errors.New("role already exists")
+)
+
+// UnknownPermissionsError lists the permission names that don't exist.
+type UnknownPermissionsError struct {
+	Names []string
+}
+
+func (e *UnknownPermissionsError) Error() string {
+	return fmt.Sprintf("unknown permissions: %v", e.Names)
+}
+
+// SelectAllPermissions lists the permissions.
+func SelectAllPermissions(db *sql.DB) ([]Permission, error) {
+	rows, err := db.Query(`SELECT id, name, description, admin_only FROM permission ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var permissions []Permission
+	for rows.Next() {
+		var p Permission
+		if err := rows.Scan(&p.ID, &p.Name, &p.Description, &p.AdminOnly); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, p)
+	}
+	return permissions, rows.Err()
+}
+
+// SelectAccountPermissions returns the permissions granted by the role of an account.
+func SelectAccountPermissions(db *sql.DB, accountID int) ([]Permission, error) {
+	rows, err := db.Query(`
+        SELECT p.id, p.name, p.description, p.admin_only
+        FROM account a
+        JOIN role_permission rp ON rp.role_id = a.role_id
+        JOIN permission p ON p.id = rp.permission_id
+        WHERE a.id = $1
+    `, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var permissions []Permission
+	for rows.Next() {
+		var p Permission
+		if err := rows.Scan(&p.ID, &p.Name, &p.Description, &p.AdminOnly); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, p)
+	}
+	return permissions, rows.Err()
+}
+
+const roleColumns = `
+        r.id, r.label,
+        COALESCE(ARRAY(
+            SELECT p.name FROM role_permission rp JOIN permission p ON p.id = rp.permission_id
+            WHERE rp.role_id = r.id ORDER BY p.name
+        ), '{}'),
+        (SELECT COUNT(*) FROM account a WHERE a.role_id = r.id)
+`
+
+func scanRole(row interface{ Scan(...any) error }) (Role, error) {
+	var role Role
+	var permissions pq.StringArray
+	err := row.Scan(&role.ID, &role.Label, &permissions, &role.Accounts)
+	role.Permissions = []string(permissions)
+	return role, err
+}
+
+// SelectAllRoles lists the roles with their permissions and number of accounts.
+func SelectAllRoles(db *sql.DB) ([]Role, error) {
+	rows, err := db.Query(`SELECT ` + roleColumns + ` FROM role r ORDER BY r.id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var roles []Role
+	for rows.Next() {
+		role, err := scanRole(rows)
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, rows.Err()
+}
+
+// SelectRoleByID returns a role with its permissions.
+func SelectRoleByID(db *sql.DB, id int) (Role, error) {
+	return scanRole(db.QueryRow(`SELECT `+roleColumns+` FROM role r WHERE r.id = $1`, id))
+}
+
+// roleAudit is what the audit log keeps of a role.
+func roleAudit(role Role) map[string]any {
+	return map[string]any{"label": role.Label, "permissions": role.Permissions}
+}
+
+// selectRoleForUpdate returns and locks a role in tx.
+func selectRoleForUpdate(tx *sql.Tx, id int) (Role, error) {
+	return scanRole(tx.QueryRow(`SELECT `+roleColumns+` FROM role r WHERE r.id = $1 FOR UPDATE OF r`, id))
+}
+
+// InsertRole creates a role granting the given permissions, audited.
+func InsertRole(db *sql.DB, audit Audit, label string, permissions []string) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var id int
+	err = tx.QueryRow(`INSERT INTO role (label) VALUES ($1) RETURNING id`, label).Scan(&id)
+	if IsUniqueViolation(err) {
+		return 0, ErrRoleExists
+	}
+	if err != nil {
+		return 0, err
+	}
+	if err := setRolePermissions(tx, id, permissions); err != nil {
+		return 0, err
+	}
+	if permissions == nil {
+		permissions = []string{}
+	}
+	if err := audit.record(tx, AuditRoleCreate, "role", id, nil, map[string]any{"label": label, "permissions": permissions}); err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+// UpdateRole renames a role (if label is not nil) and replaces its permissions (if permissions is not nil), audited.
+// It returns sql.ErrNoRows if the role doesn't exist.
+func UpdateRole(db *sql.DB, audit Audit, id int, label *string, permissions []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Lock the role; Scan returns sql.ErrNoRows if it doesn't exist.
+	before, err := selectRoleForUpdate(tx, id)
+	if err != nil {
+		return err
+	}
+
+	if label != nil {
+		_, err := tx.Exec(`UPDATE role SET label = $1 WHERE id = $2`, *label, id)
+		if IsUniqueViolation(err) {
+			return ErrRoleExists
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if permissions != nil {
+		if _, err := tx.Exec(`DELETE FROM role_permission WHERE role_id = $1`, id); err != nil {
+			return err
+		}
+		if err := setRolePermissions(tx, id, permissions); err != nil {
+			return err
+		}
+	}
+	after, err := selectRoleForUpdate(tx, id)
+	if err != nil {
+		return err
+	}
+	if err := audit.record(tx, AuditRoleUpdate, "role", id, roleAudit(before), roleAudit(after)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// setRolePermissions grants the permissions to the role. Unknown names give an *UnknownPermissionsError.
+func setRolePermissions(tx *sql.Tx, roleID int, permissions []string) error {
+	if len(permissions) == 0 {
+		return nil
+	}
+	rows, err := tx.Query(`
+        SELECT u.name FROM unnest($1::text[]) AS u(name)
+        WHERE NOT EXISTS (SELECT 1 FROM permission p WHERE p.name = u.name)
+    `, pq.Array(permissions))
+	if err != nil {
+		return err
+	}
+	var unknown []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		unknown = append(unknown, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(unknown) > 0 {
+		return &UnknownPermissionsError{Names: unknown}
+	}
+
+	_, err = tx.Exec(`
+        INSERT INTO role_permission (role_id, permission_id)
+        SELECT $1, id FROM permission WHERE name = ANY($2)
+        ON CONFLICT DO NOTHING
+    `, roleID, pq.Array(permissions))
+	return err
+}
+
+// DeleteRole deletes a role no account has, audited. It returns sql.ErrNoRows if the role doesn't exist
+// and ErrRoleInUse if accounts still have it.
+func DeleteRole(db *sql.DB, audit Audit, id int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := selectRoleForUpdate(tx, id)
+	if err != nil {
+		return err
+	}
+	if before.Accounts > 0 {
+		return ErrRoleInUse
+	}
+	result, err := tx.Exec(`DELETE FROM role WHERE id = $1 AND NOT EXISTS (SELECT 1 FROM account WHERE role_id = $1)`, id)
+	if err != nil {
+		return err
+	}
+	if err := expectOneRow(result); err != nil {
+		return ErrRoleInUse
+	}
+	if err := audit.record(tx, AuditRoleDelete, "role", id, roleAudit(before), nil); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,32 @@
+package models
+
+// Permissions checked by the routes (see the permission table).
+const (
+	PermissionAdminAccess         = "admin:access"
+	PermissionAgentManage         = "agent:manage"
+	PermissionRoleManage          = "role:manage"
+	PermissionAccountManage       = "account:manage"
+	PermissionAuditRead           = "audit:read"
+	PermissionFeedbackReview      = "feedback:review"
+	PermissionAnswerCachePurge    = "answer_cache:purge"
+	PermissionDeviceCreate        = "device:create"
+	PermissionPDFUpload           = "pdf:upload"
+	PermissionPDFExtract          = "pdf:extract"
+	PermissionPDFEdit             = "pdf:edit"
+	PermissionConversationChat    = "conversation:chat"
+	PermissionConversationReadAny = "conversation:read_any"
+)
+
+type Permission struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	AdminOnly   bool   `json:"admin_only"`
+}
+
+type Role struct {
+	ID          int      `json:"id"`
+	Label       string   `json:"label"`
+	Permissions []string `json:"permissions"`
+	Accounts    int      `json:"accounts"`
+}
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+const revisionColumns = `id, content, source, author_account_id, restored_from, created_at`
+
+func scanRevision(row interface{ Scan(...any) error }) (Revision, error) {
+	var revision Revision
+	var authorID, restoredFrom sql.NullInt64
+	err := row.Scan(&revision.ID, &revision.Content, &revision.Source, &authorID, &restoredFrom, &revision.CreatedAt)
+	if authorID.Valid {
+		id := int(authorID.Int64)
+		revision.AuthorID = &id
+	}
+	if restoredFrom.Valid {
+		revision.RestoredFrom = &restoredFrom.Int64
+	}
+	return revision, err
+}
+
+// insertRevision records a manual revision written by the audit actor and returns its id.
+func insertRevision(tx *sql.Tx, kind RevisionKind, audit Audit, ownerID int, content string, restoredFrom int64) (int64, error) {
+	var id int64
+	err := tx.QueryRow(fmt.Sprintf(`
+        INSERT INTO %s (%s, content, source, author_account_id, restored_from)
+        VALUES ($1, $2, $3, NULLIF($4, 0), NULLIF($5, 0))
+        RETURNING id
+    `, kind.table, kind.ownerColumn), ownerID, content, RevisionSourceManual, audit.ActorID, restoredFrom).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert %s revision: %w", kind.Name, err)
+	}
+	return id, nil
+}
+
+// SelectRevisions returns the revisions of a paragraph or image alt, newest first. It returns
+// sql.ErrNoRows if the paragraph or image doesn't exist.
+func SelectRevisions(db *sql.DB, kind RevisionKind, ownerID int) ([]Revision, error) {
+	var exists bool
+	err := db.QueryRow(fmt.Sprintf(`SELECT EXISTS (SELECT 1 FROM %s WHERE id = $1)`, kind.ownerTable), ownerID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, sql.ErrNoRows
+	}
+
+	rows, err := db.Query(fmt.Sprintf(`SELECT %s FROM %s WHERE %s = $1 ORDER BY id DESC`,
+		revisionColumns, kind.table, kind.ownerColumn), ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []Revision{}
+	for rows.Next() {
+		revision, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, revision)
+	}
+	return revisions, rows.Err()
+}
+
+// SelectRevision returns a revision of a paragraph or image alt, sql.ErrNoRows if it isn't one of its revisions.
+func SelectRevision(db *sql.DB, kind RevisionKind, ownerID int, revisionID int64) (Revision, error) {
+	return scanRevision(db.QueryRow(fmt.Sprintf(`SELECT %s FROM %s WHERE id = $1 AND %s = $2`,
+		revisionColumns, kind.table, kind.ownerColumn), revisionID, ownerID))
+}
@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// Sources of a revision (pdf_paragraph_revision.source, pdf_image_alt_revision.source).
+const (
+	RevisionSourceOCR    = "ocr"
+	RevisionSourceManual = "manual"
+)
+
+// RevisionKind is what revisions are kept of: the context of paragraphs or the alt text of images.
+type RevisionKind struct {
+	Name          string // paragraph or image_alt, as shown in responses
+	table         string // table of the revisions
+	ownerTable    string // table of the revised rows
+	ownerColumn   string // column of the revision referencing the revised row
+	contentColumn string // column of the revised row holding the text
+	relationTable string // relation between the revised rows and their chunks
+	updateAction  string
+	restoreAction string
+}
+
+var (
+	ParagraphRevisions = RevisionKind{
+		Name:          "paragraph",
+		table:         "pdf_paragraph_revision",
+		ownerTable:    "pdf_paragraph",
+		ownerColumn:   "pdf_paragraph_id",
+		contentColumn: "context",
+		relationTable: "pdf_chunk_pdf_paragraph",
+		updateAction:  AuditParagraphUpdate,
+		restoreAction: AuditParagraphRestore,
+	}
+	ImageAltRevisions = RevisionKind{
+		Name:          "image_alt",
+		table:         "pdf_image_alt_revision",
+		ownerTable:    "pdf_image",
+		ownerColumn:   "pdf_image_id",
+		contentColumn: "alt",
+		relationTable: "pdf_chunk_pdf_image",
+		updateAction:  AuditImageAltUpdate,
+		restoreAction: AuditImageAltRestore,
+	}
+)
+
+// Revision is a saved version of a paragraph context or an image alt text.
+type Revision struct {
+	ID           int64     `json:"id"`
+	Content      string    `json:"content"`
+	Source       string    `json:"source"`
+	AuthorID     *int      `json:"author_account_id"`
+	RestoredFrom *int64    `json:"restored_from"`
+	CreatedAt    time.Time `json:"created_at"`
+}
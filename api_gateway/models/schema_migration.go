@@ -0,0 +1,89 @@
+package models
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// schemaMigrationLockID is the advisory lock key held while a migration is applied,
+// so several gateway replicas starting at once don't apply the same file twice.
+const schemaMigrationLockID = 20250801
+
+// RunSchemaMigrations applies every migrations/*.sql file that has not been applied yet, in file name order.
+// Each file runs in its own transaction and is recorded in the schema_migration table.
+func RunSchemaMigrations(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migration (
+			version character varying(200) PRIMARY KEY,
+			applied_at timestamp without time zone NOT NULL DEFAULT NOW()
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating schema_migration table: %w", err)
+	}
+
+	entries, err := migrationFiles.ReadDir("migrations")
+	if err != nil {
+		return fmt.Errorf("error reading migrations: %w", err)
+	}
+	var versions []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".sql") {
+			versions = append(versions, entry.Name())
+		}
+	}
+	sort.Strings(versions)
+
+	for _, version := range versions {
+		applied, err := applySchemaMigration(db, version)
+		if err != nil {
+			return fmt.Errorf("error applying migration %s: %w", version, err)
+		}
+		if applied {
+			log.Printf("Applied schema migration %s", version)
+		}
+	}
+	return nil
+}
+
+// applySchemaMigration runs a single migration file unless it has already been applied.
+func applySchemaMigration(db *sql.DB, version string) (bool, error) {
+	content, err := migrationFiles.ReadFile("migrations/" + version)
+	if err != nil {
+		return false, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, schemaMigrationLockID); err != nil {
+		return false, err
+	}
+
+	var exists bool
+	err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM schema_migration WHERE version = $1)`, version).Scan(&exists)
+	if err != nil {
+		return false, err
+	}
+	if exists {
+		return false, nil
+	}
+
+	if _, err := tx.Exec(string(content)); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(`INSERT INTO schema_migration (version) VALUES ($1)`, version); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
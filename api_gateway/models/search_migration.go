@@ -0,0 +1,114 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/pgvector/pgvector-go"
+)
+
+// chunkPages relates each chunk to the page of the paragraph or image it was embedded from.
+const chunkPages = `
+    WITH chunk_page AS (
+        SELECT cpp.pdf_chunk_id AS chunk_id, pp.pdf_page_id AS page_id, 'paragraph' AS source, pp.id AS source_id
+        FROM pdf_chunk_pdf_paragraph cpp
+        JOIN pdf_paragraph pp ON pp.id = cpp.pdf_paragraph_id
+        UNION ALL
+        SELECT cpi.pdf_chunk_id, img.pdf_page_id, 'image', img.id
+        FROM pdf_chunk_pdf_image cpi
+        JOIN pdf_image img ON img.id = cpi.pdf_image_id
+    )`
+
+// chunkOnPage keeps the chunks of a page, of the device of search.DeviceID when set.
+// It returns the condition and args with its own argument appended.
+func chunkOnPage(search ChunkSearch, args []any) (string, []any) {
+	condition := `
+        EXISTS (
+            SELECT 1 FROM chunk_page cp
+            JOIN pdf_page pg ON pg.id = cp.page_id
+            JOIN pdf p ON p.id = pg.pdf_id
+            WHERE cp.chunk_id = c.id`
+	if search.DeviceID != 0 {
+		args = append(args, search.DeviceID)
+		condition += fmt.Sprintf(` AND p.device_id = $%d`, len(args))
+	}
+	return condition + `)`, args
+}
+
+func selectChunkRanks(db *sql.DB, query string, args ...any) ([]ChunkRank, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ranks := []ChunkRank{}
+	for rows.Next() {
+		var rank ChunkRank
+		if err := rows.Scan(&rank.ChunkID, &rank.Score); err != nil {
+			return nil, err
+		}
+		ranks = append(ranks, rank)
+	}
+	return ranks, rows.Err()
+}
+
+// SelectVectorChunkRanks returns the chunks nearest to an embedding (cosine distance), nearest first.
+func SelectVectorChunkRanks(db *sql.DB, embedding []float32, search ChunkSearch) ([]ChunkRank, error) {
+	onPage, args := chunkOnPage(search, []any{pgvector.NewVector(embedding)})
+	args = append(args, search.Limit)
+	return selectChunkRanks(db, chunkPages+fmt.Sprintf(`
+        SELECT c.id, c.embedding <=> $1
+        FROM pdf_chunk c
+        WHERE c.embedding IS NOT NULL AND %s
+        ORDER BY c.embedding <=> $1, c.id
+        LIMIT $%d
+    `, onPage, len(args)), args...)
+}
+
+// SelectTextChunkRanks returns the chunks matching a web search style query (quoted phrases, or,
+// -word) in full text, best match first.
+func SelectTextChunkRanks(db *sql.DB, text string, search ChunkSearch) ([]ChunkRank, error) {
+	onPage, args := chunkOnPage(search, []any{text})
+	args = append(args, search.Limit)
+	return selectChunkRanks(db, chunkPages+fmt.Sprintf(`
+        SELECT c.id, ts_rank_cd(c.context_tsv, q)
+        FROM pdf_chunk c, websearch_to_tsquery('simple', $1) q
+        WHERE c.context_tsv @@ q AND %s
+        ORDER BY 2 DESC, c.id
+        LIMIT $%d
+    `, onPage, len(args)), args...)
+}
+
+// SelectChunkProvenances returns the chunks with their page and PDF, in no particular order.
+// A chunk linked to several paragraphs or images is returned once.
+func SelectChunkProvenances(db *sql.DB, chunkIDs []int) ([]ChunkProvenance, error) {
+	rows, err := db.Query(chunkPages+`
+        SELECT DISTINCT ON (c.id)
+            c.id, COALESCE(c.context, ''), cp.source, cp.source_id,
+            p.id, COALESCE(p.filename, ''), COALESCE(p.device_id, 0), pg.id, COALESCE(pg.page_number, 0)
+        FROM pdf_chunk c
+        JOIN chunk_page cp ON cp.chunk_id = c.id
+        JOIN pdf_page pg ON pg.id = cp.page_id
+        JOIN pdf p ON p.id = pg.pdf_id
+        WHERE c.id = ANY($1)
+        ORDER BY c.id, cp.source DESC, cp.source_id
+    `, pq.Array(chunkIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	chunks := []ChunkProvenance{}
+	for rows.Next() {
+		var chunk ChunkProvenance
+		err := rows.Scan(&chunk.ChunkID, &chunk.Context, &chunk.Source, &chunk.SourceID,
+			&chunk.PDFID, &chunk.FileName, &chunk.DeviceID, &chunk.PageID, &chunk.PageNumber)
+		if err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, chunk)
+	}
+	return chunks, rows.Err()
+}
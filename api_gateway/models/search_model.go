@@ -0,0 +1,27 @@
+package models
+
+// ChunkSearch restricts a chunk search. A zero DeviceID searches the chunks of every device.
+type ChunkSearch struct {
+	DeviceID int
+	Limit    int
+}
+
+// ChunkRank is a chunk found by a search, with how close it is: the cosine distance for
+// the vector search, the ts_rank_cd for the full-text search.
+type ChunkRank struct {
+	ChunkID int
+	Score   float64
+}
+
+// ChunkProvenance is a chunk with the page and PDF it was embedded from.
+type ChunkProvenance struct {
+	ChunkID    int    `json:"chunk_id"`
+	Context    string `json:"context"`
+	Source     string `json:"source"` // paragraph or image
+	SourceID   int    `json:"source_id"`
+	PDFID      int    `json:"pdf_id"`
+	FileName   string `json:"filename"`
+	DeviceID   int    `json:"device_id"`
+	PageID     int    `json:"pdf_page_id"`
+	PageNumber int    `json:"page_number"`
+}
@@ -0,0 +1,194 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// execer is a *sql.DB or a *sql.Tx.
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// InsertRefreshToken stores a new refresh token (token.TokenHash) and the jti of its access token.
+// db may be a transaction.
+func InsertRefreshToken(db execer, token RefreshToken, accessTTL, refreshTTL time.Duration) error {
+	_, err := db.Exec(`
+        INSERT INTO refresh_token (account_id, token_hash, family_id, audience, access_jti, access_expires_at, expires_at)
+        VALUES ($1, $2, $3, $4, $5, NOW() + $6 * INTERVAL '1 second', NOW() + $7 * INTERVAL '1 second')
+    `, token.AccountID, token.TokenHash, token.FamilyID, token.Audience, token.AccessJTI, accessTTL.Seconds(), refreshTTL.Seconds())
+	return err
+}
+
+// RotateRefreshToken revokes the refresh token with hash oldHash and stores next in the same family.
+// It returns next, completed with the account and audience of the old token. An unknown, expired or revoked token gives
+// ErrRefreshTokenInvalid; a token that was already rotated gives ErrRefreshTokenReused and revokes its family.
+func RotateRefreshToken(db *sql.DB, oldHash string, next RefreshToken, accessTTL, refreshTTL time.Duration) (RefreshToken, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return next, err
+	}
+	defer tx.Rollback()
+
+	var current RefreshToken
+	var expired, replaced bool
+	err = tx.QueryRow(`
+        SELECT id, account_id, family_id, audience, expires_at < NOW(), revoked_at,
+               EXISTS (SELECT 1 FROM refresh_token n WHERE n.family_id = rt.family_id AND n.id > rt.id)
+        FROM refresh_token rt
+        WHERE token_hash = $1
+        FOR UPDATE
+    `, oldHash).Scan(&current.ID, &current.AccountID, &current.FamilyID, &current.Audience, &expired, &current.RevokedAt, &replaced)
+	if err == sql.ErrNoRows {
+		return next, ErrRefreshTokenInvalid
+	}
+	if err != nil {
+		return next, err
+	}
+
+	if current.RevokedAt.Valid {
+		if !replaced {
+			// Revoked by a logout, not by a rotation.
+			return next, ErrRefreshTokenInvalid
+		}
+		if err := revokeFamily(tx, current.FamilyID); err != nil {
+			return next, err
+		}
+		if err := tx.Commit(); err != nil {
+			return next, err
+		}
+		return next, ErrRefreshTokenReused
+	}
+
+	if expired {
+		return next, ErrRefreshTokenInvalid
+	}
+
+	if _, err := tx.Exec(`UPDATE refresh_token SET revoked_at = NOW() WHERE id = $1`, current.ID); err != nil {
+		return next, err
+	}
+	next.AccountID = current.AccountID
+	next.FamilyID = current.FamilyID
+	next.Audience = current.Audience
+	if err := InsertRefreshToken(tx, next, accessTTL, refreshTTL); err != nil {
+		return next, err
+	}
+	return next, tx.Commit()
+}
+
+// revokeFamily revokes every refresh token of the family and denies the access tokens issued with them.
+func revokeFamily(tx *sql.Tx, familyID string) error {
+	_, err := tx.Exec(`
+        INSERT INTO revoked_access_token (jti, account_id, expires_at)
+        SELECT access_jti, account_id, access_expires_at
+        FROM refresh_token
+        WHERE family_id = $1 AND access_expires_at > NOW()
+        ON CONFLICT (jti) DO NOTHING
+    `, familyID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`UPDATE refresh_token SET revoked_at = NOW() WHERE family_id = $1 AND revoked_at IS NULL`, familyID)
+	return err
+}
+
+// RevokeSession logs out the session the access token accessJTI belongs to:
+// the access token is denied and the refresh tokens of its family are revoked.
+func RevokeSession(db *sql.DB, accountID int, accessJTI string, accessExpiresAt time.Time) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+        INSERT INTO revoked_access_token (jti, account_id, expires_at)
+        VALUES ($1, $2, $3)
+        ON CONFLICT (jti) DO NOTHING
+    `, accessJTI, accountID, accessExpiresAt)
+	if err != nil {
+		return err
+	}
+
+	var familyID string
+	err = tx.QueryRow(`SELECT family_id FROM refresh_token WHERE access_jti = $1 AND account_id = $2`, accessJTI, accountID).Scan(&familyID)
+	if err == nil {
+		if err := revokeFamily(tx, familyID); err != nil {
+			return err
+		}
+	} else if err != sql.ErrNoRows {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RevokeAllSessions logs the account out everywhere: all its refresh tokens are revoked,
+// the access tokens issued with them are denied and the account's token_version is bumped.
+func RevokeAllSessions(db *sql.DB, accountID int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+        INSERT INTO revoked_access_token (jti, account_id, expires_at)
+        SELECT access_jti, account_id, access_expires_at
+        FROM refresh_token
+        WHERE account_id = $1 AND access_expires_at > NOW()
+        ON CONFLICT (jti) DO NOTHING
+    `, accountID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`UPDATE refresh_token SET revoked_at = NOW() WHERE account_id = $1 AND revoked_at IS NULL`, accountID)
+	if err != nil {
+		return err
+	}
+	// Also invalidates access tokens that have no refresh token.
+	_, err = tx.Exec(`UPDATE account SET token_version = token_version + 1 WHERE id = $1`, accountID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SelectRevokedAccessTokens returns the jti of the revoked access tokens that have not expired yet.
+func SelectRevokedAccessTokens(db *sql.DB) ([]string, error) {
+	rows, err := db.Query(`SELECT jti FROM revoked_access_token WHERE expires_at > NOW()`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jtis []string
+	for rows.Next() {
+		var jti string
+		if err := rows.Scan(&jti); err != nil {
+			return nil, err
+		}
+		jtis = append(jtis, jti)
+	}
+	return jtis, rows.Err()
+}
+
+// DeleteExpiredTokens removes the refresh tokens, denylist entries, pending OIDC logins and auth failures
+// that have expired, and the rate limit buckets idle for a day (they are full again by then).
+func DeleteExpiredTokens(db *sql.DB) (int64, error) {
+	var total int64
+	for _, query := range []string{
+		`DELETE FROM revoked_access_token WHERE expires_at < NOW()`,
+		`DELETE FROM refresh_token WHERE expires_at < NOW()`,
+		`DELETE FROM oidc_login_state WHERE expires_at < NOW()`,
+		`DELETE FROM auth_failure WHERE expires_at < NOW()`,
+		`DELETE FROM rate_limit_bucket WHERE updated_at < NOW() - INTERVAL '1 day'`,
+	} {
+		result, err := db.Exec(query)
+		if err != nil {
+			return total, err
+		}
+		n, _ := result.RowsAffected()
+		total += n
+	}
+	return total, nil
+}
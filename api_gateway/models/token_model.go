@@ -0,0 +1,29 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrRefreshTokenInvalid is returned for unknown, expired or revoked refresh tokens.
+	ErrRefreshTokenInvalid = errors.New("invalid or expired refresh token")
+	// ErrRefreshTokenReused is returned when an already rotated refresh token is presented again.
+	// Its whole family has been revoked.
+	ErrRefreshTokenReused = errors.New("refresh token reused, session revoked")
+)
+
+// RefreshToken is a row of refresh_token. The token itself is only known by the client.
+type RefreshToken struct {
+	ID              int          `json:"id"`
+	AccountID       int          `json:"account_id"`
+	TokenHash       string       `json:"-"`
+	FamilyID        string       `json:"family_id"`
+	Audience        string       `json:"audience"`
+	AccessJTI       string       `json:"access_jti"`
+	AccessExpiresAt time.Time    `json:"access_expires_at"`
+	CreatedAt       time.Time    `json:"created_at"`
+	ExpiresAt       time.Time    `json:"expires_at"`
+	RevokedAt       sql.NullTime `json:"revoked_at"`
+}
@@ -0,0 +1,115 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// uploadCreatedColumn is the column holding when a row of each upload table was created.
+var uploadCreatedColumn = map[string]string{
+	UploadTablePDF:   "uploaded_at",
+	UploadTableImage: "last_modified",
+}
+
+// SelectUpload returns the upload state of a row of table (UploadTablePDF or UploadTableImage).
+func SelectUpload(db *sql.DB, table string, id int) (Upload, error) {
+	var upload Upload
+	query := fmt.Sprintf(`
+        SELECT id, gcs_bucket, upload_status, size_bytes, content_type, checksum
+        FROM %s
+        WHERE id = $1
+    `, table)
+	err := db.QueryRow(query, id).Scan(
+		&upload.ID,
+		&upload.ObjectName,
+		&upload.Status,
+		&upload.SizeBytes,
+		&upload.ContentType,
+		&upload.Checksum,
+	)
+	return upload, err
+}
+
+// MarkUploadUploaded records the attributes of the stored object of a pending row.
+// It returns sql.ErrNoRows if the row doesn't exist or is no longer pending.
+func MarkUploadUploaded(db *sql.DB, table string, id int, sizeBytes int64, contentType, checksum string) error {
+	query := fmt.Sprintf(`
+        UPDATE %s
+        SET upload_status = 'uploaded', size_bytes = $1, content_type = $2, checksum = NULLIF($3, '')
+        WHERE id = $4 AND upload_status = 'pending'
+    `, table)
+	result, err := db.Exec(query, sizeBytes, contentType, checksum, id)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(result)
+}
+
+// MarkUploadFailed marks a pending row whose object was rejected.
+func MarkUploadFailed(db *sql.DB, table string, id int) error {
+	query := fmt.Sprintf(`UPDATE %s SET upload_status = 'failed' WHERE id = $1 AND upload_status = 'pending'`, table)
+	result, err := db.Exec(query, id)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(result)
+}
+
+// DeleteStaleUploads deletes up to limit rows of table that are still pending (or failed)
+// more than ttl after they were created, and returns the names of their objects.
+// Rows locked by another gateway are skipped.
+func DeleteStaleUploads(db *sql.DB, table string, ttl time.Duration, limit int) ([]string, error) {
+	query := fmt.Sprintf(`
+        DELETE FROM %[1]s
+        WHERE id IN (
+            SELECT id FROM %[1]s
+            WHERE upload_status <> 'uploaded' AND %[2]s < $1
+            LIMIT $2
+            FOR UPDATE SKIP LOCKED
+        )
+        RETURNING gcs_bucket
+    `, table, uploadCreatedColumn[table])
+	rows, err := db.Query(query, time.Now().Add(-ttl), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var objectNames []string
+	for rows.Next() {
+		var objectName sql.NullString
+		if err := rows.Scan(&objectName); err != nil {
+			return nil, err
+		}
+		if objectName.Valid && objectName.String != "" {
+			objectNames = append(objectNames, objectName.String)
+		}
+	}
+	return objectNames, rows.Err()
+}
+
+// SelectUnreferencedObjects returns the object names that no pdf or pdf_image row refers to.
+func SelectUnreferencedObjects(db *sql.DB, objectNames []string) ([]string, error) {
+	rows, err := db.Query(`
+        SELECT name FROM unnest($1::text[]) AS name
+        WHERE NOT EXISTS (SELECT 1 FROM pdf WHERE gcs_bucket = name)
+          AND NOT EXISTS (SELECT 1 FROM pdf_image WHERE gcs_bucket = name)
+    `, pq.StringArray(objectNames))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var unreferenced []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		unreferenced = append(unreferenced, name)
+	}
+	return unreferenced, rows.Err()
+}
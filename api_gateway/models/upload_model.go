@@ -0,0 +1,26 @@
+package models
+
+import "database/sql"
+
+// Upload statuses of pdf and pdf_image rows
+const (
+	UploadStatusPending  = "pending"
+	UploadStatusUploaded = "uploaded"
+	UploadStatusFailed   = "failed"
+)
+
+// Tables whose objects are uploaded by clients through signed URLs
+const (
+	UploadTablePDF   = "pdf"
+	UploadTableImage = "pdf_image"
+)
+
+// Upload is the upload state of a pdf or pdf_image row.
+type Upload struct {
+	ID          int            `json:"id"`
+	ObjectName  string         `json:"object_name"`
+	Status      string         `json:"upload_status"`
+	SizeBytes   sql.NullInt64  `json:"size_bytes"`
+	ContentType sql.NullString `json:"content_type"`
+	Checksum    sql.NullString `json:"checksum"`
+}
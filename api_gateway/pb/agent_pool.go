@@ -0,0 +1,116 @@
+package pb
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/models"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+)
+
+// extractLease is how long an agent stays marked ocr_in_used for one extraction.
+// It is a bit longer than the Extract call timeout, so the lease only expires when a gateway died mid-call.
+const extractLease = time.Hour + 5*time.Minute
+
+var agentDB *sql.DB
+
+// One gRPC connection per OCR agent, keyed by ai_agent_resources.id.
+var (
+	agentConnsMu sync.Mutex
+	agentConns   = map[int]*grpc.ClientConn{}
+	agentAddrs   = map[int]string{}
+)
+
+// InitAgents loads the OCR agents from ai_agent_resources and opens one connection per agent.
+// If no agent is registered yet, the default gRPC address is registered as the first one.
+func InitAgents(db *sql.DB) error {
+	agentDB = db
+
+	agents, err := models.SelectAllAIAgents(db)
+	if err != nil {
+		return err
+	}
+	if len(agents) == 0 {
+		// Another gateway starting at the same time may have registered it
+		if _, err := models.InsertAIAgent(db, "default", ip); err != nil && !errors.Is(err, models.ErrAIAgentExists) {
+			return err
+		}
+		if agents, err = models.SelectAllAIAgents(db); err != nil {
+			return err
+		}
+	}
+
+	for _, agent := range agents {
+		if _, err := agentConnection(agent); err != nil {
+			log.Printf("Failed to connect to OCR agent %s (%s): %v", agent.Name, agent.IP, err)
+		}
+	}
+	log.Printf("Loaded %d OCR agent(s).", len(agents))
+	return nil
+}
+
+// agentConnection returns the connection of the agent, dialing it if needed
+// (or again, if its address changed since the last call).
+func agentConnection(agent models.AIAgent) (*grpc.ClientConn, error) {
+	agentConnsMu.Lock()
+	defer agentConnsMu.Unlock()
+
+	if conn, ok := agentConns[agent.ID]; ok {
+		if agentAddrs[agent.ID] == agent.IP {
+			return conn, nil
+		}
+		conn.Close()
+		delete(agentConns, agent.ID)
+	}
+
+	conn, err := grpc.NewClient(agent.IP,
+		agentCredential(agent.IP),
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                5 * time.Minute,
+			Timeout:             60 * time.Second,
+			PermitWithoutStream: true,
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	agentConns[agent.ID] = conn
+	agentAddrs[agent.ID] = agent.IP
+	return conn, nil
+}
+
+// agentCredential uses TLS for agents behind a public HTTPS endpoint (e.g. Cloud Run) and plain text otherwise.
+func agentCredential(address string) grpc.DialOption {
+	if strings.HasSuffix(address, ":443") {
+		return cloudCredential
+	}
+	return localCredential
+}
+
+// CloseAgent closes the connection to a removed agent.
+func CloseAgent(id int) {
+	agentConnsMu.Lock()
+	defer agentConnsMu.Unlock()
+
+	if conn, ok := agentConns[id]; ok {
+		conn.Close()
+		delete(agentConns, id)
+		delete(agentAddrs, id)
+	}
+}
+
+func closeAgents() {
+	agentConnsMu.Lock()
+	defer agentConnsMu.Unlock()
+
+	for id, conn := range agentConns {
+		conn.Close()
+		delete(agentConns, id)
+		delete(agentAddrs, id)
+	}
+}
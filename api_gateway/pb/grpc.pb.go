@@ -262,6 +262,7 @@ type RagResponse struct {
 
 	Response  string  `protobuf:"bytes,1,opt,name=response,proto3" json:"response,omitempty"`
 	ImagesIds []int32 `protobuf:"varint,2,rep,packed,name=images_ids,json=imagesIds,proto3" json:"images_ids,omitempty"`
+	ChunkIds  []int32 `protobuf:"varint,3,rep,packed,name=chunk_ids,json=chunkIds,proto3" json:"chunk_ids,omitempty"`
 }
 
 func (x *RagResponse) Reset() {
@@ -310,6 +311,13 @@ func (x *RagResponse) GetImagesIds() []int32 {
 	return nil
 }
 
+func (x *RagResponse) GetChunkIds() []int32 {
+	if x != nil {
+		return x.ChunkIds
+	}
+	return nil
+}
+
 type RagWithDeviceIDRequest struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -428,6 +436,77 @@ func (x *RagWithConversationHistoryRequest) GetDeviceId() int32 {
 	return 0
 }
 
+type RagStreamResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Token     string  `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
+	ImagesIds []int32 `protobuf:"varint,2,rep,packed,name=images_ids,json=imagesIds,proto3" json:"images_ids,omitempty"`
+	Done      bool    `protobuf:"varint,3,opt,name=done,proto3" json:"done,omitempty"`
+	ChunkIds  []int32 `protobuf:"varint,4,rep,packed,name=chunk_ids,json=chunkIds,proto3" json:"chunk_ids,omitempty"`
+}
+
+func (x *RagStreamResponse) Reset() {
+	*x = RagStreamResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_grpc_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RagStreamResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RagStreamResponse) ProtoMessage() {}
+
+func (x *RagStreamResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_grpc_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RagStreamResponse.ProtoReflect.Descriptor instead.
+func (*RagStreamResponse) Descriptor() ([]byte, []int) {
+	return file_grpc_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *RagStreamResponse) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+func (x *RagStreamResponse) GetImagesIds() []int32 {
+	if x != nil {
+		return x.ImagesIds
+	}
+	return nil
+}
+
+func (x *RagStreamResponse) GetDone() bool {
+	if x != nil {
+		return x.Done
+	}
+	return false
+}
+
+func (x *RagStreamResponse) GetChunkIds() []int32 {
+	if x != nil {
+		return x.ChunkIds
+	}
+	return nil
+}
+
 type SummarizeRequest struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -439,7 +518,7 @@ type SummarizeRequest struct {
 func (x *SummarizeRequest) Reset() {
 	*x = SummarizeRequest{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_grpc_proto_msgTypes[8]
+		mi := &file_grpc_proto_msgTypes[9]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -452,7 +531,7 @@ func (x *SummarizeRequest) String() string {
 func (*SummarizeRequest) ProtoMessage() {}
 
 func (x *SummarizeRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_grpc_proto_msgTypes[8]
+	mi := &file_grpc_proto_msgTypes[9]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -465,7 +544,7 @@ func (x *SummarizeRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SummarizeRequest.ProtoReflect.Descriptor instead.
 func (*SummarizeRequest) Descriptor() ([]byte, []int) {
-	return file_grpc_proto_rawDescGZIP(), []int{8}
+	return file_grpc_proto_rawDescGZIP(), []int{9}
 }
 
 func (x *SummarizeRequest) GetQuery() string {
@@ -486,7 +565,7 @@ type SummarizeResponse struct {
 func (x *SummarizeResponse) Reset() {
 	*x = SummarizeResponse{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_grpc_proto_msgTypes[9]
+		mi := &file_grpc_proto_msgTypes[10]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -499,7 +578,7 @@ func (x *SummarizeResponse) String() string {
 func (*SummarizeResponse) ProtoMessage() {}
 
 func (x *SummarizeResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_grpc_proto_msgTypes[9]
+	mi := &file_grpc_proto_msgTypes[10]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -512,7 +591,7 @@ func (x *SummarizeResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SummarizeResponse.ProtoReflect.Descriptor instead.
 func (*SummarizeResponse) Descriptor() ([]byte, []int) {
-	return file_grpc_proto_rawDescGZIP(), []int{9}
+	return file_grpc_proto_rawDescGZIP(), []int{10}
 }
 
 func (x *SummarizeResponse) GetSummary() string {
@@ -542,62 +621,79 @@ var file_grpc_proto_rawDesc = []byte{
 	0x09, 0x52, 0x0a, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x4a, 0x73, 0x6f, 0x6e, 0x22, 0x22, 0x0a,
 	0x0a, 0x52, 0x61, 0x67, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x71,
 	0x75, 0x65, 0x72, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x71, 0x75, 0x65, 0x72,
-	0x79, 0x22, 0x48, 0x0a, 0x0b, 0x52, 0x61, 0x67, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
+	0x79, 0x22, 0x65, 0x0a, 0x0b, 0x52, 0x61, 0x67, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
 	0x12, 0x1a, 0x0a, 0x08, 0x72, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x18, 0x01, 0x20, 0x01,
 	0x28, 0x09, 0x52, 0x08, 0x72, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x1d, 0x0a, 0x0a,
 	0x69, 0x6d, 0x61, 0x67, 0x65, 0x73, 0x5f, 0x69, 0x64, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x05,
-	0x52, 0x09, 0x69, 0x6d, 0x61, 0x67, 0x65, 0x73, 0x49, 0x64, 0x73, 0x22, 0x4b, 0x0a, 0x16, 0x52,
-	0x61, 0x67, 0x57, 0x69, 0x74, 0x68, 0x44, 0x65, 0x76, 0x69, 0x63, 0x65, 0x49, 0x44, 0x52, 0x65,
+	0x52, 0x09, 0x69, 0x6d, 0x61, 0x67, 0x65, 0x73, 0x49, 0x64, 0x73, 0x12, 0x1b, 0x0a, 0x09, 0x63,
+	0x68, 0x75, 0x6e, 0x6b, 0x5f, 0x69, 0x64, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x05, 0x52, 0x08,
+	0x63, 0x68, 0x75, 0x6e, 0x6b, 0x49, 0x64, 0x73, 0x22, 0x4b, 0x0a, 0x16, 0x52, 0x61, 0x67, 0x57,
+	0x69, 0x74, 0x68, 0x44, 0x65, 0x76, 0x69, 0x63, 0x65, 0x49, 0x44, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x71, 0x75, 0x65, 0x72, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x05, 0x71, 0x75, 0x65, 0x72, 0x79, 0x12, 0x1b, 0x0a, 0x09, 0x64, 0x65, 0x76, 0x69,
+	0x63, 0x65, 0x5f, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x08, 0x64, 0x65, 0x76,
+	0x69, 0x63, 0x65, 0x49, 0x64, 0x22, 0x7f, 0x0a, 0x21, 0x52, 0x61, 0x67, 0x57, 0x69, 0x74, 0x68,
+	0x43, 0x6f, 0x6e, 0x76, 0x65, 0x72, 0x73, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x48, 0x69, 0x73, 0x74,
+	0x6f, 0x72, 0x79, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x71, 0x75,
+	0x65, 0x72, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x71, 0x75, 0x65, 0x72, 0x79,
+	0x12, 0x27, 0x0a, 0x0f, 0x63, 0x6f, 0x6e, 0x76, 0x65, 0x72, 0x73, 0x61, 0x74, 0x69, 0x6f, 0x6e,
+	0x5f, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0e, 0x63, 0x6f, 0x6e, 0x76, 0x65,
+	0x72, 0x73, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x49, 0x64, 0x12, 0x1b, 0x0a, 0x09, 0x64, 0x65, 0x76,
+	0x69, 0x63, 0x65, 0x5f, 0x69, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x05, 0x52, 0x08, 0x64, 0x65,
+	0x76, 0x69, 0x63, 0x65, 0x49, 0x64, 0x22, 0x79, 0x0a, 0x11, 0x52, 0x61, 0x67, 0x53, 0x74, 0x72,
+	0x65, 0x61, 0x6d, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x74,
+	0x6f, 0x6b, 0x65, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x74, 0x6f, 0x6b, 0x65,
+	0x6e, 0x12, 0x1d, 0x0a, 0x0a, 0x69, 0x6d, 0x61, 0x67, 0x65, 0x73, 0x5f, 0x69, 0x64, 0x73, 0x18,
+	0x02, 0x20, 0x03, 0x28, 0x05, 0x52, 0x09, 0x69, 0x6d, 0x61, 0x67, 0x65, 0x73, 0x49, 0x64, 0x73,
+	0x12, 0x12, 0x0a, 0x04, 0x64, 0x6f, 0x6e, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x04,
+	0x64, 0x6f, 0x6e, 0x65, 0x12, 0x1b, 0x0a, 0x09, 0x63, 0x68, 0x75, 0x6e, 0x6b, 0x5f, 0x69, 0x64,
+	0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x05, 0x52, 0x08, 0x63, 0x68, 0x75, 0x6e, 0x6b, 0x49, 0x64,
+	0x73, 0x22, 0x28, 0x0a, 0x10, 0x53, 0x75, 0x6d, 0x6d, 0x61, 0x72, 0x69, 0x7a, 0x65, 0x52, 0x65,
 	0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x71, 0x75, 0x65, 0x72, 0x79, 0x18, 0x01,
-	0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x71, 0x75, 0x65, 0x72, 0x79, 0x12, 0x1b, 0x0a, 0x09, 0x64,
-	0x65, 0x76, 0x69, 0x63, 0x65, 0x5f, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x08,
-	0x64, 0x65, 0x76, 0x69, 0x63, 0x65, 0x49, 0x64, 0x22, 0x7f, 0x0a, 0x21, 0x52, 0x61, 0x67, 0x57,
-	0x69, 0x74, 0x68, 0x43, 0x6f, 0x6e, 0x76, 0x65, 0x72, 0x73, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x48,
-	0x69, 0x73, 0x74, 0x6f, 0x72, 0x79, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x14, 0x0a,
-	0x05, 0x71, 0x75, 0x65, 0x72, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x71, 0x75,
-	0x65, 0x72, 0x79, 0x12, 0x27, 0x0a, 0x0f, 0x63, 0x6f, 0x6e, 0x76, 0x65, 0x72, 0x73, 0x61, 0x74,
-	0x69, 0x6f, 0x6e, 0x5f, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0e, 0x63, 0x6f,
-	0x6e, 0x76, 0x65, 0x72, 0x73, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x49, 0x64, 0x12, 0x1b, 0x0a, 0x09,
-	0x64, 0x65, 0x76, 0x69, 0x63, 0x65, 0x5f, 0x69, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x05, 0x52,
-	0x08, 0x64, 0x65, 0x76, 0x69, 0x63, 0x65, 0x49, 0x64, 0x22, 0x28, 0x0a, 0x10, 0x53, 0x75, 0x6d,
-	0x6d, 0x61, 0x72, 0x69, 0x7a, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x14, 0x0a,
-	0x05, 0x71, 0x75, 0x65, 0x72, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x71, 0x75,
-	0x65, 0x72, 0x79, 0x22, 0x2d, 0x0a, 0x11, 0x53, 0x75, 0x6d, 0x6d, 0x61, 0x72, 0x69, 0x7a, 0x65,
-	0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x73, 0x75, 0x6d, 0x6d,
-	0x61, 0x72, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x73, 0x75, 0x6d, 0x6d, 0x61,
-	0x72, 0x79, 0x32, 0x47, 0x0a, 0x11, 0x45, 0x78, 0x74, 0x72, 0x61, 0x63, 0x74, 0x50, 0x64, 0x66,
-	0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x32, 0x0a, 0x07, 0x45, 0x78, 0x74, 0x72, 0x61,
-	0x63, 0x74, 0x12, 0x12, 0x2e, 0x45, 0x78, 0x74, 0x72, 0x61, 0x63, 0x74, 0x50, 0x64, 0x66, 0x52,
-	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x13, 0x2e, 0x45, 0x78, 0x74, 0x72, 0x61, 0x63, 0x74,
-	0x50, 0x64, 0x66, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x32, 0x56, 0x0a, 0x14, 0x4d,
-	0x62, 0x65, 0x72, 0x74, 0x43, 0x68, 0x75, 0x6e, 0x6b, 0x69, 0x6e, 0x67, 0x53, 0x65, 0x72, 0x76,
-	0x69, 0x63, 0x65, 0x12, 0x3e, 0x0a, 0x0d, 0x43, 0x68, 0x75, 0x6e, 0x6b, 0x41, 0x6e, 0x64, 0x45,
-	0x6d, 0x62, 0x65, 0x64, 0x12, 0x15, 0x2e, 0x4d, 0x62, 0x65, 0x72, 0x74, 0x43, 0x68, 0x75, 0x6e,
-	0x6b, 0x69, 0x6e, 0x67, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x16, 0x2e, 0x4d, 0x62,
-	0x65, 0x72, 0x74, 0x43, 0x68, 0x75, 0x6e, 0x6b, 0x69, 0x6e, 0x67, 0x52, 0x65, 0x73, 0x70, 0x6f,
-	0x6e, 0x73, 0x65, 0x32, 0x30, 0x0a, 0x0a, 0x52, 0x61, 0x67, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63,
-	0x65, 0x12, 0x22, 0x0a, 0x05, 0x51, 0x75, 0x65, 0x72, 0x79, 0x12, 0x0b, 0x2e, 0x52, 0x61, 0x67,
-	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x0c, 0x2e, 0x52, 0x61, 0x67, 0x52, 0x65, 0x73,
-	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x32, 0x48, 0x0a, 0x16, 0x52, 0x61, 0x67, 0x53, 0x65, 0x72, 0x76,
-	0x69, 0x63, 0x65, 0x57, 0x69, 0x74, 0x68, 0x44, 0x65, 0x76, 0x69, 0x63, 0x65, 0x49, 0x44, 0x12,
-	0x2e, 0x0a, 0x05, 0x51, 0x75, 0x65, 0x72, 0x79, 0x12, 0x17, 0x2e, 0x52, 0x61, 0x67, 0x57, 0x69,
-	0x74, 0x68, 0x44, 0x65, 0x76, 0x69, 0x63, 0x65, 0x49, 0x44, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
-	0x74, 0x1a, 0x0c, 0x2e, 0x52, 0x61, 0x67, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x32,
-	0x5e, 0x0a, 0x21, 0x52, 0x61, 0x67, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x57, 0x69, 0x74,
-	0x68, 0x43, 0x6f, 0x6e, 0x76, 0x65, 0x72, 0x73, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x48, 0x69, 0x73,
-	0x74, 0x6f, 0x72, 0x79, 0x12, 0x39, 0x0a, 0x05, 0x51, 0x75, 0x65, 0x72, 0x79, 0x12, 0x22, 0x2e,
-	0x52, 0x61, 0x67, 0x57, 0x69, 0x74, 0x68, 0x43, 0x6f, 0x6e, 0x76, 0x65, 0x72, 0x73, 0x61, 0x74,
-	0x69, 0x6f, 0x6e, 0x48, 0x69, 0x73, 0x74, 0x6f, 0x72, 0x79, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
-	0x74, 0x1a, 0x0c, 0x2e, 0x52, 0x61, 0x67, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x32,
-	0x4b, 0x0a, 0x15, 0x53, 0x75, 0x6d, 0x6d, 0x61, 0x72, 0x69, 0x7a, 0x65, 0x51, 0x75, 0x65, 0x72,
-	0x79, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x32, 0x0a, 0x09, 0x53, 0x75, 0x6d, 0x6d,
-	0x61, 0x72, 0x69, 0x7a, 0x65, 0x12, 0x11, 0x2e, 0x53, 0x75, 0x6d, 0x6d, 0x61, 0x72, 0x69, 0x7a,
-	0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x12, 0x2e, 0x53, 0x75, 0x6d, 0x6d, 0x61,
-	0x72, 0x69, 0x7a, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x42, 0x32, 0x5a, 0x30,
-	0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x64, 0x75, 0x63, 0x74, 0x72,
-	0x75, 0x6f, 0x6e, 0x67, 0x68, 0x6f, 0x63, 0x2f, 0x44, 0x41, 0x54, 0x4e, 0x5f, 0x30, 0x38, 0x5f,
-	0x32, 0x30, 0x32, 0x34, 0x5f, 0x42, 0x61, 0x63, 0x6b, 0x2d, 0x65, 0x6e, 0x64, 0x2f, 0x70, 0x62,
-	0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x71, 0x75, 0x65, 0x72, 0x79, 0x22, 0x2d, 0x0a, 0x11, 0x53,
+	0x75, 0x6d, 0x6d, 0x61, 0x72, 0x69, 0x7a, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
+	0x12, 0x18, 0x0a, 0x07, 0x73, 0x75, 0x6d, 0x6d, 0x61, 0x72, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x07, 0x73, 0x75, 0x6d, 0x6d, 0x61, 0x72, 0x79, 0x32, 0x47, 0x0a, 0x11, 0x45, 0x78,
+	0x74, 0x72, 0x61, 0x63, 0x74, 0x50, 0x64, 0x66, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12,
+	0x32, 0x0a, 0x07, 0x45, 0x78, 0x74, 0x72, 0x61, 0x63, 0x74, 0x12, 0x12, 0x2e, 0x45, 0x78, 0x74,
+	0x72, 0x61, 0x63, 0x74, 0x50, 0x64, 0x66, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x13,
+	0x2e, 0x45, 0x78, 0x74, 0x72, 0x61, 0x63, 0x74, 0x50, 0x64, 0x66, 0x52, 0x65, 0x73, 0x70, 0x6f,
+	0x6e, 0x73, 0x65, 0x32, 0x56, 0x0a, 0x14, 0x4d, 0x62, 0x65, 0x72, 0x74, 0x43, 0x68, 0x75, 0x6e,
+	0x6b, 0x69, 0x6e, 0x67, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x3e, 0x0a, 0x0d, 0x43,
+	0x68, 0x75, 0x6e, 0x6b, 0x41, 0x6e, 0x64, 0x45, 0x6d, 0x62, 0x65, 0x64, 0x12, 0x15, 0x2e, 0x4d,
+	0x62, 0x65, 0x72, 0x74, 0x43, 0x68, 0x75, 0x6e, 0x6b, 0x69, 0x6e, 0x67, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x1a, 0x16, 0x2e, 0x4d, 0x62, 0x65, 0x72, 0x74, 0x43, 0x68, 0x75, 0x6e, 0x6b,
+	0x69, 0x6e, 0x67, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x32, 0x30, 0x0a, 0x0a, 0x52,
+	0x61, 0x67, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x22, 0x0a, 0x05, 0x51, 0x75, 0x65,
+	0x72, 0x79, 0x12, 0x0b, 0x2e, 0x52, 0x61, 0x67, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a,
+	0x0c, 0x2e, 0x52, 0x61, 0x67, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x32, 0x48, 0x0a,
+	0x16, 0x52, 0x61, 0x67, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x57, 0x69, 0x74, 0x68, 0x44,
+	0x65, 0x76, 0x69, 0x63, 0x65, 0x49, 0x44, 0x12, 0x2e, 0x0a, 0x05, 0x51, 0x75, 0x65, 0x72, 0x79,
+	0x12, 0x17, 0x2e, 0x52, 0x61, 0x67, 0x57, 0x69, 0x74, 0x68, 0x44, 0x65, 0x76, 0x69, 0x63, 0x65,
+	0x49, 0x44, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x0c, 0x2e, 0x52, 0x61, 0x67, 0x52,
+	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x32, 0x5e, 0x0a, 0x21, 0x52, 0x61, 0x67, 0x53, 0x65,
+	0x72, 0x76, 0x69, 0x63, 0x65, 0x57, 0x69, 0x74, 0x68, 0x43, 0x6f, 0x6e, 0x76, 0x65, 0x72, 0x73,
+	0x61, 0x74, 0x69, 0x6f, 0x6e, 0x48, 0x69, 0x73, 0x74, 0x6f, 0x72, 0x79, 0x12, 0x39, 0x0a, 0x05,
+	0x51, 0x75, 0x65, 0x72, 0x79, 0x12, 0x22, 0x2e, 0x52, 0x61, 0x67, 0x57, 0x69, 0x74, 0x68, 0x43,
+	0x6f, 0x6e, 0x76, 0x65, 0x72, 0x73, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x48, 0x69, 0x73, 0x74, 0x6f,
+	0x72, 0x79, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x0c, 0x2e, 0x52, 0x61, 0x67, 0x52,
+	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x32, 0x72, 0x0a, 0x27, 0x52, 0x61, 0x67, 0x53, 0x74,
+	0x72, 0x65, 0x61, 0x6d, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x57, 0x69, 0x74, 0x68, 0x43,
+	0x6f, 0x6e, 0x76, 0x65, 0x72, 0x73, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x48, 0x69, 0x73, 0x74, 0x6f,
+	0x72, 0x79, 0x12, 0x47, 0x0a, 0x0b, 0x51, 0x75, 0x65, 0x72, 0x79, 0x53, 0x74, 0x72, 0x65, 0x61,
+	0x6d, 0x12, 0x22, 0x2e, 0x52, 0x61, 0x67, 0x57, 0x69, 0x74, 0x68, 0x43, 0x6f, 0x6e, 0x76, 0x65,
+	0x72, 0x73, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x48, 0x69, 0x73, 0x74, 0x6f, 0x72, 0x79, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x12, 0x2e, 0x52, 0x61, 0x67, 0x53, 0x74, 0x72, 0x65, 0x61,
+	0x6d, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x30, 0x01, 0x32, 0x4b, 0x0a, 0x15, 0x53,
+	0x75, 0x6d, 0x6d, 0x61, 0x72, 0x69, 0x7a, 0x65, 0x51, 0x75, 0x65, 0x72, 0x79, 0x53, 0x65, 0x72,
+	0x76, 0x69, 0x63, 0x65, 0x12, 0x32, 0x0a, 0x09, 0x53, 0x75, 0x6d, 0x6d, 0x61, 0x72, 0x69, 0x7a,
+	0x65, 0x12, 0x11, 0x2e, 0x53, 0x75, 0x6d, 0x6d, 0x61, 0x72, 0x69, 0x7a, 0x65, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x1a, 0x12, 0x2e, 0x53, 0x75, 0x6d, 0x6d, 0x61, 0x72, 0x69, 0x7a, 0x65,
+	0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x42, 0x32, 0x5a, 0x30, 0x67, 0x69, 0x74, 0x68,
+	0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x64, 0x75, 0x63, 0x74, 0x72, 0x75, 0x6f, 0x6e, 0x67,
+	0x68, 0x6f, 0x63, 0x2f, 0x44, 0x41, 0x54, 0x4e, 0x5f, 0x30, 0x38, 0x5f, 0x32, 0x30, 0x32, 0x34,
+	0x5f, 0x42, 0x61, 0x63, 0x6b, 0x2d, 0x65, 0x6e, 0x64, 0x2f, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -612,7 +708,7 @@ func file_grpc_proto_rawDescGZIP() []byte {
 	return file_grpc_proto_rawDescData
 }
 
-var file_grpc_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
+var file_grpc_proto_msgTypes = make([]protoimpl.MessageInfo, 11)
 var file_grpc_proto_goTypes = []interface{}{
 	(*ExtractPdfRequest)(nil),                 // 0: ExtractPdfRequest
 	(*ExtractPdfResponse)(nil),                // 1: ExtractPdfResponse
@@ -622,27 +718,30 @@ var file_grpc_proto_goTypes = []interface{}{
 	(*RagResponse)(nil),                       // 5: RagResponse
 	(*RagWithDeviceIDRequest)(nil),            // 6: RagWithDeviceIDRequest
 	(*RagWithConversationHistoryRequest)(nil), // 7: RagWithConversationHistoryRequest
-	(*SummarizeRequest)(nil),                  // 8: SummarizeRequest
-	(*SummarizeResponse)(nil),                 // 9: SummarizeResponse
+	(*RagStreamResponse)(nil),                 // 8: RagStreamResponse
+	(*SummarizeRequest)(nil),                  // 9: SummarizeRequest
+	(*SummarizeResponse)(nil),                 // 10: SummarizeResponse
 }
 var file_grpc_proto_depIdxs = []int32{
-	0, // 0: ExtractPdfService.Extract:input_type -> ExtractPdfRequest
-	2, // 1: MbertChunkingService.ChunkAndEmbed:input_type -> MbertChunkingRequest
-	4, // 2: RagService.Query:input_type -> RagRequest
-	6, // 3: RagServiceWithDeviceID.Query:input_type -> RagWithDeviceIDRequest
-	7, // 4: RagServiceWithConversationHistory.Query:input_type -> RagWithConversationHistoryRequest
-	8, // 5: SummarizeQueryService.Summarize:input_type -> SummarizeRequest
-	1, // 6: ExtractPdfService.Extract:output_type -> ExtractPdfResponse
-	3, // 7: MbertChunkingService.ChunkAndEmbed:output_type -> MbertChunkingResponse
-	5, // 8: RagService.Query:output_type -> RagResponse
-	5, // 9: RagServiceWithDeviceID.Query:output_type -> RagResponse
-	5, // 10: RagServiceWithConversationHistory.Query:output_type -> RagResponse
-	9, // 11: SummarizeQueryService.Summarize:output_type -> SummarizeResponse
-	6, // [6:12] is the sub-list for method output_type
-	0, // [0:6] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	0,  // 0: ExtractPdfService.Extract:input_type -> ExtractPdfRequest
+	2,  // 1: MbertChunkingService.ChunkAndEmbed:input_type -> MbertChunkingRequest
+	4,  // 2: RagService.Query:input_type -> RagRequest
+	6,  // 3: RagServiceWithDeviceID.Query:input_type -> RagWithDeviceIDRequest
+	7,  // 4: RagServiceWithConversationHistory.Query:input_type -> RagWithConversationHistoryRequest
+	7,  // 5: RagStreamServiceWithConversationHistory.QueryStream:input_type -> RagWithConversationHistoryRequest
+	9,  // 6: SummarizeQueryService.Summarize:input_type -> SummarizeRequest
+	1,  // 7: ExtractPdfService.Extract:output_type -> ExtractPdfResponse
+	3,  // 8: MbertChunkingService.ChunkAndEmbed:output_type -> MbertChunkingResponse
+	5,  // 9: RagService.Query:output_type -> RagResponse
+	5,  // 10: RagServiceWithDeviceID.Query:output_type -> RagResponse
+	5,  // 11: RagServiceWithConversationHistory.Query:output_type -> RagResponse
+	8,  // 12: RagStreamServiceWithConversationHistory.QueryStream:output_type -> RagStreamResponse
+	10, // 13: SummarizeQueryService.Summarize:output_type -> SummarizeResponse
+	7,  // [7:14] is the sub-list for method output_type
+	0,  // [0:7] is the sub-list for method input_type
+	0,  // [0:0] is the sub-list for extension type_name
+	0,  // [0:0] is the sub-list for extension extendee
+	0,  // [0:0] is the sub-list for field type_name
 }
 
 func init() { file_grpc_proto_init() }
@@ -748,7 +847,7 @@ func file_grpc_proto_init() {
 			}
 		}
 		file_grpc_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*SummarizeRequest); i {
+			switch v := v.(*RagStreamResponse); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -760,6 +859,18 @@ func file_grpc_proto_init() {
 			}
 		}
 		file_grpc_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SummarizeRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_grpc_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*SummarizeResponse); i {
 			case 0:
 				return &v.state
@@ -778,9 +889,9 @@ func file_grpc_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_grpc_proto_rawDesc,
 			NumEnums:      0,
-			NumMessages:   10,
+			NumMessages:   11,
 			NumExtensions: 0,
-			NumServices:   6,
+			NumServices:   7,
 		},
 		GoTypes:           file_grpc_proto_goTypes,
 		DependencyIndexes: file_grpc_proto_depIdxs,
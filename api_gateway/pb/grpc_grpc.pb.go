@@ -450,6 +450,120 @@ var RagServiceWithConversationHistory_ServiceDesc = grpc.ServiceDesc{
 	Metadata: "grpc.proto",
 }
 
+// RagStreamServiceWithConversationHistoryClient is the client API for RagStreamServiceWithConversationHistory service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type RagStreamServiceWithConversationHistoryClient interface {
+	QueryStream(ctx context.Context, in *RagWithConversationHistoryRequest, opts ...grpc.CallOption) (RagStreamServiceWithConversationHistory_QueryStreamClient, error)
+}
+
+type ragStreamServiceWithConversationHistoryClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewRagStreamServiceWithConversationHistoryClient(cc grpc.ClientConnInterface) RagStreamServiceWithConversationHistoryClient {
+	return &ragStreamServiceWithConversationHistoryClient{cc}
+}
+
+func (c *ragStreamServiceWithConversationHistoryClient) QueryStream(ctx context.Context, in *RagWithConversationHistoryRequest, opts ...grpc.CallOption) (RagStreamServiceWithConversationHistory_QueryStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &RagStreamServiceWithConversationHistory_ServiceDesc.Streams[0], "/RagStreamServiceWithConversationHistory/QueryStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &ragStreamServiceWithConversationHistoryQueryStreamClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type RagStreamServiceWithConversationHistory_QueryStreamClient interface {
+	Recv() (*RagStreamResponse, error)
+	grpc.ClientStream
+}
+
+type ragStreamServiceWithConversationHistoryQueryStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *ragStreamServiceWithConversationHistoryQueryStreamClient) Recv() (*RagStreamResponse, error) {
+	m := new(RagStreamResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// RagStreamServiceWithConversationHistoryServer is the server API for RagStreamServiceWithConversationHistory service.
+// All implementations must embed UnimplementedRagStreamServiceWithConversationHistoryServer
+// for forward compatibility
+type RagStreamServiceWithConversationHistoryServer interface {
+	QueryStream(*RagWithConversationHistoryRequest, RagStreamServiceWithConversationHistory_QueryStreamServer) error
+	mustEmbedUnimplementedRagStreamServiceWithConversationHistoryServer()
+}
+
+// UnimplementedRagStreamServiceWithConversationHistoryServer must be embedded to have forward compatible implementations.
+type UnimplementedRagStreamServiceWithConversationHistoryServer struct {
+}
+
+func (UnimplementedRagStreamServiceWithConversationHistoryServer) QueryStream(*RagWithConversationHistoryRequest, RagStreamServiceWithConversationHistory_QueryStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method QueryStream not implemented")
+}
+func (UnimplementedRagStreamServiceWithConversationHistoryServer) mustEmbedUnimplementedRagStreamServiceWithConversationHistoryServer() {
+}
+
+// UnsafeRagStreamServiceWithConversationHistoryServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to RagStreamServiceWithConversationHistoryServer will
+// result in compilation errors.
+type UnsafeRagStreamServiceWithConversationHistoryServer interface {
+	mustEmbedUnimplementedRagStreamServiceWithConversationHistoryServer()
+}
+
+func RegisterRagStreamServiceWithConversationHistoryServer(s grpc.ServiceRegistrar, srv RagStreamServiceWithConversationHistoryServer) {
+	s.RegisterService(&RagStreamServiceWithConversationHistory_ServiceDesc, srv)
+}
+
+func _RagStreamServiceWithConversationHistory_QueryStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(RagWithConversationHistoryRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(RagStreamServiceWithConversationHistoryServer).QueryStream(m, &ragStreamServiceWithConversationHistoryQueryStreamServer{stream})
+}
+
+type RagStreamServiceWithConversationHistory_QueryStreamServer interface {
+	Send(*RagStreamResponse) error
+	grpc.ServerStream
+}
+
+type ragStreamServiceWithConversationHistoryQueryStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *ragStreamServiceWithConversationHistoryQueryStreamServer) Send(m *RagStreamResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// RagStreamServiceWithConversationHistory_ServiceDesc is the grpc.ServiceDesc for RagStreamServiceWithConversationHistory service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var RagStreamServiceWithConversationHistory_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "RagStreamServiceWithConversationHistory",
+	HandlerType: (*RagStreamServiceWithConversationHistoryServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "QueryStream",
+			Handler:       _RagStreamServiceWithConversationHistory_QueryStream_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "grpc.proto",
+}
+
 // SummarizeQueryServiceClient is the client API for SummarizeQueryService service.
 //
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
@@ -2,10 +2,14 @@ package pb
 
 import (
 	"context"
-	"fmt"
+	"database/sql"
+	"errors"
+	"io"
 	"log"
+	"strings"
 	"time"
 
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/models"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials" // Added import for TLS credentials
@@ -14,7 +18,6 @@ import (
 	"google.golang.org/grpc/status"
 )
 
-var pb_Client ExtractPdfServiceClient
 var pb_conn *grpc.ClientConn
 
 const (
@@ -33,7 +36,9 @@ var currentCredential = func() grpc.DialOption {
     return cloudCredential
 }()
 //var ip = LOCALIP // Default to local IP for testing
-var AgentIsExtracting = false
+
+// ErrAgentBusy is returned by CallExtractPDF when every OCR agent is draining or already extracting another PDF.
+var ErrAgentBusy = errors.New("OCR resource is currently in use for every agent")
 
 const (
 	// Cấu hình thời gian chờ cho việc thiết lập kết nối (Dial Timeout)
@@ -126,37 +131,54 @@ func Init() {
 	log.Fatalf("Failed to initialize gRPC connection after %d retries. Please check server status and network.", maxInitRetries)
 }
 
+// UseConn makes the Call functions reach the AI agent through conn instead of the connection
+// of Init, e.g. a fake agent in tests. It returns the connection used until now.
+func UseConn(conn *grpc.ClientConn) *grpc.ClientConn {
+	previous := pb_conn
+	pb_conn = conn
+	return previous
+}
+
 // Optionally, add a function to close the connection when your app shuts down
 func Close() {
 	if pb_conn != nil {
 		pb_conn.Close()
 	}
+	closeAgents()
 }
 
-// CallExtractPDF calls the Extract method and returns the result JSON.
+// CallExtractPDF sends the extraction to a free OCR agent and returns the result JSON.
+// The agent is marked ocr_in_used for the duration of the call; ErrAgentBusy is returned if none is free.
 func CallExtractPDF(pdfBucketName string) (string, error) {
-	if pb_conn == nil {
-		log.Fatal("pb_conn is not initialized")
+	if agentDB == nil {
+		log.Fatal("OCR agents are not initialized")
 	}
 
-	// Ensure only one OCR request at a time per agent (by ip)
-	if AgentIsExtracting {
-		return "", fmt.Errorf("OCR resource is currently in use for this agent")
+	agent, err := models.ClaimFreeAIAgent(agentDB, extractLease)
+	if err == sql.ErrNoRows {
+		return "", ErrAgentBusy
+	}
+	if err != nil {
+		return "", err
 	}
-
-	AgentIsExtracting = true
 	defer func() {
-		AgentIsExtracting = false
+		if err := models.ReleaseAIAgent(agentDB, agent.ID); err != nil {
+			log.Printf("Failed to release OCR agent %s: %v", agent.Name, err)
+		}
 	}()
 
-	pb_Client = NewExtractPdfServiceClient(pb_conn)
+	conn, err := agentConnection(agent)
+	if err != nil {
+		return "", err
+	}
+	client := NewExtractPdfServiceClient(conn)
 	req := &ExtractPdfRequest{
 		GcsPdfBucketName: pdfBucketName,
 	}
 	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
 	defer cancel()
 
-	resp, err := pb_Client.Extract(ctx, req)
+	resp, err := client.Extract(ctx, req)
 	if err != nil {
 		return "", err
 	}
@@ -259,4 +281,54 @@ func CallRagServiceWithConversationHistory(query string, conversationID string,
         return nil, err
     }
     return resp, nil
-}
\ No newline at end of file
+}
+// CallRagServiceWithConversationHistoryStream calls the QueryStream method of RagStreamServiceWithConversationHistory.
+// Every token is handed to onToken as soon as it arrives; the assembled response is returned once the stream ends.
+// Cancelling ctx (e.g. when the HTTP client disconnects) aborts the stream.
+func CallRagServiceWithConversationHistoryStream(ctx context.Context, query string, conversationID string, deviceID int32, onToken func(token string) error) (*RagResponse, error) {
+	if pb_conn == nil {
+		log.Fatal("pb_conn is not initialized")
+	}
+	client := NewRagStreamServiceWithConversationHistoryClient(pb_conn)
+	req := &RagWithConversationHistoryRequest{
+		Query:          query,
+		ConversationId: conversationID,
+		DeviceId:       deviceID,
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Hour)
+	defer cancel()
+
+	stream, err := client.QueryStream(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var response strings.Builder
+	var imagesIds, chunkIds []int32
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if token := chunk.GetToken(); token != "" {
+			response.WriteString(token)
+			if err := onToken(token); err != nil {
+				return nil, err
+			}
+		}
+		imagesIds = append(imagesIds, chunk.GetImagesIds()...)
+		chunkIds = append(chunkIds, chunk.GetChunkIds()...)
+		if chunk.GetDone() {
+			break
+		}
+	}
+
+	return &RagResponse{
+		Response:  response.String(),
+		ImagesIds: imagesIds,
+		ChunkIds:  chunkIds,
+	}, nil
+}
@@ -0,0 +1,147 @@
+package rageval
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/models"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/pb"
+)
+
+// Answer is the answer of the agent to a case.
+type Answer struct {
+	Response string
+	ChunkIDs []int
+	// Pages cited by the answer, in the order of its chunks
+	Pages []Page
+	// Latency of a recorded answer. Run measures it for the others.
+	Latency time.Duration
+}
+
+// Client answers the questions of the evaluation set.
+type Client interface {
+	Ask(c Case) (Answer, error)
+}
+
+// AgentClient asks RagServiceWithDeviceID of the AI agent, connected by pb.Init, and resolves the
+// chunks of the answers to their pages in DB.
+type AgentClient struct {
+	DB *sql.DB
+}
+
+func (a AgentClient) Ask(c Case) (Answer, error) {
+	resp, err := pb.CallRagQueryWithDeviceID(c.Question, int32(c.DeviceID))
+	if err != nil {
+		return Answer{}, fmt.Errorf("RAG query failed: %w", err)
+	}
+	answer := Answer{Response: resp.GetResponse(), ChunkIDs: make([]int, len(resp.GetChunkIds()))}
+	for i, id := range resp.GetChunkIds() {
+		answer.ChunkIDs[i] = int(id)
+	}
+	citations, err := models.SelectChunkCitations(a.DB, answer.ChunkIDs)
+	if err != nil {
+		return Answer{}, fmt.Errorf("failed to resolve the cited chunks: %w", err)
+	}
+	for _, citation := range citations {
+		answer.Pages = append(answer.Pages, Page{PDFID: citation.PDFID, FileName: citation.FileName, Number: citation.PageNumber})
+	}
+	return answer, nil
+}
+
+// Recorded is an answer of the agent as recorded by a Recorder, a line of a recording.
+type Recorded struct {
+	DeviceID   int     `json:"device_id"`
+	Question   string  `json:"question"`
+	Response   string  `json:"response"`
+	ChunkIDs   []int   `json:"chunk_ids"`
+	CitedPages []Page  `json:"cited_pages"`
+	LatencyMs  float64 `json:"latency_ms"`
+}
+
+// Recorder asks Client and writes the answers to W, a JSON line each, for a Replay.
+// Failed questions aren't recorded.
+type Recorder struct {
+	Client Client
+	W      io.Writer
+}
+
+func (r Recorder) Ask(c Case) (Answer, error) {
+	start := time.Now()
+	answer, err := r.Client.Ask(c)
+	if err != nil {
+		return answer, err
+	}
+	if answer.Latency == 0 {
+		answer.Latency = time.Since(start)
+	}
+	line, err := json.Marshal(Recorded{
+		DeviceID:   c.DeviceID,
+		Question:   c.Question,
+		Response:   answer.Response,
+		ChunkIDs:   answer.ChunkIDs,
+		CitedPages: answer.Pages,
+		LatencyMs:  milliseconds(answer.Latency),
+	})
+	if err != nil {
+		return answer, err
+	}
+	if _, err := r.W.Write(append(line, '\n')); err != nil {
+		return answer, fmt.Errorf("failed to record the answer: %w", err)
+	}
+	return answer, nil
+}
+
+// ErrNotRecorded is returned by a Replay for a question that wasn't recorded.
+var ErrNotRecorded = errors.New("no recorded answer")
+
+// Replay answers with the answers of a recording, without the agent or the database, to check
+// the scoring or compare recordings. The last answer recorded for a question wins.
+type Replay map[string]Recorded
+
+func replayKey(deviceID int, question string) string {
+	return fmt.Sprintf("%d\x00%s", deviceID, strings.TrimSpace(question))
+}
+
+// LoadReplay reads a recording written by a Recorder.
+func LoadReplay(path string) (Replay, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	replay := Replay{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 16<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var recorded Recorded
+		if err := json.Unmarshal(scanner.Bytes(), &recorded); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		replay[replayKey(recorded.DeviceID, recorded.Question)] = recorded
+	}
+	return replay, scanner.Err()
+}
+
+func (r Replay) Ask(c Case) (Answer, error) {
+	recorded, ok := r[replayKey(c.DeviceID, c.Question)]
+	if !ok {
+		return Answer{}, ErrNotRecorded
+	}
+	return Answer{
+		Response: recorded.Response,
+		ChunkIDs: recorded.ChunkIDs,
+		Pages:    recorded.CitedPages,
+		Latency:  time.Duration(recorded.LatencyMs * float64(time.Millisecond)),
+	}, nil
+}
@@ -0,0 +1,103 @@
+// Package rageval scores the RAG answers of the AI agent against a set of questions with known
+// answers: recall@k of the cited pages, hit rate of the expected keywords and latency. It is run
+// by cmd/rageval before changing the chunking or the prompts.
+package rageval
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Page is a page of a PDF. Expected pages may leave out the PDF id or the filename, they then
+// match the page of any PDF of the device.
+type Page struct {
+	PDFID    int    `json:"pdf_id,omitempty" yaml:"pdf_id"`
+	FileName string `json:"filename,omitempty" yaml:"filename"`
+	Number   int    `json:"page" yaml:"page"`
+}
+
+// matches tells whether a cited page is the expected page p.
+func (p Page) matches(cited Page) bool {
+	return p.Number == cited.Number &&
+		(p.PDFID == 0 || p.PDFID == cited.PDFID) &&
+		(p.FileName == "" || p.FileName == cited.FileName)
+}
+
+// Case is a question of the evaluation set, with the pages the answer should cite and the
+// keywords it should contain. A case needs at least one of them.
+type Case struct {
+	ID               string   `json:"id" yaml:"id"`
+	DeviceID         int      `json:"device_id" yaml:"device_id"`
+	Question         string   `json:"question" yaml:"question"`
+	ExpectedPages    []Page   `json:"expected_pages" yaml:"expected_pages"`
+	ExpectedKeywords []string `json:"expected_keywords" yaml:"expected_keywords"`
+}
+
+// LoadDataset reads the evaluation set of a .yaml/.yml file (a list of cases) or a .jsonl file
+// (a case per line).
+func LoadDataset(path string) ([]Case, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cases []Case
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &cases); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	case ".jsonl":
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(nil, 1<<20)
+		for line := 1; scanner.Scan(); line++ {
+			if strings.TrimSpace(scanner.Text()) == "" {
+				continue
+			}
+			var c Case
+			if err := json.Unmarshal(scanner.Bytes(), &c); err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+			}
+			cases = append(cases, c)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%s: the dataset must be a .yaml, .yml or .jsonl file", path)
+	}
+
+	if len(cases) == 0 {
+		return nil, fmt.Errorf("%s: no case", path)
+	}
+	ids := map[string]bool{}
+	for i := range cases {
+		c := &cases[i]
+		if c.ID == "" {
+			c.ID = fmt.Sprintf("case-%d", i+1)
+		}
+		if ids[c.ID] {
+			return nil, fmt.Errorf("%s: duplicate case id %q", path, c.ID)
+		}
+		ids[c.ID] = true
+		if strings.TrimSpace(c.Question) == "" {
+			return nil, fmt.Errorf("%s: case %s has no question", path, c.ID)
+		}
+		if len(c.ExpectedPages) == 0 && len(c.ExpectedKeywords) == 0 {
+			return nil, fmt.Errorf("%s: case %s expects no page and no keyword", path, c.ID)
+		}
+		for _, page := range c.ExpectedPages {
+			if page.Number < 1 {
+				return nil, fmt.Errorf("%s: case %s expects a page without a number", path, c.ID)
+			}
+		}
+	}
+	return cases, nil
+}
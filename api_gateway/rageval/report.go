@@ -0,0 +1,133 @@
+package rageval
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Report is the result of a run, written as JSON. A report can be the baseline of later runs.
+type Report struct {
+	Dataset     string       `json:"dataset"`
+	K           int          `json:"k"`
+	GeneratedAt time.Time    `json:"generated_at"`
+	Scores      Scores       `json:"scores"`
+	Regressions []string     `json:"regressions"`
+	Cases       []CaseResult `json:"cases"`
+}
+
+// Tolerance is how much worse than the baseline a run may score without being a regression:
+// Score for the recall and keyword hit rate (absolute), Latency for the p95 latency (relative).
+type Tolerance struct {
+	Score   float64
+	Latency float64
+}
+
+// LoadBaseline reads the report of an earlier run.
+func LoadBaseline(path string) (Report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Report{}, err
+	}
+	var baseline Report
+	if err := json.Unmarshal(data, &baseline); err != nil {
+		return Report{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return baseline, nil
+}
+
+// Compare returns how the scores regressed from the baseline scores, nothing if they didn't.
+// Scores missing from either side aren't compared.
+func Compare(baseline, scores Scores, tolerance Tolerance) []string {
+	regressions := []string{}
+	for _, s := range []struct {
+		name              string
+		baseline, current *float64
+	}{
+		{"recall@k", baseline.RecallAtK, scores.RecallAtK},
+		{"keyword hit rate", baseline.KeywordHitRate, scores.KeywordHitRate},
+	} {
+		if s.baseline != nil && s.current != nil && *s.current < *s.baseline-tolerance.Score {
+			regressions = append(regressions, fmt.Sprintf("%s dropped from %.3f to %.3f", s.name, *s.baseline, *s.current))
+		}
+	}
+	if baseline.LatencyP95Ms > 0 && scores.LatencyP95Ms > baseline.LatencyP95Ms*(1+tolerance.Latency) {
+		regressions = append(regressions, fmt.Sprintf("p95 latency rose from %.0f ms to %.0f ms", baseline.LatencyP95Ms, scores.LatencyP95Ms))
+	}
+	if scores.Errors > baseline.Errors {
+		regressions = append(regressions, fmt.Sprintf("errors rose from %d to %d", baseline.Errors, scores.Errors))
+	}
+	return regressions
+}
+
+// WriteMarkdown writes the report for a pull request or a CI summary: the scores, the
+// regressions and the cases that missed a page or a keyword, or failed.
+func WriteMarkdown(w io.Writer, report Report) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# RAG evaluation of %s\n\n", report.Dataset)
+	fmt.Fprintf(&b, "%d cases, %d errors, %s.\n\n", report.Scores.Cases, report.Scores.Errors, report.GeneratedAt.Format(time.RFC3339))
+	b.WriteString("| Score | Value |\n|---|---|\n")
+	fmt.Fprintf(&b, "| recall@%d | %s |\n", report.K, formatScore(report.Scores.RecallAtK))
+	fmt.Fprintf(&b, "| keyword hit rate | %s |\n", formatScore(report.Scores.KeywordHitRate))
+	fmt.Fprintf(&b, "| latency p50 / p90 / p95 / p99 | %.0f / %.0f / %.0f / %.0f ms |\n\n",
+		report.Scores.LatencyP50Ms, report.Scores.LatencyP90Ms, report.Scores.LatencyP95Ms, report.Scores.LatencyP99Ms)
+
+	if len(report.Regressions) > 0 {
+		b.WriteString("## Regressions\n\n")
+		for _, regression := range report.Regressions {
+			fmt.Fprintf(&b, "- %s\n", regression)
+		}
+		b.WriteString("\n")
+	}
+
+	var missed []CaseResult
+	for _, result := range report.Cases {
+		if result.Error != "" || len(result.MissingPages) > 0 || len(result.MissingKeywords) > 0 {
+			missed = append(missed, result)
+		}
+	}
+	if len(missed) > 0 {
+		b.WriteString("## Missed cases\n\n| Case | Device | Missing | Latency |\n|---|---|---|---|\n")
+		for _, result := range missed {
+			var missing []string
+			if result.Error != "" {
+				missing = append(missing, "error: "+result.Error)
+			}
+			for _, page := range result.MissingPages {
+				missing = append(missing, "page "+page.String())
+			}
+			for _, keyword := range result.MissingKeywords {
+				missing = append(missing, fmt.Sprintf("%q", keyword))
+			}
+			fmt.Fprintf(&b, "| %s | %d | %s | %.0f ms |\n", markdownCell(result.ID), result.DeviceID,
+				markdownCell(strings.Join(missing, ", ")), result.LatencyMs)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (p Page) String() string {
+	switch {
+	case p.FileName != "":
+		return fmt.Sprintf("%d of %s", p.Number, p.FileName)
+	case p.PDFID != 0:
+		return fmt.Sprintf("%d of PDF %d", p.Number, p.PDFID)
+	}
+	return fmt.Sprint(p.Number)
+}
+
+func formatScore(score *float64) string {
+	if score == nil {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.3f", *score)
+}
+
+func markdownCell(text string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(text)
+}
@@ -0,0 +1,155 @@
+package rageval
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CaseResult is how the agent did on a case. Recall and KeywordHitRate are nil when the case
+// expects no page, or no keyword.
+type CaseResult struct {
+	ID              string   `json:"id"`
+	DeviceID        int      `json:"device_id"`
+	Question        string   `json:"question"`
+	Response        string   `json:"response"`
+	CitedPages      []Page   `json:"cited_pages"`
+	Recall          *float64 `json:"recall"`
+	MissingPages    []Page   `json:"missing_pages,omitempty"`
+	KeywordHitRate  *float64 `json:"keyword_hit_rate"`
+	MissingKeywords []string `json:"missing_keywords,omitempty"`
+	LatencyMs       float64  `json:"latency_ms"`
+	Error           string   `json:"error,omitempty"`
+}
+
+// Scores sums up a run. RecallAtK and KeywordHitRate are the means over the cases expecting
+// pages, and keywords; failed cases score 0. The latencies are those of the answered cases.
+type Scores struct {
+	Cases          int      `json:"cases"`
+	Errors         int      `json:"errors"`
+	RecallAtK      *float64 `json:"recall_at_k"`
+	KeywordHitRate *float64 `json:"keyword_hit_rate"`
+	LatencyP50Ms   float64  `json:"latency_p50_ms"`
+	LatencyP90Ms   float64  `json:"latency_p90_ms"`
+	LatencyP95Ms   float64  `json:"latency_p95_ms"`
+	LatencyP99Ms   float64  `json:"latency_p99_ms"`
+}
+
+// Run asks the cases one at a time, so the latencies aren't skewed by concurrency, and scores
+// the answers: recall@k counts the expected pages among the first k distinct pages cited.
+func Run(cases []Case, client Client, k int) ([]CaseResult, Scores) {
+	results := make([]CaseResult, 0, len(cases))
+	scores := Scores{Cases: len(cases)}
+	var recalls, keywordRates []float64
+	var latencies []time.Duration
+
+	for _, c := range cases {
+		result := CaseResult{ID: c.ID, DeviceID: c.DeviceID, Question: c.Question, CitedPages: []Page{}}
+		start := time.Now()
+		answer, err := client.Ask(c)
+		if answer.Latency == 0 {
+			answer.Latency = time.Since(start)
+		}
+		if err != nil {
+			result.Error = err.Error()
+			scores.Errors++
+		} else {
+			result.Response = answer.Response
+			result.CitedPages = distinctPages(answer.Pages)
+			result.LatencyMs = milliseconds(answer.Latency)
+			latencies = append(latencies, answer.Latency)
+		}
+
+		if len(c.ExpectedPages) > 0 {
+			recall := 0.0
+			top := result.CitedPages[:min(k, len(result.CitedPages))]
+			for _, expected := range c.ExpectedPages {
+				if containsPage(top, expected) {
+					recall++
+				} else {
+					result.MissingPages = append(result.MissingPages, expected)
+				}
+			}
+			recall /= float64(len(c.ExpectedPages))
+			result.Recall = &recall
+			recalls = append(recalls, recall)
+		}
+		if len(c.ExpectedKeywords) > 0 {
+			response := normalize(result.Response)
+			hits := 0.0
+			for _, keyword := range c.ExpectedKeywords {
+				if strings.Contains(response, normalize(keyword)) {
+					hits++
+				} else {
+					result.MissingKeywords = append(result.MissingKeywords, keyword)
+				}
+			}
+			rate := hits / float64(len(c.ExpectedKeywords))
+			result.KeywordHitRate = &rate
+			keywordRates = append(keywordRates, rate)
+		}
+		results = append(results, result)
+	}
+
+	scores.RecallAtK = mean(recalls)
+	scores.KeywordHitRate = mean(keywordRates)
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	scores.LatencyP50Ms = percentile(latencies, 50)
+	scores.LatencyP90Ms = percentile(latencies, 90)
+	scores.LatencyP95Ms = percentile(latencies, 95)
+	scores.LatencyP99Ms = percentile(latencies, 99)
+	return results, scores
+}
+
+// distinctPages drops the pages cited again by later chunks, keeping the order.
+func distinctPages(pages []Page) []Page {
+	distinct := []Page{}
+	seen := map[Page]bool{}
+	for _, page := range pages {
+		if !seen[page] {
+			seen[page] = true
+			distinct = append(distinct, page)
+		}
+	}
+	return distinct
+}
+
+func containsPage(pages []Page, expected Page) bool {
+	for _, page := range pages {
+		if expected.matches(page) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalize lowercases text and collapses its white space, for the keyword matching.
+func normalize(text string) string {
+	return strings.Join(strings.Fields(strings.ToLower(text)), " ")
+}
+
+func mean(values []float64) *float64 {
+	if len(values) == 0 {
+		return nil
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	m := sum / float64(len(values))
+	return &m
+}
+
+// percentile is the nearest-rank percentile p of sorted latencies, in milliseconds.
+func percentile(sorted []time.Duration, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return milliseconds(sorted[max(rank, 1)-1])
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,82 @@
+package retrieval
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/models"
+)
+
+// AnswerCache reuses the RAG answers to the questions about a device, so a question asked again,
+// or asked differently, doesn't go to the LLM. Answers are matched on the normalized query first,
+// then on the cosine similarity of the query embeddings.
+type AnswerCache struct {
+	DB       *sql.DB
+	Embedder Embedder
+	TTL      time.Duration
+	// MinSimilarity is the cosine similarity from which the answer to another query is reused.
+	// 0 only reuses the answers to the same normalized query.
+	MinSimilarity float64
+}
+
+// NormalizeQuery lowercases a query, collapses its white space and drops its final punctuation,
+// so "How to reset the filter light?" and "how to reset  the filter light" are the same query.
+func NormalizeQuery(query string) string {
+	normalized := strings.Join(strings.Fields(strings.ToLower(query)), " ")
+	return strings.TrimRight(normalized, " ?!.…")
+}
+
+// Lookup returns the cached answer to a query about a device. On a miss, it returns false and the
+// answer to fill and Store, with the query embedding to match later queries and the generation of
+// the device's answers, so Store drops the answer if a chunk changes meanwhile. The answer can't
+// be stored if Lookup failed.
+func (c *AnswerCache) Lookup(deviceID int, query string) (models.CachedAnswer, bool, error) {
+	answer := models.CachedAnswer{DeviceID: deviceID, NormalizedQuery: NormalizeQuery(query)}
+
+	// Before the lookup: an invalidation after it is one the answer may predate
+	generation, err := models.SelectAnswerCacheGeneration(c.DB, deviceID)
+	if err != nil {
+		return answer, false, err
+	}
+	answer.Generation = generation
+
+	cached, err := models.SelectCachedAnswer(c.DB, deviceID, answer.NormalizedQuery)
+	if err == nil {
+		return c.hit(cached)
+	}
+	if !errors.Is(err, sql.ErrNoRows) || c.MinSimilarity <= 0 {
+		return answer, false, ignoreNoRows(err)
+	}
+
+	if answer.Embedding, err = c.Embedder.Embed(answer.NormalizedQuery); err != nil {
+		return answer, false, err
+	}
+	cached, err = models.SelectSimilarCachedAnswer(c.DB, deviceID, answer.Embedding, 1-c.MinSimilarity)
+	if err == nil {
+		return c.hit(cached)
+	}
+	return answer, false, ignoreNoRows(err)
+}
+
+func (c *AnswerCache) hit(cached models.CachedAnswer) (models.CachedAnswer, bool, error) {
+	if err := models.CountCachedAnswerHit(c.DB, cached.ID); err != nil {
+		log.Printf("Failed to count the hit of cached answer %d: %v", cached.ID, err)
+	}
+	return cached, true, nil
+}
+
+// Store caches an answer returned by a missed Lookup, for TTL, unless the answers of its device
+// were invalidated since.
+func (c *AnswerCache) Store(answer models.CachedAnswer) error {
+	return models.UpsertCachedAnswer(c.DB, answer, c.TTL)
+}
+
+func ignoreNoRows(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,188 @@
+// Package retrieval finds the chunks answering a query on the gateway side, by fusing a pgvector
+// search with a Postgres full-text search. The vector search finds paraphrases, the full-text
+// search exact model numbers and error codes.
+package retrieval
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/models"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/pb"
+)
+
+const (
+	// rrfK damps the weight of the first ranks in reciprocal rank fusion: a chunk scores
+	// 1/(rrfK+rank) in each search finding it. 60 is the value of the original paper.
+	rrfK = 60
+	// Chunks each search contributes to the fusion, at least.
+	minCandidates = 50
+)
+
+// Embedder embeds a query in the space of pdf_chunk.embedding.
+type Embedder interface {
+	Embed(text string) ([]float32, error)
+}
+
+// AgentEmbedder embeds through the chunk and embed service of the AI agent, like the chunks are.
+type AgentEmbedder struct{}
+
+func (AgentEmbedder) Embed(text string) ([]float32, error) {
+	resultJson, err := pb.CallChunkAndEmbed(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call embedding service: %w", err)
+	}
+	var result models.ChunkResult
+	if err := json.Unmarshal([]byte(resultJson), &result); err != nil {
+		return nil, fmt.Errorf("failed to parse embedding result: %w", err)
+	}
+	if len(result.Chunks) == 0 {
+		return nil, errors.New("embedding service returned no chunk")
+	}
+	// A long query is split in chunks: search with their mean
+	mean := make([]float32, len(result.Chunks[0].Vector))
+	for _, chunk := range result.Chunks {
+		if len(chunk.Vector) != len(mean) {
+			return nil, errors.New("embedding service returned vectors of different sizes")
+		}
+		for i, v := range chunk.Vector {
+			mean[i] += v / float32(len(result.Chunks))
+		}
+	}
+	return mean, nil
+}
+
+// Query is a search of the chunks. A zero DeviceID searches every device.
+type Query struct {
+	Text     string
+	DeviceID int
+	Limit    int
+}
+
+// Hit is a chunk found by Search. A zero rank means the search didn't find the chunk.
+type Hit struct {
+	models.ChunkProvenance
+	Score      float64 `json:"score"`
+	VectorRank int     `json:"vector_rank"`
+	TextRank   int     `json:"text_rank"`
+}
+
+// Fused is a chunk ranked by Fuse, with its rank (1 first, 0 if absent) in each list.
+type Fused struct {
+	ChunkID int
+	Score   float64
+	Ranks   []int
+}
+
+// Fuse merges ranked lists of chunk ids with reciprocal rank fusion and returns the best limit
+// chunks, best first. Ties go to the chunk with the best rank in any list, then the lowest id.
+func Fuse(limit int, lists ...[]int) []Fused {
+	byID := map[int]*Fused{}
+	var fused []*Fused
+	for l, list := range lists {
+		for i, id := range list {
+			f, ok := byID[id]
+			if !ok {
+				f = &Fused{ChunkID: id, Ranks: make([]int, len(lists))}
+				byID[id] = f
+				fused = append(fused, f)
+			}
+			if f.Ranks[l] != 0 {
+				continue
+			}
+			f.Ranks[l] = i + 1
+			f.Score += 1 / float64(rrfK+i+1)
+		}
+	}
+
+	bestRank := func(f *Fused) int {
+		best := 0
+		for _, rank := range f.Ranks {
+			if rank != 0 && (best == 0 || rank < best) {
+				best = rank
+			}
+		}
+		return best
+	}
+	sort.SliceStable(fused, func(i, j int) bool {
+		if fused[i].Score != fused[j].Score {
+			return fused[i].Score > fused[j].Score
+		}
+		if bi, bj := bestRank(fused[i]), bestRank(fused[j]); bi != bj {
+			return bi < bj
+		}
+		return fused[i].ChunkID < fused[j].ChunkID
+	})
+
+	if len(fused) > limit {
+		fused = fused[:limit]
+	}
+	result := make([]Fused, len(fused))
+	for i, f := range fused {
+		result[i] = *f
+	}
+	return result
+}
+
+// Search runs the vector and full-text searches of the query and returns the best chunks with
+// their page and PDF, best first.
+func Search(db *sql.DB, embedder Embedder, query Query) ([]Hit, error) {
+	search := models.ChunkSearch{DeviceID: query.DeviceID, Limit: max(query.Limit, minCandidates)}
+
+	embedding, err := embedder.Embed(query.Text)
+	if err != nil {
+		return nil, err
+	}
+	vectorRanks, err := models.SelectVectorChunkRanks(db, embedding, search)
+	if err != nil {
+		return nil, fmt.Errorf("vector search failed: %w", err)
+	}
+	textRanks, err := models.SelectTextChunkRanks(db, query.Text, search)
+	if err != nil {
+		return nil, fmt.Errorf("full-text search failed: %w", err)
+	}
+
+	fused := Fuse(query.Limit, chunkIDs(vectorRanks), chunkIDs(textRanks))
+	if len(fused) == 0 {
+		return []Hit{}, nil
+	}
+	ids := make([]int, len(fused))
+	for i, f := range fused {
+		ids[i] = f.ChunkID
+	}
+	provenances, err := models.SelectChunkProvenances(db, ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int]models.ChunkProvenance, len(provenances))
+	for _, p := range provenances {
+		byID[p.ChunkID] = p
+	}
+
+	hits := make([]Hit, 0, len(fused))
+	for _, f := range fused {
+		provenance, ok := byID[f.ChunkID]
+		if !ok {
+			// Deleted since the searches
+			continue
+		}
+		hits = append(hits, Hit{
+			ChunkProvenance: provenance,
+			Score:           f.Score,
+			VectorRank:      f.Ranks[0],
+			TextRank:        f.Ranks[1],
+		})
+	}
+	return hits, nil
+}
+
+func chunkIDs(ranks []models.ChunkRank) []int {
+	ids := make([]int, len(ranks))
+	for i, rank := range ranks {
+		ids[i] = rank.ChunkID
+	}
+	return ids
+}
@@ -0,0 +1,40 @@
+// For sub route groups
+package routes
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/controllers"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/middlewares"
+	"github.com/gin-gonic/gin"
+)
+
+// Rate limits of the account routes sending email or checking the password, per account.
+var (
+	accountEmailLimit    = middlewares.RateLimitRule{Burst: 3, Every: 5 * time.Minute}
+	accountPasswordLimit = middlewares.RateLimitRule{Burst: 10, Every: 30 * time.Second}
+)
+
+func AccountRoutes(r *gin.Engine, db *sql.DB) {
+	routeGroup := r.Group("/account", middlewares.RequirePermission())
+	{
+		byPassword := middlewares.RateLimit("account_password", accountPasswordLimit, middlewares.ByAccount)
+
+		routeGroup.GET("", controllers.GetAccountProfileHandler(db))
+		routeGroup.PUT("/display_name", controllers.UpdateDisplayNameHandler(db))
+		routeGroup.POST("/password", byPassword, controllers.ChangePasswordHandler(db))
+		routeGroup.POST(
+			"/email",
+			middlewares.RateLimit("account_email", accountEmailLimit, middlewares.ByAccount),
+			controllers.RequestEmailChangeHandler(db),
+		)
+		routeGroup.POST(
+			"/email/confirm",
+			middlewares.RateLimit("otp_verify", otpVerifyLimit, middlewares.ByAccount),
+			controllers.ConfirmEmailChangeHandler(db),
+		)
+		routeGroup.DELETE("", byPassword, controllers.DeleteAccountHandler(db))
+		routeGroup.GET("/export", controllers.ExportAccountHandler(db))
+	}
+}
@@ -0,0 +1,46 @@
+// For sub route groups
+package routes
+
+import (
+	"database/sql"
+
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/controllers"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/middlewares"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/models"
+	"github.com/gin-gonic/gin"
+)
+
+func AdminRoutes(r *gin.Engine, db *sql.DB) {
+	routeGroup := r.Group("/admin", middlewares.RequirePermission(models.PermissionAdminAccess))
+	{
+		agents := routeGroup.Group("", middlewares.RequirePermission(models.PermissionAgentManage))
+		agents.GET("/agents", controllers.ListAIAgentsHandler(db))
+		agents.POST("/agents", controllers.AddAIAgentHandler(db))
+		agents.POST("/agents/:id/drain", controllers.DrainAIAgentHandler(db))
+		agents.DELETE("/agents/:id", controllers.RemoveAIAgentHandler(db))
+
+		roles := routeGroup.Group("", middlewares.RequirePermission(models.PermissionRoleManage))
+		roles.GET("/permissions", controllers.ListPermissionsHandler(db))
+		roles.GET("/roles", controllers.ListRolesHandler(db))
+		roles.POST("/roles", controllers.CreateRoleHandler(db))
+		roles.PUT("/roles/:id", controllers.UpdateRoleHandler(db))
+		roles.DELETE("/roles/:id", controllers.DeleteRoleHandler(db))
+
+		accounts := routeGroup.Group("/accounts", middlewares.RequirePermission(models.PermissionAccountManage))
+		accounts.GET("", controllers.ListAccountsHandler(db))
+		accounts.GET("/:id", controllers.GetAccountHandler(db))
+		accounts.PUT("/:id/role", controllers.UpdateAccountRoleHandler(db))
+		accounts.POST("/:id/disable", controllers.DisableAccountHandler(db))
+		accounts.POST("/:id/enable", controllers.EnableAccountHandler(db))
+		accounts.POST("/:id/password_reset", controllers.ForcePasswordResetHandler(db))
+
+		audit := routeGroup.Group("", middlewares.RequirePermission(models.PermissionAuditRead))
+		audit.GET("/audit_events", controllers.ListAuditEventsHandler(db))
+
+		feedback := routeGroup.Group("", middlewares.RequirePermission(models.PermissionFeedbackReview))
+		feedback.GET("/feedback", controllers.ListFeedbackHandler(db))
+
+		answerCache := routeGroup.Group("", middlewares.RequirePermission(models.PermissionAnswerCachePurge))
+		answerCache.DELETE("/answer_cache", controllers.PurgeAnswerCacheHandler(db))
+	}
+}
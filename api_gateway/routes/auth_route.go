@@ -3,94 +3,165 @@ package routes
 
 import (
 	"database/sql"
+	"time"
+
 	"github.com/ductruonghoc/DATN_08_2025_Back-end/controllers"
 	"github.com/ductruonghoc/DATN_08_2025_Back-end/middlewares"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/models"
 	"github.com/gin-gonic/gin"
 )
 
+// Rate limits of the auth routes, per client IP and per email or username.
+var (
+	authIPLimit    = middlewares.RateLimitRule{Burst: 30, Every: 2 * time.Second}
+	loginLimit     = middlewares.RateLimitRule{Burst: 10, Every: 30 * time.Second}
+	otpVerifyLimit = middlewares.RateLimitRule{Burst: 10, Every: 30 * time.Second}
+	otpSendLimit   = middlewares.RateLimitRule{Burst: 3, Every: 5 * time.Minute}
+)
+
 func UserRoutes(r *gin.Engine, db *sql.DB) {
+	byIP := middlewares.RateLimit("auth", authIPLimit, middlewares.ByClientIP)
+	byEmail := middlewares.ByJSONField("email")
+
 	routeGroup := r.Group("/auth")
 	{
 		routeGroup.POST(
 			"/unverified_register",
+			byIP,
+			middlewares.RateLimit("otp_send", otpSendLimit, byEmail),
 			middlewares.CheckVerifiedEmailExisted(db),
 			middlewares.UserExistedIgnore(),
-			middlewares.SendOTP(),
+			middlewares.OTPResendCooldown(db, 1),
+			middlewares.SendOTP(db),
 			middlewares.StoreTemporatoryUser(db),
 			controllers.NonVerifiedRegistration,
 		)
 		routeGroup.POST(
 			"/verify_registration",
+			byIP,
+			middlewares.RateLimit("otp_verify", otpVerifyLimit, byEmail),
 			middlewares.VeirifyOTP(db, 1),
 			controllers.VerifiedRegistration(db),
 		)
 		routeGroup.POST(
 			"/can_google_register",
-			middlewares.CheckGoogleUserExisted(),
+			middlewares.VerifyGoogleIDToken(),
+			middlewares.CheckGoogleUserExisted(db),
 			middlewares.UserExistedIgnore(),
 			controllers.CanGoogleRegister,
 		)
 		routeGroup.POST(
 			"/google_registration",
-			controllers.GoogleRegistration,
+			middlewares.VerifyGoogleIDToken(),
+			controllers.GoogleRegistration(db),
 		)
 		routeGroup.POST(
 			"/login",
+			byIP,
+			middlewares.RateLimit("login", loginLimit, byEmail),
 			middlewares.UserAuthenticate(db),
-			controllers.UserLogin,
+			controllers.UserLogin(db),
 		)
 		routeGroup.POST(
 			"/google_login",
-			middlewares.CheckGoogleUserExisted(),
-			controllers.GoogleLogin,
+			middlewares.VerifyGoogleIDToken(),
+			controllers.GoogleLogin(db),
 		)
 		routeGroup.POST(
 			"/can_reset_password",
+			byIP,
+			middlewares.RateLimit("otp_send", otpSendLimit, byEmail),
 			middlewares.CheckVerifiedEmailExisted(db),
 			middlewares.UserExistedFirst(),
-			middlewares.SendOTP(),
+			middlewares.OTPResendCooldown(db, 0),
+			middlewares.SendOTP(db),
 			controllers.SendOTP(db, 0),
 		)
 		routeGroup.POST(
 			"/reset_password",
+			byIP,
+			middlewares.RateLimit("otp_verify", otpVerifyLimit, byEmail),
 			middlewares.VeirifyOTP(db, 0),
 			controllers.ResetPassword(db),
 		)
 
 		routeGroup.POST(
 			"/resend_otp_registration",
-			middlewares.SendOTP(),
+			byIP,
+			middlewares.RateLimit("otp_send", otpSendLimit, byEmail),
+			middlewares.OTPResendCooldown(db, 1),
+			middlewares.SendOTP(db),
 			controllers.SendOTP(db, 1),
 		)
 		routeGroup.POST(
 			"/resend_otp_reset_password",
-			middlewares.SendOTP(),
+			byIP,
+			middlewares.RateLimit("otp_send", otpSendLimit, byEmail),
+			middlewares.OTPResendCooldown(db, 0),
+			middlewares.SendOTP(db),
 			controllers.SendOTP(db, 0),
 		)
 		routeGroup.POST(
 			"/admin_login",
+			byIP,
+			middlewares.RateLimit("admin_login", loginLimit, middlewares.ByJSONField("username")),
 			middlewares.AdminAuthenticate(db),
-			controllers.AdminLogin,
+			controllers.AdminLogin(db),
+		)
+		routeGroup.POST(
+			"/admin_login/mfa",
+			byIP,
+			middlewares.RequireMFAPending(db),
+			controllers.AdminMFALogin(db),
 		)
 		routeGroup.POST(
 			"/admin_authorize",
-			middlewares.Authorization([]string{"admin"}), // Use the Authorization middleware with "admin" permission
+			middlewares.RequirePermission(models.PermissionAdminAccess),
 			controllers.AdminAuthorization,
 		)
 		routeGroup.POST(
 			"/client_authorize",
-			middlewares.Authorization([]string{"user"}), // Use the Authorization middleware with "admin" permission
+			middlewares.RequirePermission(models.PermissionConversationChat),
+			middlewares.RequireUserToken(),
 			controllers.AdminAuthorization,
 		)
 		routeGroup.POST(
 			"/verify_otp",
+			byIP,
+			middlewares.RateLimit("otp_verify", otpVerifyLimit, byEmail),
 			middlewares.VeirifyOTP(db, 0),
 			controllers.VerifyOTPAndRespondHandler(),
 		)
+		routeGroup.POST(
+			"/refresh",
+			controllers.RefreshTokenHandler(db),
+		)
+		routeGroup.POST(
+			"/logout",
+			middlewares.RequirePermission(),
+			controllers.LogoutHandler(db),
+		)
+		routeGroup.POST(
+			"/logout_all",
+			middlewares.RequirePermission(),
+			controllers.LogoutAllHandler(db),
+		)
 		routeGroup.GET(
             "/display_name",
-            middlewares.Authorization([]string{"user", "admin"}),
+            middlewares.RequirePermission(),
             controllers.GetAccountDisplayNameHandler(db),
         )
+
+		adminMFA := routeGroup.Group("/admin_mfa", middlewares.AdminMFAAuthorization(db))
+		adminMFA.GET("", controllers.AdminMFAStatus(db))
+		adminMFA.POST("/enroll", controllers.AdminMFAEnroll(db))
+		adminMFA.POST("/verify", controllers.AdminMFAVerify(db))
+		adminMFA.POST("/recovery_codes", controllers.AdminMFARecoveryCodes(db))
+		adminMFA.POST("/disable", controllers.AdminMFADisable(db))
+
+		oidc := routeGroup.Group("/oidc")
+		oidc.GET("/providers", controllers.ListOIDCProvidersHandler())
+		oidc.GET("/:provider/login", controllers.OIDCLoginHandler(db))
+		oidc.GET("/:provider/callback", controllers.OIDCCallbackHandler(db))
 	}
 }
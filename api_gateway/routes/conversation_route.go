@@ -4,21 +4,30 @@ import (
 	"github.com/ductruonghoc/DATN_08_2025_Back-end/controllers"
 	"github.com/ductruonghoc/DATN_08_2025_Back-end/middlewares"
 	"github.com/ductruonghoc/DATN_08_2025_Back-end/models"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/retrieval"
 	"github.com/gin-gonic/gin"
 )
 
-// Register conversation-related routes
-func ConversationRoutes(r *gin.Engine) {
+// Register conversation-related routes. The RAG queries are answered from answerCache when it
+// holds the answer, it may be nil.
+func ConversationRoutes(r *gin.Engine, answerCache *retrieval.AnswerCache) {
+    // Conversations, pairs and notes are only reachable by their owner;
+    // conversation:read_any lets support staff read (not change) them.
+    readAny := models.PermissionConversationReadAny
+    ownConversation := middlewares.RequireOwner(middlewares.ConversationResource, middlewares.OptionalIDFromJSON("conversation_id"), "")
     routeGroup := r.Group("/conversation")
     {
-        routeGroup.POST("/rag_query",  middlewares.Authorization(nil), controllers.RagQueryHandler())
-        routeGroup.POST("/storing", middlewares.Authorization([]string{models.UserPermission, models.AdminPermission}), controllers.ConversationStoringHandler())
-        routeGroup.GET("/:id", middlewares.Authorization([]string{models.UserPermission, models.AdminPermission}), controllers.GetConversationInfoHandler())
-        routeGroup.GET("/list", middlewares.Authorization([]string{models.UserPermission, models.AdminPermission}), controllers.ListConversationsHandler())
-        routeGroup.POST("/note/take", middlewares.Authorization([]string{models.UserPermission, models.AdminPermission}), controllers.TakeNoteHandler())
-        routeGroup.POST("/note/list", middlewares.Authorization([]string{models.UserPermission, models.AdminPermission}), controllers.NoteListHandler())
-        routeGroup.POST("/note/delete", middlewares.Authorization([]string{models.UserPermission, models.AdminPermission}), controllers.DeleteNoteHandler())
-        routeGroup.POST("/delete", middlewares.Authorization([]string{models.UserPermission, models.AdminPermission}), controllers.DeleteConversationHandler())
+        routeGroup.POST("/rag_query",  middlewares.Authorization(nil), ownConversation, controllers.RagQueryHandler(answerCache))
+        routeGroup.POST("/rag_query/stream", middlewares.Authorization(nil), ownConversation, controllers.RagQueryStreamHandler(answerCache))
+        routeGroup.POST("/storing", middlewares.RequirePermission(models.PermissionConversationChat), controllers.ConversationStoringHandler())
+        routeGroup.GET("/:id", middlewares.RequirePermission(models.PermissionConversationChat), middlewares.RequireOwner(middlewares.ConversationResource, middlewares.IDFromParam("id"), readAny), controllers.GetConversationInfoHandler())
+        routeGroup.GET("/list", middlewares.RequirePermission(models.PermissionConversationChat), controllers.ListConversationsHandler())
+        routeGroup.POST("/note/take", middlewares.RequirePermission(models.PermissionConversationChat), middlewares.RequireOwner(middlewares.PairResource, middlewares.IDFromJSON("requestresponsepairid"), ""), controllers.TakeNoteHandler())
+        routeGroup.POST("/note/list", middlewares.RequirePermission(models.PermissionConversationChat), middlewares.RequireOwner(middlewares.ConversationResource, middlewares.IDFromJSON("conversation_id"), readAny), controllers.NoteListHandler())
+        routeGroup.POST("/note/delete", middlewares.RequirePermission(models.PermissionConversationChat), middlewares.RequireOwner(middlewares.NoteResource, middlewares.IDFromJSON("id"), ""), controllers.DeleteNoteHandler())
+        routeGroup.POST("/feedback", middlewares.RequirePermission(models.PermissionConversationChat), middlewares.RequireOwner(middlewares.PairResource, middlewares.IDFromJSON("request_response_pair_id"), ""), controllers.RateAnswerHandler())
+        routeGroup.POST("/feedback/delete", middlewares.RequirePermission(models.PermissionConversationChat), middlewares.RequireOwner(middlewares.PairResource, middlewares.IDFromJSON("request_response_pair_id"), ""), controllers.DeleteFeedbackHandler())
+        routeGroup.POST("/delete", middlewares.RequirePermission(models.PermissionConversationChat), middlewares.RequireOwner(middlewares.ConversationResource, middlewares.IDFromJSON("conversation_id"), ""), controllers.DeleteConversationHandler())
         // Add more conversation routes here as needed
     }
 }
\ No newline at end of file
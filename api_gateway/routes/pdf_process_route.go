@@ -13,24 +13,35 @@ import (
 func PDFProcessRoutes(r *gin.Engine, db *sql.DB) {
 	routeGroup := r.Group("/pdf_process")
 	{
-		routeGroup.GET("/new_device", controllers.NewDevice(db))
+		routeGroup.GET("/new_device", middlewares.RequirePermission(models.PermissionDeviceCreate), controllers.NewDevice(db))
 		routeGroup.GET("/get_brands_and_device_types", controllers.DeviceTypeAndBrandReceive(db))
-		routeGroup.GET("/pdf_upload", controllers.PDFUpload())
-		routeGroup.GET("/extract_pdf", controllers.ExtractPDFHandler())
-		routeGroup.POST("/save_and_embed_paragraph", controllers.SaveAndEmbedParagraphHandler())
-		routeGroup.POST("/save_and_embed_img_alt", controllers.SaveAndEmbedImgAltHandler())
+		routeGroup.GET("/pdf_upload", middlewares.RequirePermission(models.PermissionPDFUpload), controllers.PDFUpload())
+		routeGroup.POST("/pdfs", middlewares.RequirePermission(models.PermissionPDFUpload), controllers.UploadPDFHandler(db))
+		routeGroup.POST("/pdfs/:id/confirm", middlewares.RequirePermission(models.PermissionPDFUpload), controllers.ConfirmPDFUploadHandler(db))
+		routeGroup.POST("/images/:id/confirm", middlewares.RequirePermission(models.PermissionPDFEdit), controllers.ConfirmImageUploadHandler(db))
+		routeGroup.GET("/extract_pdf", middlewares.RequirePermission(models.PermissionPDFExtract), controllers.ExtractPDFHandler(db))
+		routeGroup.POST("/jobs", middlewares.RequirePermission(models.PermissionPDFExtract), controllers.CreateExtractionJobHandler(db))
+		routeGroup.GET("/jobs/:id", middlewares.RequirePermission(models.PermissionPDFExtract), controllers.GetExtractionJobHandler(db))
+		routeGroup.POST("/save_and_embed_paragraph", middlewares.RequirePermission(models.PermissionPDFEdit), controllers.SaveAndEmbedParagraphHandler())
+		routeGroup.POST("/save_and_embed_img_alt", middlewares.RequirePermission(models.PermissionPDFEdit), controllers.SaveAndEmbedImgAltHandler())
 		routeGroup.GET("/get_pdf_initial_state", controllers.GetPDFInitialStateHandler())
 		routeGroup.GET("/get_pdf_state", controllers.GetPDFStateHandler())
 		routeGroup.GET("/get_img_signed_url", controllers.GetImgSignedURLHandler())
-		routeGroup.POST("/delete_chunk", controllers.DeleteChunkHandler())
-		routeGroup.POST("/create_new_image", controllers.CreateNewImageHandler())
+		routeGroup.POST("/delete_chunk", middlewares.RequirePermission(models.PermissionPDFEdit), controllers.DeleteChunkHandler())
+		routeGroup.POST("/create_new_image", middlewares.RequirePermission(models.PermissionPDFEdit), controllers.CreateNewImageHandler())
 		routeGroup.GET("/devices", controllers.ListDevicesHandler(db))
-		routeGroup.GET("/agent_is_extracting_status", controllers.GetAgentIsExtractingStatusHandler())
+		routeGroup.GET("/agent_is_extracting_status", controllers.GetAgentIsExtractingStatusHandler(db))
 		routeGroup.GET("/devices_for_chat", controllers.ListDeviceForChatHandler(db))
 		routeGroup.GET("/list_pdfs_states", controllers.ListPDFsStatesHandler(db))
 		routeGroup.GET("/pdf_pages_embedding_status", controllers.PDFPagesEmbeddedStatusesHandler(db))
-		routeGroup.POST("/add_brand", middlewares.Authorization([]string{models.AdminPermission}), controllers.AddBrandHandler(db))
-		routeGroup.POST("/add_category", middlewares.Authorization([]string{models.AdminPermission}), controllers.AddCategoryHandler(db))
+		routeGroup.POST("/add_brand", middlewares.RequirePermission(models.PermissionDeviceCreate), controllers.AddBrandHandler(db))
+		routeGroup.POST("/add_category", middlewares.RequirePermission(models.PermissionDeviceCreate), controllers.AddCategoryHandler(db))
+		routeGroup.GET("/paragraphs/:id/revisions", middlewares.RequirePermission(models.PermissionPDFEdit), controllers.ListRevisionsHandler(db, models.ParagraphRevisions))
+		routeGroup.GET("/paragraphs/:id/revisions/diff", middlewares.RequirePermission(models.PermissionPDFEdit), controllers.DiffRevisionsHandler(db, models.ParagraphRevisions))
+		routeGroup.POST("/paragraphs/:id/revisions/:revision_id/restore", middlewares.RequirePermission(models.PermissionPDFEdit), controllers.RestoreRevisionHandler(db, models.ParagraphRevisions))
+		routeGroup.GET("/images/:id/alt_revisions", middlewares.RequirePermission(models.PermissionPDFEdit), controllers.ListRevisionsHandler(db, models.ImageAltRevisions))
+		routeGroup.GET("/images/:id/alt_revisions/diff", middlewares.RequirePermission(models.PermissionPDFEdit), controllers.DiffRevisionsHandler(db, models.ImageAltRevisions))
+		routeGroup.POST("/images/:id/alt_revisions/:revision_id/restore", middlewares.RequirePermission(models.PermissionPDFEdit), controllers.RestoreRevisionHandler(db, models.ImageAltRevisions))
 	
 	}
 }
@@ -3,12 +3,18 @@ package routes
 import (
 	"database/sql"
 
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/retrieval"
 	"github.com/gin-gonic/gin"
 );
 
-func RegisterRoutes(r *gin.Engine, db *sql.DB) {
+// answerCache is nil when ANSWER_CACHE_TTL disables the cache.
+func RegisterRoutes(r *gin.Engine, db *sql.DB, answerCache *retrieval.AnswerCache) {
 	// Add route groups here
 	UserRoutes(r, db);
 	PDFProcessRoutes(r, db);
-	ConversationRoutes(r);
+	ConversationRoutes(r, answerCache);
+	AdminRoutes(r, db);
+	AccountRoutes(r, db);
+	StorageRoutes(r);
+	SearchRoutes(r, db, retrieval.AgentEmbedder{});
 };
@@ -0,0 +1,20 @@
+// For sub route groups
+package routes
+
+import (
+	"database/sql"
+
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/controllers"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/middlewares"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/models"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/retrieval"
+	"github.com/gin-gonic/gin"
+)
+
+// SearchRoutes serves the gateway side retrieval, embedding the queries with embedder.
+func SearchRoutes(r *gin.Engine, db *sql.DB, embedder retrieval.Embedder) {
+	routeGroup := r.Group("/search")
+	{
+		routeGroup.GET("/chunks", middlewares.RequirePermission(models.PermissionConversationChat), controllers.SearchChunksHandler(db, embedder))
+	}
+}
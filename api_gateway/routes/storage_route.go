@@ -0,0 +1,14 @@
+// For sub route groups
+package routes
+
+import (
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/controllers"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/internal"
+	"github.com/gin-gonic/gin"
+)
+
+// StorageRoutes serves the signed URLs of the local blob store. The signature is the authorization.
+func StorageRoutes(r *gin.Engine) {
+	r.GET(internal.LocalBlobPath, controllers.DownloadLocalBlobHandler())
+	r.PUT(internal.LocalBlobPath, controllers.UploadLocalBlobHandler())
+}
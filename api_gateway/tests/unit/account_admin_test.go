@@ -0,0 +1,130 @@
+package _test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/middlewares"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/models"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/routes"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newAdminAccountsTest serves the admin routes on a mocked database, authenticated as account 1
+// with the account management permissions.
+func newAdminAccountsTest(t *testing.T) (*gin.Engine, sqlmock.Sqlmock, string) {
+	t.Setenv("JWT_KEY", "test-key")
+	gin.SetMode(gin.TestMode)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	previous := models.DB
+	models.DB = db
+	t.Cleanup(func() { models.DB = previous })
+
+	r := gin.New()
+	routes.AdminRoutes(r, db)
+	expectAccount(mock, 1, models.PermissionAdminAccess, models.PermissionAccountManage)
+	return r, mock, userToken(t, 1)
+}
+
+var adminAccountColumns = []string{
+	"id", "username", "display_name", "role_id", "label", "has_password", "google_linked",
+	"password_reset_required", "disabled_at", "disabled_reason", "deleted_at", "conversations", "last_activity",
+}
+
+func TestListAccounts(t *testing.T) {
+	r, mock, token := newAdminAccountsTest(t)
+
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM account a WHERE \(a.username ILIKE \$1 OR a.display_name ILIKE \$1\) AND a.deleted_at IS NULL AND a.disabled_at IS NOT NULL`).
+		WithArgs(`%50\%%`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(12))
+	mock.ExpectQuery(`LIMIT \$2 OFFSET \$3`).WithArgs(`%50\%%`, 5, 10).
+		WillReturnRows(sqlmock.NewRows(adminAccountColumns).
+			AddRow(9, "user@example.com", "User", 2, "user", true, false, false, time.Now(), "spam", nil, 3, time.Now()))
+
+	w := serve(r, "GET", "/admin/accounts?q=50%25&status=disabled&page=3&page_size=5", token, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"total":12`)
+	assert.Contains(t, w.Body.String(), `"disabled_reason":"spam"`)
+	assert.Contains(t, w.Body.String(), `"conversation_count":3`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListAccountsValidatesPaging(t *testing.T) {
+	r, _, token := newAdminAccountsTest(t)
+
+	w := serve(r, "GET", "/admin/accounts?page_size=1000", token, "")
+	assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+}
+
+func TestDisableAccount(t *testing.T) {
+	r, mock, token := newAdminAccountsTest(t)
+
+	// The change and its audit event are written together
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT disabled_at IS NOT NULL`).WithArgs(9).
+		WillReturnRows(sqlmock.NewRows([]string{"disabled", "reason"}).AddRow(false, ""))
+	mock.ExpectExec(`UPDATE account SET disabled_at`).WithArgs(9, "spam").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO audit_event`).
+		WithArgs(1, models.AuditAccountDisable, "account", "9",
+			`{"disabled":false,"reason":""}`, `{"disabled":true,"reason":"spam"}`, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO revoked_access_token`).WithArgs(9).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE refresh_token SET revoked_at`).WithArgs(9).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE account SET token_version`).WithArgs(9).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	w := serve(r, "POST", "/admin/accounts/9/disable", token, `{"reason": "spam"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAdminsCantDisableThemselves(t *testing.T) {
+	r, mock, token := newAdminAccountsTest(t)
+
+	w := serve(r, "POST", "/admin/accounts/1/disable", token, "")
+	assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateAccountRoleRefusesAdminRole(t *testing.T) {
+	r, mock, token := newAdminAccountsTest(t)
+
+	mock.ExpectQuery(`FROM role r WHERE r.id`).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "label", "permissions", "accounts"}).AddRow(1, "admin", "{}", 1))
+	mock.ExpectQuery(`WHERE a.id = \$1`).WithArgs(9).
+		WillReturnRows(sqlmock.NewRows(adminAccountColumns).
+			AddRow(9, "user@example.com", "", 2, "user", true, false, false, nil, "", nil, 0, nil))
+	mock.ExpectQuery(`SELECT\s+\(SELECT COUNT`).WithArgs(9).
+		WillReturnRows(sqlmock.NewRows([]string{"questions", "notes", "sessions"}).AddRow(0, 0, 0))
+	mock.ExpectQuery(`FROM external_identity`).WithArgs(9).WillReturnRows(sqlmock.NewRows([]string{"provider"}))
+
+	w := serve(r, "PUT", "/admin/accounts/9/role", token, `{"role_id": 1}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDisabledAccountTokensAreRefused(t *testing.T) {
+	r, mock := newRouteTest(t)
+	middlewares.InvalidateAllAccountRoles()
+	middlewares.InvalidateRevokedTokens()
+
+	mock.ExpectQuery(`SELECT jti FROM revoked_access_token`).WillReturnRows(sqlmock.NewRows([]string{"jti"}))
+	mock.ExpectQuery(`SELECT r.label, a.token_version`).WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"label", "token_version", "disabled"}).AddRow("user", 0, true))
+	mock.ExpectQuery(`JOIN role_permission`).WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "description", "admin_only"}).
+			AddRow(1, models.PermissionConversationChat, "", false))
+
+	w := serve(r, "GET", "/conversation/list", userToken(t, 7), "")
+	assert.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), "Account is disabled")
+}
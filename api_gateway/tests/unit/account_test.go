@@ -0,0 +1,209 @@
+package _test
+
+import (
+	"archive/zip"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/internal"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/middlewares"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/models"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/routes"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// newAccountTest serves the account routes on a mocked database, authenticated as account 7.
+func newAccountTest(t *testing.T) (*gin.Engine, sqlmock.Sqlmock, string) {
+	t.Setenv("JWT_KEY", "test-key")
+	gin.SetMode(gin.TestMode)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	previous := models.DB
+	models.DB = db
+	t.Cleanup(func() { models.DB = previous })
+
+	r := gin.New()
+	routes.AccountRoutes(r, db)
+	expectAccount(mock, 7)
+	return r, mock, userToken(t, 7)
+}
+
+func TestChangePasswordRefusesWrongPassword(t *testing.T) {
+	r, mock, token := newAccountTest(t)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("right-password"), bcrypt.MinCost)
+	require.NoError(t, err)
+	mock.ExpectQuery(`FROM auth_failure`).WithArgs("password:7").WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(`SELECT password FROM "user"`).WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"password"}).AddRow(string(hash)))
+	mock.ExpectQuery(`INSERT INTO auth_failure`).WithArgs("password:7", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"failures"}).AddRow(1))
+
+	w := serve(r, "POST", "/account/password", token, `{"current_password": "wrong", "new_password": "new-password"}`)
+	assert.Equal(t, http.StatusUnauthorized, w.Code, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestChangePasswordKeepsAdminAudience(t *testing.T) {
+	t.Setenv("JWT_KEY", "test-key")
+	gin.SetMode(gin.TestMode)
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	previous := models.DB
+	models.DB = db
+	defer func() { models.DB = previous }()
+	r := gin.New()
+	routes.AccountRoutes(r, db)
+
+	admin, err := internal.JWTGenerator(internal.TokenSubject{AccountID: 5, Role: "admin", Audience: internal.AudienceAdmin}, internal.NewTokenID())
+	require.NoError(t, err)
+	hash, err := bcrypt.GenerateFromPassword([]byte("right-password"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	middlewares.InvalidateAllAccountRoles()
+	middlewares.InvalidateRevokedTokens()
+	mock.ExpectQuery(`SELECT jti FROM revoked_access_token`).WillReturnRows(sqlmock.NewRows([]string{"jti"}))
+	mock.ExpectQuery(`SELECT r.label, a.token_version`).WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"label", "token_version", "disabled"}).AddRow("admin", 0, false))
+	mock.ExpectQuery(`JOIN role_permission`).WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "description", "admin_only"}))
+	mock.ExpectQuery(`FROM auth_failure`).WithArgs("password:5").WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(`SELECT password FROM "user"`).WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"password"}).AddRow(string(hash)))
+	mock.ExpectExec(`DELETE FROM auth_failure`).WithArgs("password:5").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`UPDATE "user" SET password`).WithArgs(5, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO revoked_access_token`).WithArgs(5).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE refresh_token SET revoked_at`).WithArgs(5).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE account SET token_version`).WithArgs(5).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	// The new session is still an admin one
+	mock.ExpectExec(`INSERT INTO refresh_token`).
+		WithArgs(5, sqlmock.AnyArg(), sqlmock.AnyArg(), internal.AudienceAdmin, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT r.label, a.token_version`).WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"label", "token_version", "disabled"}).AddRow("admin", 1, false))
+
+	w := serve(r, "POST", "/account/password", admin, `{"current_password": "right-password", "new_password": "new-password"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var body struct {
+		Data struct {
+			Token string `json:"token"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	claims, err := internal.JWTParse(body.Data.Token)
+	require.NoError(t, err)
+	assert.True(t, claims.VerifyAudience(internal.AudienceAdmin, true))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestConfirmEmailChange(t *testing.T) {
+	r, mock, token := newAccountTest(t)
+
+	otp, err := bcrypt.GenerateFromPassword([]byte("123456"), bcrypt.MinCost)
+	require.NoError(t, err)
+	mock.ExpectQuery(`FROM email_change`).WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"new_email", "otp", "otp_generated_time", "otp_attempts"}).
+			AddRow("new@example.com", string(otp), time.Now(), 0))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`DELETE FROM email_change`).WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"new_email"}).AddRow("new@example.com"))
+	mock.ExpectQuery(`SELECT EXISTS`).WithArgs(7, "new@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery(`SELECT username FROM account`).WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow("old@example.com"))
+	mock.ExpectExec(`UPDATE account SET username`).WithArgs(7, "new@example.com").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE "user" SET email`).WithArgs(7, "new@example.com").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	// The old address is told about the change
+	mock.ExpectQuery(`INSERT INTO email_outbox`).
+		WithArgs(internal.EmailKindEmailChanged, "old@example.com", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+
+	w := serve(r, "POST", "/account/email/confirm", token, `{"otp_code": "123456"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"new@example.com"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestConfirmEmailChangeRefusesTooManyAttempts(t *testing.T) {
+	r, mock, token := newAccountTest(t)
+
+	mock.ExpectQuery(`FROM email_change`).WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"new_email", "otp", "otp_generated_time", "otp_attempts"}).
+			AddRow("new@example.com", "hash", time.Now(), 5))
+
+	w := serve(r, "POST", "/account/email/confirm", token, `{"otp_code": "123456"}`)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteAccountRequiresConfirmation(t *testing.T) {
+	r, mock, token := newAccountTest(t)
+
+	w := serve(r, "DELETE", "/account", token, `{"password": "secret"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestExportAccountZIP(t *testing.T) {
+	r, mock, token := newAccountTest(t)
+
+	mock.ExpectQuery(`SELECT a.username, a.display_name`).WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"username", "display_name", "label", "has_password", "google_linked"}).
+			AddRow("user@example.com", "User", "user", true, false))
+	mock.ExpectQuery(`FROM external_identity`).WithArgs(7).WillReturnRows(sqlmock.NewRows([]string{"provider"}))
+	mock.ExpectQuery(`FROM conversation WHERE account_id`).WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "account_id", "title", "created_time", "updated_time"}).
+			AddRow("c1", 7, "Printer", time.Now(), time.Now()))
+	mock.ExpectQuery(`FROM device_conversation`).WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"conversation_id", "device_id"}).AddRow("c1", 3))
+	mock.ExpectQuery(`FROM request_response_pair`).WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "request", "response", "conversation_id", "created_time", "title"}).
+			AddRow(1, "How do I reset it?", "Hold the button.", "c1", time.Now(), "Reset"))
+
+	w := serve(r, "GET", "/account/export?format=zip", token, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "application/zip", w.Header().Get("Content-Type"))
+
+	archive, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	require.NoError(t, err)
+	files := map[string][]byte{}
+	for _, f := range archive.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		files[f.Name], err = io.ReadAll(rc)
+		require.NoError(t, err)
+		rc.Close()
+	}
+	require.Contains(t, files, "account.json")
+	require.Contains(t, files, "conversations.json")
+
+	var conversations []struct {
+		ID        string `json:"id"`
+		DeviceIDs []int  `json:"device_ids"`
+		Pairs     []struct {
+			Request   string  `json:"request"`
+			NoteTitle *string `json:"note_title"`
+		} `json:"request_response_pairs"`
+	}
+	require.NoError(t, json.Unmarshal(files["conversations.json"], &conversations))
+	require.Len(t, conversations, 1)
+	assert.Equal(t, []int{3}, conversations[0].DeviceIDs)
+	require.Len(t, conversations[0].Pairs, 1)
+	assert.Equal(t, "Reset", *conversations[0].Pairs[0].NoteTitle)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,104 @@
+package _test
+
+import (
+	"database/sql"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/models"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var aiAgentColumns = []string{"id", "name", "ip", "ocr_in_used", "draining", "ocr_locked_until"}
+
+func TestAddAIAgent(t *testing.T) {
+	r, mock := newAuditTest(t)
+	expectAccount(mock, 1, models.PermissionAdminAccess, models.PermissionAgentManage)
+	mock.ExpectQuery(`INSERT INTO ai_agent_resources`).WithArgs("ocr-2", "10.0.0.2:50051").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+
+	w := serve(r, "POST", "/admin/agents", userToken(t, 1), `{"name": " ocr-2 ", "ip": "10.0.0.2:50051"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"agent_id":2`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAddAIAgentRefusesUsedName(t *testing.T) {
+	r, mock := newAuditTest(t)
+	expectAccount(mock, 1, models.PermissionAdminAccess, models.PermissionAgentManage)
+	mock.ExpectQuery(`INSERT INTO ai_agent_resources`).WithArgs("ocr-1", "10.0.0.2:50051").
+		WillReturnError(&pq.Error{Code: "23505", Constraint: "ai_agent_resources_unique_name"})
+
+	w := serve(r, "POST", "/admin/agents", userToken(t, 1), `{"name": "ocr-1", "ip": "10.0.0.2:50051"}`)
+	assert.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClaimFreeAIAgent(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`WHERE draining = false\s+AND \(ocr_in_used = false OR ocr_locked_until < NOW\(\)\)\s+ORDER BY id\s+FOR UPDATE SKIP LOCKED`).
+		WillReturnRows(sqlmock.NewRows(aiAgentColumns).AddRow(2, "ocr-2", "10.0.0.2:50051", false, false, nil))
+	mock.ExpectExec(`SET ocr_in_used = true, ocr_locked_until = NOW\(\) \+ \$1 \* INTERVAL '1 second'`).
+		WithArgs(600, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	agent, err := models.ClaimFreeAIAgent(db, 10*time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, 2, agent.ID)
+	assert.True(t, agent.OCRInUsed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClaimFreeAIAgentWhenAllBusy(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FOR UPDATE SKIP LOCKED`).WillReturnRows(sqlmock.NewRows(aiAgentColumns))
+	mock.ExpectRollback()
+
+	_, err = models.ClaimFreeAIAgent(db, 10*time.Minute)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrationFreesLegacyAgents(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	entries, err := os.ReadDir("../../models/migrations")
+	require.NoError(t, err)
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS schema_migration`).WillReturnResult(sqlmock.NewResult(0, 0))
+	for _, entry := range entries {
+		version := entry.Name()
+		mock.ExpectBegin()
+		mock.ExpectExec(`SELECT pg_advisory_xact_lock`).WillReturnResult(sqlmock.NewResult(0, 0))
+		pending := strings.HasSuffix(version, "_ai_agent_resources_scheduler.sql")
+		mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM schema_migration`).WithArgs(version).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(!pending))
+		if !pending {
+			mock.ExpectRollback()
+			continue
+		}
+		// Agents in use without a lock were claimed before the lease existed
+		mock.ExpectExec(`ADD COLUMN IF NOT EXISTS ocr_locked_until[\s\S]+UPDATE public.ai_agent_resources\s+SET ocr_in_used = false\s+WHERE ocr_in_used AND ocr_locked_until IS NULL`).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`INSERT INTO schema_migration`).WithArgs(version).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+	}
+
+	require.NoError(t, models.RunSchemaMigrations(db))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
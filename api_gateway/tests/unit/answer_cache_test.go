@@ -0,0 +1,196 @@
+package _test
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/models"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/retrieval"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/routes"
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var cachedAnswerColumns = []string{"id", "device_id", "normalized_query", "response", "images_ids", "chunk_ids", "expires_at"}
+
+// embedderFunc embeds with a function, failing the test when nil.
+type embedderFunc func(text string) ([]float32, error)
+
+func (f embedderFunc) Embed(text string) ([]float32, error) {
+	if f == nil {
+		return nil, errors.New("unexpected embedding")
+	}
+	return f(text)
+}
+
+// expectGeneration expects the read of the answer cache generation of device 2 by Lookup.
+func expectGeneration(mock sqlmock.Sqlmock, generation int64) {
+	mock.ExpectQuery(`SELECT answer_cache_generation FROM device WHERE id = \$1$`).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"answer_cache_generation"}).AddRow(generation))
+}
+
+func newAnswerCache(t *testing.T, embed embedderFunc) (*retrieval.AnswerCache, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return &retrieval.AnswerCache{DB: db, Embedder: embed, TTL: time.Hour, MinSimilarity: 0.95}, mock
+}
+
+func TestNormalizeQuery(t *testing.T) {
+	assert.Equal(t, "how to reset the filter light", retrieval.NormalizeQuery("  How to reset\tthe Filter light?? "))
+	assert.Equal(t, "lỗi e-04 là gì", retrieval.NormalizeQuery("Lỗi E-04 là gì?"))
+}
+
+func TestAnswerCacheExactHit(t *testing.T) {
+	cache, mock := newAnswerCache(t, nil)
+
+	expectGeneration(mock, 3)
+	mock.ExpectQuery(`FROM answer_cache\s+WHERE device_id = \$1 AND expires_at > NOW\(\) AND normalized_query = \$2`).
+		WithArgs(2, "how to reset the filter light").
+		WillReturnRows(sqlmock.NewRows(cachedAnswerColumns).
+			AddRow(7, 2, "how to reset the filter light", "Hold the filter button.", "{31}", "{11,12}", time.Now().Add(time.Hour)))
+	mock.ExpectExec(`UPDATE answer_cache SET hits = hits \+ 1`).WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	answer, hit, err := cache.Lookup(2, "How to reset the filter light?")
+	require.NoError(t, err)
+	assert.True(t, hit)
+	assert.Equal(t, "Hold the filter button.", answer.Response)
+	assert.Equal(t, []int32{31}, answer.ImagesIDs)
+	assert.Equal(t, []int32{11, 12}, answer.ChunkIDs)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAnswerCacheSimilarHit(t *testing.T) {
+	cache, mock := newAnswerCache(t, func(text string) ([]float32, error) {
+		assert.Equal(t, "how do i reset the filter light", text)
+		return []float32{0.1, 0.2}, nil
+	})
+
+	expectGeneration(mock, 3)
+	mock.ExpectQuery(`normalized_query = \$2`).WillReturnRows(sqlmock.NewRows(cachedAnswerColumns))
+	mock.ExpectQuery(`query_embedding <=> \$2 <= \$3\s+ORDER BY query_embedding <=> \$2, id\s+LIMIT 1`).
+		WithArgs(2, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(cachedAnswerColumns).
+			AddRow(7, 2, "how to reset the filter light", "Hold the filter button.", "{}", "{11}", time.Now().Add(time.Hour)))
+	mock.ExpectExec(`UPDATE answer_cache SET hits = hits \+ 1`).WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	answer, hit, err := cache.Lookup(2, "How do I reset the filter light?")
+	require.NoError(t, err)
+	assert.True(t, hit)
+	assert.Equal(t, "Hold the filter button.", answer.Response)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAnswerCacheMissIsStored(t *testing.T) {
+	cache, mock := newAnswerCache(t, func(text string) ([]float32, error) { return []float32{0.1, 0.2}, nil })
+
+	expectGeneration(mock, 3)
+	mock.ExpectQuery(`normalized_query = \$2`).WillReturnRows(sqlmock.NewRows(cachedAnswerColumns))
+	mock.ExpectQuery(`query_embedding <=> \$2`).WillReturnError(sql.ErrNoRows)
+
+	answer, hit, err := cache.Lookup(2, "What does E-04 mean?")
+	require.NoError(t, err)
+	assert.False(t, hit)
+	assert.Equal(t, []float32{0.1, 0.2}, answer.Embedding)
+	assert.Equal(t, int64(3), answer.Generation)
+
+	answer.Response = "The drain is blocked."
+	answer.ChunkIDs = []int32{11}
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT answer_cache_generation FROM device WHERE id = \$1 FOR SHARE`).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"answer_cache_generation"}).AddRow(3))
+	mock.ExpectExec(`INSERT INTO answer_cache`).
+		WithArgs(2, "what does e-04 mean", sqlmock.AnyArg(), "The drain is blocked.", pq.Int32Array(nil), pq.Int32Array{11}, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	require.NoError(t, cache.Store(answer))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAnswerCacheLookupNeedsTheGeneration(t *testing.T) {
+	cache, mock := newAnswerCache(t, nil)
+	mock.ExpectQuery(`SELECT answer_cache_generation FROM device`).WithArgs(2).WillReturnError(sql.ErrNoRows)
+
+	_, hit, err := cache.Lookup(2, "What does E-04 mean?")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	assert.False(t, hit)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAnswerCacheSkipsInvalidatedAnswer(t *testing.T) {
+	cache, mock := newAnswerCache(t, nil)
+
+	// A chunk of the device changed while the answer was generated
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT answer_cache_generation FROM device WHERE id = \$1 FOR SHARE`).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"answer_cache_generation"}).AddRow(4))
+	mock.ExpectRollback()
+
+	answer := models.CachedAnswer{DeviceID: 2, NormalizedQuery: "what does e-04 mean", Response: "The drain is blocked.", Generation: 3}
+	require.NoError(t, cache.Store(answer))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRagQueryFromAnswerCache(t *testing.T) {
+	t.Setenv("JWT_KEY", "test-key")
+	gin.SetMode(gin.TestMode)
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	previous := models.DB
+	models.DB = db
+	t.Cleanup(func() { models.DB = previous })
+
+	r := gin.New()
+	// The agent is never called: pb isn't initialized
+	routes.ConversationRoutes(r, &retrieval.AnswerCache{DB: db, TTL: time.Hour})
+	expectAccount(mock, 5, models.PermissionConversationChat)
+
+	mock.ExpectQuery(`SELECT answer_cache_generation FROM device`).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"answer_cache_generation"}).AddRow(0))
+	mock.ExpectQuery(`normalized_query = \$2`).WithArgs(2, "how to reset the filter light").
+		WillReturnRows(sqlmock.NewRows(cachedAnswerColumns).
+			AddRow(7, 2, "how to reset the filter light", "Hold the filter button.", "{31}", "{11}", time.Now().Add(time.Hour)))
+	mock.ExpectExec(`UPDATE answer_cache SET hits = hits \+ 1`).WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT DISTINCT ON \(c.id\) c.id, p.id`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "pdf_id", "filename", "page_number", "gcs_bucket"}).AddRow(11, 4, "manual.pdf", 9, ""))
+
+	w := serve(r, "POST", "/conversation/rag_query", userToken(t, 5), `{"query": "How to reset the filter light?", "device_id": 2}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"cached":true`)
+	assert.Contains(t, w.Body.String(), `"response":"Hold the filter button."`)
+	assert.Contains(t, w.Body.String(), `"images_ids":[31]`)
+	assert.Contains(t, w.Body.String(), `"page_number":9`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPurgeAnswerCache(t *testing.T) {
+	r, mock := newAuditTest(t)
+	expectAccount(mock, 1, models.PermissionAdminAccess, models.PermissionAnswerCachePurge)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM answer_cache WHERE \$1 = 0 OR device_id = \$1`).WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec(`INSERT INTO audit_event`).
+		WithArgs(1, models.AuditAnswerCachePurge, "answer_cache", "2", nil, `{"purged":3}`, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	w := serve(r, "DELETE", "/admin/answer_cache?device_id=2", userToken(t, 1), "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"purged":3`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPurgeAnswerCacheRequiresPermission(t *testing.T) {
+	r, mock := newAuditTest(t)
+	expectAccount(mock, 1, models.PermissionAdminAccess)
+
+	w := serve(r, "DELETE", "/admin/answer_cache", userToken(t, 1), "")
+	assert.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+}
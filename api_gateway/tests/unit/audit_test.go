@@ -0,0 +1,105 @@
+package _test
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/middlewares"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/models"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/routes"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newAuditTest serves the admin and pdf_process routes behind the request id middleware, on a
+// mocked database.
+func newAuditTest(t *testing.T) (*gin.Engine, sqlmock.Sqlmock) {
+	t.Setenv("JWT_KEY", "test-key")
+	gin.SetMode(gin.TestMode)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	previous := models.DB
+	models.DB = db
+	t.Cleanup(func() { models.DB = previous })
+
+	r := gin.New()
+	r.Use(middlewares.RequestID())
+	routes.AdminRoutes(r, db)
+	routes.PDFProcessRoutes(r, db)
+	return r, mock
+}
+
+func TestListAuditEvents(t *testing.T) {
+	r, mock := newAuditTest(t)
+	expectAccount(mock, 1, models.PermissionAdminAccess, models.PermissionAuditRead)
+
+	from := time.Date(2025, 8, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM audit_event WHERE actor_account_id = \$1 AND action = \$2 AND occurred_at >= \$3`).
+		WithArgs(1, models.AuditChunkDelete, from).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(`ORDER BY occurred_at DESC, id DESC LIMIT \$4 OFFSET \$5`).
+		WithArgs(1, models.AuditChunkDelete, from, 20, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "occurred_at", "actor_account_id", "action", "target_type", "target_id", "before", "after", "request_id"}).
+			AddRow(4, time.Now(), 1, models.AuditChunkDelete, "pdf_chunk", "12", []byte(`{"context":"Hold the button."}`), nil, "req-1"))
+
+	w := serve(r, "GET", "/admin/audit_events?actor_id=1&action=chunk.delete&from=2025-08-01T00:00:00Z", userToken(t, 1), "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"before":{"context":"Hold the button."}`)
+	assert.Contains(t, w.Body.String(), `"after":null`)
+	assert.Contains(t, w.Body.String(), `"request_id":"req-1"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListAuditEventsRequiresPermission(t *testing.T) {
+	r, mock := newAuditTest(t)
+	expectAccount(mock, 1, models.PermissionAdminAccess)
+
+	w := serve(r, "GET", "/admin/audit_events", userToken(t, 1), "")
+	assert.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+}
+
+func TestDeleteChunkIsAudited(t *testing.T) {
+	r, mock := newAuditTest(t)
+	expectAccount(mock, 5, models.PermissionPDFEdit)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE device SET answer_cache_generation = answer_cache_generation \+ 1[\s\S]+DELETE FROM answer_cache WHERE device_id IN`).WithArgs(12).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectQuery(`DELETE FROM pdf_chunk`).WithArgs(12).
+		WillReturnRows(sqlmock.NewRows([]string{"context"}).AddRow("Hold the button."))
+	mock.ExpectExec(`INSERT INTO audit_event`).
+		WithArgs(5, models.AuditChunkDelete, "pdf_chunk", "12", `{"context":"Hold the button."}`, nil, "req-1").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	req := httptest.NewRequest("POST", "/pdf_process/delete_chunk", strings.NewReader(`{"chunk_id": 12}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+userToken(t, 5))
+	req.Header.Set(middlewares.RequestIDHeader, "req-1")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "req-1", w.Header().Get(middlewares.RequestIDHeader))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteMissingChunk(t *testing.T) {
+	r, mock := newAuditTest(t)
+	expectAccount(mock, 5, models.PermissionPDFEdit)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE device SET answer_cache_generation = answer_cache_generation \+ 1[\s\S]+DELETE FROM answer_cache WHERE device_id IN`).WithArgs(12).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`DELETE FROM pdf_chunk`).WithArgs(12).WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	w := serve(r, "POST", "/pdf_process/delete_chunk", userToken(t, 5), `{"chunk_id": 12}`)
+	assert.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
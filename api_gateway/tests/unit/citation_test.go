@@ -0,0 +1,63 @@
+package _test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/internal"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectChunkCitationsKeepsAgentOrder(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery(`WHERE c.id = ANY\(\$1\)`).WithArgs(`{12,99,10}`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "pdf_id", "filename", "page_number", "gcs_bucket"}).
+			AddRow(10, 5, "manual.pdf", 3, "pdfs/manual.pdf").
+			AddRow(12, 5, "manual.pdf", 7, "pdfs/manual.pdf"))
+
+	// Chunk 99 was deleted since the answer
+	citations, err := models.SelectChunkCitations(db, []int{12, 99, 10})
+	require.NoError(t, err)
+	require.Len(t, citations, 2)
+	assert.Equal(t, 12, *citations[0].ChunkID)
+	assert.Equal(t, 7, citations[0].PageNumber)
+	assert.Equal(t, 10, *citations[1].ChunkID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestConversationInfoReturnsCitations(t *testing.T) {
+	r, mock := newRouteTest(t)
+	previous := internal.Storage
+	internal.Storage = newLocalStore(t)
+	t.Cleanup(func() { internal.Storage = previous })
+
+	expectAccount(mock, ownerID, models.PermissionConversationChat)
+	mock.ExpectQuery(`SELECT account_id FROM conversation WHERE id`).WithArgs("conv-1").
+		WillReturnRows(sqlmock.NewRows([]string{"account_id"}).AddRow(ownerID))
+	mock.ExpectQuery(`SELECT title FROM conversation`).WithArgs("conv-1").
+		WillReturnRows(sqlmock.NewRows([]string{"title"}).AddRow("Printer"))
+	mock.ExpectQuery(`FROM device_conversation`).WithArgs("conv-1").
+		WillReturnRows(sqlmock.NewRows([]string{"device_id"}).AddRow(4))
+	mock.ExpectQuery(`FROM request_response_pair_pdf_chunk`).WithArgs("conv-1").
+		WillReturnRows(sqlmock.NewRows([]string{"pair_id", "chunk_id", "pdf_id", "filename", "page_number", "gcs_bucket"}).
+			AddRow(8, nil, 5, "manual.pdf", 7, "pdfs/manual.pdf"))
+	mock.ExpectQuery(`FROM request_response_pair WHERE conversation_id`).WithArgs("conv-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "request", "response", "created_time"}).
+			AddRow(8, "How do I fix E-04?", "Clean the drain.", time.Now()).
+			AddRow(9, "Thanks", "You're welcome.", time.Now()))
+	mock.ExpectQuery(`FROM request_response_pair_pdf_image`).WithArgs(8).WillReturnRows(sqlmock.NewRows([]string{"pdf_image_id"}))
+	mock.ExpectQuery(`FROM request_response_pair_pdf_image`).WithArgs(9).WillReturnRows(sqlmock.NewRows([]string{"pdf_image_id"}))
+
+	w := serve(r, "GET", "/conversation/conv-1", userToken(t, ownerID), "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Regexp(t, `"citations":\[\{"chunk_id":null,"pdf_id":5,"filename":"manual.pdf","page_number":7,"url":"http://gateway.test[^"]*#page=7"\}\]`, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"citations":[]`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
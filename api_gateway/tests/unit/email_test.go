@@ -0,0 +1,255 @@
+package _test
+
+import (
+	"bufio"
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/internal"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/workers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderOTPEmail(t *testing.T) {
+	data := internal.OTPEmailData{Code: "012345", ValidHours: 2}
+	for _, lang := range internal.EmailLanguages {
+		email, err := internal.RenderEmail(internal.EmailKindOTP, lang, "a@example.com", data)
+		require.NoError(t, err, lang)
+		assert.Equal(t, "a@example.com", email.To)
+		assert.NotEmpty(t, email.Subject)
+		assert.NotContains(t, email.Subject, "\n")
+		assert.Contains(t, email.Text, "012345")
+		assert.Contains(t, email.HTML, "012345")
+		assert.Contains(t, email.HTML, `lang="`+lang+`"`)
+	}
+
+	vi, err := internal.RenderEmail(internal.EmailKindOTP, "vi", "a@example.com", data)
+	require.NoError(t, err)
+	assert.Contains(t, vi.Text, "Mã xác thực")
+
+	// Unsupported languages get the default one
+	t.Setenv("MAIL_DEFAULT_LANG", "en")
+	fr, err := internal.RenderEmail(internal.EmailKindOTP, "fr", "a@example.com", data)
+	require.NoError(t, err)
+	assert.Contains(t, fr.Text, "verification code")
+}
+
+func TestRenderEmailEscapesHTML(t *testing.T) {
+	email, err := internal.RenderEmail(internal.EmailKindOTP, "en", "a@example.com", internal.OTPEmailData{Code: "<b>x</b>"})
+	require.NoError(t, err)
+	assert.NotContains(t, email.HTML, "<b>x</b>")
+	assert.Contains(t, email.Text, "<b>x</b>")
+}
+
+func TestEmailLanguage(t *testing.T) {
+	t.Setenv("MAIL_DEFAULT_LANG", "vi")
+	assert.Equal(t, "en", internal.EmailLanguage("en-US,en;q=0.9"))
+	assert.Equal(t, "vi", internal.EmailLanguage("vi-VN"))
+	assert.Equal(t, "en", internal.EmailLanguage("fr-FR, en;q=0.5"))
+	assert.Equal(t, "vi", internal.EmailLanguage("fr"))
+	assert.Equal(t, "vi", internal.EmailLanguage(""))
+}
+
+func TestLogMailerWritesFiles(t *testing.T) {
+	dir := t.TempDir()
+	mailer := &internal.LogMailer{Dir: dir}
+	require.NoError(t, mailer.Send(context.Background(), internal.Email{
+		To: "a@example.com", Subject: "Hi", Text: "text body", HTML: "<p>html body</p>",
+	}))
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		require.NoError(t, err)
+		if strings.HasSuffix(f, ".txt") {
+			assert.Contains(t, string(b), "Subject: Hi")
+			assert.Contains(t, string(b), "text body")
+		} else {
+			assert.Equal(t, "<p>html body</p>", string(b))
+		}
+	}
+}
+
+type fakeMailer struct {
+	sent []internal.Email
+	err  error
+	// timeouts are the time each send had left
+	timeouts []time.Duration
+}
+
+func (m *fakeMailer) Send(ctx context.Context, email internal.Email) error {
+	if deadline, ok := ctx.Deadline(); ok {
+		m.timeouts = append(m.timeouts, time.Until(deadline))
+	}
+	if m.err != nil {
+		return m.err
+	}
+	m.sent = append(m.sent, email)
+	return nil
+}
+
+var outboxColumns = []string{"id", "kind", "recipient", "subject", "text_body", "html_body", "status", "attempts", "last_error", "created_at", "sent_at"}
+
+func TestSendOutboxEmails(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery(`UPDATE email_outbox`).
+		WillReturnRows(sqlmock.NewRows(outboxColumns).
+			AddRow(1, "otp", "a@example.com", "Code", "text", "<p>html</p>", "pending", 1, nil, time.Now(), nil))
+	mock.ExpectExec(`SET status = 'sent'`).WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	mailer := &fakeMailer{}
+	assert.Equal(t, 1, workers.SendOutboxEmails(context.Background(), db, mailer))
+	require.Len(t, mailer.sent, 1)
+	assert.Equal(t, internal.Email{To: "a@example.com", Subject: "Code", Text: "text", HTML: "<p>html</p>"}, mailer.sent[0])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSendOutboxEmailsRetriesThenGivesUp(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery(`UPDATE email_outbox`).
+		WillReturnRows(sqlmock.NewRows(outboxColumns).
+			AddRow(1, "otp", "a@example.com", "Code", "text", "html", "pending", 2, nil, time.Now(), nil).
+			AddRow(2, "otp", "b@example.com", "Code", "text", "html", "pending", 6, nil, time.Now(), nil))
+	// Second attempt: retried after twice the first delay
+	mock.ExpectExec(`SET last_error = \$2, next_attempt_at`).WithArgs(1, "smtp down", 60).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`SET status = 'failed'`).WithArgs(2, "smtp down").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	mailer := &fakeMailer{err: errors.New("smtp down")}
+	assert.Equal(t, 2, workers.SendOutboxEmails(context.Background(), db, mailer))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// intArg is a sqlmock argument matcher remembering the int it was given.
+type intArg struct{ value *int64 }
+
+func (a intArg) Match(v driver.Value) bool {
+	n, ok := v.(int64)
+	*a.value = n
+	return ok
+}
+
+func TestSendOutboxEmailsHaveADeadline(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	var leaseSeconds, batchSize int64
+	rows := sqlmock.NewRows(outboxColumns)
+	for id := 1; id <= 2; id++ {
+		rows.AddRow(id, "otp", "a@example.com", "Code", "text", "html", "pending", 1, nil, time.Now(), nil)
+	}
+	mock.ExpectQuery(`UPDATE email_outbox`).WithArgs(intArg{&leaseSeconds}, intArg{&batchSize}).WillReturnRows(rows)
+	mock.ExpectExec(`SET status = 'sent'`).WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`SET status = 'sent'`).WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	mailer := &fakeMailer{}
+	workers.SendOutboxEmails(context.Background(), db, mailer)
+	require.Len(t, mailer.timeouts, 2)
+	// The emails stay claimed for longer than a batch of sends can take
+	for _, timeout := range mailer.timeouts {
+		assert.Positive(t, timeout)
+		assert.Greater(t, time.Duration(leaseSeconds)*time.Second, time.Duration(batchSize)*timeout)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSMTPMailerGivesUpOnStuckServer(t *testing.T) {
+	// The server accepts the connection and never greets
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	mailer := &internal.SMTPMailer{Host: "127.0.0.1", Port: addr.Port, From: "bot@example.com", TLS: "starttls"}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err = mailer.Send(ctx, internal.Email{To: "a@example.com", Subject: "Code", Text: "text"})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestSMTPMailerSends(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	// A plain server, without STARTTLS or AUTH
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		fmt.Fprint(conn, "220 test\r\n")
+		var data strings.Builder
+		inData := false
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch {
+			case inData && line == ".\r\n":
+				inData = false
+				received <- data.String()
+				fmt.Fprint(conn, "250 queued\r\n")
+			case inData:
+				data.WriteString(line)
+			case strings.HasPrefix(line, "EHLO"):
+				fmt.Fprint(conn, "250 test\r\n")
+			case strings.HasPrefix(line, "DATA"):
+				inData = true
+				fmt.Fprint(conn, "354 go on\r\n")
+			case strings.HasPrefix(line, "QUIT"):
+				fmt.Fprint(conn, "221 bye\r\n")
+				return
+			default:
+				fmt.Fprint(conn, "250 ok\r\n")
+			}
+		}
+	}()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	mailer := &internal.SMTPMailer{Host: "127.0.0.1", Port: addr.Port, From: "bot@example.com", TLS: "starttls"}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, mailer.Send(ctx, internal.Email{To: "a@example.com", Subject: "Code", Text: "012345"}))
+
+	message := <-received
+	assert.Contains(t, message, "To: a@example.com")
+	assert.Contains(t, message, "Subject: Code")
+	assert.Contains(t, message, "012345")
+}
@@ -0,0 +1,202 @@
+package _test
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var extractionJobColumns = []string{"id", "pdf_id", "status", "error", "attempts", "created_at", "started_at", "finished_at"}
+
+// twoPageResult is the extraction of a two page PDF, with an image on its second page.
+func twoPageResult(t *testing.T) models.ExtractResult {
+	var result models.ExtractResult
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"pages": [
+			{"page": {"page_number": 1, "paragraph": "Safety instructions", "imgs": []}},
+			{"page": {"page_number": 2, "paragraph": "Hold the button.", "imgs": [{"gcs_bucket_name": "img-1.png", "order": 0}]}}
+		],
+		"pdf_number_of_pages": 2
+	}`), &result))
+	return result
+}
+
+// expectPreviousContentDeleted expects InsertExtractionResult to drop the content of PDF 3 from an earlier run.
+func expectPreviousContentDeleted(mock sqlmock.Sqlmock) {
+	mock.ExpectBegin()
+	mock.ExpectExec(`SELECT id FROM pdf WHERE id = \$1 FOR UPDATE`).WithArgs(3).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE device SET answer_cache_generation`).WithArgs(3).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM pdf_chunk`).WithArgs(3).WillReturnResult(sqlmock.NewResult(0, 4))
+	mock.ExpectExec(`DELETE FROM pdf_page WHERE pdf_id = \$1`).WithArgs(3).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectQuery(`SELECT nextval\(pg_get_serial_sequence\(\$1, 'id'\)\)`).WithArgs("pdf_page", 2).
+		WillReturnRows(sqlmock.NewRows([]string{"nextval"}).AddRow(10).AddRow(11))
+}
+
+func TestInsertExtractionResultReplacesPages(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// The pages of the earlier run go, with their paragraphs, images and chunks
+	expectPreviousContentDeleted(mock)
+	pages := mock.ExpectPrepare(`COPY "pdf_page" \("id", "pdf_id", "page_number"\) FROM STDIN`)
+	pages.ExpectExec().WithArgs(10, 3, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	pages.ExpectExec().WithArgs(11, 3, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	pages.ExpectExec().WithoutArgs().WillReturnResult(sqlmock.NewResult(0, 0))
+	paragraphs := mock.ExpectPrepare(`COPY "pdf_paragraph"`)
+	paragraphs.ExpectExec().WithArgs(10, "Safety instructions", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	paragraphs.ExpectExec().WithArgs(11, "Hold the button.", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	paragraphs.ExpectExec().WithoutArgs().WillReturnResult(sqlmock.NewResult(0, 0))
+	images := mock.ExpectPrepare(`COPY "pdf_image"`)
+	images.ExpectExec().WithArgs(11, 0, "img-1.png", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	images.ExpectExec().WithoutArgs().WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO pdf_paragraph_revision`).WithArgs(3).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(`UPDATE pdf SET ocr_flag = true, number_of_pages = \$1 WHERE id = \$2`).WithArgs(2, 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	require.NoError(t, models.InsertExtractionResult(db, 3, twoPageResult(t)))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestInsertExtractionResultRollsBackOnFailure(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	expectPreviousContentDeleted(mock)
+	pages := mock.ExpectPrepare(`COPY "pdf_page"`)
+	pages.ExpectExec().WithArgs(10, 3, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	pages.ExpectExec().WithArgs(11, 3, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	pages.ExpectExec().WithoutArgs().WillReturnResult(sqlmock.NewResult(0, 0))
+	paragraphs := mock.ExpectPrepare(`COPY "pdf_paragraph"`)
+	paragraphs.ExpectExec().WithArgs(10, "Safety instructions", sqlmock.AnyArg()).
+		WillReturnError(errors.New("connection reset"))
+	// Nothing is kept: the earlier pages are back and the PDF is not flagged as extracted
+	mock.ExpectRollback()
+
+	err = models.InsertExtractionResult(db, 3, twoPageResult(t))
+	assert.ErrorContains(t, err, "failed to insert PDF paragraphs")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestInsertExtractionJob(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery(`INSERT INTO extraction_job \(pdf_id, status\)\s+VALUES \(\$1, 'queued'\)\s+ON CONFLICT \(pdf_id\) WHERE status IN \('queued', 'running'\) DO NOTHING`).
+		WithArgs(3).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+
+	id, created, err := models.InsertExtractionJob(db, 3)
+	require.NoError(t, err)
+	assert.Equal(t, 4, id)
+	assert.True(t, created)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestInsertExtractionJobReturnsJobInProgress(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// The PDF already has a running job
+	mock.ExpectQuery(`INSERT INTO extraction_job`).WithArgs(3).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(`FROM extraction_job\s+WHERE pdf_id = \$1 AND status IN \('queued', 'running'\)`).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+
+	id, created, err := models.InsertExtractionJob(db, 3)
+	require.NoError(t, err)
+	assert.Equal(t, 2, id)
+	assert.False(t, created)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClaimExtractionJob(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	now := time.Now()
+	mock.ExpectQuery(`WHERE status = 'queued'\s+OR \(status = 'running' AND locked_until < NOW\(\)\)\s+ORDER BY id\s+FOR UPDATE SKIP LOCKED`).
+		WithArgs(600).
+		WillReturnRows(sqlmock.NewRows(extractionJobColumns).AddRow(4, 3, models.JobStatusRunning, nil, 1, now, now, nil))
+
+	job, err := models.ClaimExtractionJob(db, 10*time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, 4, job.ID)
+	assert.Equal(t, models.JobStatusRunning, job.Status)
+	assert.Equal(t, 1, job.Attempts)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClaimExtractionJobWhenQueueIsEmpty(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery(`UPDATE extraction_job`).WithArgs(600).WillReturnRows(sqlmock.NewRows(extractionJobColumns))
+
+	_, err = models.ClaimExtractionJob(db, 10*time.Minute)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFinishExtractionJob(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectExec(`SET status = \$1, error = NULLIF\(\$2, ''\), finished_at = NOW\(\), locked_until = NULL`).
+		WithArgs(models.JobStatusFailed, "OCR failed", 4).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`SET status = 'queued', error = NULLIF\(\$1, ''\), locked_until = NULL`).
+		WithArgs("agent unavailable", 5).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	require.NoError(t, models.FinishExtractionJob(db, 4, models.JobStatusFailed, "OCR failed"))
+	require.NoError(t, models.RequeueExtractionJob(db, 5, "agent unavailable"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetExtractionJob(t *testing.T) {
+	r, mock := newRouteTest(t)
+	token := userToken(t, ownerID)
+
+	created := time.Date(2025, 8, 1, 10, 0, 0, 0, time.UTC)
+	expectAccount(mock, ownerID, models.PermissionPDFExtract)
+	mock.ExpectQuery(`FROM extraction_job WHERE id = \$1`).WithArgs(4).
+		WillReturnRows(sqlmock.NewRows(extractionJobColumns).
+			AddRow(4, 3, models.JobStatusFailed, "OCR failed", 3, created, created, created.Add(time.Minute)))
+
+	w := serve(r, "GET", "/pdf_process/jobs/4", token, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"status":"failed"`)
+	assert.Contains(t, w.Body.String(), `"error":"OCR failed"`)
+	assert.Contains(t, w.Body.String(), `"finished_at":"2025-08-01T10:01:00Z"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	expectAccount(mock, ownerID, models.PermissionPDFExtract)
+	mock.ExpectQuery(`FROM extraction_job WHERE id = \$1`).WithArgs(5).WillReturnError(sql.ErrNoRows)
+	w = serve(r, "GET", "/pdf_process/jobs/5", token, "")
+	assert.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetExtractionJobRequiresPermission(t *testing.T) {
+	r, mock := newRouteTest(t)
+
+	w := serve(r, "GET", "/pdf_process/jobs/4", "", "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code, w.Body.String())
+
+	expectAccount(mock, ownerID, models.PermissionConversationChat)
+	w = serve(r, "GET", "/pdf_process/jobs/4", userToken(t, ownerID), "")
+	assert.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,110 @@
+package _test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func expectPairOwner(mock sqlmock.Sqlmock, pairID string, ownerID int) {
+	mock.ExpectQuery(`FROM request_response_pair rrp`).WithArgs(pairID).
+		WillReturnRows(sqlmock.NewRows([]string{"account_id"}).AddRow(ownerID))
+}
+
+func TestRateAnswer(t *testing.T) {
+	r, mock := newRouteTest(t)
+	expectAccount(mock, 5, models.PermissionConversationChat)
+	expectPairOwner(mock, "7", 5)
+
+	reason, comment := "wrong_device", "This is the manual of the WM-7100."
+	mock.ExpectQuery(`INSERT INTO answer_feedback`).WithArgs(7, 5, models.RatingDown, &reason, &comment).
+		WillReturnRows(sqlmock.NewRows([]string{"created_at", "updated_at"}).AddRow(time.Now(), time.Now()))
+
+	w := serve(r, "POST", "/conversation/feedback", userToken(t, 5),
+		`{"request_response_pair_id": 7, "rating": "down", "reason": "wrong_device", "comment": " This is the manual of the WM-7100. "}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"rating":"down"`)
+	assert.Contains(t, w.Body.String(), `"comment":"This is the manual of the WM-7100."`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRateAnswerValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"unknown rating", `{"request_response_pair_id": 7, "rating": "meh"}`},
+		{"unknown reason", `{"request_response_pair_id": 7, "rating": "down", "reason": "boring"}`},
+		{"missing pair", `{"rating": "up"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, mock := newRouteTest(t)
+			expectAccount(mock, 5, models.PermissionConversationChat)
+			mock.ExpectQuery(`FROM request_response_pair rrp`).
+				WillReturnRows(sqlmock.NewRows([]string{"account_id"}).AddRow(5))
+
+			w := serve(r, "POST", "/conversation/feedback", userToken(t, 5), tt.body)
+			assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+		})
+	}
+}
+
+func TestRateAnswerOfAnotherAccount(t *testing.T) {
+	r, mock := newRouteTest(t)
+	expectAccount(mock, 5, models.PermissionConversationChat)
+	expectPairOwner(mock, "7", 6)
+
+	w := serve(r, "POST", "/conversation/feedback", userToken(t, 5), `{"request_response_pair_id": 7, "rating": "up"}`)
+	assert.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteMissingFeedback(t *testing.T) {
+	r, mock := newRouteTest(t)
+	expectAccount(mock, 5, models.PermissionConversationChat)
+	expectPairOwner(mock, "7", 5)
+	mock.ExpectExec(`DELETE FROM answer_feedback`).WithArgs(7, 5).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	w := serve(r, "POST", "/conversation/feedback/delete", userToken(t, 5), `{"request_response_pair_id": 7}`)
+	assert.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListFeedback(t *testing.T) {
+	r, mock := newAuditTest(t)
+	expectAccount(mock, 1, models.PermissionAdminAccess, models.PermissionFeedbackReview)
+
+	mock.ExpectQuery(`SELECT COUNT\(\*\)\s+FROM answer_feedback f .* WHERE d.brand_id = \$1 AND f.rating = \$2`).
+		WithArgs(3, models.RatingDown).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(`ORDER BY f.updated_at DESC, f.request_response_pair_id DESC, f.account_id LIMIT \$3 OFFSET \$4`).
+		WithArgs(3, models.RatingDown, 20, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"request_response_pair_id", "account_id", "rating", "reason", "comment", "created_at", "updated_at",
+			"conversation_id", "device_id", "label", "brand_id", "request", "response"}).
+			AddRow(7, 5, models.RatingDown, "outdated", nil, time.Now(), time.Now(),
+				"conv-1", 2, "WM-7200", 3, "How do I reset it?", "Hold the power button."))
+	mock.ExpectQuery(`WHERE rc.request_response_pair_id = ANY\(\$1\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"request_response_pair_id", "pdf_chunk_id", "pdf_id", "filename", "page_number", "gcs_bucket"}).
+			AddRow(7, 12, 4, "manual.pdf", 9, ""))
+
+	w := serve(r, "GET", "/admin/feedback?brand_id=3&rating=down", userToken(t, 1), "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"query":"How do I reset it?"`)
+	assert.Contains(t, w.Body.String(), `"device_label":"WM-7200"`)
+	assert.Contains(t, w.Body.String(), `"editor_path":"/pdf_process/get_pdf_state?pdf_id=4\u0026page_number=9"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListFeedbackRequiresPermission(t *testing.T) {
+	r, mock := newAuditTest(t)
+	expectAccount(mock, 1, models.PermissionAdminAccess)
+
+	w := serve(r, "GET", "/admin/feedback", userToken(t, 1), "")
+	assert.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+}
@@ -0,0 +1,132 @@
+package _test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/internal"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const googleClientID = "client-1.apps.googleusercontent.com"
+
+// newJWKSServer serves the public key of key as kid, and counts the fetches.
+func newJWKSServer(t *testing.T, kid string, key *rsa.PrivateKey) (*httptest.Server, *int32) {
+	var fetches int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		json.NewEncoder(w).Encode(map[string]any{
+			"keys": []map[string]string{{
+				"kid": kid,
+				"kty": "RSA",
+				"alg": "RS256",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &fetches
+}
+
+func signGoogleToken(t *testing.T, key *rsa.PrivateKey, kid string, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
+
+func googleClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":            "https://accounts.google.com",
+		"aud":            googleClientID,
+		"sub":            "1234567890",
+		"email":          "jane@example.com",
+		"email_verified": true,
+		"name":           "Jane",
+		"iat":            time.Now().Unix(),
+		"exp":            time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func TestGoogleIDTokenVerifier(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	srv, fetches := newJWKSServer(t, "kid-1", key)
+	verifier := internal.NewGoogleIDTokenVerifier(srv.URL, []string{googleClientID})
+	ctx := context.Background()
+
+	claims, err := verifier.Verify(ctx, signGoogleToken(t, key, "kid-1", googleClaims()))
+	require.NoError(t, err)
+	assert.Equal(t, "1234567890", claims.Subject)
+	assert.Equal(t, "jane@example.com", claims.Email)
+	assert.True(t, bool(claims.EmailVerified))
+
+	// Older tokens send email_verified as a string
+	c := googleClaims()
+	c["email_verified"] = "true"
+	c["iss"] = "accounts.google.com"
+	claims, err = verifier.Verify(ctx, signGoogleToken(t, key, "kid-1", c))
+	require.NoError(t, err)
+	assert.True(t, bool(claims.EmailVerified))
+
+	// The keys are cached
+	assert.Equal(t, int32(1), atomic.LoadInt32(fetches))
+
+	invalid := map[string]func(jwt.MapClaims){
+		"other client": func(c jwt.MapClaims) { c["aud"] = "other-client" },
+		"other issuer": func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" },
+		"expired":      func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() },
+		"no subject":   func(c jwt.MapClaims) { delete(c, "sub") },
+	}
+	for name, mutate := range invalid {
+		t.Run(name, func(t *testing.T) {
+			c := googleClaims()
+			mutate(c)
+			_, err := verifier.Verify(ctx, signGoogleToken(t, key, "kid-1", c))
+			assert.ErrorIs(t, err, internal.ErrInvalidGoogleIDToken)
+		})
+	}
+}
+
+func TestGoogleIDTokenVerifierRejectsOtherKeys(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	forger, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	srv, fetches := newJWKSServer(t, "kid-1", key)
+	verifier := internal.NewGoogleIDTokenVerifier(srv.URL, []string{googleClientID})
+	ctx := context.Background()
+
+	// Signed by another key with a known kid
+	_, err = verifier.Verify(ctx, signGoogleToken(t, forger, "kid-1", googleClaims()))
+	assert.ErrorIs(t, err, internal.ErrInvalidGoogleIDToken)
+
+	// Unknown kids refetch the keys, at most once a minute
+	_, err = verifier.Verify(ctx, signGoogleToken(t, forger, "kid-2", googleClaims()))
+	assert.ErrorIs(t, err, internal.ErrInvalidGoogleIDToken)
+	_, err = verifier.Verify(ctx, signGoogleToken(t, forger, "kid-3", googleClaims()))
+	assert.ErrorIs(t, err, internal.ErrInvalidGoogleIDToken)
+	assert.Equal(t, int32(1), atomic.LoadInt32(fetches))
+
+	// HS256 tokens are refused, whatever their key
+	hs := jwt.NewWithClaims(jwt.SigningMethodHS256, googleClaims())
+	hs.Header["kid"] = "kid-1"
+	signed, err := hs.SignedString([]byte("secret"))
+	require.NoError(t, err)
+	_, err = verifier.Verify(ctx, signed)
+	assert.ErrorIs(t, err, internal.ErrInvalidGoogleIDToken)
+}
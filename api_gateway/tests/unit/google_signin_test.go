@@ -0,0 +1,183 @@
+package _test
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/controllers"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/internal"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/models"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// janeGoogle is a Google identity whose email Google verified.
+var janeGoogle = models.GoogleUser{GoogleID: "g-1", GoogleEmail: "jane@example.com", EmailVerified: true, Name: "Jane"}
+
+// expectGoogleIdentityUnknown expects the lookups of a Google identity linked to no account, whose
+// email no account uses.
+func expectGoogleIdentityUnknown(mock sqlmock.Sqlmock) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id FROM google_user WHERE google_id = \$1`).WithArgs("g-1").WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(`SELECT id FROM "user" WHERE lower\(email\) = lower\(\$1\) FOR UPDATE`).WithArgs("jane@example.com").
+		WillReturnError(sql.ErrNoRows)
+}
+
+func TestSignInGoogleUserLinksVerifiedEmail(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Jane registered with a password, then signs in with Google for the first time
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id FROM google_user WHERE google_id = \$1`).WithArgs("g-1").WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(`SELECT id FROM "user" WHERE lower\(email\) = lower\(\$1\) FOR UPDATE`).WithArgs("jane@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectExec(`INSERT INTO google_user \(id, google_id, email\)`).WithArgs(7, "g-1", "jane@example.com").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	accountID, created, err := models.SignInGoogleUser(db, janeGoogle, true)
+	require.NoError(t, err)
+	assert.Equal(t, 7, accountID)
+	assert.False(t, created)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSignInGoogleUserRefusesUnverifiedEmail(t *testing.T) {
+	unverified := janeGoogle
+	unverified.EmailVerified = false
+
+	for _, create := range []bool{true, false} {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		// The account registered with the email is not looked up, let alone linked
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT id FROM google_user WHERE google_id = \$1`).WithArgs("g-1").WillReturnError(sql.ErrNoRows)
+		mock.ExpectRollback()
+
+		_, _, err = models.SignInGoogleUser(db, unverified, create)
+		if create {
+			assert.ErrorIs(t, err, models.ErrGoogleEmailNotVerified)
+		} else {
+			assert.ErrorIs(t, err, models.ErrGoogleAccountNotFound)
+		}
+		assert.NoError(t, mock.ExpectationsWereMet())
+	}
+}
+
+func TestSignInGoogleUserCreatesAccount(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	expectGoogleIdentityUnknown(mock)
+	mock.ExpectQuery(`INSERT INTO account \(username, role_id, display_name\)\s+SELECT \$1, id, \$2 FROM role WHERE label = \$3`).
+		WithArgs("jane@example.com", "Jane", models.UserPermission).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+	mock.ExpectExec(`INSERT INTO google_user \(id, google_id, email\)`).WithArgs(9, "g-1", "jane@example.com").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	accountID, created, err := models.SignInGoogleUser(db, janeGoogle, true)
+	require.NoError(t, err)
+	assert.Equal(t, 9, accountID)
+	assert.True(t, created)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSignInGoogleUserRollsBackAccountCreation(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	expectGoogleIdentityUnknown(mock)
+	mock.ExpectQuery(`INSERT INTO account`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+	mock.ExpectExec(`INSERT INTO google_user`).WillReturnError(errors.New("connection reset"))
+	// No account is left without its Google identity
+	mock.ExpectRollback()
+
+	_, _, err = models.SignInGoogleUser(db, janeGoogle, true)
+	assert.ErrorContains(t, err, "connection reset")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// newGoogleSignInTest serves the Google registration and login handlers for the identity,
+// as VerifyGoogleIDToken would have set it.
+func newGoogleSignInTest(t *testing.T, user models.GoogleUser) (*gin.Engine, sqlmock.Sqlmock) {
+	t.Setenv("JWT_KEY", "test-key")
+	gin.SetMode(gin.TestMode)
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	r := gin.New()
+	verified := func(c *gin.Context) { c.Set("google_user", user) }
+	r.POST("/auth/google_registration", verified, controllers.GoogleRegistration(db))
+	r.POST("/auth/google_login", verified, controllers.GoogleLogin(db))
+	return r, mock
+}
+
+// expectGoogleSession expects the session started for the account after a Google sign-in.
+func expectGoogleSession(mock sqlmock.Sqlmock, accountID int) {
+	mock.ExpectExec(`INSERT INTO refresh_token`).
+		WithArgs(accountID, sqlmock.AnyArg(), sqlmock.AnyArg(), internal.AudienceUser, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT r.label, a.token_version`).WithArgs(accountID).
+		WillReturnRows(sqlmock.NewRows([]string{"label", "token_version", "disabled"}).AddRow("user", 0, false))
+}
+
+func TestGoogleRegistrationCreatesAccount(t *testing.T) {
+	r, mock := newGoogleSignInTest(t, janeGoogle)
+
+	expectGoogleIdentityUnknown(mock)
+	mock.ExpectQuery(`INSERT INTO account`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+	mock.ExpectExec(`INSERT INTO google_user`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectGoogleSession(mock, 9)
+
+	w := serve(r, "POST", "/auth/google_registration", "", "")
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"refresh_token"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGoogleRegistrationOfLinkedIdentityLogsIn(t *testing.T) {
+	r, mock := newGoogleSignInTest(t, janeGoogle)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id FROM google_user WHERE google_id = \$1`).WithArgs("g-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectCommit()
+	expectGoogleSession(mock, 7)
+
+	w := serve(r, "POST", "/auth/google_registration", "", "")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGoogleSignInRefusesUnverifiedEmail(t *testing.T) {
+	unverified := janeGoogle
+	unverified.EmailVerified = false
+	for path, status := range map[string]int{
+		"/auth/google_registration": http.StatusForbidden,
+		"/auth/google_login":        http.StatusNotFound,
+	} {
+		t.Run(path, func(t *testing.T) {
+			r, mock := newGoogleSignInTest(t, unverified)
+			mock.ExpectBegin()
+			mock.ExpectQuery(`SELECT id FROM google_user WHERE google_id = \$1`).WithArgs("g-1").WillReturnError(sql.ErrNoRows)
+			mock.ExpectRollback()
+
+			w := serve(r, "POST", path, "", "")
+			assert.Equal(t, status, w.Code, w.Body.String())
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package _test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/internal"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessToken(t *testing.T) {
+	t.Setenv("JWT_KEY", "test-key")
+	t.Setenv("ACCESS_TOKEN_TTL", "5m")
+
+	jti := internal.NewTokenID()
+	token, err := internal.JWTGenerator(internal.TokenSubject{
+		AccountID:    42,
+		Role:         "admin",
+		TokenVersion: 3,
+		Audience:     internal.AudienceAdmin,
+	}, jti)
+	require.NoError(t, err)
+
+	claims, err := internal.JWTParse(token)
+	require.NoError(t, err)
+	assert.Equal(t, 42, claims.UserID)
+	assert.Equal(t, "admin", claims.Role)
+	assert.Equal(t, 3, claims.TokenVersion)
+	assert.True(t, claims.VerifyAudience(internal.AudienceAdmin, true))
+	assert.False(t, claims.VerifyAudience(internal.AudienceUser, true))
+	assert.Equal(t, jti, claims.ID)
+	assert.WithinDuration(t, time.Now().Add(5*time.Minute), claims.ExpiresAt.Time, 5*time.Second)
+
+	t.Setenv("JWT_KEY", "other-key")
+	_, err = internal.JWTParse(token)
+	assert.Error(t, err)
+}
+
+func TestLegacyAccessTokensAreRefused(t *testing.T) {
+	t.Setenv("JWT_KEY", "test-key")
+	expiresAt := jwt.NewNumericDate(time.Now().Add(time.Hour))
+
+	for name, claims := range map[string]jwt.RegisteredClaims{
+		"without jti":      {ExpiresAt: expiresAt, Audience: jwt.ClaimStrings{internal.AudienceUser}},
+		"without audience": {ExpiresAt: expiresAt, ID: internal.NewTokenID()},
+		"unknown audience": {ExpiresAt: expiresAt, ID: internal.NewTokenID(), Audience: jwt.ClaimStrings{"other"}},
+	} {
+		legacy := jwt.NewWithClaims(jwt.SigningMethodHS256, &internal.UserIDClaims{UserID: 42, RegisteredClaims: claims})
+		token, err := legacy.SignedString([]byte("test-key"))
+		require.NoError(t, err)
+
+		_, err = internal.JWTParse(token)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestRefreshTokenHash(t *testing.T) {
+	token, hash := internal.NewRefreshToken()
+	other, _ := internal.NewRefreshToken()
+	assert.NotEqual(t, token, other)
+	assert.Len(t, hash, 64)
+	assert.Equal(t, hash, internal.HashRefreshToken(token))
+}
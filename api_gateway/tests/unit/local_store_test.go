@@ -0,0 +1,113 @@
+package _test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/controllers"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/internal"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newLocalStore(t *testing.T) *internal.LocalBlobStore {
+	store, err := internal.NewLocalBlobStore(t.TempDir(), "http://gateway.test", []byte("secret"))
+	require.NoError(t, err)
+	return store
+}
+
+func TestLocalBlobStoreSignedURLs(t *testing.T) {
+	store := newLocalStore(t)
+
+	signed, err := store.SignedWriteURL("a/../b.pdf", "application/pdf", time.Minute)
+	require.NoError(t, err)
+	u, err := url.Parse(signed)
+	require.NoError(t, err)
+	assert.Equal(t, internal.LocalBlobPath, u.Path)
+
+	name, err := store.VerifySignedURL("PUT", u.Query(), "application/pdf")
+	require.NoError(t, err)
+	assert.Equal(t, "a/../b.pdf", name)
+
+	// Wrong content type, wrong method and altered name are refused.
+	_, err = store.VerifySignedURL("PUT", u.Query(), "image/png")
+	assert.ErrorIs(t, err, internal.ErrInvalidSignature)
+	_, err = store.VerifySignedURL("GET", u.Query(), "")
+	assert.ErrorIs(t, err, internal.ErrInvalidSignature)
+	query := u.Query()
+	query.Set("name", "other.pdf")
+	_, err = store.VerifySignedURL("PUT", query, "application/pdf")
+	assert.ErrorIs(t, err, internal.ErrInvalidSignature)
+
+	// Expired URLs are refused.
+	expired, err := store.SignedReadURL("b.pdf", -time.Minute)
+	require.NoError(t, err)
+	u, err = url.Parse(expired)
+	require.NoError(t, err)
+	_, err = store.VerifySignedURL("GET", u.Query(), "")
+	assert.ErrorIs(t, err, internal.ErrInvalidSignature)
+}
+
+func TestLocalBlobStoreObjects(t *testing.T) {
+	store := newLocalStore(t)
+	ctx := context.Background()
+
+	_, err := store.Stat(ctx, "missing")
+	assert.ErrorIs(t, err, internal.ErrBlobNotFound)
+
+	require.NoError(t, store.Put(ctx, "x.png", "image/png", strings.NewReader("png data")))
+	attrs, err := store.Stat(ctx, "x.png")
+	require.NoError(t, err)
+	assert.Equal(t, int64(8), attrs.Size)
+	assert.Equal(t, "image/png", attrs.ContentType)
+	assert.Len(t, attrs.MD5, 16)
+
+	reader, err := store.Get(ctx, "x.png")
+	require.NoError(t, err)
+	data, _ := io.ReadAll(reader)
+	reader.Close()
+	assert.Equal(t, "png data", string(data))
+
+	require.NoError(t, store.Delete(ctx, "x.png"))
+	assert.ErrorIs(t, store.Delete(ctx, "x.png"), internal.ErrBlobNotFound)
+}
+
+func TestLocalBlobRoutes(t *testing.T) {
+	store := newLocalStore(t)
+	previous := internal.Storage
+	internal.Storage = store
+	defer func() { internal.Storage = previous }()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET(internal.LocalBlobPath, controllers.DownloadLocalBlobHandler())
+	router.PUT(internal.LocalBlobPath, controllers.UploadLocalBlobHandler())
+
+	writeURL, _ := store.SignedWriteURL("doc.pdf", "application/pdf", time.Minute)
+	req, _ := http.NewRequest("PUT", strings.TrimPrefix(writeURL, "http://gateway.test"), strings.NewReader("%PDF-1.7"))
+	req.Header.Set("Content-Type", "application/pdf")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// The write URL can't be used to read.
+	req, _ = http.NewRequest("GET", strings.TrimPrefix(writeURL, "http://gateway.test"), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	readURL, _ := store.SignedReadURL("doc.pdf", time.Minute)
+	req, _ = http.NewRequest("GET", strings.TrimPrefix(readURL, "http://gateway.test"), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/pdf", w.Header().Get("Content-Type"))
+	assert.Equal(t, "%PDF-1.7", w.Body.String())
+}
@@ -0,0 +1,341 @@
+package _test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/internal"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/models"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/routes"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockOIDCServer is a minimal OIDC provider: discovery, JWKS, an authorize endpoint
+// that signs the user in right away, and a token endpoint checking PKCE.
+type mockOIDCServer struct {
+	*httptest.Server
+	key    *rsa.PrivateKey
+	claims jwt.MapClaims // Claims of the ID tokens, besides iss, aud, exp and nonce
+
+	mu    sync.Mutex
+	codes map[string]mockOIDCCode
+}
+
+type mockOIDCCode struct {
+	challenge, nonce, redirectURI string
+}
+
+func newMockOIDCServer(t *testing.T) *mockOIDCServer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	m := &mockOIDCServer{key: key, codes: map[string]mockOIDCCode{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 m.URL,
+			"authorization_endpoint": m.URL + "/authorize",
+			"token_endpoint":         m.URL + "/token",
+			"jwks_uri":               m.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"keys": []map[string]string{{
+				"kid": "mock",
+				"kty": "RSA",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("code_challenge_method") != "S256" || q.Get("response_type") != "code" {
+			http.Error(w, "PKCE S256 required", http.StatusBadRequest)
+			return
+		}
+		code := internal.NewTokenID()
+		m.mu.Lock()
+		m.codes[code] = mockOIDCCode{challenge: q.Get("code_challenge"), nonce: q.Get("nonce"), redirectURI: q.Get("redirect_uri")}
+		m.mu.Unlock()
+		http.Redirect(w, r, q.Get("redirect_uri")+"?code="+code+"&state="+url.QueryEscape(q.Get("state")), http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		m.mu.Lock()
+		code, ok := m.codes[r.PostForm.Get("code")]
+		delete(m.codes, r.PostForm.Get("code"))
+		m.mu.Unlock()
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if !ok || r.PostForm.Get("grant_type") != "authorization_code" ||
+			base64.RawURLEncoding.EncodeToString(sum[:]) != code.challenge ||
+			r.PostForm.Get("redirect_uri") != code.redirectURI {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": m.idToken(t, r.PostForm.Get("client_id"), code.nonce)})
+	})
+	m.Server = httptest.NewServer(mux)
+	t.Cleanup(m.Close)
+	return m
+}
+
+func (m *mockOIDCServer) idToken(t *testing.T, audience, nonce string) string {
+	claims := jwt.MapClaims{
+		"iss":   m.URL,
+		"aud":   audience,
+		"nonce": nonce,
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	}
+	for k, v := range m.claims {
+		claims[k] = v
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "mock"
+	signed, err := token.SignedString(m.key)
+	require.NoError(t, err)
+	return signed
+}
+
+// setupOIDCProvider configures the gateway with the mock server as provider "corp".
+func setupOIDCProvider(t *testing.T, m *mockOIDCServer) *internal.OIDCProvider {
+	t.Setenv("OIDC_PROVIDERS", "corp")
+	t.Setenv("OIDC_CORP_ISSUER", m.URL)
+	t.Setenv("OIDC_CORP_CLIENT_ID", "gateway")
+	t.Setenv("OIDC_CORP_CLIENT_SECRET", "secret")
+	t.Setenv("OIDC_CORP_REDIRECT_URL", "http://gateway.test/auth/oidc/corp/callback")
+	t.Setenv("OIDC_CORP_ROLE_MAP", "editors=content_editor,staff=viewer")
+	require.NoError(t, internal.InitOIDCProviders())
+	t.Cleanup(func() { internal.OIDCProviders = map[string]*internal.OIDCProvider{} })
+	return internal.OIDCProviders["corp"]
+}
+
+// followAuthorize opens an authorization URL at the mock provider and returns the callback URL it redirects to.
+func followAuthorize(t *testing.T, authURL string) *url.URL {
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	return callback
+}
+
+func TestOIDCProviderCodeFlow(t *testing.T) {
+	m := newMockOIDCServer(t)
+	m.claims = jwt.MapClaims{
+		"sub":            "user-1",
+		"email":          "jane@corp.example",
+		"email_verified": "true",
+		"name":           "Jane",
+		"groups":         []string{"staff", "editors"},
+	}
+	provider := setupOIDCProvider(t, m)
+	ctx := context.Background()
+
+	verifier := internal.NewPKCEVerifier()
+	authURL, err := provider.AuthCodeURL(ctx, "state-1", "nonce-1", verifier)
+	require.NoError(t, err)
+	parsed, err := url.Parse(authURL)
+	require.NoError(t, err)
+	assert.Equal(t, "gateway", parsed.Query().Get("client_id"))
+	assert.Equal(t, "openid email profile", parsed.Query().Get("scope"))
+	assert.Equal(t, internal.PKCEChallenge(verifier), parsed.Query().Get("code_challenge"))
+
+	callback := followAuthorize(t, authURL)
+	assert.Equal(t, "state-1", callback.Query().Get("state"))
+	code := callback.Query().Get("code")
+
+	// The code can't be redeemed without the verifier
+	_, err = provider.Exchange(ctx, code, internal.NewPKCEVerifier(), "nonce-1")
+	assert.Error(t, err)
+
+	callback = followAuthorize(t, authURL)
+	identity, err := provider.Exchange(ctx, callback.Query().Get("code"), verifier, "nonce-1")
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", identity.Subject)
+	assert.Equal(t, "jane@corp.example", identity.Email)
+	assert.True(t, identity.EmailVerified)
+	assert.Equal(t, []string{"staff", "editors"}, identity.Groups)
+	// The first mapping matching a group wins
+	assert.Equal(t, "content_editor", provider.MapRole(identity.Groups))
+	assert.Equal(t, "", provider.MapRole([]string{"other"}))
+
+	// ID tokens of another login (nonce) or client are refused
+	_, err = provider.VerifyIDToken(ctx, m.idToken(t, "gateway", "nonce-2"), "nonce-1")
+	assert.ErrorIs(t, err, internal.ErrInvalidOIDCIDToken)
+	_, err = provider.VerifyIDToken(ctx, m.idToken(t, "other-client", "nonce-1"), "nonce-1")
+	assert.ErrorIs(t, err, internal.ErrInvalidOIDCIDToken)
+}
+
+func TestParseRoleMap(t *testing.T) {
+	mappings, err := internal.ParseRoleMap(" editors = content_editor ,staff=viewer,")
+	require.NoError(t, err)
+	assert.Equal(t, []internal.RoleMapping{{Group: "editors", Role: "content_editor"}, {Group: "staff", Role: "viewer"}}, mappings)
+
+	_, err = internal.ParseRoleMap("admins")
+	assert.Error(t, err)
+	// Admins are only made by admins
+	_, err = internal.ParseRoleMap("staff=viewer,admins=admin")
+	assert.Error(t, err)
+}
+
+// expectLinkedIdentity expects the sign in of the identity corp/user-1, linked to account 5.
+func expectLinkedIdentity(mock sqlmock.Sqlmock) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(`UPDATE external_identity`).WithArgs("corp", "user-1", "jane@corp.example").
+		WillReturnRows(sqlmock.NewRows([]string{"account_id"}).AddRow(5))
+}
+
+func TestSignInWithoutMappedGroupResetsRole(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// The account left the editors group: it's a user again
+	expectLinkedIdentity(mock)
+	mock.ExpectQuery(`FROM role r WHERE r.label`).WithArgs(models.UserPermission).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "admin_only"}).AddRow(2, false))
+	mock.ExpectExec(`UPDATE account SET role_id`).WithArgs(2, 5).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	accountID, created, err := models.SignInExternalIdentity(db, models.ExternalIdentity{
+		Provider: "corp", Subject: "user-1", Email: "jane@corp.example", RoleMapped: true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 5, accountID)
+	assert.False(t, created)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// A provider without a role map keeps the role given by the admins
+	expectLinkedIdentity(mock)
+	mock.ExpectCommit()
+	_, _, err = models.SignInExternalIdentity(db, models.ExternalIdentity{Provider: "corp", Subject: "user-1", Email: "jane@corp.example"})
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSignInRefusesAdminOnlyRole(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	expectLinkedIdentity(mock)
+	mock.ExpectQuery(`FROM role r WHERE r.label`).WithArgs("auditor").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "admin_only"}).AddRow(6, true))
+	mock.ExpectRollback()
+
+	_, _, err = models.SignInExternalIdentity(db, models.ExternalIdentity{
+		Provider: "corp", Subject: "user-1", Email: "jane@corp.example", Role: "auditor", RoleMapped: true,
+	})
+	assert.ErrorContains(t, err, "admin-only")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// captureArg is a sqlmock argument matcher remembering the value it was given.
+type captureArg struct{ value *string }
+
+func (a captureArg) Match(v driver.Value) bool {
+	s, ok := v.(string)
+	*a.value = s
+	return ok
+}
+
+func TestOIDCLoginRoutes(t *testing.T) {
+	t.Setenv("JWT_KEY", "test-key")
+	gin.SetMode(gin.TestMode)
+	m := newMockOIDCServer(t)
+	m.claims = jwt.MapClaims{"sub": "user-1", "email": "jane@corp.example", "email_verified": true, "groups": "editors"}
+	setupOIDCProvider(t, m)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	r := gin.New()
+	routes.UserRoutes(r, db)
+
+	// Login: the state is stored and the browser sent to the provider
+	var state, verifier, nonce string
+	mock.ExpectExec(`INSERT INTO oidc_login_state`).
+		WithArgs(captureArg{&state}, "corp", captureArg{&verifier}, captureArg{&nonce}, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/auth/oidc/corp/login", nil))
+	require.Equal(t, http.StatusFound, w.Code, w.Body.String())
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, state, cookies[0].Value)
+	assert.True(t, cookies[0].HttpOnly)
+
+	callback := followAuthorize(t, w.Header().Get("Location"))
+
+	// Another browser (without the cookie) can't use the callback
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/auth/oidc/corp/callback?"+callback.RawQuery, nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Callback: the identity is linked to the account with the same verified email, and mapped to content_editor
+	mock.ExpectQuery(`DELETE FROM oidc_login_state`).WithArgs(state, "corp").
+		WillReturnRows(sqlmock.NewRows([]string{"code_verifier", "nonce", "valid"}).AddRow(verifier, nonce, true))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`UPDATE external_identity`).WithArgs("corp", "user-1", "jane@corp.example").
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(`SELECT a.id FROM account a`).WithArgs("jane@corp.example").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	mock.ExpectExec(`INSERT INTO external_identity`).WithArgs("corp", "user-1", 5, "jane@corp.example").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(`FROM role r WHERE r.label`).WithArgs("content_editor").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "admin_only"}).AddRow(3, false))
+	mock.ExpectExec(`UPDATE account SET role_id`).WithArgs(3, 5).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec(`INSERT INTO refresh_token`).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(`SELECT r.label, a.token_version`).WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"label", "token_version", "disabled"}).AddRow("content_editor", 0, false))
+
+	req := httptest.NewRequest("GET", "/auth/oidc/corp/callback?"+callback.RawQuery, nil)
+	req.AddCookie(cookies[0])
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	var resp struct {
+		Data struct {
+			Token string `json:"token"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	claims, err := internal.JWTParse(resp.Data.Token)
+	require.NoError(t, err)
+	assert.Equal(t, 5, claims.UserID)
+	assert.Equal(t, "content_editor", claims.Role)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/auth/oidc/other/login", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/auth/oidc/providers", nil))
+	assert.True(t, strings.Contains(w.Body.String(), `"corp"`), w.Body.String())
+}
@@ -0,0 +1,205 @@
+package _test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/internal"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/middlewares"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/models"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/routes"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	ownerID    = 1
+	strangerID = 2
+	supportID  = 3
+)
+
+// newRouteTest serves the conversation and pdf_process routes on a mocked database.
+func newRouteTest(t *testing.T) (*gin.Engine, sqlmock.Sqlmock) {
+	t.Setenv("JWT_KEY", "test-key")
+	gin.SetMode(gin.TestMode)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	mock.MatchExpectationsInOrder(false)
+	t.Cleanup(func() { db.Close() })
+
+	previous := models.DB
+	models.DB = db
+	t.Cleanup(func() { models.DB = previous })
+
+	r := gin.New()
+	routes.ConversationRoutes(r, nil)
+	routes.PDFProcessRoutes(r, db)
+	return r, mock
+}
+
+// expectAccount expects the queries authenticating a token of the account.
+func expectAccount(mock sqlmock.Sqlmock, accountID int, permissions ...string) {
+	middlewares.InvalidateAllAccountRoles()
+	middlewares.InvalidateRevokedTokens()
+	mock.ExpectQuery(`SELECT jti FROM revoked_access_token`).WillReturnRows(sqlmock.NewRows([]string{"jti"}))
+	mock.ExpectQuery(`SELECT r.label, a.token_version`).WithArgs(accountID).
+		WillReturnRows(sqlmock.NewRows([]string{"label", "token_version", "disabled"}).AddRow("user", 0, false))
+	rows := sqlmock.NewRows([]string{"id", "name", "description", "admin_only"})
+	for i, p := range permissions {
+		rows.AddRow(i+1, p, "", false)
+	}
+	mock.ExpectQuery(`JOIN role_permission`).WithArgs(accountID).WillReturnRows(rows)
+}
+
+func userToken(t *testing.T, accountID int) string {
+	token, err := internal.JWTGenerator(internal.TokenSubject{
+		AccountID: accountID,
+		Role:      "user",
+		Audience:  internal.AudienceUser,
+	}, internal.NewTokenID())
+	require.NoError(t, err)
+	return token
+}
+
+func serve(r *gin.Engine, method, path, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestConversationRoutesRefuseOtherAccounts(t *testing.T) {
+	r, mock := newRouteTest(t)
+	token := userToken(t, strangerID)
+
+	cases := []struct {
+		name, method, path, body, ownerQuery, id string
+	}{
+		{"conversation info", "GET", "/conversation/conv-1", "", `FROM conversation WHERE id`, "conv-1"},
+		{"delete conversation", "POST", "/conversation/delete", `{"conversation_id": "conv-1"}`, `FROM conversation WHERE id`, "conv-1"},
+		{"note list", "POST", "/conversation/note/list", `{"conversation_id": "conv-1"}`, `FROM conversation WHERE id`, "conv-1"},
+		{"take note", "POST", "/conversation/note/take", `{"requestresponsepairid": 7, "title": "x"}`, `FROM request_response_pair rrp`, "7"},
+		{"delete note", "POST", "/conversation/note/delete", `{"id": 7}`, `FROM note n`, "7"},
+		{"rag query", "POST", "/conversation/rag_query", `{"query": "q", "conversation_id": "conv-1"}`, `FROM conversation WHERE id`, "conv-1"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			expectAccount(mock, strangerID, models.PermissionConversationChat)
+			mock.ExpectQuery(tc.ownerQuery).WithArgs(tc.id).
+				WillReturnRows(sqlmock.NewRows([]string{"account_id"}).AddRow(ownerID))
+
+			w := serve(r, tc.method, tc.path, token, tc.body)
+			assert.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+		})
+	}
+}
+
+// The id checked must be the id the handler binds: encoding/json matches keys case-insensitively
+// and keeps the last of duplicate keys.
+func TestOwnershipChecksTheBoundID(t *testing.T) {
+	r, mock := newRouteTest(t)
+	token := userToken(t, strangerID)
+
+	cases := []struct {
+		name, path, body, ownerQuery, id string
+	}{
+		{"case-variant conversation", "/conversation/note/list", `{"Conversation_ID": "conv-1"}`, `FROM conversation WHERE id`, "conv-1"},
+		{"case-variant note", "/conversation/note/delete", `{"ID": 7}`, `FROM note n`, "7"},
+		{"case-variant pair", "/conversation/note/take", `{"RequestResponsePairID": 7, "title": "x"}`, `FROM request_response_pair rrp`, "7"},
+		{"case-variant rated pair", "/conversation/feedback", `{"Request_Response_Pair_ID": 7, "rating": "up"}`, `FROM request_response_pair rrp`, "7"},
+		{"duplicate conversation", "/conversation/note/list", `{"conversation_id": "conv-2", "Conversation_ID": "conv-1"}`, `FROM conversation WHERE id`, "conv-1"},
+		{"duplicate note", "/conversation/note/delete", `{"id": 8, "ID": 7}`, `FROM note n`, "7"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			expectAccount(mock, strangerID, models.PermissionConversationChat)
+			mock.ExpectQuery(tc.ownerQuery).WithArgs(tc.id).
+				WillReturnRows(sqlmock.NewRows([]string{"account_id"}).AddRow(ownerID))
+
+			w := serve(r, "POST", tc.path, token, tc.body)
+			assert.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestOwnershipRequiresTheID(t *testing.T) {
+	r, mock := newRouteTest(t)
+
+	for _, body := range []string{`{}`, `{"id": null}`, `not json`} {
+		expectAccount(mock, strangerID, models.PermissionConversationChat)
+		w := serve(r, "POST", "/conversation/note/delete", userToken(t, strangerID), body)
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestConversationRoutesAllowOwner(t *testing.T) {
+	r, mock := newRouteTest(t)
+
+	expectAccount(mock, ownerID, models.PermissionConversationChat)
+	mock.ExpectQuery(`FROM note n`).WithArgs("7").
+		WillReturnRows(sqlmock.NewRows([]string{"account_id"}).AddRow(ownerID))
+	mock.ExpectExec(`DELETE FROM note`).WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	w := serve(r, "POST", "/conversation/note/delete", userToken(t, ownerID), `{"id": 7}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestConversationReadAnyOverride(t *testing.T) {
+	r, mock := newRouteTest(t)
+	token := userToken(t, supportID)
+
+	// conversation:read_any lets the account read notes of others...
+	expectAccount(mock, supportID, models.PermissionConversationChat, models.PermissionConversationReadAny)
+	mock.ExpectQuery(`FROM conversation WHERE id`).WithArgs("conv-1").
+		WillReturnRows(sqlmock.NewRows([]string{"account_id"}).AddRow(ownerID))
+	mock.ExpectQuery(`FROM request_response_pair rrp`).WithArgs("conv-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "response"}))
+	w := serve(r, "POST", "/conversation/note/list", token, `{"conversation_id": "conv-1"}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// ...but not delete them
+	expectAccount(mock, supportID, models.PermissionConversationChat, models.PermissionConversationReadAny)
+	mock.ExpectQuery(`FROM note n`).WithArgs("7").
+		WillReturnRows(sqlmock.NewRows([]string{"account_id"}).AddRow(ownerID))
+	w = serve(r, "POST", "/conversation/note/delete", token, `{"id": 7}`)
+	assert.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+}
+
+func TestConversationRoutesRefuseAnonymous(t *testing.T) {
+	r, _ := newRouteTest(t)
+
+	w := serve(r, "GET", "/conversation/conv-1", "", "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// rag_query works without an account, but not on a conversation
+	w = serve(r, "POST", "/conversation/rag_query", "", `{"query": "q", "conversation_id": "conv-1"}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestPDFProcessMutationsRequirePermission(t *testing.T) {
+	r, mock := newRouteTest(t)
+
+	w := serve(r, "POST", "/pdf_process/delete_chunk", "", `{}`)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// A chat-only account can't edit PDFs
+	expectAccount(mock, strangerID, models.PermissionConversationChat)
+	w = serve(r, "POST", "/pdf_process/delete_chunk", userToken(t, strangerID), `{}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	expectAccount(mock, strangerID, models.PermissionConversationChat)
+	w = serve(r, "GET", "/pdf_process/new_device?label=x&brand_id=1&device_type_id=1", userToken(t, strangerID), "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
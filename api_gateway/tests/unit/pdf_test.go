@@ -0,0 +1,67 @@
+package _test
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// minimalPDF builds a valid PDF with the given number of empty pages.
+func minimalPDF(pages int) []byte {
+	var buf bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	buf.WriteString("%PDF-1.4\n")
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	kids := make([]string, pages)
+	for i := range kids {
+		kids[i] = fmt.Sprintf("%d 0 R", i+3)
+	}
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), pages))
+	for i := 0; i < pages; i++ {
+		object("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] >>")
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return buf.Bytes()
+}
+
+func TestSpoolPDF(t *testing.T) {
+	data := minimalPDF(3)
+
+	spooled, err := internal.SpoolPDF(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	defer spooled.Remove()
+	assert.Equal(t, int64(len(data)), spooled.Size)
+	assert.Len(t, spooled.SHA256, 64)
+
+	pages, err := internal.CountPDFPages(spooled.File, spooled.Size)
+	require.NoError(t, err)
+	assert.Equal(t, 3, pages)
+
+	_, err = internal.SpoolPDF(bytes.NewReader(data), int64(len(data))-1)
+	assert.ErrorIs(t, err, internal.ErrPDFTooLarge)
+
+	_, err = internal.SpoolPDF(strings.NewReader("PK\x03\x04 not a pdf"), 1024)
+	assert.ErrorIs(t, err, internal.ErrNotPDF)
+}
+
+func TestCountPDFPagesRejectsBrokenPDF(t *testing.T) {
+	data := []byte("%PDF-1.4\nthis is not really a pdf")
+	_, err := internal.CountPDFPages(bytes.NewReader(data), int64(len(data)))
+	assert.ErrorIs(t, err, internal.ErrNotPDF)
+}
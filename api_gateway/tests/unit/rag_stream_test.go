@@ -0,0 +1,184 @@
+package _test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/models"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/pb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// fakeAIAgent answers the RAG queries with tokens, then fails with err if it is set, and chunks
+// and embeds any text into chunks.
+type fakeAIAgent struct {
+	pb.UnimplementedRagServiceServer
+	pb.UnimplementedRagStreamServiceWithConversationHistoryServer
+	pb.UnimplementedMbertChunkingServiceServer
+	tokens    []string
+	imagesIDs []int32
+	err       error
+	chunks    []models.EmbeddedChunk
+}
+
+func (a *fakeAIAgent) ChunkAndEmbed(ctx context.Context, req *pb.MbertChunkingRequest) (*pb.MbertChunkingResponse, error) {
+	result, err := json.Marshal(models.ChunkResult{Chunks: a.chunks})
+	if err != nil {
+		return nil, err
+	}
+	return &pb.MbertChunkingResponse{ResultJson: string(result)}, nil
+}
+
+func (a *fakeAIAgent) Query(ctx context.Context, req *pb.RagRequest) (*pb.RagResponse, error) {
+	if a.err != nil {
+		return nil, a.err
+	}
+	return &pb.RagResponse{Response: strings.Join(a.tokens, ""), ImagesIds: a.imagesIDs}, nil
+}
+
+func (a *fakeAIAgent) QueryStream(req *pb.RagWithConversationHistoryRequest, stream pb.RagStreamServiceWithConversationHistory_QueryStreamServer) error {
+	for _, token := range a.tokens {
+		if err := stream.Send(&pb.RagStreamResponse{Token: token}); err != nil {
+			return err
+		}
+	}
+	if a.err != nil {
+		return a.err
+	}
+	return stream.Send(&pb.RagStreamResponse{ImagesIds: a.imagesIDs, Done: true})
+}
+
+// useFakeAIAgent makes the gateway send its gRPC calls to agent for the test.
+func useFakeAIAgent(t *testing.T, agent *fakeAIAgent) {
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	pb.RegisterRagServiceServer(server, agent)
+	pb.RegisterRagStreamServiceWithConversationHistoryServer(server, agent)
+	pb.RegisterMbertChunkingServiceServer(server, agent)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///agent",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	previous := pb.UseConn(conn)
+	t.Cleanup(func() {
+		pb.UseConn(previous)
+		conn.Close()
+	})
+}
+
+// expectOwnConversation expects the authentication of the owner of conv-1.
+func expectOwnConversation(mock sqlmock.Sqlmock) {
+	expectAccount(mock, ownerID, models.PermissionConversationChat)
+	mock.ExpectQuery(`FROM conversation WHERE id`).WithArgs("conv-1").
+		WillReturnRows(sqlmock.NewRows([]string{"account_id"}).AddRow(ownerID))
+}
+
+// assertEvents asserts that the event stream body has the events, in order.
+func assertEvents(t *testing.T, body string, events ...string) {
+	t.Helper()
+	for _, event := range events {
+		i := strings.Index(body, event)
+		if !assert.GreaterOrEqual(t, i, 0, "missing %q in %s", event, body) {
+			return
+		}
+		body = body[i+len(event):]
+	}
+}
+
+func TestRagQueryStreamRelaysTokens(t *testing.T) {
+	r, mock := newRouteTest(t)
+	useFakeAIAgent(t, &fakeAIAgent{tokens: []string{"The filter light ", "can be reset."}, imagesIDs: []int32{4}})
+
+	w := serve(r, "POST", "/conversation/rag_query/stream", "", `{"query": "How do I reset the filter light?"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/event-stream")
+	assertEvents(t, w.Body.String(),
+		"event:token\ndata:{\"token\":\"The filter light \"}\n\n",
+		"event:token\ndata:{\"token\":\"can be reset.\"}\n\n",
+		"event:done\ndata:{\"cached\":false,\"citations\":[],\"images_ids\":[4],\"pair_id\":-1}\n\n",
+	)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRagQueryStreamStoresPair(t *testing.T) {
+	r, mock := newRouteTest(t)
+	useFakeAIAgent(t, &fakeAIAgent{tokens: []string{"Hold the button."}, imagesIDs: []int32{4}})
+
+	expectOwnConversation(mock)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO request_response_pair`).
+		WithArgs("How?", "Hold the button.", "conv-1", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+	mock.ExpectExec(`INSERT INTO request_response_pair_pdf_image`).WithArgs(9, 4).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE conversation SET updated_time`).WithArgs(sqlmock.AnyArg(), "conv-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	w := serve(r, "POST", "/conversation/rag_query/stream", userToken(t, ownerID), `{"query": "How?", "conversation_id": "conv-1"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assertEvents(t, w.Body.String(), "event:token\n", "event:done\n", `"pair_id":9`)
+	assert.NotContains(t, w.Body.String(), `"error"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRagQueryStreamReportsStoreFailure(t *testing.T) {
+	r, mock := newRouteTest(t)
+	useFakeAIAgent(t, &fakeAIAgent{tokens: []string{"Hold the button."}, imagesIDs: []int32{4}})
+
+	expectOwnConversation(mock)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO request_response_pair`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+	// The pair goes with its image links
+	mock.ExpectExec(`INSERT INTO request_response_pair_pdf_image`).WithArgs(9, 4).WillReturnError(errors.New("connection reset"))
+	mock.ExpectRollback()
+
+	w := serve(r, "POST", "/conversation/rag_query/stream", userToken(t, ownerID), `{"query": "How?", "conversation_id": "conv-1"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	// The answer was sent, the done event says it was not kept
+	assertEvents(t, w.Body.String(), "event:token\n", "event:done\n",
+		`"error":"Failed to store the answer in the conversation: connection reset"`, `"pair_id":-1`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRagQueryStreamRelaysAgentError(t *testing.T) {
+	r, mock := newRouteTest(t)
+	useFakeAIAgent(t, &fakeAIAgent{tokens: []string{"Hold "}, err: status.Error(codes.Internal, "model crashed")})
+
+	w := serve(r, "POST", "/conversation/rag_query/stream", "", `{"query": "How?"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assertEvents(t, w.Body.String(), "event:token\ndata:{\"token\":\"Hold \"}\n\n", "event:error\n", "model crashed")
+	assert.NotContains(t, w.Body.String(), "event:done")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRagQueryReportsStoreFailure(t *testing.T) {
+	r, mock := newRouteTest(t)
+	useFakeAIAgent(t, &fakeAIAgent{tokens: []string{"Hold the button."}})
+
+	expectOwnConversation(mock)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO request_response_pair`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+	mock.ExpectExec(`UPDATE conversation SET updated_time`).WillReturnError(errors.New("connection reset"))
+	mock.ExpectRollback()
+
+	w := serve(r, "POST", "/conversation/rag_query", userToken(t, ownerID), `{"query": "How?", "conversation_id": "conv-1"}`)
+	assert.Equal(t, http.StatusInternalServerError, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), "Failed to store the answer in the conversation")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,162 @@
+package _test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/rageval"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAgent answers the questions of the evaluation tests by question.
+type fakeAgent map[string]rageval.Answer
+
+func (f fakeAgent) Ask(c rageval.Case) (rageval.Answer, error) {
+	answer, ok := f[c.Question]
+	if !ok {
+		return rageval.Answer{}, errors.New("agent unavailable")
+	}
+	return answer, nil
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func TestLoadDataset(t *testing.T) {
+	yamlPath := writeFile(t, "eval.yaml", `
+- id: reset
+  device_id: 2
+  question: How do I reset the WM-7200?
+  expected_pages:
+    - filename: manual.pdf
+      page: 9
+  expected_keywords: [power button]
+- device_id: 2
+  question: What does E-04 mean?
+  expected_keywords: [drain]
+`)
+	cases, err := rageval.LoadDataset(yamlPath)
+	require.NoError(t, err)
+	require.Len(t, cases, 2)
+	assert.Equal(t, []rageval.Page{{FileName: "manual.pdf", Number: 9}}, cases[0].ExpectedPages)
+	assert.Equal(t, "case-2", cases[1].ID)
+
+	jsonlPath := writeFile(t, "eval.jsonl", `{"id": "reset", "device_id": 2, "question": "How do I reset it?", "expected_pages": [{"pdf_id": 4, "page": 9}]}`+"\n\n")
+	cases, err = rageval.LoadDataset(jsonlPath)
+	require.NoError(t, err)
+	assert.Equal(t, 4, cases[0].ExpectedPages[0].PDFID)
+
+	_, err = rageval.LoadDataset(writeFile(t, "eval.jsonl", `{"question": "How do I reset it?"}`))
+	assert.ErrorContains(t, err, "expects no page and no keyword")
+	_, err = rageval.LoadDataset(writeFile(t, "eval.csv", "question"))
+	assert.Error(t, err)
+}
+
+func TestRunScoresAnswers(t *testing.T) {
+	cases := []rageval.Case{
+		{ID: "reset", DeviceID: 2, Question: "How do I reset it?",
+			ExpectedPages:    []rageval.Page{{FileName: "manual.pdf", Number: 9}, {Number: 12}},
+			ExpectedKeywords: []string{"power  button", "5 seconds"}},
+		{ID: "e04", DeviceID: 2, Question: "What does E-04 mean?", ExpectedPages: []rageval.Page{{PDFID: 4, Number: 30}}},
+		{ID: "down", DeviceID: 2, Question: "Is the agent up?", ExpectedKeywords: []string{"yes"}},
+	}
+	agent := fakeAgent{
+		"How do I reset it?": {
+			Response: "Hold the Power\nButton for 10 seconds.",
+			// Page 12 is cited, but after k distinct pages
+			Pages: []rageval.Page{
+				{PDFID: 4, FileName: "manual.pdf", Number: 9}, {PDFID: 4, FileName: "manual.pdf", Number: 9},
+				{PDFID: 4, FileName: "manual.pdf", Number: 10}, {PDFID: 4, FileName: "manual.pdf", Number: 12},
+			},
+			Latency: 100 * time.Millisecond,
+		},
+		"What does E-04 mean?": {
+			Response: "The drain is blocked.",
+			Pages:    []rageval.Page{{PDFID: 4, FileName: "manual.pdf", Number: 30}},
+			Latency:  300 * time.Millisecond,
+		},
+	}
+
+	results, scores := rageval.Run(cases, agent, 2)
+	require.Len(t, results, 3)
+	assert.Equal(t, 0.5, *results[0].Recall)
+	assert.Equal(t, []rageval.Page{{Number: 12}}, results[0].MissingPages)
+	assert.Equal(t, 0.5, *results[0].KeywordHitRate)
+	assert.Equal(t, []string{"5 seconds"}, results[0].MissingKeywords)
+	assert.Len(t, results[0].CitedPages, 3)
+	assert.Nil(t, results[1].KeywordHitRate)
+	assert.Equal(t, "agent unavailable", results[2].Error)
+
+	assert.Equal(t, 3, scores.Cases)
+	assert.Equal(t, 1, scores.Errors)
+	assert.Equal(t, 0.75, *scores.RecallAtK)
+	assert.Equal(t, 0.25, *scores.KeywordHitRate)
+	assert.Equal(t, 100.0, scores.LatencyP50Ms)
+	assert.Equal(t, 300.0, scores.LatencyP95Ms)
+}
+
+func TestCompareWithBaseline(t *testing.T) {
+	score := func(v float64) *float64 { return &v }
+	baseline := rageval.Scores{RecallAtK: score(0.8), KeywordHitRate: score(0.7), LatencyP95Ms: 1000}
+	tolerance := rageval.Tolerance{Score: 0.02, Latency: 0.25}
+
+	assert.Empty(t, rageval.Compare(baseline, rageval.Scores{RecallAtK: score(0.79), KeywordHitRate: score(0.9), LatencyP95Ms: 1200}, tolerance))
+	assert.Equal(t, []string{
+		"recall@k dropped from 0.800 to 0.700",
+		"p95 latency rose from 1000 ms to 1300 ms",
+		"errors rose from 0 to 1",
+	}, rageval.Compare(baseline, rageval.Scores{RecallAtK: score(0.7), LatencyP95Ms: 1300, Errors: 1}, tolerance))
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	c := rageval.Case{DeviceID: 2, Question: "What does E-04 mean?", ExpectedKeywords: []string{"drain"}}
+	agent := fakeAgent{c.Question: {
+		Response: "The drain is blocked.",
+		ChunkIDs: []int{11},
+		Pages:    []rageval.Page{{PDFID: 4, FileName: "manual.pdf", Number: 30}},
+		Latency:  250 * time.Millisecond,
+	}}
+
+	var recording bytes.Buffer
+	_, err := rageval.Recorder{Client: agent, W: &recording}.Ask(c)
+	require.NoError(t, err)
+
+	replay, err := rageval.LoadReplay(writeFile(t, "recording.jsonl", recording.String()))
+	require.NoError(t, err)
+	answer, err := replay.Ask(c)
+	require.NoError(t, err)
+	assert.Equal(t, agent[c.Question], answer)
+
+	_, err = replay.Ask(rageval.Case{DeviceID: 3, Question: c.Question})
+	assert.ErrorIs(t, err, rageval.ErrNotRecorded)
+}
+
+func TestWriteMarkdownReport(t *testing.T) {
+	recall := 0.5
+	report := rageval.Report{
+		Dataset:     "eval.yaml",
+		K:           5,
+		Scores:      rageval.Scores{Cases: 1, RecallAtK: &recall},
+		Regressions: []string{"recall@k dropped from 0.800 to 0.500"},
+		Cases: []rageval.CaseResult{{
+			ID:           "reset",
+			DeviceID:     2,
+			MissingPages: []rageval.Page{{FileName: "manual.pdf", Number: 12}},
+		}},
+	}
+
+	var b bytes.Buffer
+	require.NoError(t, rageval.WriteMarkdown(&b, report))
+	assert.Contains(t, b.String(), "| recall@5 | 0.500 |")
+	assert.Contains(t, b.String(), "| keyword hit rate | n/a |")
+	assert.Contains(t, b.String(), "- recall@k dropped from 0.800 to 0.500")
+	assert.Contains(t, b.String(), "| reset | 2 | page 12 of manual.pdf | 0 ms |")
+}
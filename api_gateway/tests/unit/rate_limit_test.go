@@ -0,0 +1,119 @@
+package _test
+
+import (
+	"database/sql"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/internal"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/middlewares"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryRateLimitStore(t *testing.T) {
+	store := middlewares.NewMemoryRateLimitStore()
+	rule := middlewares.RateLimitRule{Burst: 3, Every: time.Hour}
+
+	for i := 0; i < 3; i++ {
+		ok, _, err := store.Take("k", rule)
+		require.NoError(t, err)
+		assert.True(t, ok, "request %d", i)
+	}
+	ok, retryAfter, err := store.Take("k", rule)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.InDelta(t, time.Hour.Seconds(), retryAfter.Seconds(), 5)
+
+	// Other keys have their own bucket
+	ok, _, _ = store.Take("other", rule)
+	assert.True(t, ok)
+}
+
+func TestMemoryRateLimitStoreRefills(t *testing.T) {
+	store := middlewares.NewMemoryRateLimitStore()
+	rule := middlewares.RateLimitRule{Burst: 1, Every: 50 * time.Millisecond}
+
+	ok, _, _ := store.Take("k", rule)
+	assert.True(t, ok)
+	ok, _, _ = store.Take("k", rule)
+	assert.False(t, ok)
+	time.Sleep(60 * time.Millisecond)
+	ok, _, _ = store.Take("k", rule)
+	assert.True(t, ok)
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	rule := middlewares.RateLimitRule{Burst: 2, Every: time.Hour}
+	// The handler still gets the body the limiter read the email from
+	r.POST("/login", middlewares.RateLimit(t.Name(), rule, middlewares.ByJSONField("email")), func(c *gin.Context) {
+		var req struct {
+			Email string `json:"email"`
+		}
+		require.NoError(t, c.ShouldBindJSON(&req))
+		c.String(http.StatusOK, req.Email)
+	})
+
+	for i := 0; i < 2; i++ {
+		w := serve(r, "POST", "/login", "", `{"email": "a@example.com"}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "a@example.com", w.Body.String())
+	}
+	// Emails are compared case-insensitively
+	w := serve(r, "POST", "/login", "", `{"email": "A@Example.com"}`)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+
+	w = serve(r, "POST", "/login", "", `{"email": "b@example.com"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestLockoutPolicy(t *testing.T) {
+	policy := middlewares.LockoutPolicy{Threshold: 5, Base: time.Minute, Max: time.Hour, Window: time.Hour}
+
+	assert.Zero(t, policy.LockFor(4))
+	assert.Equal(t, time.Minute, policy.LockFor(5))
+	assert.Equal(t, 2*time.Minute, policy.LockFor(6))
+	assert.Equal(t, 32*time.Minute, policy.LockFor(10))
+	assert.Equal(t, time.Hour, policy.LockFor(11))
+	assert.Equal(t, time.Hour, policy.LockFor(100))
+}
+
+func TestCheckLockout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	r := gin.New()
+	r.POST("/login", func(c *gin.Context) {
+		if middlewares.CheckLockout(c, db, "login:a@example.com") {
+			c.Status(http.StatusOK)
+		}
+	})
+
+	mock.ExpectQuery(`FROM auth_failure`).WithArgs("login:a@example.com").WillReturnError(sql.ErrNoRows)
+	assert.Equal(t, http.StatusOK, serve(r, "POST", "/login", "", "").Code)
+
+	mock.ExpectQuery(`FROM auth_failure`).WithArgs("login:a@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"seconds"}).AddRow(90.5))
+	w := serve(r, "POST", "/login", "", "")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "91", w.Header().Get("Retry-After"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDigit6Random(t *testing.T) {
+	seen := map[string]bool{}
+	for i := 0; i < 50; i++ {
+		otp := internal.Digit6Random()
+		assert.Regexp(t, `^[0-9]{6}$`, otp)
+		seen[otp] = true
+	}
+	assert.Greater(t, len(seen), 40)
+}
@@ -0,0 +1,165 @@
+package _test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/internal"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var revisionColumns = []string{"id", "content", "source", "author_account_id", "restored_from", "created_at"}
+
+func TestDiffWords(t *testing.T) {
+	oldText := "Hold the power button for 5 seconds."
+	newText := "Hold the reset button for 10 seconds."
+	ops := internal.DiffWords(oldText, newText)
+
+	var before, after strings.Builder
+	for _, op := range ops {
+		if op.Op != internal.DiffInsert {
+			before.WriteString(op.Text)
+		}
+		if op.Op != internal.DiffDelete {
+			after.WriteString(op.Text)
+		}
+	}
+	assert.Equal(t, oldText, before.String())
+	assert.Equal(t, newText, after.String())
+	assert.Equal(t, []internal.DiffOp{
+		{Op: internal.DiffEqual, Text: "Hold the "},
+		{Op: internal.DiffDelete, Text: "power "},
+		{Op: internal.DiffInsert, Text: "reset "},
+		{Op: internal.DiffEqual, Text: "button for "},
+		{Op: internal.DiffDelete, Text: "5 "},
+		{Op: internal.DiffInsert, Text: "10 "},
+		{Op: internal.DiffEqual, Text: "seconds."},
+	}, ops)
+	assert.Equal(t, []internal.DiffOp{}, internal.DiffWords("", ""))
+}
+
+func TestListParagraphRevisions(t *testing.T) {
+	r, mock := newRouteTest(t)
+	expectAccount(mock, 5, models.PermissionPDFEdit)
+
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM pdf_paragraph WHERE id = \$1\)`).WithArgs(12).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(`FROM pdf_paragraph_revision WHERE pdf_paragraph_id = \$1 ORDER BY id DESC`).WithArgs(12).
+		WillReturnRows(sqlmock.NewRows(revisionColumns).
+			AddRow(2, "Hold the reset button.", models.RevisionSourceManual, 5, nil, time.Now()).
+			AddRow(1, "Hold the power button.", models.RevisionSourceOCR, nil, nil, time.Now()))
+
+	w := serve(r, "GET", "/pdf_process/paragraphs/12/revisions", userToken(t, 5), "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"source":"ocr"`)
+	assert.Contains(t, w.Body.String(), `"author_account_id":5`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListRevisionsOfMissingImage(t *testing.T) {
+	r, mock := newRouteTest(t)
+	expectAccount(mock, 5, models.PermissionPDFEdit)
+
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM pdf_image WHERE id = \$1\)`).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+	w := serve(r, "GET", "/pdf_process/images/3/alt_revisions", userToken(t, 5), "")
+	assert.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDiffParagraphRevisions(t *testing.T) {
+	r, mock := newRouteTest(t)
+	expectAccount(mock, 5, models.PermissionPDFEdit)
+
+	mock.ExpectQuery(`FROM pdf_paragraph_revision WHERE id = \$1 AND pdf_paragraph_id = \$2`).WithArgs(1, 12).
+		WillReturnRows(sqlmock.NewRows(revisionColumns).AddRow(1, "Hold the power button.", models.RevisionSourceOCR, nil, nil, time.Now()))
+	mock.ExpectQuery(`FROM pdf_paragraph_revision WHERE id = \$1 AND pdf_paragraph_id = \$2`).WithArgs(2, 12).
+		WillReturnRows(sqlmock.NewRows(revisionColumns).AddRow(2, "Hold the reset button.", models.RevisionSourceManual, 5, nil, time.Now()))
+
+	w := serve(r, "GET", "/pdf_process/paragraphs/12/revisions/diff?from=1&to=2", userToken(t, 5), "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `{"op":"delete","text":"power "},{"op":"insert","text":"reset "}`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRestoreRevisionOfAnotherParagraph(t *testing.T) {
+	r, mock := newRouteTest(t)
+	expectAccount(mock, 5, models.PermissionPDFEdit)
+
+	// Revision 1 belongs to another paragraph: nothing is embedded or restored
+	mock.ExpectQuery(`FROM pdf_paragraph_revision WHERE id = \$1 AND pdf_paragraph_id = \$2`).WithArgs(1, 12).
+		WillReturnRows(sqlmock.NewRows(revisionColumns))
+
+	w := serve(r, "POST", "/pdf_process/paragraphs/12/revisions/1/restore", userToken(t, 5), "")
+	assert.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// expectParagraphReplaced expects paragraph 12 to go from old to content, written by account 5 as
+// revision 8 with its chunk 30, and audited with action and after.
+func expectParagraphReplaced(mock sqlmock.Sqlmock, old, content string, restoredFrom int64, action, after string) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT context FROM pdf_paragraph WHERE id = \$1 FOR UPDATE`).WithArgs(12).
+		WillReturnRows(sqlmock.NewRows([]string{"context"}).AddRow(old))
+	mock.ExpectExec(`UPDATE pdf_paragraph SET context = \$1, last_modified = NOW\(\) WHERE id = \$2`).WithArgs(content, 12).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO pdf_paragraph_revision \(pdf_paragraph_id, content, source, author_account_id, restored_from\)`).
+		WithArgs(12, content, models.RevisionSourceManual, 5, restoredFrom).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(8))
+	mock.ExpectExec(`UPDATE device SET answer_cache_generation`).WithArgs(12).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM pdf_chunk\s+WHERE id IN \(SELECT pdf_chunk_id FROM pdf_chunk_pdf_paragraph WHERE pdf_paragraph_id = \$1\)`).
+		WithArgs(12).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT nextval`).WithArgs("pdf_chunk", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"nextval"}).AddRow(30))
+	chunks := mock.ExpectPrepare(`COPY "pdf_chunk" \("id", "context", "embedding"\)`)
+	chunks.ExpectExec().WithArgs(30, content, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	chunks.ExpectExec().WithoutArgs().WillReturnResult(sqlmock.NewResult(0, 0))
+	relations := mock.ExpectPrepare(`COPY "pdf_chunk_pdf_paragraph" \("pdf_chunk_id", "pdf_paragraph_id"\)`)
+	relations.ExpectExec().WithArgs(30, 12).WillReturnResult(sqlmock.NewResult(0, 1))
+	relations.ExpectExec().WithoutArgs().WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO audit_event`).
+		WithArgs(5, action, "pdf_paragraph", "12", `{"context":"`+old+`"}`, after, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+}
+
+func TestSaveParagraphWritesManualRevision(t *testing.T) {
+	r, mock := newRouteTest(t)
+	mock.MatchExpectationsInOrder(true)
+	content := "Hold the reset button."
+	useFakeAIAgent(t, &fakeAIAgent{chunks: []models.EmbeddedChunk{{Context: content, Vector: []float32{0.1, 0.2}}}})
+
+	expectAccount(mock, 5, models.PermissionPDFEdit)
+	expectParagraphReplaced(mock, "Hold the power button.", content, 0, models.AuditParagraphUpdate,
+		`{"chunks":1,"context":"Hold the reset button.","revision_id":8}`)
+
+	w := serve(r, "POST", "/pdf_process/save_and_embed_paragraph", userToken(t, 5),
+		`{"pdf_paragraph_id": 12, "context": "`+content+`"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRestoreParagraphRevision(t *testing.T) {
+	r, mock := newRouteTest(t)
+	mock.MatchExpectationsInOrder(true)
+	content := "Hold the power button."
+	useFakeAIAgent(t, &fakeAIAgent{chunks: []models.EmbeddedChunk{{Context: content, Vector: []float32{0.1, 0.2}}}})
+
+	expectAccount(mock, 5, models.PermissionPDFEdit)
+	mock.ExpectQuery(`FROM pdf_paragraph_revision WHERE id = \$1 AND pdf_paragraph_id = \$2`).WithArgs(1, 12).
+		WillReturnRows(sqlmock.NewRows(revisionColumns).AddRow(1, content, models.RevisionSourceOCR, nil, nil, time.Now()))
+	// The restored text is chunked and embedded again, and kept as a new revision
+	expectParagraphReplaced(mock, "Hold the reset button.", content, 1, models.AuditParagraphRestore,
+		`{"chunks":1,"context":"Hold the power button.","restored_from":1,"revision_id":8}`)
+
+	w := serve(r, "POST", "/pdf_process/paragraphs/12/revisions/1/restore", userToken(t, 5), "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"restored_from":1`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,269 @@
+package _test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/internal"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/middlewares"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/models"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/routes"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var roleColumns = []string{"id", "label", "permissions", "accounts"}
+
+// expectAdmin expects the authentication of admin accountID, whose role grants the admin only permissions.
+func expectAdmin(mock sqlmock.Sqlmock, accountID int, permissions ...string) {
+	middlewares.InvalidateAllAccountRoles()
+	middlewares.InvalidateRevokedTokens()
+	mock.ExpectQuery(`SELECT jti FROM revoked_access_token`).WillReturnRows(sqlmock.NewRows([]string{"jti"}))
+	mock.ExpectQuery(`SELECT r.label, a.token_version`).WithArgs(accountID).
+		WillReturnRows(sqlmock.NewRows([]string{"label", "token_version", "disabled"}).AddRow("admin", 0, false))
+	rows := sqlmock.NewRows([]string{"id", "name", "description", "admin_only"})
+	for i, p := range permissions {
+		rows.AddRow(i+1, p, "", true)
+	}
+	mock.ExpectQuery(`JOIN role_permission`).WithArgs(accountID).WillReturnRows(rows)
+}
+
+// adminToken returns a token of admin accountID for audience.
+func adminToken(t *testing.T, accountID int, audience string) string {
+	token, err := internal.JWTGenerator(internal.TokenSubject{
+		AccountID: accountID,
+		Role:      "admin",
+		Audience:  audience,
+	}, internal.NewTokenID())
+	require.NoError(t, err)
+	return token
+}
+
+func TestClientAuthorize(t *testing.T) {
+	r, mock := newAuditTest(t)
+	routes.UserRoutes(r, models.DB)
+	expectAccount(mock, 5, models.PermissionConversationChat)
+
+	w := serve(r, "POST", "/auth/client_authorize", userToken(t, 5), "")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClientAuthorizeRefusesAdminToken(t *testing.T) {
+	r, mock := newAuditTest(t)
+	routes.UserRoutes(r, models.DB)
+	// The admin role grants conversation:chat, but the client app is for users
+	expectAdmin(mock, 1, models.PermissionConversationChat)
+
+	w := serve(r, "POST", "/auth/client_authorize", adminToken(t, 1, internal.AudienceAdmin), "")
+	assert.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRequirePermission(t *testing.T) {
+	t.Run("no token", func(t *testing.T) {
+		r, mock := newAuditTest(t)
+
+		w := serve(r, "GET", "/admin/permissions", "", "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code, w.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("missing permission", func(t *testing.T) {
+		r, mock := newAuditTest(t)
+		expectAccount(mock, 1, models.PermissionAdminAccess)
+
+		w := serve(r, "GET", "/admin/permissions", userToken(t, 1), "")
+		assert.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+		assert.Contains(t, w.Body.String(), models.PermissionRoleManage)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("admin only permission with a user token", func(t *testing.T) {
+		r, mock := newAuditTest(t)
+		expectAdmin(mock, 1, models.PermissionAdminAccess, models.PermissionRoleManage)
+
+		w := serve(r, "GET", "/admin/permissions", adminToken(t, 1, internal.AudienceUser), "")
+		assert.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("admin only permission with an admin token", func(t *testing.T) {
+		r, mock := newAuditTest(t)
+		expectAdmin(mock, 1, models.PermissionAdminAccess, models.PermissionRoleManage)
+		mock.ExpectQuery(`FROM permission ORDER BY name`).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "description", "admin_only"}).
+				AddRow(1, models.PermissionAdminAccess, "", true))
+
+		w := serve(r, "GET", "/admin/permissions", adminToken(t, 1, internal.AudienceAdmin), "")
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("outdated role", func(t *testing.T) {
+		r, mock := newAuditTest(t)
+		// The token says admin, the account was made a user since
+		expectAccount(mock, 1, models.PermissionAdminAccess, models.PermissionRoleManage)
+
+		w := serve(r, "GET", "/admin/permissions", adminToken(t, 1, internal.AudienceAdmin), "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code, w.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("revoked token", func(t *testing.T) {
+		r, mock := newAuditTest(t)
+		jti := internal.NewTokenID()
+		token, err := internal.JWTGenerator(internal.TokenSubject{AccountID: 1, Role: "user", Audience: internal.AudienceUser}, jti)
+		require.NoError(t, err)
+		expectAccount(mock, 1, models.PermissionAdminAccess)
+
+		w := serve(r, "GET", "/admin/permissions", token, "")
+		assert.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+		// The denylist is cached, the next request doesn't query it
+		w = serve(r, "GET", "/admin/permissions", token, "")
+		assert.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+		require.NoError(t, mock.ExpectationsWereMet())
+
+		// A logout on this gateway drops the copy
+		middlewares.InvalidateRevokedTokens()
+		mock.ExpectQuery(`SELECT jti FROM revoked_access_token WHERE expires_at > NOW\(\)`).
+			WillReturnRows(sqlmock.NewRows([]string{"jti"}).AddRow(jti))
+		w = serve(r, "GET", "/admin/permissions", token, "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code, w.Body.String())
+		assert.Contains(t, w.Body.String(), "Token has been revoked")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestCreateRole(t *testing.T) {
+	r, mock := newAuditTest(t)
+	expectAdmin(mock, 1, models.PermissionAdminAccess, models.PermissionRoleManage)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO role \(label\)`).WithArgs("support").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectQuery(`FROM unnest\(\$1::text\[\]\)`).WithArgs(pq.Array([]string{models.PermissionFeedbackReview})).
+		WillReturnRows(sqlmock.NewRows([]string{"name"}))
+	mock.ExpectExec(`INSERT INTO role_permission`).WithArgs(3, pq.Array([]string{models.PermissionFeedbackReview})).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO audit_event`).
+		WithArgs(1, models.AuditRoleCreate, "role", "3", nil, `{"label":"support","permissions":["feedback:review"]}`, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery(`FROM role r WHERE r.id = \$1`).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows(roleColumns).AddRow(3, "support", "{feedback:review}", 0))
+
+	w := serve(r, "POST", "/admin/roles", adminToken(t, 1, internal.AudienceAdmin),
+		`{"label": " support ", "permissions": ["feedback:review"]}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"permissions":["feedback:review"]`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateRoleRefusesUnknownPermission(t *testing.T) {
+	r, mock := newAuditTest(t)
+	expectAdmin(mock, 1, models.PermissionAdminAccess, models.PermissionRoleManage)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO role \(label\)`).WithArgs("support").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectQuery(`FROM unnest\(\$1::text\[\]\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("feedback:rewiew"))
+	mock.ExpectRollback()
+
+	w := serve(r, "POST", "/admin/roles", adminToken(t, 1, internal.AudienceAdmin),
+		`{"label": "support", "permissions": ["feedback:rewiew"]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), "feedback:rewiew")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateRoleRefusesUsedLabel(t *testing.T) {
+	r, mock := newAuditTest(t)
+	expectAdmin(mock, 1, models.PermissionAdminAccess, models.PermissionRoleManage)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO role \(label\)`).WithArgs("user").
+		WillReturnError(&pq.Error{Code: "23505", Constraint: "role_label_key"})
+	mock.ExpectRollback()
+
+	w := serve(r, "POST", "/admin/roles", adminToken(t, 1, internal.AudienceAdmin), `{"label": "user"}`)
+	assert.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateRole(t *testing.T) {
+	r, mock := newAuditTest(t)
+	expectAdmin(mock, 1, models.PermissionAdminAccess, models.PermissionRoleManage)
+	mock.ExpectQuery(`FROM role r WHERE r.id = \$1`).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows(roleColumns).AddRow(3, "support", "{feedback:review}", 2))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM role r WHERE r.id = \$1 FOR UPDATE OF r`).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows(roleColumns).AddRow(3, "support", "{feedback:review}", 2))
+	mock.ExpectExec(`UPDATE role SET label`).WithArgs("moderator", 3).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`FROM role r WHERE r.id = \$1 FOR UPDATE OF r`).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows(roleColumns).AddRow(3, "moderator", "{feedback:review}", 2))
+	mock.ExpectExec(`INSERT INTO audit_event`).
+		WithArgs(1, models.AuditRoleUpdate, "role", "3",
+			`{"label":"support","permissions":["feedback:review"]}`, `{"label":"moderator","permissions":["feedback:review"]}`, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery(`FROM role r WHERE r.id = \$1`).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows(roleColumns).AddRow(3, "moderator", "{feedback:review}", 2))
+
+	// Without permissions, the role keeps its own
+	w := serve(r, "PUT", "/admin/roles/3", adminToken(t, 1, internal.AudienceAdmin), `{"label": "moderator"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"label":"moderator"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateRoleProtectsBuiltInRoles(t *testing.T) {
+	for name, update := range map[string]struct {
+		role models.Role
+		path string
+		body string
+	}{
+		"rename":             {role: models.Role{ID: 1, Label: "user"}, path: "/admin/roles/1", body: `{"label": "member"}`},
+		"remove role:manage": {role: models.Role{ID: 2, Label: "admin"}, path: "/admin/roles/2", body: `{"permissions": ["admin:access"]}`},
+	} {
+		t.Run(name, func(t *testing.T) {
+			r, mock := newAuditTest(t)
+			expectAdmin(mock, 1, models.PermissionAdminAccess, models.PermissionRoleManage)
+			mock.ExpectQuery(`FROM role r WHERE r.id = \$1`).WithArgs(update.role.ID).
+				WillReturnRows(sqlmock.NewRows(roleColumns).AddRow(update.role.ID, update.role.Label, "{}", 1))
+
+			w := serve(r, "PUT", update.path, adminToken(t, 1, internal.AudienceAdmin), update.body)
+			assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestDeleteRole(t *testing.T) {
+	r, mock := newAuditTest(t)
+	expectAdmin(mock, 1, models.PermissionAdminAccess, models.PermissionRoleManage)
+	mock.ExpectQuery(`FROM role r WHERE r.id = \$1`).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows(roleColumns).AddRow(3, "support", "{}", 0))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FOR UPDATE OF r`).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows(roleColumns).AddRow(3, "support", "{}", 0))
+	mock.ExpectExec(`DELETE FROM role WHERE id = \$1`).WithArgs(3).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO audit_event`).
+		WithArgs(1, models.AuditRoleDelete, "role", "3", `{"label":"support","permissions":[]}`, nil, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	w := serve(r, "DELETE", "/admin/roles/3", adminToken(t, 1, internal.AudienceAdmin), "")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteRoleInUse(t *testing.T) {
+	r, mock := newAuditTest(t)
+	expectAdmin(mock, 1, models.PermissionAdminAccess, models.PermissionRoleManage)
+	mock.ExpectQuery(`FROM role r WHERE r.id = \$1`).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows(roleColumns).AddRow(3, "support", "{}", 2))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FOR UPDATE OF r`).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows(roleColumns).AddRow(3, "support", "{}", 2))
+	mock.ExpectRollback()
+
+	w := serve(r, "DELETE", "/admin/roles/3", adminToken(t, 1, internal.AudienceAdmin), "")
+	assert.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,84 @@
+package _test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/models"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/retrieval"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/routes"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeEmbedder []float32
+
+func (e fakeEmbedder) Embed(string) ([]float32, error) { return e, nil }
+
+// newSearchTest serves the search routes on a mocked database, authenticated as account 7 allowed to chat.
+func newSearchTest(t *testing.T) (*gin.Engine, sqlmock.Sqlmock) {
+	t.Setenv("JWT_KEY", "test-key")
+	gin.SetMode(gin.TestMode)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	previous := models.DB
+	models.DB = db
+	t.Cleanup(func() { models.DB = previous })
+
+	r := gin.New()
+	routes.SearchRoutes(r, db, fakeEmbedder{0.1, 0.2})
+	expectAccount(mock, 7, models.PermissionConversationChat)
+	return r, mock
+}
+
+func TestFuse(t *testing.T) {
+	vector := []int{1, 2, 3}
+	text := []int{3, 4}
+	fused := retrieval.Fuse(4, vector, text)
+
+	require.Len(t, fused, 4)
+	// Found by both searches, 3 comes first
+	assert.Equal(t, 3, fused[0].ChunkID)
+	assert.Equal(t, []int{3, 1}, fused[0].Ranks)
+	assert.InDelta(t, 1.0/63+1.0/61, fused[0].Score, 1e-12)
+	// 1 is first of the vector search only
+	assert.Equal(t, 1, fused[1].ChunkID)
+	assert.Equal(t, []int{1, 0}, fused[1].Ranks)
+	// 2 and 4 tie on score (1/62) and on their best rank (2): the lowest id wins
+	assert.Equal(t, 2, fused[2].ChunkID)
+	assert.Equal(t, []int{2, 0}, fused[2].Ranks)
+	assert.Equal(t, 4, fused[3].ChunkID)
+	assert.Equal(t, []int{0, 2}, fused[3].Ranks)
+	assert.Equal(t, fused[2].Score, fused[3].Score)
+	assert.InDelta(t, 1.0/62, fused[2].Score, 1e-12)
+}
+
+func TestSearchChunks(t *testing.T) {
+	r, mock := newSearchTest(t)
+
+	mock.ExpectQuery(`ORDER BY c.embedding <=> \$1, c.id\s+LIMIT \$3`).WithArgs(sqlmock.AnyArg(), 4, 50).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "distance"}).AddRow(10, 0.1).AddRow(11, 0.2))
+	mock.ExpectQuery(`websearch_to_tsquery\('simple', \$1\)`).WithArgs("error E-04", 4, 50).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "rank"}).AddRow(12, 0.5).AddRow(11, 0.3))
+	mock.ExpectQuery(`WHERE c.id = ANY\(\$1\)`).WithArgs(`{11,10}`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "context", "source", "source_id", "pdf_id", "filename", "device_id", "page_id", "page_number"}).
+			AddRow(10, "Reset the machine.", "paragraph", 100, 5, "manual.pdf", 4, 50, 3).
+			AddRow(11, "Error E-04: drain blocked.", "paragraph", 101, 5, "manual.pdf", 4, 51, 7))
+
+	w := serve(r, "GET", "/search/chunks?q=error+E-04&device_id=4&limit=2", userToken(t, 7), "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Regexp(t, `"chunk_id":11,.*"page_number":7,.*"vector_rank":2,"text_rank":2.*"chunk_id":10`, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSearchChunksRequiresQuery(t *testing.T) {
+	r, mock := newSearchTest(t)
+
+	w := serve(r, "GET", "/search/chunks?q=+", userToken(t, 7), "")
+	assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
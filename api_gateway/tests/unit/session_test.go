@@ -0,0 +1,173 @@
+package _test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/internal"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/models"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/routes"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var refreshTokenColumns = []string{"id", "account_id", "family_id", "audience", "expired", "revoked_at", "replaced"}
+
+// newSessionTest serves the auth routes on a mocked database.
+func newSessionTest(t *testing.T) (*gin.Engine, sqlmock.Sqlmock) {
+	r, mock := newAuditTest(t)
+	routes.UserRoutes(r, models.DB)
+	return r, mock
+}
+
+// sessionToken returns an access token of user accountID with the jti.
+func sessionToken(t *testing.T, accountID int, jti string) string {
+	token, err := internal.JWTGenerator(internal.TokenSubject{AccountID: accountID, Role: "user", Audience: internal.AudienceUser}, jti)
+	require.NoError(t, err)
+	return token
+}
+
+// expectFamilyRevoked expects the refresh tokens of the family and their access tokens to be revoked.
+func expectFamilyRevoked(mock sqlmock.Sqlmock, familyID string) {
+	mock.ExpectExec(`INSERT INTO revoked_access_token \(jti, account_id, expires_at\)\s+SELECT access_jti, account_id, access_expires_at\s+FROM refresh_token\s+WHERE family_id = \$1`).
+		WithArgs(familyID).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(`UPDATE refresh_token SET revoked_at = NOW\(\) WHERE family_id = \$1 AND revoked_at IS NULL`).
+		WithArgs(familyID).WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func TestRefreshRotatesToken(t *testing.T) {
+	r, mock := newSessionTest(t)
+
+	var newHash, newJTI string
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM refresh_token rt\s+WHERE token_hash = \$1\s+FOR UPDATE`).WithArgs(internal.HashRefreshToken("old-refresh")).
+		WillReturnRows(sqlmock.NewRows(refreshTokenColumns).AddRow(3, 7, "family-1", internal.AudienceUser, false, nil, false))
+	mock.ExpectExec(`UPDATE refresh_token SET revoked_at = NOW\(\) WHERE id = \$1`).WithArgs(3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// The new token joins the family of the old one
+	mock.ExpectExec(`INSERT INTO refresh_token`).
+		WithArgs(7, captureArg{&newHash}, "family-1", internal.AudienceUser, captureArg{&newJTI}, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery(`SELECT r.label, a.token_version`).WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"label", "token_version", "disabled"}).AddRow("user", 2, false))
+
+	w := serve(r, "POST", "/auth/refresh", "", `{"refresh_token": "old-refresh"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var body struct {
+		Data struct {
+			Token        string `json:"token"`
+			RefreshToken string `json:"refresh_token"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.NotEqual(t, "old-refresh", body.Data.RefreshToken)
+	assert.Equal(t, internal.HashRefreshToken(body.Data.RefreshToken), newHash)
+	claims, err := internal.JWTParse(body.Data.Token)
+	require.NoError(t, err)
+	assert.Equal(t, 7, claims.UserID)
+	assert.Equal(t, 2, claims.TokenVersion)
+	assert.Equal(t, newJTI, claims.ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+	r, mock := newSessionTest(t)
+	jti := internal.NewTokenID()
+	token := sessionToken(t, 7, jti)
+	expectAccount(mock, 7)
+	mock.ExpectQuery(`SELECT display_name FROM account`).WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"display_name"}).AddRow("Jane"))
+	w := serve(r, "GET", "/auth/display_name", token, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// old-refresh was already rotated: someone else holds a copy of it
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM refresh_token rt\s+WHERE token_hash = \$1`).WithArgs(internal.HashRefreshToken("old-refresh")).
+		WillReturnRows(sqlmock.NewRows(refreshTokenColumns).AddRow(3, 7, "family-1", internal.AudienceUser, false, time.Now(), true))
+	expectFamilyRevoked(mock, "family-1")
+	mock.ExpectCommit()
+
+	w = serve(r, "POST", "/auth/refresh", "", `{"refresh_token": "old-refresh"}`)
+	assert.Equal(t, http.StatusUnauthorized, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), models.ErrRefreshTokenReused.Error())
+
+	// The access tokens of the family are refused at once
+	mock.ExpectQuery(`SELECT jti FROM revoked_access_token`).WillReturnRows(sqlmock.NewRows([]string{"jti"}).AddRow(jti))
+	w = serve(r, "GET", "/auth/display_name", token, "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), "Token has been revoked")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRefreshRefusesLoggedOutToken(t *testing.T) {
+	r, mock := newSessionTest(t)
+
+	// Revoked by a logout, not replaced: the family is already revoked
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM refresh_token rt\s+WHERE token_hash = \$1`).WithArgs(internal.HashRefreshToken("old-refresh")).
+		WillReturnRows(sqlmock.NewRows(refreshTokenColumns).AddRow(3, 7, "family-1", internal.AudienceUser, false, time.Now(), false))
+	mock.ExpectRollback()
+
+	w := serve(r, "POST", "/auth/refresh", "", `{"refresh_token": "old-refresh"}`)
+	assert.Equal(t, http.StatusUnauthorized, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), models.ErrRefreshTokenInvalid.Error())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLogout(t *testing.T) {
+	r, mock := newSessionTest(t)
+	jti := internal.NewTokenID()
+	token := sessionToken(t, 7, jti)
+
+	expectAccount(mock, 7)
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO revoked_access_token \(jti, account_id, expires_at\)\s+VALUES \(\$1, \$2, \$3\)`).
+		WithArgs(jti, 7, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT family_id FROM refresh_token WHERE access_jti = \$1 AND account_id = \$2`).WithArgs(jti, 7).
+		WillReturnRows(sqlmock.NewRows([]string{"family_id"}).AddRow("family-1"))
+	expectFamilyRevoked(mock, "family-1")
+	mock.ExpectCommit()
+
+	w := serve(r, "POST", "/auth/logout", token, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// The logged out access token is refused, though it has not expired
+	mock.ExpectQuery(`SELECT jti FROM revoked_access_token`).WillReturnRows(sqlmock.NewRows([]string{"jti"}).AddRow(jti))
+	w = serve(r, "POST", "/auth/logout", token, "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), "Token has been revoked")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLogoutAll(t *testing.T) {
+	r, mock := newSessionTest(t)
+	token := sessionToken(t, 7, internal.NewTokenID())
+
+	expectAccount(mock, 7)
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO revoked_access_token \(jti, account_id, expires_at\)\s+SELECT access_jti, account_id, access_expires_at\s+FROM refresh_token\s+WHERE account_id = \$1`).
+		WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec(`UPDATE refresh_token SET revoked_at = NOW\(\) WHERE account_id = \$1 AND revoked_at IS NULL`).WithArgs(7).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec(`UPDATE account SET token_version = token_version \+ 1 WHERE id = \$1`).WithArgs(7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	w := serve(r, "POST", "/auth/logout_all", token, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// Every access token of the account is outdated, even those without a refresh token
+	mock.ExpectQuery(`SELECT r.label, a.token_version`).WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"label", "token_version", "disabled"}).AddRow("user", 1, false))
+	mock.ExpectQuery(`JOIN role_permission`).WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "description", "admin_only"}))
+	w = serve(r, "POST", "/auth/logout", token, "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), "Token is outdated")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
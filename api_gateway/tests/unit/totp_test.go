@@ -0,0 +1,180 @@
+package _test
+
+import (
+	"database/sql"
+	"encoding/base32"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/internal"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/middlewares"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/routes"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rfc6238Secret is the SHA-1 key of the RFC 6238 test vectors.
+var rfc6238Secret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	// The 8-digit codes of RFC 6238 appendix B, truncated to 6 digits
+	vectors := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+	for unix, want := range vectors {
+		code, err := internal.TOTPCode(rfc6238Secret, internal.TOTPStep(time.Unix(unix, 0)))
+		require.NoError(t, err)
+		assert.Equal(t, want, code, "at %d", unix)
+	}
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	step := internal.TOTPStep(now)
+	code, err := internal.TOTPCode(rfc6238Secret, step)
+	require.NoError(t, err)
+
+	matched, ok := internal.VerifyTOTP(rfc6238Secret, code, now, 0)
+	assert.True(t, ok)
+	assert.Equal(t, step, matched)
+
+	// One step of clock drift is allowed, two are not
+	_, ok = internal.VerifyTOTP(rfc6238Secret, code, now.Add(internal.TOTPPeriod), 0)
+	assert.True(t, ok)
+	_, ok = internal.VerifyTOTP(rfc6238Secret, code, now.Add(2*internal.TOTPPeriod), 0)
+	assert.False(t, ok)
+
+	// A code of an already used step is refused
+	_, ok = internal.VerifyTOTP(rfc6238Secret, code, now, step)
+	assert.False(t, ok)
+
+	_, ok = internal.VerifyTOTP(rfc6238Secret, "000000", now, 0)
+	assert.False(t, ok)
+	_, ok = internal.VerifyTOTP(rfc6238Secret, "", now, 0)
+	assert.False(t, ok)
+}
+
+func TestTOTPProvisioningURI(t *testing.T) {
+	secret := internal.NewTOTPSecret()
+	uri, err := url.Parse(internal.TOTPProvisioningURI(secret, "admin@example.com", "TechBot"))
+	require.NoError(t, err)
+
+	assert.Equal(t, "otpauth", uri.Scheme)
+	assert.Equal(t, "totp", uri.Host)
+	assert.Equal(t, "/TechBot:admin@example.com", uri.Path)
+	assert.Equal(t, secret, uri.Query().Get("secret"))
+	assert.Equal(t, "TechBot", uri.Query().Get("issuer"))
+	assert.Equal(t, "6", uri.Query().Get("digits"))
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes := internal.NewRecoveryCodes(internal.RecoveryCodeCount)
+	require.Len(t, codes, internal.RecoveryCodeCount)
+	assert.Regexp(t, `^[0-9a-f]{5}-[0-9a-f]{5}$`, codes[0])
+	assert.NotEqual(t, codes[0], codes[1])
+
+	// Codes are checked without the dash and case-insensitively
+	assert.Equal(t, internal.HashRecoveryCode("abcde-12345"), internal.HashRecoveryCode(" ABCDE12345 "))
+	assert.NotEqual(t, internal.HashRecoveryCode("abcde-12345"), internal.HashRecoveryCode("abcde-12346"))
+}
+
+func TestMFAPendingTokenIsNotAnAccessToken(t *testing.T) {
+	t.Setenv("JWT_KEY", "test-key")
+
+	pending, err := internal.MFAPendingTokenGenerator(42, internal.NewTokenID())
+	require.NoError(t, err)
+	claims, err := internal.MFAPendingTokenParse(pending)
+	require.NoError(t, err)
+	assert.Equal(t, 42, claims.UserID)
+	_, err = internal.JWTParse(pending)
+	assert.Error(t, err)
+
+	access, err := internal.JWTGenerator(internal.TokenSubject{AccountID: 42, Role: "admin", Audience: internal.AudienceAdmin}, internal.NewTokenID())
+	require.NoError(t, err)
+	_, err = internal.MFAPendingTokenParse(access)
+	assert.Error(t, err)
+}
+
+// newAdminMFATest serves the auth routes on a mocked database, with admin 5 enrolled with rfc6238Secret.
+func newAdminMFATest(t *testing.T) (*gin.Engine, sqlmock.Sqlmock, string) {
+	t.Setenv("JWT_KEY", "test-key")
+	gin.SetMode(gin.TestMode)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	r := gin.New()
+	routes.UserRoutes(r, db)
+
+	pending, err := internal.MFAPendingTokenGenerator(5, internal.NewTokenID())
+	require.NoError(t, err)
+
+	middlewares.InvalidateRevokedTokens()
+	mock.ExpectQuery(`SELECT jti FROM revoked_access_token`).WillReturnRows(sqlmock.NewRows([]string{"jti"}))
+	mock.ExpectQuery(`FROM auth_failure`).WithArgs("mfa:5").WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(`FROM admin_totp`).WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"secret", "confirmed_at", "last_used_step"}).
+			AddRow(rfc6238Secret, time.Now(), 0))
+	return r, mock, pending
+}
+
+func TestAdminMFALogin(t *testing.T) {
+	r, mock, pending := newAdminMFATest(t)
+
+	code, err := internal.TOTPCode(rfc6238Secret, internal.TOTPStep(time.Now()))
+	require.NoError(t, err)
+
+	mock.ExpectExec(`UPDATE admin_totp SET last_used_step`).WithArgs(5, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM auth_failure`).WithArgs("mfa:5").WillReturnResult(sqlmock.NewResult(0, 0))
+	// The pending token is revoked
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO revoked_access_token`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT family_id FROM refresh_token`).WillReturnError(sql.ErrNoRows)
+	mock.ExpectCommit()
+	// and an admin session starts
+	mock.ExpectExec(`INSERT INTO refresh_token`).
+		WithArgs(5, sqlmock.AnyArg(), sqlmock.AnyArg(), internal.AudienceAdmin, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT r.label, a.token_version`).WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"label", "token_version", "disabled"}).AddRow("admin", 0, false))
+
+	w := serve(r, "POST", "/auth/admin_login/mfa", pending, `{"code": "`+code+`"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"refresh_token"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAdminMFALoginRefusesReplayedCode(t *testing.T) {
+	r, mock, pending := newAdminMFATest(t)
+
+	code, err := internal.TOTPCode(rfc6238Secret, internal.TOTPStep(time.Now()))
+	require.NoError(t, err)
+
+	// Another request used the step in the meantime
+	mock.ExpectExec(`UPDATE admin_totp SET last_used_step`).WithArgs(5, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`INSERT INTO auth_failure`).WithArgs("mfa:5", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"failures"}).AddRow(1))
+
+	w := serve(r, "POST", "/auth/admin_login/mfa", pending, `{"code": "`+code+`"}`)
+	assert.Equal(t, http.StatusUnauthorized, w.Code, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAdminMFALoginRefusesAccessTokens(t *testing.T) {
+	r, _, _ := newAdminMFATest(t)
+
+	w := serve(r, "POST", "/auth/admin_login/mfa", userToken(t, 5), `{"code": "123456"}`)
+	assert.Equal(t, http.StatusUnauthorized, w.Code, w.Body.String())
+}
@@ -0,0 +1,383 @@
+package _test
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/internal"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/models"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/workers"
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var uploadColumns = []string{"id", "gcs_bucket", "upload_status", "size_bytes", "content_type", "checksum"}
+
+// useLocalStorage makes the gateway store its objects in a local store in dir for the test.
+func useLocalStorage(t *testing.T, dir string) *internal.LocalBlobStore {
+	store, err := internal.NewLocalBlobStore(dir, "http://gateway.test", []byte("secret"))
+	require.NoError(t, err)
+	previous := internal.Storage
+	internal.Storage = store
+	t.Cleanup(func() { internal.Storage = previous })
+	return store
+}
+
+// expectPendingPDF expects the read of pending pdf 3, uploaded to object.
+func expectPendingPDF(mock sqlmock.Sqlmock, object string) {
+	mock.ExpectQuery(`FROM pdf\s+WHERE id = \$1`).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows(uploadColumns).AddRow(3, object, models.UploadStatusPending, nil, nil, nil))
+}
+
+func TestConfirmPDFUpload(t *testing.T) {
+	r, mock := newRouteTest(t)
+	store := useLocalStorage(t, t.TempDir())
+	content := "%PDF-1.4 manual"
+	require.NoError(t, store.Put(context.Background(), "manual.pdf", "application/pdf", strings.NewReader(content)))
+	sum := md5.Sum([]byte(content))
+	checksum := hex.EncodeToString(sum[:])
+
+	expectAccount(mock, 5, models.PermissionPDFUpload)
+	expectPendingPDF(mock, "manual.pdf")
+	mock.ExpectExec(`UPDATE pdf\s+SET upload_status = 'uploaded'`).
+		WithArgs(int64(len(content)), "application/pdf", checksum, 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	w := serve(r, "POST", "/pdf_process/pdfs/3/confirm", userToken(t, 5), `{"checksum": "`+strings.ToUpper(checksum)+`"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"upload_status":"uploaded"`)
+	assert.Contains(t, w.Body.String(), `"checksum":"`+checksum+`"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestConfirmPDFUploadBeforeTheUpload(t *testing.T) {
+	r, mock := newRouteTest(t)
+	useLocalStorage(t, t.TempDir())
+
+	expectAccount(mock, 5, models.PermissionPDFUpload)
+	expectPendingPDF(mock, "manual.pdf")
+
+	// The row stays pending: the client can confirm again after its PUT
+	w := serve(r, "POST", "/pdf_process/pdfs/3/confirm", userToken(t, 5), "")
+	assert.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestConfirmPDFUploadRejectsWrongFile(t *testing.T) {
+	for name, upload := range map[string]struct{ contentType, checksum string }{
+		"content type": {contentType: "image/png"},
+		"checksum":     {contentType: "application/pdf", checksum: strings.Repeat("0", 32)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			r, mock := newRouteTest(t)
+			store := useLocalStorage(t, t.TempDir())
+			require.NoError(t, store.Put(context.Background(), "manual.pdf", upload.contentType, strings.NewReader("%PDF-1.4")))
+
+			expectAccount(mock, 5, models.PermissionPDFUpload)
+			expectPendingPDF(mock, "manual.pdf")
+			mock.ExpectExec(`UPDATE pdf SET upload_status = 'failed'`).WithArgs(3).WillReturnResult(sqlmock.NewResult(0, 1))
+
+			w := serve(r, "POST", "/pdf_process/pdfs/3/confirm", userToken(t, 5), `{"checksum": "`+upload.checksum+`"}`)
+			assert.Equal(t, http.StatusUnprocessableEntity, w.Code, w.Body.String())
+			// The rejected object is deleted
+			_, err := store.Stat(context.Background(), "manual.pdf")
+			assert.ErrorIs(t, err, internal.ErrBlobNotFound)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestConfirmRejectedPDFUpload(t *testing.T) {
+	r, mock := newRouteTest(t)
+	useLocalStorage(t, t.TempDir())
+
+	expectAccount(mock, 5, models.PermissionPDFUpload)
+	mock.ExpectQuery(`FROM pdf\s+WHERE id = \$1`).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows(uploadColumns).AddRow(3, "manual.pdf", models.UploadStatusFailed, nil, nil, nil))
+
+	w := serve(r, "POST", "/pdf_process/pdfs/3/confirm", userToken(t, 5), "")
+	assert.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUploadReaper(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	dir := t.TempDir()
+	store := useLocalStorage(t, dir)
+	ctx := context.Background()
+
+	old := time.Now().Add(-2 * time.Hour)
+	for _, object := range []string{"uploads/stale.pdf", "uploads/kept.pdf", "uploads/stray.png", "uploads/fresh.png", "agent.png"} {
+		require.NoError(t, store.Put(ctx, object, "application/pdf", strings.NewReader("%PDF-1.4")))
+		if object != "uploads/fresh.png" {
+			path := filepath.Join(dir, "objects", url.PathEscape(object))
+			require.NoError(t, os.Chtimes(path, old, old))
+		}
+	}
+
+	// The pending row of stale.pdf expired
+	mock.ExpectQuery(`DELETE FROM pdf\s+WHERE id IN`).WithArgs(sqlmock.AnyArg(), 100).
+		WillReturnRows(sqlmock.NewRows([]string{"gcs_bucket"}).AddRow("uploads/stale.pdf"))
+	mock.ExpectQuery(`DELETE FROM pdf_image\s+WHERE id IN`).WithArgs(sqlmock.AnyArg(), 100).
+		WillReturnRows(sqlmock.NewRows([]string{"gcs_bucket"}))
+	// kept.pdf has a row, stray.png has none, fresh.png may be waiting for its row and agent.png
+	// is not an upload
+	mock.ExpectQuery(`SELECT name FROM unnest\(\$1::text\[\]\) AS name`).
+		WithArgs(pq.StringArray{"uploads/kept.pdf", "uploads/stray.png"}).
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("uploads/stray.png"))
+
+	workers.ReapUploads(ctx, db, time.Hour, true)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	var remaining []string
+	require.NoError(t, store.List(ctx, "", func(objectName string, attrs internal.BlobAttrs) error {
+		remaining = append(remaining, objectName)
+		return nil
+	}))
+	assert.ElementsMatch(t, []string{"agent.png", "uploads/fresh.png", "uploads/kept.pdf"}, remaining)
+}
+
+func TestUploadReaperSweepsOnlyWhenEnabled(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	dir := t.TempDir()
+	store := useLocalStorage(t, dir)
+	ctx := context.Background()
+
+	require.NoError(t, store.Put(ctx, "uploads/stray.png", "image/png", strings.NewReader("png")))
+	old := time.Now().Add(-2 * time.Hour)
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "objects", url.PathEscape("uploads/stray.png")), old, old))
+
+	// Only the pending rows are reaped, no object is looked up
+	mock.ExpectQuery(`DELETE FROM pdf\s+WHERE id IN`).WillReturnRows(sqlmock.NewRows([]string{"gcs_bucket"}))
+	mock.ExpectQuery(`DELETE FROM pdf_image\s+WHERE id IN`).WillReturnRows(sqlmock.NewRows([]string{"gcs_bucket"}))
+
+	workers.ReapUploads(ctx, db, time.Hour, false)
+	require.NoError(t, mock.ExpectationsWereMet())
+	_, err = store.Stat(ctx, "uploads/stray.png")
+	assert.NoError(t, err)
+}
+
+// fakeBlobStore keeps the objects in memory; its Puts fail with putErr if it is set.
+type fakeBlobStore struct {
+	objects map[string][]byte
+	putErr  error
+}
+
+// useFakeStorage makes the gateway store its objects in a fakeBlobStore for the test.
+func useFakeStorage(t *testing.T) *fakeBlobStore {
+	store := &fakeBlobStore{objects: map[string][]byte{}}
+	previous := internal.Storage
+	internal.Storage = store
+	t.Cleanup(func() { internal.Storage = previous })
+	return store
+}
+
+func (s *fakeBlobStore) SignedReadURL(objectName string, expires time.Duration) (string, error) {
+	return "https://storage.test/" + objectName, nil
+}
+
+func (s *fakeBlobStore) SignedWriteURL(objectName, contentType string, expires time.Duration) (string, error) {
+	return "https://storage.test/" + objectName, nil
+}
+
+func (s *fakeBlobStore) Put(ctx context.Context, objectName, contentType string, r io.Reader) error {
+	if s.putErr != nil {
+		return s.putErr
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	s.objects[objectName] = data
+	return nil
+}
+
+func (s *fakeBlobStore) Get(ctx context.Context, objectName string) (io.ReadCloser, error) {
+	data, ok := s.objects[objectName]
+	if !ok {
+		return nil, internal.ErrBlobNotFound
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (s *fakeBlobStore) Delete(ctx context.Context, objectName string) error {
+	if _, ok := s.objects[objectName]; !ok {
+		return internal.ErrBlobNotFound
+	}
+	delete(s.objects, objectName)
+	return nil
+}
+
+func (s *fakeBlobStore) Stat(ctx context.Context, objectName string) (internal.BlobAttrs, error) {
+	data, ok := s.objects[objectName]
+	if !ok {
+		return internal.BlobAttrs{}, internal.ErrBlobNotFound
+	}
+	return internal.BlobAttrs{Size: int64(len(data))}, nil
+}
+
+func (s *fakeBlobStore) List(ctx context.Context, prefix string, fn func(objectName string, attrs internal.BlobAttrs) error) error {
+	for objectName, data := range s.objects {
+		if !strings.HasPrefix(objectName, prefix) {
+			continue
+		}
+		if err := fn(objectName, internal.BlobAttrs{Size: int64(len(data))}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// storedArg matches the name of an object that is already in the store.
+type storedArg struct{ store *fakeBlobStore }
+
+func (a storedArg) Match(v driver.Value) bool {
+	objectName, ok := v.(string)
+	_, stored := a.store.objects[objectName]
+	return ok && stored
+}
+
+// uploadPDF posts a PDF for device 2 to /pdf_process/pdfs as account 5.
+func uploadPDF(t *testing.T, r *gin.Engine, content []byte) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	require.NoError(t, form.WriteField("device_id", "2"))
+	file, err := form.CreateFormFile("file", "manual.pdf")
+	require.NoError(t, err)
+	_, err = file.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, form.Close())
+
+	req := httptest.NewRequest("POST", "/pdf_process/pdfs", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+userToken(t, 5))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// expectUploadDevice expects the authentication of account 5 and the read of device 2.
+func expectUploadDevice(mock sqlmock.Sqlmock) {
+	expectAccount(mock, 5, models.PermissionPDFUpload)
+	mock.ExpectQuery(`SELECT id, label, brand_id, device_type_id FROM device WHERE id = \$1`).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "label", "brand_id", "device_type_id"}).AddRow(2, "WM-7200", 1, 1))
+}
+
+func TestUploadPDF(t *testing.T) {
+	r, mock := newAuditTest(t)
+	store := useFakeStorage(t)
+	content := minimalPDF(2)
+	sum := sha256.Sum256(content)
+	digest := hex.EncodeToString(sum[:])
+
+	expectUploadDevice(mock)
+	mock.ExpectQuery(`SELECT id FROM pdf WHERE device_id = \$1 AND sha256 = \$2`).WithArgs(2, digest).
+		WillReturnError(sql.ErrNoRows)
+	// The row is inserted once its object is stored
+	mock.ExpectQuery(`INSERT INTO pdf`).
+		WithArgs(storedArg{store}, 2, false, "manual", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), digest, int64(len(content))).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(8))
+
+	w := uploadPDF(t, r, content)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"pdf_id":8`)
+	assert.Contains(t, w.Body.String(), `"number_of_pages":2`)
+	require.Len(t, store.objects, 1)
+	for objectName, data := range store.objects {
+		assert.True(t, strings.HasPrefix(objectName, internal.UploadPrefix), objectName)
+		assert.Equal(t, content, data)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUploadPDFRejectsOtherFiles(t *testing.T) {
+	r, mock := newAuditTest(t)
+	store := useFakeStorage(t)
+
+	expectAccount(mock, 5, models.PermissionPDFUpload)
+	w := uploadPDF(t, r, []byte("PK\x03\x04 a zip named manual.pdf"))
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code, w.Body.String())
+	assert.Empty(t, store.objects)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUploadPDFRejectsLargeFiles(t *testing.T) {
+	t.Setenv("PDF_MAX_UPLOAD_MB", "1")
+	r, mock := newAuditTest(t)
+	store := useFakeStorage(t)
+
+	expectAccount(mock, 5, models.PermissionPDFUpload)
+	w := uploadPDF(t, r, append([]byte("%PDF-1.4\n"), make([]byte, 1<<20)...))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code, w.Body.String())
+	assert.Empty(t, store.objects)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUploadPDFRejectsDuplicate(t *testing.T) {
+	r, mock := newAuditTest(t)
+	store := useFakeStorage(t)
+
+	expectUploadDevice(mock)
+	mock.ExpectQuery(`SELECT id FROM pdf WHERE device_id = \$1 AND sha256 = \$2`).WithArgs(2, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+
+	w := uploadPDF(t, r, minimalPDF(1))
+	assert.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"pdf_id":4`)
+	assert.Empty(t, store.objects)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUploadPDFRejectsConcurrentDuplicate(t *testing.T) {
+	r, mock := newAuditTest(t)
+	store := useFakeStorage(t)
+
+	// The same PDF is inserted by another request between the check and the insert
+	expectUploadDevice(mock)
+	mock.ExpectQuery(`SELECT id FROM pdf WHERE device_id = \$1 AND sha256 = \$2`).WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(`INSERT INTO pdf`).WillReturnError(&pq.Error{Code: "23505"})
+
+	w := uploadPDF(t, r, minimalPDF(1))
+	assert.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+	// Its object is not left behind
+	assert.Empty(t, store.objects)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUploadPDFStoreFailure(t *testing.T) {
+	r, mock := newAuditTest(t)
+	store := useFakeStorage(t)
+	store.putErr = errors.New("bucket unavailable")
+
+	// No row is inserted for a PDF that was not stored
+	expectUploadDevice(mock)
+	mock.ExpectQuery(`SELECT id FROM pdf WHERE device_id = \$1 AND sha256 = \$2`).WillReturnError(sql.ErrNoRows)
+
+	w := uploadPDF(t, r, minimalPDF(1))
+	assert.Equal(t, http.StatusInternalServerError, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), "Failed to store PDF")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
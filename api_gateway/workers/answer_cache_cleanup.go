@@ -0,0 +1,27 @@
+package workers
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/models"
+)
+
+// StartAnswerCacheCleanup starts a goroutine that removes the expired cached answers every interval.
+func StartAnswerCacheCleanup(db *sql.DB, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			n, err := models.DeleteExpiredCachedAnswers(db)
+			if err != nil {
+				log.Printf("Answer cache cleanup: %v", err)
+				continue
+			}
+			if n > 0 {
+				log.Printf("Answer cache cleanup: removed %d expired answer(s)", n)
+			}
+		}
+	}()
+}
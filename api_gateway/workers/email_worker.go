@@ -0,0 +1,109 @@
+package workers
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/internal"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/models"
+)
+
+const (
+	// How often the worker looks for due emails (it is also woken up on enqueue).
+	emailPollInterval = 10 * time.Second
+	// Emails claimed at once.
+	emailBatchSize = 20
+	// How long the mailer gets to send one email.
+	emailSendTimeout = 30 * time.Second
+	// How long a claimed email stays locked to the worker: longer than sending the whole batch,
+	// so another gateway doesn't claim and send it again meanwhile.
+	emailLease = emailBatchSize*emailSendTimeout + time.Minute
+	// Emails that failed this many times are given up.
+	maxEmailAttempts = 6
+	// First retry delay, doubled after each failure.
+	emailRetryDelay = 30 * time.Second
+	// Sent and failed emails are kept this long.
+	emailRetention = 7 * 24 * time.Hour
+)
+
+var emailWakeup = make(chan struct{}, 1)
+
+// QueueEmail renders an email of a kind in lang and puts it in the outbox; the email worker sends it.
+func QueueEmail(db *sql.DB, kind, lang, to string, data any) error {
+	email, err := internal.RenderEmail(kind, lang, to, data)
+	if err != nil {
+		return err
+	}
+	_, err = models.InsertOutboxEmail(db, models.OutboxEmail{
+		Kind:      kind,
+		Recipient: email.To,
+		Subject:   email.Subject,
+		TextBody:  email.Text,
+		HTMLBody:  email.HTML,
+	})
+	if err != nil {
+		return err
+	}
+	select {
+	case emailWakeup <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// StartEmailWorker starts a goroutine sending the emails of the outbox with mailer.
+func StartEmailWorker(db *sql.DB, mailer internal.Mailer) {
+	go func() {
+		ticker := time.NewTicker(emailPollInterval)
+		defer ticker.Stop()
+		lastCleanup := time.Now()
+
+		for {
+			for SendOutboxEmails(context.Background(), db, mailer) == emailBatchSize {
+			}
+			if time.Since(lastCleanup) > time.Hour {
+				if _, err := models.DeleteOldOutboxEmails(db, emailRetention); err != nil {
+					log.Printf("Email worker: cleanup: %v", err)
+				}
+				lastCleanup = time.Now()
+			}
+
+			select {
+			case <-emailWakeup:
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// SendOutboxEmails sends a batch of due emails and returns how many were claimed.
+// A failed email is retried with exponential backoff, up to maxEmailAttempts.
+func SendOutboxEmails(ctx context.Context, db *sql.DB, mailer internal.Mailer) int {
+	emails, err := models.ClaimOutboxEmails(db, emailLease, emailBatchSize)
+	if err != nil {
+		log.Printf("Email worker: failed to claim emails: %v", err)
+		return 0
+	}
+
+	for _, e := range emails {
+		sendCtx, cancel := context.WithTimeout(ctx, emailSendTimeout)
+		err := mailer.Send(sendCtx, internal.Email{To: e.Recipient, Subject: e.Subject, Text: e.TextBody, HTML: e.HTMLBody})
+		cancel()
+		switch {
+		case err == nil:
+			err = models.MarkOutboxEmailSent(db, e.ID)
+		case e.Attempts >= maxEmailAttempts:
+			log.Printf("Email worker: giving up email %d (%s) after %d attempts: %v", e.ID, e.Kind, e.Attempts, err)
+			err = models.FailOutboxEmail(db, e.ID, err.Error())
+		default:
+			log.Printf("Email worker: email %d (%s) failed, retrying: %v", e.ID, e.Kind, err)
+			err = models.RetryOutboxEmail(db, e.ID, err.Error(), emailRetryDelay<<(e.Attempts-1))
+		}
+		if err != nil {
+			log.Printf("Email worker: failed to update email %d: %v", e.ID, err)
+		}
+	}
+	return len(emails)
+}
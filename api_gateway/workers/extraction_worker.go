@@ -0,0 +1,145 @@
+package workers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/models"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/pb"
+)
+
+const (
+	// How long a claimed job stays locked to a worker without a heartbeat.
+	extractionJobLease = 2 * time.Minute
+	// How often a busy worker renews its lease.
+	extractionJobHeartbeat = 30 * time.Second
+	// How often idle workers look for new jobs (they are also woken up on enqueue).
+	extractionPollInterval = 10 * time.Second
+	// Jobs that have been claimed this many times are failed instead of retried.
+	maxExtractionAttempts = 3
+)
+
+// extractionWakeup lets the enqueue handler wake an idle worker right away.
+var extractionWakeup = make(chan struct{}, 1)
+
+// NotifyExtractionJob wakes up an idle extraction worker, if any.
+func NotifyExtractionJob() {
+	select {
+	case extractionWakeup <- struct{}{}:
+	default:
+	}
+}
+
+// StartExtractionWorkers starts n goroutines that run queued extraction jobs.
+// Jobs left running by a gateway that stopped are picked up again once their lease expires.
+func StartExtractionWorkers(db *sql.DB, n int) {
+	if n < 1 {
+		n = 1
+	}
+	for i := 0; i < n; i++ {
+		go extractionWorker(db, i+1)
+	}
+	log.Printf("Started %d extraction worker(s)", n)
+}
+
+func extractionWorker(db *sql.DB, workerID int) {
+	ticker := time.NewTicker(extractionPollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Drain the queue before going back to sleep.
+		for {
+			job, err := models.ClaimExtractionJob(db, extractionJobLease)
+			if err == sql.ErrNoRows {
+				break
+			}
+			if err != nil {
+				log.Printf("Extraction worker %d: failed to claim job: %v", workerID, err)
+				break
+			}
+			if !runExtractionJob(db, workerID, job) {
+				// The job went back to the queue, wait for the next tick instead of spinning.
+				break
+			}
+		}
+
+		select {
+		case <-extractionWakeup:
+		case <-ticker.C:
+		}
+	}
+}
+
+// runExtractionJob runs one claimed job. It returns false if the job was put back in the queue,
+// so the worker waits for the next tick before trying again.
+func runExtractionJob(db *sql.DB, workerID int, job models.ExtractionJob) bool {
+	log.Printf("Extraction worker %d: running job %d (pdf %d, attempt %d)", workerID, job.ID, job.PDFID, job.Attempts)
+
+	// Keep the lease alive while the gRPC call is in flight.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(extractionJobHeartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := models.ExtendExtractionJobLease(db, job.ID, extractionJobLease); err != nil {
+					log.Printf("Extraction worker %d: failed to extend lease of job %d: %v", workerID, job.ID, err)
+				}
+			}
+		}
+	}()
+
+	err := extractPDF(db, job.PDFID)
+	switch {
+	case err == nil:
+		if err := models.FinishExtractionJob(db, job.ID, models.JobStatusSucceeded, ""); err != nil {
+			log.Printf("Extraction worker %d: failed to finish job %d: %v", workerID, job.ID, err)
+		}
+		log.Printf("Extraction worker %d: job %d succeeded", workerID, job.ID)
+	case errors.Is(err, pb.ErrAgentBusy):
+		if err := models.ReleaseExtractionJob(db, job.ID); err != nil {
+			log.Printf("Extraction worker %d: failed to requeue job %d: %v", workerID, job.ID, err)
+		}
+		return false
+	case job.Attempts < maxExtractionAttempts:
+		log.Printf("Extraction worker %d: job %d failed, will retry: %v", workerID, job.ID, err)
+		if err := models.RequeueExtractionJob(db, job.ID, err.Error()); err != nil {
+			log.Printf("Extraction worker %d: failed to requeue job %d: %v", workerID, job.ID, err)
+		}
+		return false
+	default:
+		log.Printf("Extraction worker %d: job %d failed: %v", workerID, job.ID, err)
+		if err := models.FinishExtractionJob(db, job.ID, models.JobStatusFailed, err.Error()); err != nil {
+			log.Printf("Extraction worker %d: failed to finish job %d: %v", workerID, job.ID, err)
+		}
+	}
+	return true
+}
+
+// extractPDF calls the extract service for the PDF and stores its pages, paragraphs and images.
+func extractPDF(db *sql.DB, pdfID int) error {
+	pdf, err := models.SelectPDFByID(pdfID)
+	if err != nil {
+		return fmt.Errorf("PDF not found: %w", err)
+	}
+
+	resultJson, err := pb.CallExtractPDF(pdf.GCSBucket)
+	if err != nil {
+		return err
+	}
+
+	var extractResult models.ExtractResult
+	if err := json.Unmarshal([]byte(resultJson), &extractResult); err != nil {
+		return fmt.Errorf("failed to parse result JSON: %w", err)
+	}
+
+	return models.InsertExtractionResult(db, pdf.ID, extractResult)
+}
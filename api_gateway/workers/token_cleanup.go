@@ -0,0 +1,27 @@
+package workers
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/models"
+)
+
+// StartTokenCleanup starts a goroutine that removes expired refresh tokens and revoked access tokens every interval.
+func StartTokenCleanup(db *sql.DB, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			n, err := models.DeleteExpiredTokens(db)
+			if err != nil {
+				log.Printf("Token cleanup: %v", err)
+				continue
+			}
+			if n > 0 {
+				log.Printf("Token cleanup: removed %d expired token(s)", n)
+			}
+		}
+	}()
+}
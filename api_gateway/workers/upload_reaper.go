@@ -0,0 +1,109 @@
+package workers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/internal"
+	"github.com/ductruonghoc/DATN_08_2025_Back-end/models"
+)
+
+// How many rows one reaper pass deletes per table.
+const uploadReaperBatch = 100
+
+// StartUploadReaper starts a goroutine that, every interval, deletes the pdf and pdf_image rows
+// whose signed URL upload was not confirmed within ttl, together with their objects, and, if
+// sweepStray is set, the upload objects older than ttl that no row refers to.
+func StartUploadReaper(db *sql.DB, ttl, interval time.Duration, sweepStray bool) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			ReapUploads(context.Background(), db, ttl, sweepStray)
+			<-ticker.C
+		}
+	}()
+	log.Printf("Started upload reaper (ttl %s, every %s, sweep stray objects %t)", ttl, interval, sweepStray)
+}
+
+// ReapUploads runs one pass of the upload reaper.
+func ReapUploads(ctx context.Context, db *sql.DB, ttl time.Duration, sweepStray bool) {
+	reapStaleUploads(ctx, db, ttl)
+	if sweepStray {
+		sweepStrayObjects(ctx, db, ttl)
+	}
+}
+
+func reapStaleUploads(ctx context.Context, db *sql.DB, ttl time.Duration) {
+	for _, table := range []string{models.UploadTablePDF, models.UploadTableImage} {
+		for {
+			objectNames, err := models.DeleteStaleUploads(db, table, ttl, uploadReaperBatch)
+			if err != nil {
+				log.Printf("Upload reaper: failed to delete stale %s rows: %v", table, err)
+				break
+			}
+			for _, objectName := range objectNames {
+				// The client may never have uploaded anything.
+				err := internal.Storage.Delete(ctx, objectName)
+				if err != nil && !errors.Is(err, internal.ErrBlobNotFound) {
+					log.Printf("Upload reaper: failed to delete object %s: %v", objectName, err)
+				}
+			}
+			if len(objectNames) > 0 {
+				log.Printf("Upload reaper: removed %d stale %s upload(s)", len(objectNames), table)
+			}
+			if len(objectNames) < uploadReaperBatch {
+				break
+			}
+		}
+	}
+}
+
+// sweepStrayObjects deletes the objects under internal.UploadPrefix older than ttl that no pdf or
+// pdf_image row refers to: uploads whose row was deleted without them, or whose row was never
+// inserted. Younger objects may be waiting for their row (an upload before its insert). The rest of
+// the bucket belongs to the OCR agents and is left alone.
+func sweepStrayObjects(ctx context.Context, db *sql.DB, ttl time.Duration) {
+	cutoff := time.Now().Add(-ttl)
+	var batch []string
+	removed := 0
+	sweep := func() error {
+		stray, err := models.SelectUnreferencedObjects(db, batch)
+		if err != nil {
+			return err
+		}
+		for _, objectName := range stray {
+			err := internal.Storage.Delete(ctx, objectName)
+			if err != nil && !errors.Is(err, internal.ErrBlobNotFound) {
+				log.Printf("Upload reaper: failed to delete stray object %s: %v", objectName, err)
+				continue
+			}
+			removed++
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	err := internal.Storage.List(ctx, internal.UploadPrefix, func(objectName string, attrs internal.BlobAttrs) error {
+		if attrs.Updated.After(cutoff) {
+			return nil
+		}
+		batch = append(batch, objectName)
+		if len(batch) < uploadReaperBatch {
+			return nil
+		}
+		return sweep()
+	})
+	if err == nil && len(batch) > 0 {
+		err = sweep()
+	}
+	if err != nil {
+		log.Printf("Upload reaper: failed to sweep stray objects: %v", err)
+	}
+	if removed > 0 {
+		log.Printf("Upload reaper: removed %d stray object(s)", removed)
+	}
+}